@@ -1,28 +1,184 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"log"
+	"time"
 
 	"grpc-go-fx/internal/api"
+	"grpc-go-fx/internal/audit"
 	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/deadline"
+	"grpc-go-fx/internal/fixtures"
 	"grpc-go-fx/internal/gateway"
+	"grpc-go-fx/internal/idempotency"
+	"grpc-go-fx/internal/logging"
+	"grpc-go-fx/internal/outbox"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/repository"
+	"grpc-go-fx/internal/similarity"
 
 	"go.uber.org/fx"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
 )
 
 func main() {
 	addr := flag.String("addr", ":50051", "gRPC API listen address")
 	httpAddr := flag.String("http-addr", ":8080", "HTTP/JSON gateway listen address (grpc-gateway)")
+	opRetention := flag.Duration("operation-retention", 24*time.Hour, "how long finished long-running operations stay queryable")
+	idempotencyWindow := flag.Duration("idempotency-window", 24*time.Hour, "how long responses to requests with an Idempotency-Key are replayed")
+	idempotencyMaxEntries := flag.Int("idempotency-max-entries", idempotency.DefaultMaxEntries, "how many responses to requests with an Idempotency-Key are kept, evicting the least recently used")
+	auditLog := flag.String("audit-log", "audit.jsonl", "file that product audit events are appended to (empty keeps them in memory)")
+	auditMaxEvents := flag.Int("audit-memory-events", audit.DefaultMaxEvents, "how many of the most recent audit events are kept in memory; older ones are read back from -audit-log")
+	trustActorHeader := flag.Bool("trust-actor-header", false, "audit gateway requests with the actor in their X-Authenticated-User header; only set behind an authenticating proxy that strips the header from client requests")
+	storage := flag.String("storage", "memory", "product repository: memory, sharded to partition memory over -shards locks, versioned for lock-free reads of immutable versions, file to persist the catalog in -data-dir, events to keep a log of product events in -data-dir, or sql to store it in -db-dsn")
+	shards := flag.Int("shards", repository.DefaultShards, "number of separately locked shards of the sharded storage")
+	versionPinTTL := flag.Duration("version-pin-ttl", repository.DefaultPinTTL, "how long the versioned storage keeps the catalog version a page token refers to")
+	dataDir := flag.String("data-dir", "data", "directory of the file store's write-ahead log and snapshot, and of the event store's log")
+	fsyncPolicy := repository.SyncAlways
+	flag.Func("fsync", "when the file store fsyncs its log: always, interval or never (default always)", func(s string) error {
+		p, err := repository.ParseSyncPolicy(s)
+		fsyncPolicy = p
+		return err
+	})
+	fsyncInterval := flag.Duration("fsync-interval", repository.DefaultSyncInterval, "how often the log is synced with -fsync=interval")
+	snapshotThreshold := flag.Int("snapshot-threshold", repository.DefaultSnapshotThreshold, "log records after which the file store snapshots the catalog and truncates its log (negative: only on shutdown)")
+	dbDriver := flag.String("db-driver", "sqlite", "database/sql driver of the sql storage (sqlite is built in)")
+	dbDSN := flag.String("db-dsn", "", "data source name of the sql storage, e.g. file:data/products.db?_pragma=busy_timeout(5000)")
+	migrateOnStart := flag.Bool("migrate-on-start", true, "apply pending schema migrations when the sql storage is opened")
+	dbMaxOpenConns := flag.Int("db-max-open-conns", 0, "maximum open database connections (0: unlimited)")
+	dbMaxIdleConns := flag.Int("db-max-idle-conns", 0, "idle database connections kept (0: database/sql default, negative: none)")
+	dbConnMaxLifetime := flag.Duration("db-conn-max-lifetime", 0, "close database connections after this long (0: never)")
+	dbConnMaxIdleTime := flag.Duration("db-conn-max-idle-time", 0, "close database connections idle this long (0: never)")
+	cacheSize := flag.Int("cache-size", 0, "products cached in front of the storage, evicting the least recently used (0: no cache)")
+	cacheTTL := flag.Duration("cache-ttl", repository.DefaultCacheTTL, "how long a cached product is served")
+	cacheNegativeTTL := flag.Duration("cache-negative-ttl", repository.DefaultCacheNegativeTTL, "how long a missing product ID is cached (negative: not at all)")
+	backupDir := flag.String("backup-dir", "backups", "directory of the backups written and restored by AdminService")
+	replicationRole := replication.RoleStandalone
+	flag.Func("replication-role", "standalone (default), leader to stream writes to followers, or follower of -leader-addr", func(s string) error {
+		r, err := replication.ParseRole(s)
+		replicationRole = r
+		return err
+	})
+	leaderAddr := flag.String("leader-addr", "", "gRPC address of the leader a follower replicates, e.g. leader:50051")
+	replicationLogSize := flag.Int("replication-log-size", replication.DefaultLogSize, "recent writes a leader keeps for followers; followers further behind load a snapshot")
+	forwardWrites := flag.Bool("forward-writes", false, "make a follower forward writes to its leader instead of rejecting them")
+	replicationToken := flag.String("replication-token", "", "secret shared by a leader and its followers; the leader serves only followers sending it and audits the writes they forward with the callers' actors")
+	outboxPublisher := flag.String("outbox", "", "publish product change events through a transactional outbox to stdout, file (-outbox-file) or webhook (-outbox-webhook) as CloudEvents JSON (empty: no outbox)")
+	outboxPath := flag.String("outbox-log", "", "log of the outbox's undelivered events (empty keeps them in memory)")
+	outboxFile := flag.String("outbox-file", "events.jsonl", "JSON lines file the file publisher appends events to")
+	outboxWebhook := flag.String("outbox-webhook", "", "URL the webhook publisher POSTs events to")
+	outboxDeadLetters := flag.String("outbox-dead-letters", "", "JSON lines file of events that could not be delivered (empty: standard error)")
+	outboxSource := flag.String("outbox-source", outbox.DefaultSource, "CloudEvents source of the published events")
+	outboxMaxAttempts := flag.Int("outbox-max-attempts", outbox.DefaultMaxAttempts, "deliveries of an event tried before it is dead-lettered")
+	outboxMinBackoff := flag.Duration("outbox-min-backoff", outbox.DefaultMinBackoff, "delay before an event's first redelivery, doubled for each later one")
+	outboxMaxBackoff := flag.Duration("outbox-max-backoff", outbox.DefaultMaxBackoff, "longest delay between deliveries of an event")
+	seedPath := flag.String("seed", "", "JSON, YAML or CSV fixture file to seed the catalog from (default: the built-in sample catalog)")
+	seedMode := fixtures.IfEmpty
+	flag.Func("seed-mode", "when to seed: if-empty (default), upsert on every start, or skip", func(s string) error {
+		m, err := fixtures.ParseMode(s)
+		seedMode = m
+		return err
+	})
+	incrementalStats := flag.Bool("incremental-stats", false, "maintain catalog statistics on every write instead of scanning on each GetCatalogStats call")
+	similarityWeights := similarity.DefaultWeights
+	flag.Func("similarity-weights", "GetSimilarProducts weights as name=value pairs, e.g. text=0.5,labels=0.3,price=0.2", func(s string) error {
+		w, err := similarity.ParseWeights(s)
+		similarityWeights = w
+		return err
+	})
+	rpcTimeout := flag.Duration("rpc-timeout", 30*time.Second, "deadline given to unary calls that arrive without one (0: none)")
+	var rpcMethodTimeouts map[string]time.Duration
+	flag.Func("rpc-method-timeouts", "deadlines of calls without one by full method or service name, e.g. /product.v1.ProductService/ListProducts=2s,product.v1.AdminService=5m (0: none); they also apply to streaming methods", func(s string) error {
+		m, err := deadline.ParseMethods(s)
+		rpcMethodTimeouts = m
+		return err
+	})
+	logFormat := flag.String("log-format", "json", "log encoding: json, one object per line, or console for people")
+	logLevel := zapcore.InfoLevel
+	flag.Func("log-level", "lowest level logged: debug, info (default), warn or error", func(s string) error {
+		l, err := zapcore.ParseLevel(s)
+		logLevel = l
+		return err
+	})
+	accessLog := flag.Bool("access-log", true, "log every gRPC call and HTTP request with its method, status, latency, peer and request ID")
+	accessLogSampleInitial := flag.Int("access-log-sample-initial", 0, "access log entries of each level logged per second before sampling starts (0: log every call)")
+	accessLogSampleThereafter := flag.Int("access-log-sample-thereafter", 100, "once sampling starts, log every Nth access log entry of the level in the second (0: none)")
 	flag.Parse()
 
 	cfg := &config.Config{
-		ServerAddr:      *addr,
-		HTTPGatewayAddr: *httpAddr,
+		ServerAddr:                *addr,
+		HTTPGatewayAddr:           *httpAddr,
+		Storage:                   *storage,
+		Shards:                    *shards,
+		VersionPinTTL:             *versionPinTTL,
+		DataDir:                   *dataDir,
+		FsyncPolicy:               fsyncPolicy,
+		FsyncInterval:             *fsyncInterval,
+		SnapshotThreshold:         *snapshotThreshold,
+		DatabaseDriver:            *dbDriver,
+		DatabaseDSN:               *dbDSN,
+		MigrateOnStart:            *migrateOnStart,
+		DatabaseMaxOpenConns:      *dbMaxOpenConns,
+		DatabaseMaxIdleConns:      *dbMaxIdleConns,
+		DatabaseConnMaxLifetime:   *dbConnMaxLifetime,
+		DatabaseConnMaxIdleTime:   *dbConnMaxIdleTime,
+		CacheSize:                 *cacheSize,
+		CacheTTL:                  *cacheTTL,
+		CacheNegativeTTL:          *cacheNegativeTTL,
+		BackupDir:                 *backupDir,
+		ReplicationRole:           replicationRole,
+		LeaderAddr:                *leaderAddr,
+		ReplicationLogSize:        *replicationLogSize,
+		ForwardWrites:             *forwardWrites,
+		ReplicationToken:          *replicationToken,
+		OutboxPublisher:           *outboxPublisher,
+		OutboxPath:                *outboxPath,
+		OutboxFile:                *outboxFile,
+		OutboxWebhookURL:          *outboxWebhook,
+		OutboxDeadLetterPath:      *outboxDeadLetters,
+		OutboxSource:              *outboxSource,
+		OutboxMaxAttempts:         *outboxMaxAttempts,
+		OutboxMinBackoff:          *outboxMinBackoff,
+		OutboxMaxBackoff:          *outboxMaxBackoff,
+		SeedPath:                  *seedPath,
+		SeedMode:                  seedMode,
+		IncrementalStats:          *incrementalStats,
+		OperationRetention:        *opRetention,
+		IdempotencyWindow:         *idempotencyWindow,
+		IdempotencyMaxEntries:     *idempotencyMaxEntries,
+		RPCTimeout:                *rpcTimeout,
+		RPCMethodTimeouts:         rpcMethodTimeouts,
+		LogFormat:                 *logFormat,
+		LogLevel:                  logLevel,
+		AccessLog:                 *accessLog,
+		AccessLogSampleInitial:    *accessLogSampleInitial,
+		AccessLogSampleThereafter: *accessLogSampleThereafter,
+		AuditLogPath:              *auditLog,
+		AuditMaxEvents:            *auditMaxEvents,
+		TrustActorHeader:          *trustActorHeader,
+		SimilarityWeights:         similarityWeights,
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+	case "migrate":
+		if err := migrate(cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("unknown command %q (want migrate, or none to serve)", cmd)
 	}
 
 	app := fx.New(
 		fx.Supply(cfg),
+		fx.WithLogger(logging.NewFxLogger),
+		logging.Module,
 		api.Module,
 		gateway.Module,
 		fx.Invoke(func(*grpc.Server) {}), // ensure API server is built and lifecycle runs
@@ -30,3 +186,26 @@ func main() {
 	app.Run()
 }
 
+// migrate applies pending schema migrations to the sql storage and exits.
+func migrate(cfg *config.Config) error {
+	db, err := api.OpenDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	s, err := api.NewSQLRepository(db, cfg)
+	if err != nil {
+		return err
+	}
+	applied, err := s.Migrate(context.Background())
+	for _, m := range applied {
+		fmt.Printf("applied %s\n", m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("schema is up to date")
+	}
+	return nil
+}
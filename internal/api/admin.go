@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/backup"
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxRestoreChanges bounds the changes listed in a RestoreBackupResponse.
+const maxRestoreChanges = 1000
+
+// AdminService implements product.AdminServiceServer on top of a
+// ProductService, whose catalog lock makes backups consistent and restores
+// atomic.
+type AdminService struct {
+	product.UnimplementedAdminServiceServer
+	products *ProductService
+	backups  *backup.Store
+}
+
+// NewAdminService creates an AdminService that backs up products to backups.
+func NewAdminService(products *ProductService, backups *backup.Store) *AdminService {
+	return &AdminService{products: products, backups: backups}
+}
+
+// NewBackupStore creates the store of backups in cfg.BackupDir.
+func NewBackupStore(cfg *config.Config) *backup.Store {
+	return backup.NewStore(cfg.BackupDir)
+}
+
+// CreateBackup copies the catalog while no write is in progress, so the copy
+// includes exactly the writes that completed before it, and writes it to the
+// backup store.
+func (s *AdminService) CreateBackup(ctx context.Context, req *product.CreateBackupRequest) (*product.Backup, error) {
+	products, err := s.products.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := s.backups.Create(products, req.GetDescription())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "writing backup: %v", err)
+	}
+	return backupToProto(meta), nil
+}
+
+// RestoreBackup verifies a backup and replaces the catalog with it, or for a
+// dry run reports the changes that would make. A backup that fails
+// verification is DataLoss.
+func (s *AdminService) RestoreBackup(ctx context.Context, req *product.RestoreBackupRequest) (*product.RestoreBackupResponse, error) {
+	meta, products, err := s.backups.Open(req.GetId())
+	switch {
+	case errors.Is(err, backup.ErrNotFound):
+		return nil, status.Errorf(codes.NotFound, "backup %q not found", req.GetId())
+	case errors.Is(err, backup.ErrCorrupt):
+		return nil, status.Errorf(codes.DataLoss, "backup %q: %v", req.GetId(), err)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "reading backup %q: %v", req.GetId(), err)
+	}
+	resp, err := s.products.restore(ctx, products, req.GetDryRun())
+	if err != nil {
+		return nil, err
+	}
+	resp.Backup = backupToProto(meta)
+	return resp, nil
+}
+
+func backupToProto(meta backup.Metadata) *product.Backup {
+	return &product.Backup{
+		Id:           meta.ID,
+		CreateTime:   timestamppb.New(meta.CreateTime),
+		Description:  meta.Description,
+		ProductCount: int64(meta.Products),
+		SizeBytes:    meta.Size,
+		Sha256:       meta.SHA256,
+	}
+}
+
+// snapshot returns every stored product, in ID order, as of one point in
+// time. Writes wait until it returns.
+func (s *ProductService) snapshot(ctx context.Context) ([]*product.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var products []*product.Product
+	err := s.scan(ctx, "", func(p *product.Product) bool {
+		products = append(products, p)
+		return true
+	})
+	return products, err
+}
+
+// catalogChange replaces old with p; either may be nil.
+type catalogChange struct {
+	id     string
+	old, p *product.Product
+}
+
+// restore makes the stored catalog equal to products in one repository
+// batch while no other write is in progress, and audits every change with
+// the caller's origin. With dryRun it only reports the changes.
+func (s *ProductService) restore(ctx context.Context, products []*product.Product, dryRun bool) (*product.RestoreBackupResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp, changes, err := s.diffCatalog(ctx, products)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if len(resp.Changes) == maxRestoreChanges {
+			resp.ChangesTruncated = true
+			break
+		}
+		kind := product.RestoreChange_KIND_UPDATE
+		switch {
+		case c.old == nil:
+			kind = product.RestoreChange_KIND_CREATE
+		case c.p == nil:
+			kind = product.RestoreChange_KIND_DELETE
+		}
+		resp.Changes = append(resp.Changes, &product.RestoreChange{ProductId: c.id, Kind: kind})
+	}
+	if dryRun {
+		return resp, nil
+	}
+	if err := s.applyChanges(ctx, audit.OriginFromContext(ctx), changes); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// diffCatalog returns the changes, in ID order, that make the stored catalog
+// equal to products, and counts them in a RestoreBackupResponse without
+// Changes. Callers must hold s.mu for writing.
+func (s *ProductService) diffCatalog(ctx context.Context, products []*product.Product) (*product.RestoreBackupResponse, []catalogChange, error) {
+	want := make(map[string]*product.Product, len(products))
+	for _, p := range products {
+		p.Etag = ""
+		want[p.GetId()] = p
+	}
+	resp := &product.RestoreBackupResponse{}
+	var changes []catalogChange
+	err := s.scan(ctx, "", func(old *product.Product) bool {
+		p, kept := want[old.GetId()]
+		delete(want, old.GetId())
+		switch {
+		case !kept:
+			resp.Deleted++
+		case proto.Equal(old, p):
+			resp.Unchanged++
+			return true
+		default:
+			resp.Updated++
+		}
+		changes = append(changes, catalogChange{id: old.GetId(), old: old, p: p})
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for id, p := range want {
+		resp.Created++
+		changes = append(changes, catalogChange{id: id, p: p})
+	}
+	slices.SortFunc(changes, func(a, b catalogChange) int { return strings.Compare(a.id, b.id) })
+	return resp, changes, nil
+}
+
+// applyChanges applies changes in one repository batch, updates the derived
+// state and then audits each change with origin. Callers must hold the
+// locks of the changed products (lockProducts) or s.mu for writing.
+func (s *ProductService) applyChanges(ctx context.Context, origin audit.Origin, changes []catalogChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	err := s.repo.Batch(ctx, func(tx repository.Tx) error {
+		for _, c := range changes {
+			var err error
+			if c.p != nil {
+				err = tx.Put(ctx, c.p)
+			} else {
+				err = tx.Delete(ctx, c.id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return storageError(err)
+	}
+	events := make([]*product.AuditEvent, len(changes))
+	for i, c := range changes {
+		s.index(c.old, c.p)
+		events[i] = s.audit.Prepare(origin, c.old, c.p)
+	}
+	if err := s.audit.Commit(events...); err != nil {
+		return auditError(err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grpc-go-fx/internal/backup"
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAdminService_RestoreUndoesChangesSinceBackup(t *testing.T) {
+	svc := NewProductService()
+	admin := NewAdminService(svc, backup.NewStore(t.TempDir()))
+	ctx := context.Background()
+	before := storedCount(t, svc)
+	p1 := stored(t, svc, "prod-1")
+
+	b, err := admin.CreateBackup(ctx, &product.CreateBackupRequest{Description: "before changes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.GetProductCount() != int64(before) || b.GetSha256() == "" || b.GetDescription() != "before changes" {
+		t.Fatalf("unexpected backup %v", b)
+	}
+
+	changed := proto.Clone(p1).(*product.Product)
+	changed.Price = 999
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: changed}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "prod-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Id: "added", Name: "Added", Price: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	audited := func() int {
+		events, _ := svc.ListAuditEvents(ctx, &product.ListAuditEventsRequest{PageSize: 1000})
+		return len(events.GetEvents())
+	}
+	auditedBefore := audited()
+
+	dry, err := admin.RestoreBackup(ctx, &product.RestoreBackupRequest{Id: b.GetId(), DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &product.RestoreBackupResponse{
+		Backup:    b,
+		Created:   1,
+		Updated:   1,
+		Deleted:   1,
+		Unchanged: int64(before - 2),
+		Changes: []*product.RestoreChange{
+			{ProductId: "added", Kind: product.RestoreChange_KIND_DELETE},
+			{ProductId: "prod-1", Kind: product.RestoreChange_KIND_UPDATE},
+			{ProductId: "prod-2", Kind: product.RestoreChange_KIND_CREATE},
+		},
+	}
+	if !proto.Equal(dry, want) {
+		t.Fatalf("dry run reported %v, want %v", dry, want)
+	}
+	if stored(t, svc, "added") == nil || audited() != auditedBefore {
+		t.Fatal("dry run changed the catalog")
+	}
+
+	resp, err := admin.RestoreBackup(ctx, &product.RestoreBackupRequest{Id: b.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(resp, want) {
+		t.Fatalf("restore reported %v, want %v", resp, want)
+	}
+	if stored(t, svc, "added") != nil || stored(t, svc, "prod-2") == nil || !proto.Equal(stored(t, svc, "prod-1"), p1) {
+		t.Fatal("catalog not restored")
+	}
+	if n := audited() - auditedBefore; n != 3 {
+		t.Fatalf("restore recorded %d audit events, want 3", n)
+	}
+	if _, err := svc.GetSimilarProducts(ctx, &product.GetSimilarProductsRequest{Id: "added"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("deleted product still in the similarity index: %v", err)
+	}
+
+	again, err := admin.RestoreBackup(ctx, &product.RestoreBackupRequest{Id: b.GetId()})
+	if err != nil || again.GetUnchanged() != int64(before) || len(again.GetChanges()) != 0 {
+		t.Fatalf("restoring twice: %v, %v", again, err)
+	}
+}
+
+func TestAdminService_RestoreRejectsMissingAndCorruptBackups(t *testing.T) {
+	dir := t.TempDir()
+	svc := NewProductService()
+	admin := NewAdminService(svc, backup.NewStore(dir))
+	ctx := context.Background()
+
+	if _, err := admin.RestoreBackup(ctx, &product.RestoreBackupRequest{Id: "20261019T101500Z-000000"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("got %v, want NotFound", err)
+	}
+
+	b, err := admin.CreateBackup(ctx, &product.CreateBackupRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, b.GetId(), "catalog.pb")
+	data, _ := os.ReadFile(path)
+	data[0] ^= 0xff
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "prod-1"})
+	if _, err := admin.RestoreBackup(ctx, &product.RestoreBackupRequest{Id: b.GetId()}); status.Code(err) != codes.DataLoss {
+		t.Fatalf("got %v, want DataLoss", err)
+	}
+	if stored(t, svc, "prod-1") != nil {
+		t.Fatal("corrupt backup restored")
+	}
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// maxBatchChanges bounds the number of changes in a BatchUpdateProducts request.
+const maxBatchChanges = 1000
+
+// Types of the precondition violations reported by BatchUpdateProducts.
+const (
+	violationNotFound      = "NOT_FOUND"
+	violationAlreadyExists = "ALREADY_EXISTS"
+	violationETagMismatch  = "ETAG_MISMATCH"
+)
+
+// change is a validated ProductChange.
+type change struct {
+	id string
+	// p is the product to store; nil for a delete.
+	p      *product.Product
+	create bool
+	// etag is the etag the stored product must have; empty for none.
+	etag string
+}
+
+// BatchUpdateProducts applies every change in one repository transaction
+// while holding the locks of the changed products, so readers of the
+// repository do not see part of a batch. Invalid changes fail the
+// batch with InvalidArgument and a BadRequest field violation each; unmet
+// existence or etag preconditions fail it with FailedPrecondition and a
+// PreconditionFailure violation per change. The changes are audited together
+// once the transaction commits.
+func (s *ProductService) BatchUpdateProducts(ctx context.Context, req *product.BatchUpdateProductsRequest) (*product.BatchUpdateProductsResponse, error) {
+	changes, err := s.checkChanges(req.GetChanges())
+	if err != nil {
+		return nil, err
+	}
+	origin := audit.OriginFromContext(ctx)
+
+	ids := make([]string, len(changes))
+	for i, c := range changes {
+		ids[i] = c.id
+	}
+	defer s.lockProducts(ids...)()
+	var olds []*product.Product
+	err = s.repo.Batch(ctx, func(tx repository.Tx) error {
+		var err error
+		if olds, err = checkPreconditions(ctx, tx, changes); err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if c.p != nil {
+				err = tx.Put(ctx, c.p)
+			} else {
+				err = tx.Delete(ctx, c.id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+	resp := &product.BatchUpdateProductsResponse{}
+	events := make([]*product.AuditEvent, len(changes))
+	for i, c := range changes {
+		s.index(olds[i], c.p)
+		events[i] = s.audit.Prepare(origin, olds[i], c.p)
+		if c.p != nil {
+			resp.Products = append(resp.Products, withETag(c.p))
+		}
+	}
+	if err := s.audit.Commit(events...); err != nil {
+		return nil, auditError(err)
+	}
+	return resp, nil
+}
+
+// checkChanges validates the changes of a batch like the corresponding single
+// product requests and assigns IDs to creates that have none.
+func (s *ProductService) checkChanges(reqs []*product.ProductChange) ([]change, error) {
+	if len(reqs) > maxBatchChanges {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d changes are allowed, got %d", maxBatchChanges, len(reqs))
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	invalid := func(field, format string, args ...any) {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: field, Description: fmt.Sprintf(format, args...)})
+	}
+	changes := make([]change, len(reqs))
+	changedBy := make(map[string]int, len(reqs))
+	for i, r := range reqs {
+		path := fmt.Sprintf("changes[%d]", i)
+		c := change{etag: r.GetEtag()}
+		switch k := r.GetKind().(type) {
+		case *product.ProductChange_Create:
+			c.p = proto.Clone(k.Create).(*product.Product)
+			c.create = true
+			if c.p.GetId() == "" {
+				c.p.Id = newProductID()
+			}
+			if c.etag != "" {
+				invalid(path+".etag", "cannot be set on a create")
+			}
+		case *product.ProductChange_Update:
+			c.p = proto.Clone(k.Update).(*product.Product)
+			if c.etag == "" {
+				c.etag = c.p.GetEtag()
+			}
+			if c.p.GetId() == "" {
+				invalid(path+".update.id", "must be set")
+				continue
+			}
+		case *product.ProductChange_Delete:
+			c.id = k.Delete
+			if c.id == "" {
+				invalid(path+".delete", "must be set")
+				continue
+			}
+		default:
+			invalid(path, "must set create, update or delete")
+			continue
+		}
+		if c.p != nil {
+			c.p.Etag = ""
+			c.id = c.p.GetId()
+			if err := s.validateProduct(c.p); err != nil {
+				invalid(path, "%s", status.Convert(err).Message())
+			}
+		}
+		if j, dup := changedBy[c.id]; dup {
+			invalid(path, "changes product %q, which changes[%d] already changes", c.id, j)
+		} else {
+			changedBy[c.id] = i
+		}
+		changes[i] = c
+	}
+	if len(violations) > 0 {
+		msgs := make([]string, len(violations))
+		for i, v := range violations {
+			msgs[i] = v.GetField() + ": " + v.GetDescription()
+		}
+		return nil, withDetails(status.New(codes.InvalidArgument, "invalid changes: "+strings.Join(msgs, "; ")),
+			&errdetails.BadRequest{FieldViolations: violations})
+	}
+	return changes, nil
+}
+
+// checkPreconditions returns the stored product each change replaces (nil for
+// creates), or a FailedPrecondition error listing every change whose
+// existence or etag precondition does not hold.
+func checkPreconditions(ctx context.Context, tx repository.Tx, changes []change) ([]*product.Product, error) {
+	var violations []*errdetails.PreconditionFailure_Violation
+	failed := func(i int, kind, format string, args ...any) {
+		violations = append(violations, &errdetails.PreconditionFailure_Violation{
+			Type:        kind,
+			Subject:     fmt.Sprintf("changes[%d]", i),
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+	olds := make([]*product.Product, len(changes))
+	for i, c := range changes {
+		old, err := tx.Get(ctx, c.id)
+		if errors.Is(err, repository.ErrNotFound) {
+			old, err = nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case c.create && old != nil:
+			failed(i, violationAlreadyExists, "product %q already exists", c.id)
+		case !c.create && old == nil:
+			failed(i, violationNotFound, "product %q not found", c.id)
+		case old != nil:
+			if err := checkETag(old, c.etag); err != nil {
+				failed(i, violationETagMismatch, "%s", status.Convert(err).Message())
+			}
+		}
+		olds[i] = old
+	}
+	if len(violations) > 0 {
+		msgs := make([]string, len(violations))
+		for i, v := range violations {
+			msgs[i] = v.GetSubject() + ": " + v.GetDescription()
+		}
+		return nil, withDetails(status.New(codes.FailedPrecondition, "batch not applied: "+strings.Join(msgs, "; ")),
+			&errdetails.PreconditionFailure{Violations: violations})
+	}
+	return olds, nil
+}
+
+// withDetails returns st with detail attached, or st alone if it cannot be.
+func withDetails(st *status.Status, detail protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(detail)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// etagOf returns the etag of p: a hash of the deterministic encoding of every
+// field but the etag. It is opaque to clients and may change when the
+// Product message does.
+func etagOf(p *product.Product) string {
+	if p.GetEtag() != "" {
+		p = proto.Clone(p).(*product.Product)
+		p.Etag = ""
+	}
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(p)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// withETag sets the etag of p and returns it.
+func withETag(p *product.Product) *product.Product {
+	p.Etag = etagOf(p)
+	return p
+}
+
+// checkETag returns FailedPrecondition if want is set and old's etag differs.
+func checkETag(old *product.Product, want string) error {
+	if want != "" && want != etagOf(old) {
+		return status.Errorf(codes.FailedPrecondition, "product %q has changed: etag %q does not match", old.GetId(), want)
+	}
+	return nil
+}
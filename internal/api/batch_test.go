@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func createChange(p *product.Product) *product.ProductChange {
+	return &product.ProductChange{Kind: &product.ProductChange_Create{Create: p}}
+}
+
+func updateChange(p *product.Product) *product.ProductChange {
+	return &product.ProductChange{Kind: &product.ProductChange_Update{Update: p}}
+}
+
+func deleteChange(id, etag string) *product.ProductChange {
+	return &product.ProductChange{Kind: &product.ProductChange_Delete{Delete: id}, Etag: etag}
+}
+
+func TestBatchUpdateProducts_AppliesEveryChange(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	p1, err := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1.Price = 42
+
+	resp, err := svc.BatchUpdateProducts(ctx, &product.BatchUpdateProductsRequest{Changes: []*product.ProductChange{
+		updateChange(p1),
+		createChange(&product.Product{Name: "Lamp", Price: 12}),
+		deleteChange("prod-2", ""),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := resp.GetProducts()
+	if len(got) != 2 || got[0].GetPrice() != 42 || got[1].GetId() == "" || got[0].GetEtag() == "" || got[0].GetEtag() == p1.GetEtag() {
+		t.Fatalf("unexpected response %v", resp)
+	}
+	if stored(t, svc, "prod-1").GetPrice() != 42 || stored(t, svc, got[1].GetId()) == nil || stored(t, svc, "prod-2") != nil {
+		t.Fatal("batch not applied")
+	}
+	if stored(t, svc, "prod-1").GetEtag() != "" {
+		t.Fatal("etag stored")
+	}
+	events, _ := svc.ListAuditEvents(ctx, &product.ListAuditEventsRequest{})
+	if n := len(events.GetEvents()); n != 3 {
+		t.Fatalf("%d audit events, want 3", n)
+	}
+	similar, err := svc.GetSimilarProducts(ctx, &product.GetSimilarProductsRequest{Id: got[1].GetId()})
+	if err != nil {
+		t.Fatalf("created product missing from the similarity index: %v", err)
+	}
+	for _, r := range similar.GetResults() {
+		if r.GetProduct().GetId() == "prod-2" {
+			t.Fatal("deleted product still in the similarity index")
+		}
+	}
+}
+
+func TestBatchUpdateProducts_FailsWholeBatchOnPreconditions(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	p1, _ := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-1"})
+	before := stored(t, svc, "prod-1")
+
+	stale := proto.Clone(p1).(*product.Product)
+	stale.Etag = "0000000000000000"
+	_, err := svc.BatchUpdateProducts(ctx, &product.BatchUpdateProductsRequest{Changes: []*product.ProductChange{
+		updateChange(&product.Product{Id: "prod-3", Name: "Renamed", Price: 1}),
+		updateChange(stale),
+		createChange(&product.Product{Id: "prod-2", Name: "Again", Price: 1}),
+		deleteChange("nope", ""),
+	}})
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("got %v, want FailedPrecondition", err)
+	}
+	var violations []string
+	for _, d := range st.Details() {
+		if pf, ok := d.(*errdetails.PreconditionFailure); ok {
+			for _, v := range pf.GetViolations() {
+				violations = append(violations, v.GetSubject()+" "+v.GetType())
+			}
+		}
+	}
+	if want := "changes[1] ETAG_MISMATCH,changes[2] ALREADY_EXISTS,changes[3] NOT_FOUND"; strings.Join(violations, ",") != want {
+		t.Fatalf("violations %v, want %s", violations, want)
+	}
+	if stored(t, svc, "prod-3").GetName() == "Renamed" || !proto.Equal(stored(t, svc, "prod-1"), before) {
+		t.Fatal("failed batch partially applied")
+	}
+	if events, _ := svc.ListAuditEvents(ctx, &product.ListAuditEventsRequest{}); len(events.GetEvents()) != 0 {
+		t.Fatal("failed batch audited")
+	}
+
+	// The etag of the stored product satisfies the precondition.
+	if _, err := svc.BatchUpdateProducts(ctx, &product.BatchUpdateProductsRequest{Changes: []*product.ProductChange{deleteChange("prod-1", p1.GetEtag())}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchUpdateProducts_RejectsInvalidChanges(t *testing.T) {
+	svc := NewProductService()
+	_, err := svc.BatchUpdateProducts(context.Background(), &product.BatchUpdateProductsRequest{Changes: []*product.ProductChange{
+		updateChange(&product.Product{Id: "prod-1", Name: "Ok", Price: 1}),
+		{},
+		updateChange(&product.Product{Id: "prod-2", Name: "Bad", Price: -1}),
+		deleteChange("prod-1", ""),
+		{Kind: &product.ProductChange_Create{Create: &product.Product{Id: "new", Name: "New"}}, Etag: "x"},
+	}})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument", err)
+	}
+	var fields []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				fields = append(fields, v.GetField())
+			}
+		}
+	}
+	if want := "changes[1],changes[2],changes[3],changes[4].etag"; strings.Join(fields, ",") != want {
+		t.Fatalf("violations on %v, want %s", fields, want)
+	}
+	if stored(t, svc, "prod-1").GetName() == "Ok" {
+		t.Fatal("invalid batch applied")
+	}
+}
+
+func TestUpdateProduct_ChecksETag(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	p, _ := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-1"})
+	p.Price = 5
+	updated, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: proto.Clone(p).(*product.Product)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// p still carries the etag from before the update.
+	p.Price = 6
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: p}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("update with a stale etag: got %v, want FailedPrecondition", err)
+	}
+	updated.Price = 7
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: updated}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchUpdateProducts_ReadersSeeAllOrNothing(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	// Every batch moves the same amount of money between prod-1 and prod-2,
+	// so any consistent view of the catalog has the same total.
+	total := func(ps []*product.Product) float64 {
+		var sum float64
+		for _, p := range ps {
+			if p.GetId() == "prod-1" || p.GetId() == "prod-2" {
+				sum += p.GetPrice()
+			}
+		}
+		return sum
+	}
+	list, _ := svc.ListProducts(ctx, &product.ListProductsRequest{})
+	want := total(list.GetProducts())
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			a, _ := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-1"})
+			b, _ := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-2"})
+			shift := float64(i%2*2-1) * 0.5
+			a.Price += shift
+			b.Price -= shift
+			if _, err := svc.BatchUpdateProducts(ctx, &product.BatchUpdateProductsRequest{Changes: []*product.ProductChange{updateChange(a), updateChange(b)}}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for range 200 {
+		list, err := svc.ListProducts(ctx, &product.ListProductsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := total(list.GetProducts()); math.Abs(got-want) > 1e-6 {
+			close(stop)
+			wg.Wait()
+			t.Fatalf("reader saw a total of %v, want %v", got, want)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
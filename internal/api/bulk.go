@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"math"
+
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/repository"
+	"grpc-go-fx/internal/validate"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Bulk operations take the write lock once per item rather than for the whole
+// batch, so reads keep flowing while they run, and check for cancellation
+// between items. Work done before a cancellation is kept.
+
+// BulkImportProducts starts an operation that creates or upserts products.
+func (s *ProductService) BulkImportProducts(ctx context.Context, req *product.BulkImportProductsRequest) (*longrunningpb.Operation, error) {
+	items := make([]*product.Product, len(req.GetProducts()))
+	for i, p := range req.GetProducts() {
+		items[i] = proto.Clone(p).(*product.Product)
+	}
+	upsert := req.GetUpsert()
+	origin := audit.OriginFromContext(ctx)
+	meta := newBulkMetadata(len(items))
+
+	return s.ops.Start(meta, func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		resp := &product.BulkImportProductsResponse{}
+		for i, p := range items {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := s.importProduct(ctx, origin, p, upsert); err != nil {
+				resp.Failures = append(resp.Failures, &product.BulkItemFailure{Index: int32(i), Id: p.GetId(), Message: status.Convert(err).Message()})
+				meta.FailedItems++
+			} else {
+				resp.ImportedCount++
+			}
+			meta.ProcessedItems++
+			progress(touch(meta))
+		}
+		return resp, nil
+	})
+}
+
+func (s *ProductService) importProduct(ctx context.Context, origin audit.Origin, p *product.Product, upsert bool) error {
+	if p.GetId() == "" {
+		p.Id = newProductID()
+	}
+	// Items are skipped by the validation interceptor, so that one invalid
+	// item fails alone; apply the Product rules here.
+	if err := validate.Message(p); err != nil {
+		return err
+	}
+	if err := s.validateProduct(p); err != nil {
+		return err
+	}
+	defer s.lockProducts(p.GetId())()
+	old, err := s.get(ctx, p.GetId())
+	if err != nil {
+		return err
+	}
+	if old != nil && !upsert {
+		return status.Errorf(codes.AlreadyExists, "product %q already exists", p.GetId())
+	}
+	return s.put(ctx, origin, old, p)
+}
+
+// BulkUpdatePrices starts an operation that reprices every product matching the filter.
+func (s *ProductService) BulkUpdatePrices(ctx context.Context, req *product.BulkUpdatePricesRequest) (*longrunningpb.Operation, error) {
+	f, err := s.parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	var reprice func(float64) float64
+	switch c := req.GetChange().(type) {
+	case *product.BulkUpdatePricesRequest_Multiplier:
+		if !(c.Multiplier >= 0) || math.IsInf(c.Multiplier, 0) {
+			return nil, status.Error(codes.InvalidArgument, "multiplier must be a finite, non-negative number")
+		}
+		reprice = func(p float64) float64 { return p * c.Multiplier }
+	case *product.BulkUpdatePricesRequest_Delta:
+		if math.IsNaN(c.Delta) || math.IsInf(c.Delta, 0) {
+			return nil, status.Error(codes.InvalidArgument, "delta must be a finite number")
+		}
+		reprice = func(p float64) float64 { return max(p+c.Delta, 0) }
+	default:
+		return nil, status.Error(codes.InvalidArgument, "one of multiplier or delta is required")
+	}
+
+	ids, err := s.matchingIDs(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	origin := audit.OriginFromContext(ctx)
+	meta := newBulkMetadata(len(ids))
+	return s.ops.Start(meta, func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		resp := &product.BulkUpdatePricesResponse{}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			updated, err := s.repriceProduct(ctx, origin, id, f, reprice)
+			if err != nil {
+				return nil, err
+			}
+			if updated {
+				resp.UpdatedCount++
+			}
+			meta.ProcessedItems++
+			progress(touch(meta))
+		}
+		return resp, nil
+	})
+}
+
+// repriceProduct replaces the product with a repriced copy if it still matches f.
+func (s *ProductService) repriceProduct(ctx context.Context, origin audit.Origin, id string, f *filter.Filter, reprice func(float64) float64) (bool, error) {
+	defer s.lockProducts(id)()
+	old, err := s.get(ctx, id)
+	if err != nil || old == nil || !f.Match(old) {
+		return false, err
+	}
+	p := proto.Clone(old).(*product.Product)
+	p.Price = reprice(p.GetPrice())
+	if p.GetPrice() > maxPrice {
+		return false, status.Errorf(codes.InvalidArgument, "repricing product %q would exceed the maximum price %g", id, maxPrice)
+	}
+	return true, s.put(ctx, origin, old, p)
+}
+
+// PurgeProducts starts an operation that deletes every product matching the filter.
+func (s *ProductService) PurgeProducts(ctx context.Context, req *product.PurgeProductsRequest) (*longrunningpb.Operation, error) {
+	f, err := s.parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	if f == nil && !req.GetForce() {
+		return nil, status.Error(codes.InvalidArgument, "force is required to purge the whole catalog")
+	}
+
+	ids, err := s.matchingIDs(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	origin := audit.OriginFromContext(ctx)
+	meta := newBulkMetadata(len(ids))
+	return s.ops.Start(meta, func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		resp := &product.PurgeProductsResponse{}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			purged, err := s.purgeProduct(ctx, origin, id, f)
+			if err != nil {
+				return nil, err
+			}
+			if purged {
+				resp.PurgedCount++
+			}
+			meta.ProcessedItems++
+			progress(touch(meta))
+		}
+		return resp, nil
+	})
+}
+
+func (s *ProductService) purgeProduct(ctx context.Context, origin audit.Origin, id string, f *filter.Filter) (bool, error) {
+	defer s.lockProducts(id)()
+	old, err := s.get(ctx, id)
+	if err != nil || old == nil || !f.Match(old) {
+		return false, err
+	}
+	return true, s.put(ctx, origin, old, nil)
+}
+
+// matchingIDs returns the IDs of products matching f, in ascending order.
+func (s *ProductService) matchingIDs(ctx context.Context, f *filter.Filter) ([]string, error) {
+	var ids []string
+	err := s.query(ctx, repository.Query{Filter: f}, func(p *product.Product) bool {
+		ids = append(ids, p.GetId())
+		return true
+	})
+	return ids, err
+}
+
+func newBulkMetadata(total int) *product.BulkOperationMetadata {
+	now := timestamppb.Now()
+	return &product.BulkOperationMetadata{CreateTime: now, UpdateTime: now, TotalItems: int64(total)}
+}
+
+// touch stamps meta with the current time and returns it for progress reporting.
+func touch(meta *product.BulkOperationMetadata) *product.BulkOperationMetadata {
+	meta.UpdateTime = timestamppb.Now()
+	return meta
+}
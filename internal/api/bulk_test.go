@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func waitOperation(t *testing.T, svc *ProductService, op *longrunningpb.Operation, resp proto.Message) *product.BulkOperationMetadata {
+	t.Helper()
+	done, err := svc.ops.Wait(context.Background(), op.GetName(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if !done.GetDone() {
+		t.Fatalf("operation %q did not finish", op.GetName())
+	}
+	if done.GetError() != nil {
+		t.Fatalf("operation failed: %v", done.GetError())
+	}
+	if err := done.GetResponse().UnmarshalTo(resp); err != nil {
+		t.Fatalf("failed to unpack response: %v", err)
+	}
+	var meta product.BulkOperationMetadata
+	if err := done.GetMetadata().UnmarshalTo(&meta); err != nil {
+		t.Fatalf("failed to unpack metadata: %v", err)
+	}
+	return &meta
+}
+
+func TestBulkImportProducts(t *testing.T) {
+	svc := NewProductService()
+	op, err := svc.BulkImportProducts(context.Background(), &product.BulkImportProductsRequest{Products: []*product.Product{
+		{Id: "bulk-1", Name: "One", Price: 1},
+		{Id: "prod-1", Name: "Clash", Price: 2},
+		{Id: "bulk-2", Name: "", Price: 3},
+		{Id: "bulk-3", Name: "Three", Price: 3},
+	}})
+	if err != nil {
+		t.Fatalf("BulkImportProducts returned error: %v", err)
+	}
+	if op.GetDone() {
+		t.Fatal("expected a running operation")
+	}
+
+	var resp product.BulkImportProductsResponse
+	meta := waitOperation(t, svc, op, &resp)
+	if resp.GetImportedCount() != 2 || len(resp.GetFailures()) != 2 {
+		t.Fatalf("unexpected import response: %v", &resp)
+	}
+	if f := resp.GetFailures()[0]; f.GetIndex() != 1 || f.GetId() != "prod-1" {
+		t.Fatalf("unexpected first failure: %v", f)
+	}
+	if meta.GetTotalItems() != 4 || meta.GetProcessedItems() != 4 || meta.GetFailedItems() != 2 {
+		t.Fatalf("unexpected metadata: %v", meta)
+	}
+	if stored(t, svc, "bulk-3") == nil {
+		t.Fatal("bulk-3 was not imported")
+	}
+}
+
+func TestBulkImportProducts_AppliesProductRules(t *testing.T) {
+	svc := NewProductService()
+	op, err := svc.BulkImportProducts(context.Background(), &product.BulkImportProductsRequest{Products: []*product.Product{
+		{Id: "bad id", Name: "Spaced", Price: 1},
+		{Id: "bulk-1", Name: strings.Repeat("n", 201), Price: 1},
+		{Id: "bulk-2", Name: "Tagged", Price: 1, Tags: []string{strings.Repeat("t", 65)}},
+		{Id: "bulk-3", Name: "Valid", Price: 1},
+	}})
+	if err != nil {
+		t.Fatalf("BulkImportProducts returned error: %v", err)
+	}
+	var resp product.BulkImportProductsResponse
+	waitOperation(t, svc, op, &resp)
+	if resp.GetImportedCount() != 1 || len(resp.GetFailures()) != 3 {
+		t.Fatalf("unexpected import response: %v", &resp)
+	}
+	for i, want := range []string{"id must match", "name must be at most 200 characters", "tags[0] must be at most 64 characters"} {
+		if f := resp.GetFailures()[i]; f.GetIndex() != int32(i) || !strings.Contains(f.GetMessage(), want) {
+			t.Errorf("failure %d = %v, want one about %q", i, f, want)
+		}
+	}
+	if stored(t, svc, "bulk-1") != nil {
+		t.Fatal("an item breaking the Product rules was imported")
+	}
+}
+
+func TestBulkImportProducts_Upsert(t *testing.T) {
+	svc := NewProductService()
+	op, _ := svc.BulkImportProducts(context.Background(), &product.BulkImportProductsRequest{
+		Upsert:   true,
+		Products: []*product.Product{{Id: "prod-1", Name: "Widget A v2", Price: 12}},
+	})
+	var resp product.BulkImportProductsResponse
+	waitOperation(t, svc, op, &resp)
+	if resp.GetImportedCount() != 1 || stored(t, svc, "prod-1").GetName() != "Widget A v2" {
+		t.Fatalf("upsert did not replace prod-1: %v", &resp)
+	}
+}
+
+func TestBulkUpdatePrices(t *testing.T) {
+	svc := NewProductService()
+	op, err := svc.BulkUpdatePrices(context.Background(), &product.BulkUpdatePricesRequest{
+		Filter: "price < 10",
+		Change: &product.BulkUpdatePricesRequest_Multiplier{Multiplier: 2},
+	})
+	if err != nil {
+		t.Fatalf("BulkUpdatePrices returned error: %v", err)
+	}
+	var resp product.BulkUpdatePricesResponse
+	waitOperation(t, svc, op, &resp)
+	if resp.GetUpdatedCount() != 2 {
+		t.Fatalf("unexpected updated count: %d", resp.GetUpdatedCount())
+	}
+	if got := stored(t, svc, "prod-3").GetPrice(); got != 4.99*2 {
+		t.Fatalf("prod-3 not repriced: %v", got)
+	}
+	if got := stored(t, svc, "prod-2").GetPrice(); got != 19.99 {
+		t.Fatalf("prod-2 should not be repriced: %v", got)
+	}
+
+	if _, err := svc.BulkUpdatePrices(context.Background(), &product.BulkUpdatePricesRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument without a change, got %v", err)
+	}
+}
+
+func TestPurgeProducts(t *testing.T) {
+	svc := NewProductService()
+	if _, err := svc.PurgeProducts(context.Background(), &product.PurgeProductsRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument purging everything without force, got %v", err)
+	}
+
+	op, err := svc.PurgeProducts(context.Background(), &product.PurgeProductsRequest{Filter: `name = "G*"`})
+	if err != nil {
+		t.Fatalf("PurgeProducts returned error: %v", err)
+	}
+	var resp product.PurgeProductsResponse
+	waitOperation(t, svc, op, &resp)
+	if resp.GetPurgedCount() != 2 || storedCount(t, svc) != 1 {
+		t.Fatalf("unexpected purge result: %v, remaining %d", &resp, storedCount(t, svc))
+	}
+}
+
+func TestBulkOperations_CancelStopsWork(t *testing.T) {
+	svc := NewProductService()
+	products := make([]*product.Product, 20000)
+	for i := range products {
+		products[i] = &product.Product{Name: "Item", Price: 1}
+	}
+	op, err := svc.BulkImportProducts(context.Background(), &product.BulkImportProductsRequest{Products: products})
+	if err != nil {
+		t.Fatalf("BulkImportProducts returned error: %v", err)
+	}
+	if err := svc.ops.Cancel(op.GetName()); err != nil {
+		t.Fatalf("Cancel returned error: %v", err)
+	}
+	done, err := svc.ops.Wait(context.Background(), op.GetName(), 5*time.Second)
+	if err != nil || !done.GetDone() {
+		t.Fatalf("operation did not finish after cancel: %v (err %v)", done, err)
+	}
+	if got := codes.Code(done.GetError().GetCode()); got != codes.Canceled {
+		t.Fatalf("expected Canceled, got %v", got)
+	}
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+	if storedCount(t, svc) >= 3+len(products) {
+		t.Fatal("cancellation did not stop the import")
+	}
+}
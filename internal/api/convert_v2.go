@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"grpc-go-fx/internal/generated/product"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CatalogCurrency is the currency of every stored price. product.v1 prices are
+// plain numbers in this currency; product.v2 prices must name it explicitly.
+const CatalogCurrency = "USD"
+
+// Products are stored in their v1 form. The functions below translate between
+// the two versions so that both see the same catalog; the only lossy step is
+// rounding a stored price to the nearest nano when it is read through v2.
+
+// productToV2 converts a stored product to its v2 form. It returns Internal if
+// the stored price cannot be represented as Money.
+func productToV2(p *product.Product) (*productv2.Product, error) {
+	price, err := moneyFromPrice(p.GetPrice())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "product %q: %v", p.GetId(), err)
+	}
+	out := &productv2.Product{
+		Id:          p.GetId(),
+		Name:        p.GetName(),
+		Description: p.GetDescription(),
+		Price:       price,
+		Tags:        p.GetTags(),
+		Categories:  p.GetCategories(),
+	}
+	if len(p.GetAttributes()) > 0 {
+		out.Attributes = make(map[string]*productv2.AttributeValue, len(p.GetAttributes()))
+		for k, v := range p.GetAttributes() {
+			out.Attributes[k] = attributeToV2(v)
+		}
+	}
+	return out, nil
+}
+
+// productFromV2 converts a v2 product to its stored form. It returns
+// InvalidArgument if the price cannot be represented in the catalog.
+func productFromV2(p *productv2.Product) (*product.Product, error) {
+	price, err := priceFromMoney(p.GetPrice())
+	if err != nil {
+		return nil, err
+	}
+	out := &product.Product{
+		Id:          p.GetId(),
+		Name:        p.GetName(),
+		Description: p.GetDescription(),
+		Price:       price,
+		Tags:        p.GetTags(),
+		Categories:  p.GetCategories(),
+	}
+	if len(p.GetAttributes()) > 0 {
+		out.Attributes = make(map[string]*product.AttributeValue, len(p.GetAttributes()))
+		for k, v := range p.GetAttributes() {
+			out.Attributes[k] = attributeFromV2(v)
+		}
+	}
+	return out, nil
+}
+
+// moneyFromPrice converts a stored price to Money, rounding to the nearest
+// nano. Validation bounds prices by maxPrice, but a price outside the range of
+// the units, or not a number, is an error rather than a wrapped-around amount.
+func moneyFromPrice(price float64) (*productv2.Money, error) {
+	// -math.MinInt64 is exactly representable; math.MaxInt64 is not.
+	if !(math.Abs(price) < -math.MinInt64) {
+		return nil, fmt.Errorf("price %g is out of the range of Money", price)
+	}
+	units, frac := math.Modf(price)
+	nanos := math.Round(frac * 1e9)
+	if math.Abs(nanos) >= 1e9 {
+		units += math.Copysign(1, nanos)
+		nanos = 0
+	}
+	if !(math.Abs(units) < -math.MinInt64) {
+		return nil, fmt.Errorf("price %g is out of the range of Money", price)
+	}
+	return &productv2.Money{CurrencyCode: CatalogCurrency, Units: int64(units), Nanos: int32(nanos)}, nil
+}
+
+// priceFromMoney converts m to a stored price. The amount is parsed from its
+// decimal form so that prices such as 9.99 round-trip exactly.
+func priceFromMoney(m *productv2.Money) (float64, error) {
+	if m == nil {
+		return 0, status.Error(codes.InvalidArgument, "price is required")
+	}
+	if m.GetCurrencyCode() != CatalogCurrency {
+		return 0, status.Errorf(codes.InvalidArgument, "price.currency_code must be %s, got %q", CatalogCurrency, m.GetCurrencyCode())
+	}
+	units, nanos := m.GetUnits(), m.GetNanos()
+	if nanos <= -1e9 || nanos >= 1e9 {
+		return 0, status.Error(codes.InvalidArgument, "price.nanos must be between -999999999 and 999999999")
+	}
+	if (units > 0 && nanos < 0) || (units < 0 && nanos > 0) {
+		return 0, status.Error(codes.InvalidArgument, "price.units and price.nanos must have the same sign")
+	}
+	if units < 0 || nanos < 0 {
+		return 0, status.Error(codes.InvalidArgument, "price must not be negative")
+	}
+	return strconv.ParseFloat(fmt.Sprintf("%d.%09d", units, nanos), 64)
+}
+
+func attributeToV2(v *product.AttributeValue) *productv2.AttributeValue {
+	switch k := v.GetKind().(type) {
+	case *product.AttributeValue_StringValue:
+		return &productv2.AttributeValue{Kind: &productv2.AttributeValue_StringValue{StringValue: k.StringValue}}
+	case *product.AttributeValue_NumberValue:
+		return &productv2.AttributeValue{Kind: &productv2.AttributeValue_NumberValue{NumberValue: k.NumberValue}}
+	case *product.AttributeValue_BoolValue:
+		return &productv2.AttributeValue{Kind: &productv2.AttributeValue_BoolValue{BoolValue: k.BoolValue}}
+	case *product.AttributeValue_EnumValue:
+		return &productv2.AttributeValue{Kind: &productv2.AttributeValue_EnumValue{EnumValue: k.EnumValue}}
+	}
+	return &productv2.AttributeValue{}
+}
+
+func attributeFromV2(v *productv2.AttributeValue) *product.AttributeValue {
+	switch k := v.GetKind().(type) {
+	case *productv2.AttributeValue_StringValue:
+		return &product.AttributeValue{Kind: &product.AttributeValue_StringValue{StringValue: k.StringValue}}
+	case *productv2.AttributeValue_NumberValue:
+		return &product.AttributeValue{Kind: &product.AttributeValue_NumberValue{NumberValue: k.NumberValue}}
+	case *productv2.AttributeValue_BoolValue:
+		return &product.AttributeValue{Kind: &product.AttributeValue_BoolValue{BoolValue: k.BoolValue}}
+	case *productv2.AttributeValue_EnumValue:
+		return &product.AttributeValue{Kind: &product.AttributeValue_EnumValue{EnumValue: k.EnumValue}}
+	}
+	return &product.AttributeValue{}
+}
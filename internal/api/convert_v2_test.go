@@ -0,0 +1,114 @@
+package api
+
+import (
+	"math"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMoneyFromPrice(t *testing.T) {
+	tests := []struct {
+		price float64
+		units int64
+		nanos int32
+	}{
+		{0, 0, 0},
+		{9.99, 9, 990000000},
+		{19.99, 19, 990000000},
+		{0.5, 0, 500000000},
+		{1234567.000000001, 1234567, 1},
+		{2.9999999999, 3, 0},
+	}
+	for _, tt := range tests {
+		m, err := moneyFromPrice(tt.price)
+		if err != nil {
+			t.Errorf("moneyFromPrice(%v): %v", tt.price, err)
+			continue
+		}
+		if m.GetCurrencyCode() != CatalogCurrency || m.GetUnits() != tt.units || m.GetNanos() != tt.nanos {
+			t.Errorf("moneyFromPrice(%v) = %v, want %d units %d nanos", tt.price, m, tt.units, tt.nanos)
+		}
+	}
+}
+
+func TestMoneyFromPrice_RejectsPricesOutOfRange(t *testing.T) {
+	for _, price := range []float64{1e19, math.MaxFloat64, math.Inf(1), math.NaN()} {
+		if m, err := moneyFromPrice(price); err == nil {
+			t.Errorf("moneyFromPrice(%v) = %v, want an error", price, m)
+		}
+	}
+	if _, err := productToV2(&product.Product{Id: "p", Price: 1e300}); status.Code(err) != codes.Internal {
+		t.Errorf("productToV2 with price 1e300: %v, want Internal", err)
+	}
+	if _, err := moneyFromPrice(maxPrice); err != nil {
+		t.Errorf("moneyFromPrice(maxPrice): %v", err)
+	}
+}
+
+func TestPriceFromMoney_RoundTripsDecimalPrices(t *testing.T) {
+	for _, price := range []float64{0, 0.01, 4.99, 9.99, 19.99, 1e6 + 0.35} {
+		m, err := moneyFromPrice(price)
+		if err != nil {
+			t.Fatalf("moneyFromPrice(%v): %v", price, err)
+		}
+		got, err := priceFromMoney(m)
+		if err != nil {
+			t.Fatalf("priceFromMoney(%v): %v", price, err)
+		}
+		if got != price {
+			t.Fatalf("round trip of %v gave %v", price, got)
+		}
+	}
+}
+
+func TestPriceFromMoney_RejectsUnrepresentableAmounts(t *testing.T) {
+	for name, m := range map[string]*productv2.Money{
+		"missing":        nil,
+		"other currency": {CurrencyCode: "EUR", Units: 1},
+		"negative":       {CurrencyCode: "USD", Units: -1, Nanos: -500000000},
+		"negative nanos": {CurrencyCode: "USD", Nanos: -1},
+		"mixed signs":    {CurrencyCode: "USD", Units: 1, Nanos: -1},
+		"nanos overflow": {CurrencyCode: "USD", Nanos: 1e9},
+	} {
+		if _, err := priceFromMoney(m); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: expected InvalidArgument, got %v", name, err)
+		}
+	}
+}
+
+func TestProductTranslation_RoundTrip(t *testing.T) {
+	v1 := &product.Product{
+		Id:          "prod-9",
+		Name:        "Drill",
+		Description: "Cordless",
+		Price:       89.95,
+		Tags:        []string{"acme"},
+		Categories:  []string{"power-tools"},
+		Attributes: map[string]*product.AttributeValue{
+			"color":    {Kind: &product.AttributeValue_StringValue{StringValue: "red"}},
+			"voltage":  {Kind: &product.AttributeValue_NumberValue{NumberValue: 18}},
+			"cordless": {Kind: &product.AttributeValue_BoolValue{BoolValue: true}},
+			"size":     {Kind: &product.AttributeValue_EnumValue{EnumValue: "M"}},
+		},
+	}
+	v2, err := productToV2(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.GetPrice().GetUnits() != 89 || v2.GetPrice().GetNanos() != 950000000 {
+		t.Fatalf("unexpected v2 price %v", v2.GetPrice())
+	}
+	back, err := productFromV2(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(back, v1) {
+		t.Fatalf("round trip changed the product:\n got %v\nwant %v", back, v1)
+	}
+}
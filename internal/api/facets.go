@@ -0,0 +1,107 @@
+package api
+
+import (
+	"sort"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultMaxTags = 20
+
+// facetCounter accumulates facet counts in a single pass over the filtered
+// products: one map increment per tag and one binary search per price.
+type facetCounter struct {
+	tags    map[string]int64
+	maxTags int
+	bounds  []float64
+	buckets []int64
+}
+
+// newFacetCounter returns nil when opts requests no facets.
+func newFacetCounter(opts *product.FacetOptions) (*facetCounter, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	bounds := opts.GetPriceBucketBounds()
+	if err := checkBounds(bounds, "facets.price_bucket_bounds"); err != nil {
+		return nil, err
+	}
+	fc := &facetCounter{maxTags: int(opts.GetMaxTags()), bounds: bounds}
+	if fc.maxTags <= 0 {
+		fc.maxTags = defaultMaxTags
+	}
+	if opts.GetTags() {
+		fc.tags = make(map[string]int64)
+	}
+	if len(bounds) > 0 {
+		fc.buckets = make([]int64, len(bounds)+1)
+	}
+	return fc, nil
+}
+
+func (fc *facetCounter) add(p *product.Product) {
+	if fc.tags != nil {
+		for _, t := range p.GetTags() {
+			fc.tags[t]++
+		}
+	}
+	if fc.buckets != nil {
+		fc.buckets[bucketIndex(fc.bounds, p.GetPrice())]++
+	}
+}
+
+func (fc *facetCounter) result() *product.Facets {
+	out := &product.Facets{}
+	for tag, n := range fc.tags {
+		out.Tags = append(out.Tags, &product.TagCount{Tag: tag, Count: n})
+	}
+	sort.Slice(out.Tags, func(i, j int) bool {
+		if out.Tags[i].GetCount() != out.Tags[j].GetCount() {
+			return out.Tags[i].GetCount() > out.Tags[j].GetCount()
+		}
+		return out.Tags[i].GetTag() < out.Tags[j].GetTag()
+	})
+	if len(out.Tags) > fc.maxTags {
+		out.Tags = out.Tags[:fc.maxTags]
+	}
+	if fc.buckets != nil {
+		out.PriceBuckets = priceBuckets(fc.bounds, fc.buckets)
+	}
+	return out
+}
+
+// checkBounds validates that bucket bounds are strictly ascending.
+func checkBounds(bounds []float64, field string) error {
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return status.Errorf(codes.InvalidArgument, "%s must be strictly ascending", field)
+		}
+	}
+	return nil
+}
+
+// bucketIndex returns the index of the bucket containing price: the first
+// bound greater than price, since upper bounds are exclusive.
+func bucketIndex(bounds []float64, price float64) int {
+	return sort.Search(len(bounds), func(i int) bool { return bounds[i] > price })
+}
+
+// priceBuckets converts bucket counts for bounds into PriceBucket messages.
+func priceBuckets(bounds []float64, counts []int64) []*product.PriceBucket {
+	out := make([]*product.PriceBucket, len(counts))
+	for i, n := range counts {
+		b := &product.PriceBucket{Count: n}
+		if i > 0 {
+			b.Min = proto.Float64(bounds[i-1])
+		}
+		if i < len(bounds) {
+			b.Max = proto.Float64(bounds[i])
+		}
+		out[i] = b
+	}
+	return out
+}
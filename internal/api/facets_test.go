@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func seedTaggedProducts(t *testing.T, svc *ProductService) {
+	t.Helper()
+	ctx := context.Background()
+	products := []*product.Product{
+		{Id: "t-1", Name: "Hammer", Price: 5, Tags: []string{"acme", "tools"}},
+		{Id: "t-2", Name: "Saw", Price: 15, Tags: []string{"acme", "tools"}},
+		{Id: "t-3", Name: "Rake", Price: 25, Tags: []string{"garden"}},
+		{Id: "t-4", Name: "Hose", Price: 50, Tags: []string{"acme", "garden"}},
+	}
+	for _, p := range products {
+		if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: p}); err != nil {
+			t.Fatalf("CreateProduct(%q) returned error: %v", p.GetId(), err)
+		}
+	}
+}
+
+func TestListProducts_FacetsCountWholeFilteredSet(t *testing.T) {
+	svc := NewProductService()
+	seedTaggedProducts(t, svc)
+
+	resp, err := svc.ListProducts(context.Background(), &product.ListProductsRequest{
+		Limit:  1,
+		Filter: "tags:*",
+		Facets: &product.FacetOptions{Tags: true, PriceBucketBounds: []float64{10, 30}},
+	})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if got := len(resp.GetProducts()); got != 1 {
+		t.Fatalf("limit not applied to products: got %d", got)
+	}
+
+	wantTags := []struct {
+		tag   string
+		count int64
+	}{{"acme", 3}, {"garden", 2}, {"tools", 2}}
+	tags := resp.GetFacets().GetTags()
+	if len(tags) != len(wantTags) {
+		t.Fatalf("unexpected tag facets: %v", tags)
+	}
+	for i, w := range wantTags {
+		if tags[i].GetTag() != w.tag || tags[i].GetCount() != w.count {
+			t.Fatalf("tag facet %d: got %s=%d, want %s=%d", i, tags[i].GetTag(), tags[i].GetCount(), w.tag, w.count)
+		}
+	}
+
+	buckets := resp.GetFacets().GetPriceBuckets()
+	wantCounts := []int64{1, 2, 1}
+	if len(buckets) != len(wantCounts) {
+		t.Fatalf("unexpected price buckets: %v", buckets)
+	}
+	for i, want := range wantCounts {
+		if buckets[i].GetCount() != want {
+			t.Fatalf("bucket %d: got %d, want %d", i, buckets[i].GetCount(), want)
+		}
+	}
+	if buckets[0].Min != nil || buckets[0].GetMax() != 10 || buckets[2].Max != nil || buckets[2].GetMin() != 30 {
+		t.Fatalf("unexpected bucket bounds: %v", buckets)
+	}
+}
+
+func TestListProducts_FacetsRespectFilter(t *testing.T) {
+	svc := NewProductService()
+	seedTaggedProducts(t, svc)
+
+	resp, err := svc.ListProducts(context.Background(), &product.ListProductsRequest{
+		Filter: "tags:garden",
+		Facets: &product.FacetOptions{Tags: true, MaxTags: 1},
+	})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	tags := resp.GetFacets().GetTags()
+	if len(tags) != 1 || tags[0].GetTag() != "garden" || tags[0].GetCount() != 2 {
+		t.Fatalf("unexpected tag facets: %v", tags)
+	}
+	if resp.GetFacets().GetPriceBuckets() != nil {
+		t.Fatal("price buckets returned without bounds")
+	}
+}
+
+func TestListProducts_FacetsRejectUnorderedBounds(t *testing.T) {
+	svc := NewProductService()
+	_, err := svc.ListProducts(context.Background(), &product.ListProductsRequest{
+		Facets: &product.FacetOptions{PriceBucketBounds: []float64{10, 10}},
+	})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", got)
+	}
+}
+
+func TestListProducts_NoFacetsByDefault(t *testing.T) {
+	svc := NewProductService()
+	resp, err := svc.ListProducts(context.Background(), &product.ListProductsRequest{})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if resp.GetFacets() != nil {
+		t.Fatalf("expected no facets, got %v", resp.GetFacets())
+	}
+}
+
+func TestCreateProduct_RejectsDuplicateTags(t *testing.T) {
+	svc := NewProductService()
+	_, err := svc.CreateProduct(context.Background(), &product.CreateProductRequest{
+		Product: &product.Product{Name: "Dup", Tags: []string{"a", "a"}},
+	})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", got)
+	}
+}
+
+func BenchmarkListProductsWithFacets(b *testing.B) {
+	svc := NewProductService()
+	tags := []string{"acme", "globex", "initech", "umbrella", "garden", "tools"}
+	for i := 0; i < 10000; i++ {
+		svc.repo.Put(context.Background(), &product.Product{
+			Id: fmt.Sprintf("bench-%05d", i), Name: "Item", Price: float64(i % 200),
+			Tags: []string{tags[i%len(tags)], tags[(i+1)%len(tags)]},
+		})
+	}
+	req := &product.ListProductsRequest{
+		Filter: "price < 150",
+		Facets: &product.FacetOptions{Tags: true, PriceBucketBounds: []float64{25, 50, 100}},
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := svc.ListProducts(context.Background(), req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
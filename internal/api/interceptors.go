@@ -0,0 +1,43 @@
+package api
+
+import (
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/deadline"
+	"grpc-go-fx/internal/idempotency"
+	"grpc-go-fx/internal/interceptor"
+	"grpc-go-fx/internal/recovery"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/validate"
+
+	"go.uber.org/zap"
+)
+
+// UnaryInterceptors returns the unary interceptors of the API, which Module
+// contributes to the value group "unary_interceptors": panic recovery,
+// logging to log, default deadlines from cfg, validation against the proto
+// field rules, rejection or forwarding of writes to a replication follower by
+// repl, and deduplication of requests carrying an idempotency key through
+// keys.
+func UnaryInterceptors(cfg *config.Config, log *zap.Logger, repl *replication.Server, keys *idempotency.Store) []interceptor.Unary {
+	return []interceptor.Unary{
+		{Order: interceptor.OrderRecovery, Name: "recovery", Interceptor: recovery.UnaryServerInterceptor(log)},
+		{Order: interceptor.OrderDeadline, Name: "deadline", Interceptor: deadline.UnaryServerInterceptor(timeouts(cfg))},
+		{Order: interceptor.OrderValidate, Name: "validate", Interceptor: validate.UnaryServerInterceptor()},
+		{Order: interceptor.OrderReplication, Name: "replication", Interceptor: repl.UnaryServerInterceptor()},
+		{Order: interceptor.OrderIdempotency, Name: "idempotency", Interceptor: idempotency.UnaryServerInterceptor(keys)},
+	}
+}
+
+// StreamInterceptors returns the stream interceptors of the API, which
+// Module contributes to the value group "stream_interceptors": panic
+// recovery, logging to log, and the deadlines cfg gives streaming methods.
+func StreamInterceptors(cfg *config.Config, log *zap.Logger) []interceptor.Stream {
+	return []interceptor.Stream{
+		{Order: interceptor.OrderRecovery, Name: "recovery", Interceptor: recovery.StreamServerInterceptor(log)},
+		{Order: interceptor.OrderDeadline, Name: "deadline", Interceptor: deadline.StreamServerInterceptor(timeouts(cfg))},
+	}
+}
+
+func timeouts(cfg *config.Config) deadline.Timeouts {
+	return deadline.Timeouts{Default: cfg.RPCTimeout, Methods: cfg.RPCMethodTimeouts}
+}
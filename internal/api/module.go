@@ -2,24 +2,281 @@ package api
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
 	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 
+	"grpc-go-fx/internal/audit"
 	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/eventsource"
 	"grpc-go-fx/internal/generated/product"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+	"grpc-go-fx/internal/idempotency"
+	"grpc-go-fx/internal/operations"
+	"grpc-go-fx/internal/outbox"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/repository"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
 // Module is the FX module for the Product API gRPC server.
 var Module = fx.Module("api",
-	fx.Provide(fx.Annotate(NewProductService, fx.As(new(product.ProductServiceServer)))),
-	fx.Provide(NewGRPCServer),
+	fx.Provide(NewOperationRegistry),
+	fx.Provide(NewIdempotencyStore),
+	fx.Provide(NewAuditLog),
+	fx.Provide(NewReplicationLog),
+	fx.Provide(NewEventCounts),
+	fx.Provide(fx.Annotate(
+		func(c *eventsource.EventCounts) eventsource.Projection { return c },
+		fx.ResultTags(`group:"projections"`),
+	)),
+	fx.Provide(NewOutbox),
+	fx.Provide(NewOutboxPublisher),
+	fx.Provide(fx.Annotate(NewProductRepository, fx.ParamTags(``, ``, ``, ``, `group:"projections"`))),
+	fx.Provide(fx.Annotate(operations.NewServer, fx.As(new(longrunningpb.OperationsServer)))),
+	fx.Provide(fx.Annotate(NewProductServiceFromConfig, fx.As(fx.Self()), fx.As(new(product.ProductServiceServer)))),
+	fx.Provide(fx.Annotate(NewProductServiceV2, fx.As(new(productv2.ProductServiceServer)))),
+	fx.Provide(NewBackupStore),
+	fx.Provide(fx.Annotate(NewAdminService, fx.As(new(product.AdminServiceServer)))),
+	fx.Provide(NewReplicationServer),
+	fx.Provide(fx.Annotate(UnaryInterceptors, fx.ResultTags(`group:"unary_interceptors,flatten"`))),
+	fx.Provide(fx.Annotate(StreamInterceptors, fx.ResultTags(`group:"stream_interceptors,flatten"`))),
+	fx.Provide(fx.Annotate(NewGRPCServer, fx.ParamTags(``, ``, ``, ``, ``, `group:"unary_interceptors"`, `group:"stream_interceptors"`, `group:"server_options"`))),
+	fx.Invoke(PublishCacheStats),
+	fx.Invoke(PublishReplicationStatus),
+	fx.Invoke(PublishEventCounts),
+	fx.Invoke(PublishOutboxStats),
+	fx.Invoke(RegisterOutboxRelay),
 	fx.Invoke(RegisterGRPCLifecycle),
+	fx.Invoke(RegisterOperationsLifecycle),
 )
 
-// RegisterGRPCLifecycle registers the gRPC server with FX lifecycle (OnStart listen/serve, OnStop GracefulStop).
-func RegisterGRPCLifecycle(lc fx.Lifecycle, srv *grpc.Server, cfg *config.Config) {
+// NewOperationRegistry creates the registry of long-running operations used by bulk RPCs.
+func NewOperationRegistry(cfg *config.Config) *operations.Registry {
+	return operations.NewRegistry(cfg.OperationRetention)
+}
+
+// NewIdempotencyStore creates the store of outcomes replayed for retried requests.
+func NewIdempotencyStore(cfg *config.Config) *idempotency.Store {
+	return idempotency.NewStore(cfg.IdempotencyWindow, cfg.IdempotencyMaxEntries)
+}
+
+// NewProductRepository creates the product repository selected by cfg.Storage,
+// recording its writes in log if the instance is a replication leader and in
+// ob if the outbox is enabled, behind a repository.Cache if cfg.CacheSize is
+// positive. The file store, and then the outbox, are opened on OnStart and
+// closed on OnStop. The event store maintains projections, the value group
+// "projections", besides its catalog.
+func NewProductRepository(lc fx.Lifecycle, cfg *config.Config, log *replication.Log, ob *outbox.Outbox, projections []eventsource.Projection) (repository.ProductRepository, error) {
+	repo, err := newStorage(lc, cfg, projections)
+	if err != nil {
+		return nil, err
+	}
+	if log != nil {
+		repo = log.Wrap(repo)
+	}
+	if ob != nil {
+		repo = ob.Wrap(repo)
+		lc.Append(fx.StartStopHook(ob.Open, ob.Close))
+	}
+	if cfg.CacheSize <= 0 {
+		return repo, nil
+	}
+	return repository.NewCache(repo, repository.CacheOptions{
+		Size:        cfg.CacheSize,
+		TTL:         cfg.CacheTTL,
+		NegativeTTL: cfg.CacheNegativeTTL,
+	}), nil
+}
+
+// newStorage creates the uncached repository selected by cfg.Storage.
+func newStorage(lc fx.Lifecycle, cfg *config.Config, projections []eventsource.Projection) (repository.ProductRepository, error) {
+	switch cfg.Storage {
+	case "", "memory":
+		return repository.NewMemory(), nil
+	case "sharded":
+		return repository.NewSharded(cfg.Shards), nil
+	case "versioned":
+		return repository.NewVersioned(repository.VersionedOptions{PinTTL: cfg.VersionPinTTL}), nil
+	case "file":
+		f := repository.NewFile(cfg.DataDir, repository.FileOptions{
+			Sync:              cfg.FsyncPolicy,
+			SyncInterval:      cfg.FsyncInterval,
+			SnapshotThreshold: cfg.SnapshotThreshold,
+		})
+		lc.Append(fx.StartStopHook(f.Open, f.Close))
+		return f, nil
+	case "events":
+		store := eventsource.NewFileStore(filepath.Join(cfg.DataDir, EventLogFile))
+		repo := eventsource.NewRepository(store, projections...)
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				if err := store.Open(); err != nil {
+					return err
+				}
+				return repo.Rebuild(ctx)
+			},
+			OnStop: func(ctx context.Context) error { return store.Close() },
+		})
+		return repo, nil
+	case "sql":
+		db, err := OpenDatabase(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s, err := NewSQLRepository(db, cfg)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				if !cfg.MigrateOnStart {
+					return s.CheckSchema(ctx)
+				}
+				_, err := s.Migrate(ctx)
+				return err
+			},
+			OnStop: func(ctx context.Context) error { return db.Close() },
+		})
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q", cfg.Storage)
+	}
+}
+
+// cacheStatsVar is the expvar under which PublishCacheStats exposes the
+// counters of the product cache.
+const cacheStatsVar = "product_cache"
+
+var (
+	publishCacheStats sync.Once
+	publishedCache    atomic.Pointer[repository.Cache]
+)
+
+// PublishCacheStats exposes the hit, miss and eviction counters of repo, if it
+// is a repository.Cache, as the expvar "product_cache" (served by the gateway
+// at /debug/vars).
+func PublishCacheStats(repo repository.ProductRepository) {
+	c, ok := repo.(*repository.Cache)
+	if !ok {
+		return
+	}
+	publishedCache.Store(c)
+	publishCacheStats.Do(func() {
+		expvar.Publish(cacheStatsVar, expvar.Func(func() any {
+			return publishedCache.Load().Stats()
+		}))
+	})
+}
+
+// EventLogFile is the name of the event store's log in the data directory.
+const EventLogFile = "events.log"
+
+// NewEventCounts creates the projection counting the events of the event
+// store; it is registered in the value group "projections".
+func NewEventCounts() *eventsource.EventCounts {
+	return eventsource.NewEventCounts()
+}
+
+// eventCountsVar is the expvar under which PublishEventCounts exposes the
+// event counts.
+const eventCountsVar = "product_events"
+
+var (
+	publishEventCounts sync.Once
+	publishedEvents    atomic.Pointer[eventsource.EventCounts]
+)
+
+// PublishEventCounts exposes the number of events of each kind in the event
+// store, and the sequence number of the last, as the expvar "product_events".
+// It publishes nothing unless cfg.Storage is "events".
+func PublishEventCounts(c *eventsource.EventCounts, cfg *config.Config) {
+	if cfg.Storage != "events" {
+		return
+	}
+	publishedEvents.Store(c)
+	publishEventCounts.Do(func() {
+		expvar.Publish(eventCountsVar, expvar.Func(func() any {
+			c := publishedEvents.Load()
+			return map[string]any{"sequence": c.Sequence(), "events": c.Counts()}
+		}))
+	})
+}
+
+// OpenDatabase opens the database of the SQL store and applies the pool
+// settings in cfg. It does not connect.
+func OpenDatabase(cfg *config.Config) (*sql.DB, error) {
+	if cfg.DatabaseDriver == "" || cfg.DatabaseDSN == "" {
+		return nil, errors.New("sql storage needs a database driver and DSN")
+	}
+	db, err := sql.Open(cfg.DatabaseDriver, cfg.DatabaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.DatabaseMaxOpenConns)
+	if cfg.DatabaseMaxIdleConns != 0 {
+		db.SetMaxIdleConns(cfg.DatabaseMaxIdleConns)
+	}
+	db.SetConnMaxLifetime(cfg.DatabaseConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DatabaseConnMaxIdleTime)
+	return db, nil
+}
+
+// NewSQLRepository creates the SQL store over db in the dialect of
+// cfg.DatabaseDriver.
+func NewSQLRepository(db *sql.DB, cfg *config.Config) (*repository.SQL, error) {
+	dialect, err := repository.DialectOf(cfg.DatabaseDriver)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewSQL(db, repository.SQLOptions{Dialect: dialect}), nil
+}
+
+// NewAuditLog opens the audit log configured by cfg and closes it on OnStop.
+func NewAuditLog(lc fx.Lifecycle, cfg *config.Config) (*audit.Log, error) {
+	var sink audit.Sink
+	if cfg.AuditLogPath != "" {
+		fs, err := audit.OpenFileSink(cfg.AuditLogPath)
+		if err != nil {
+			return nil, err
+		}
+		sink = fs
+	}
+	log, err := audit.NewLog(sink, cfg.AuditMaxEvents)
+	if err != nil {
+		if sink != nil {
+			sink.Close()
+		}
+		return nil, err
+	}
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error { return log.Close() },
+	})
+	return log, nil
+}
+
+// RegisterOperationsLifecycle cancels running operations on OnStop and waits for them to finish.
+func RegisterOperationsLifecycle(lc fx.Lifecycle, reg *operations.Registry) {
+	lc.Append(fx.Hook{
+		OnStop: reg.Close,
+	})
+}
+
+// RegisterGRPCLifecycle registers the gRPC server with FX lifecycle (OnStart
+// listen/serve, OnStop GracefulStop). Replication streams, which would keep a
+// graceful stop waiting, are ended first. If the server fails, the failure is
+// logged to log and the app is shut down with exit code 1.
+func RegisterGRPCLifecycle(lc fx.Lifecycle, shutdown fx.Shutdowner, srv *grpc.Server, repl *replication.Server, cfg *config.Config, log *zap.Logger) {
 	var lis net.Listener
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -28,13 +285,27 @@ func RegisterGRPCLifecycle(lc fx.Lifecycle, srv *grpc.Server, cfg *config.Config
 			if err != nil {
 				return err
 			}
-			go func() { _ = srv.Serve(lis) }()
+			log.Info("gRPC server listening", zap.Stringer("addr", lis.Addr()))
+			go serve(srv, lis, shutdown, log)
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
+			repl.Close()
 			srv.GracefulStop()
 			return nil
 		},
 	})
 }
 
+// serve serves srv on lis until it is stopped, and shuts the app down if it
+// fails instead.
+func serve(srv *grpc.Server, lis net.Listener, shutdown fx.Shutdowner, log *zap.Logger) {
+	err := srv.Serve(lis)
+	if err == nil || errors.Is(err, grpc.ErrServerStopped) {
+		return
+	}
+	log.Error("gRPC server failed", zap.Error(err))
+	if err := shutdown.Shutdown(fx.ExitCode(1)); err != nil {
+		log.Error("shutting down after the gRPC server failed", zap.Error(err))
+	}
+}
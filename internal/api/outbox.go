@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/outbox"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/repository"
+
+	"go.uber.org/fx"
+)
+
+// NewOutbox creates the transactional outbox enabled by cfg.OutboxPublisher,
+// logged to cfg.OutboxPath, which NewProductRepository records writes in.
+// Followers, and instances without a publisher, get nil.
+func NewOutbox(cfg *config.Config) *outbox.Outbox {
+	if cfg.OutboxPublisher == "" || cfg.ReplicationRole == replication.RoleFollower {
+		return nil
+	}
+	return outbox.New(cfg.OutboxPath)
+}
+
+// NewOutboxPublisher creates the publisher selected by cfg.OutboxPublisher,
+// or nil if there is none. A file publisher is closed on OnStop.
+func NewOutboxPublisher(lc fx.Lifecycle, cfg *config.Config) (outbox.Publisher, error) {
+	switch cfg.OutboxPublisher {
+	case "":
+		return nil, nil
+	case "stdout":
+		return outbox.NewWriterPublisher(os.Stdout), nil
+	case "file":
+		p, err := outbox.OpenFilePublisher(cfg.OutboxFile)
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.StopHook(p.Close))
+		return p, nil
+	case "webhook":
+		if cfg.OutboxWebhookURL == "" {
+			return nil, errors.New("the webhook outbox publisher needs a URL")
+		}
+		return outbox.NewWebhook(cfg.OutboxWebhookURL, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", cfg.OutboxPublisher)
+	}
+}
+
+// RegisterOutboxRelay delivers the events of ob to pub from OnStart until
+// OnStop, dead-lettering them to cfg.OutboxDeadLetterPath. It does nothing
+// without an outbox. repo is a parameter so that the relay starts after the
+// outbox has been opened, and stops before it is closed.
+func RegisterOutboxRelay(lc fx.Lifecycle, cfg *config.Config, ob *outbox.Outbox, pub outbox.Publisher, repo repository.ProductRepository) error {
+	if ob == nil || pub == nil {
+		return nil
+	}
+	var deadLetters outbox.Publisher = outbox.NewWriterPublisher(os.Stderr)
+	if cfg.OutboxDeadLetterPath != "" {
+		p, err := outbox.OpenFilePublisher(cfg.OutboxDeadLetterPath)
+		if err != nil {
+			return err
+		}
+		lc.Append(fx.StopHook(p.Close))
+		deadLetters = p
+	}
+	relay := outbox.NewRelay(ob, pub, deadLetters, outbox.RelayOptions{
+		Source:      cfg.OutboxSource,
+		MaxAttempts: cfg.OutboxMaxAttempts,
+		MinBackoff:  cfg.OutboxMinBackoff,
+		MaxBackoff:  cfg.OutboxMaxBackoff,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				relay.Run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stop context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stop.Done():
+				return stop.Err()
+			}
+		},
+	})
+	return nil
+}
+
+// outboxStatsVar is the expvar under which PublishOutboxStats exposes the
+// counters of the outbox.
+const outboxStatsVar = "outbox"
+
+var (
+	publishOutboxStats sync.Once
+	publishedOutbox    atomic.Pointer[outbox.Outbox]
+)
+
+// PublishOutboxStats exposes the pending, delivered, failed and
+// dead-lettered counts of ob, if the outbox is enabled, as the expvar
+// "outbox".
+func PublishOutboxStats(ob *outbox.Outbox) {
+	if ob == nil {
+		return
+	}
+	publishedOutbox.Store(ob)
+	publishOutboxStats.Do(func() {
+		expvar.Publish(outboxStatsVar, expvar.Func(func() any {
+			return publishedOutbox.Load().Stats()
+		}))
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/operations"
+	"grpc-go-fx/internal/replication"
+)
+
+func TestOutbox_PublishesServiceWrites(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		OutboxPublisher: "file",
+		OutboxPath:      filepath.Join(dir, "outbox.log"),
+		OutboxFile:      filepath.Join(dir, "events.jsonl"),
+	}
+	lc := &stubLifecycle{}
+	ob := NewOutbox(cfg)
+	pub, err := NewOutboxPublisher(lc, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewProductRepository(lc, cfg, nil, ob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewProductServiceFromConfig(lc, cfg, repo, operations.NewRegistry(0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterOutboxRelay(lc, cfg, ob, pub, repo); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range lc.hooks {
+		if h.OnStart != nil {
+			if err := h.OnStart(ctx); err != nil {
+				t.Fatalf("OnStart: %v", err)
+			}
+		}
+	}
+	defer func() {
+		for i := len(lc.hooks) - 1; i >= 0; i-- {
+			if h := lc.hooks[i]; h.OnStop != nil {
+				h.OnStop(ctx)
+			}
+		}
+	}()
+	if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Id: "lamp", Name: "Desk lamp", Price: 12}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The seed catalog's three products, then the lamp.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(cfg.OutboxFile)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) == 4 {
+			if !strings.Contains(lines[3], `"type":"product.v1.created"`) || !strings.Contains(lines[3], `"subject":"lamp"`) {
+				t.Fatalf("last event %s", lines[3])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("published %d events: %s", len(lines), data)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if s := ob.Stats(); s.Delivered != 4 {
+		t.Fatalf("outbox stats %+v", s)
+	}
+}
+
+func TestNewOutbox(t *testing.T) {
+	if NewOutbox(&config.Config{}) != nil {
+		t.Fatal("outbox without a publisher")
+	}
+	if NewOutbox(&config.Config{OutboxPublisher: "stdout", ReplicationRole: replication.RoleFollower}) != nil {
+		t.Fatal("outbox on a follower")
+	}
+	if _, err := NewOutboxPublisher(&stubLifecycle{}, &config.Config{OutboxPublisher: "pigeon"}); err == nil {
+		t.Fatal("expected an error for an unknown publisher")
+	}
+	if _, err := NewOutboxPublisher(&stubLifecycle{}, &config.Config{OutboxPublisher: "webhook"}); err == nil {
+		t.Fatal("expected an error for a webhook without a URL")
+	}
+}
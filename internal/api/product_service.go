@@ -2,63 +2,626 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
 	"sync"
 
+	"grpc-go-fx/internal/audit"
 	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/fieldmask"
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/fixtures"
 	"grpc-go-fx/internal/generated/product"
+	replicationpb "grpc-go-fx/internal/generated/product/replication"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+	"grpc-go-fx/internal/interceptor"
+	"grpc-go-fx/internal/operations"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/repository"
+	"grpc-go-fx/internal/schema"
+	"grpc-go-fx/internal/similarity"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"go.uber.org/fx"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
-// ProductService implements product.ProductServiceServer with in-memory storage.
+// maxLabelLength bounds the length of a single tag or category.
+const maxLabelLength = 64
+
+// maxPrice bounds prices, leaving every stored price well inside the range of
+// the int64 units of a product.v2 Money.
+const maxPrice = 1e15
+
+// ProductService implements product.ProductServiceServer on top of a
+// repository.ProductRepository.
 type ProductService struct {
 	product.UnimplementedProductServiceServer
-	mu    sync.RWMutex
-	store map[string]*product.Product
+	repo repository.ProductRepository
+	// mu is held for reading by writes and for writing by operations on the
+	// whole catalog (loading, seeding, restores and backups), which must not
+	// overlap a write. Writes of the same product are serialized by ids, so
+	// that a lookup and the write that depends on it are atomic, while
+	// writes of different products run concurrently. Reads take neither;
+	// the repository keeps them consistent on its own.
+	mu  sync.RWMutex
+	ids idLocks
+	// defsMu serializes writes of attribute definitions, so that the schema
+	// registers them in the order the repository stores them.
+	defsMu sync.Mutex
+	schema *schema.Registry
+	// indexMu guards the derived state below. It is held only while the
+	// state is read or updated, never across repository calls.
+	indexMu sync.RWMutex
+	// stats is maintained on every write when incremental stats are enabled; nil otherwise.
+	stats *catalogAggregates
+	// similar holds the text vectors and labels GetSimilarProducts scores with; it is updated on every write.
+	similar *similarity.Index
+	ops     *operations.Registry
+	audit   *audit.Log
+}
+
+// Option configures a ProductService.
+type Option func(*ProductService)
+
+// WithIncrementalStats keeps catalog statistics up to date on every write, so
+// unfiltered GetCatalogStats calls with the default histogram avoid a full scan.
+func WithIncrementalStats() Option {
+	return func(s *ProductService) { s.stats = newCatalogAggregates(defaultHistogramBounds) }
+}
+
+// WithSimilarityWeights scores GetSimilarProducts results with w instead of similarity.DefaultWeights.
+func WithSimilarityWeights(w similarity.Weights) Option {
+	return func(s *ProductService) { s.similar = similarity.NewIndex(w) }
+}
+
+// WithOperations runs bulk RPCs on reg instead of a registry private to the service.
+func WithOperations(reg *operations.Registry) Option {
+	return func(s *ProductService) { s.ops = reg }
+}
+
+// WithAuditLog records every mutation in log instead of an in-memory log private to the service.
+func WithAuditLog(log *audit.Log) Option {
+	return func(s *ProductService) { s.audit = log }
+}
+
+// NewProductService creates a ProductService over an in-memory repository
+// holding the built-in sample catalog, fixtures.Sample.
+func NewProductService(opts ...Option) *ProductService {
+	// Loading cannot fail for the in-memory repository.
+	s, _ := NewProductServiceWithRepository(context.Background(), repository.NewMemory(fixtures.Sample()...), opts...)
+	return s
+}
+
+// NewProductServiceWithRepository creates a ProductService storing products in
+// repo. The derived state (similarity index and, if enabled, statistics) is
+// built from the products already in repo.
+func NewProductServiceWithRepository(ctx context.Context, repo repository.ProductRepository, opts ...Option) (*ProductService, error) {
+	s := newProductService(repo, opts)
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newProductService(repo repository.ProductRepository, opts []Option) *ProductService {
+	s := &ProductService{repo: repo, schema: schema.NewRegistry(), similar: similarity.NewIndex(similarity.DefaultWeights)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.ops == nil {
+		s.ops = operations.NewRegistry(operations.DefaultRetention)
+	}
+	if s.audit == nil {
+		s.audit, _ = audit.NewLog(nil, 0)
+	}
+	return s
+}
+
+// load registers the attribute definitions stored in the repository and
+// builds the derived state from its products.
+func (s *ProductService) load(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defs, err := s.repo.AttributeDefinitions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, def := range defs {
+		if err := s.schema.Put(def); err != nil {
+			return fmt.Errorf("stored attribute definition %q: %w", def.GetName(), err)
+		}
+	}
+	return s.scan(ctx, "", func(p *product.Product) bool {
+		s.index(nil, p)
+		return true
+	})
 }
 
-// NewProductService creates a ProductService with seeded product data.
-func NewProductService() *ProductService {
-	store := map[string]*product.Product{
-		"prod-1": {Id: "prod-1", Name: "Widget A", Description: "A useful widget", Price: 9.99},
-		"prod-2": {Id: "prod-2", Name: "Gadget B", Description: "A handy gadget", Price: 19.99},
-		"prod-3": {Id: "prod-3", Name: "Gizmo C", Description: "A small gizmo", Price: 4.99},
+// NewProductServiceFromConfig creates a ProductService with options taken from cfg
+// that stores products in repo, runs bulk operations on ops and records mutations in log.
+// The seed catalog (cfg.SeedPath, or fixtures.Sample if empty) is read and
+// validated here; on OnStart, after the repository has been opened, the
+// derived state is built and the catalog seeded according to cfg.SeedMode.
+// Replication followers are not seeded: their catalog comes from the leader.
+func NewProductServiceFromConfig(lc fx.Lifecycle, cfg *config.Config, repo repository.ProductRepository, ops *operations.Registry, log *audit.Log) (*ProductService, error) {
+	opts := []Option{WithOperations(ops), WithAuditLog(log)}
+	if cfg.IncrementalStats {
+		opts = append(opts, WithIncrementalStats())
+	}
+	if cfg.SimilarityWeights != (similarity.Weights{}) {
+		opts = append(opts, WithSimilarityWeights(cfg.SimilarityWeights))
 	}
-	return &ProductService{store: store}
+	seed, err := loadSeed(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := newProductService(repo, opts)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if err := s.load(ctx); err != nil {
+				return err
+			}
+			if cfg.ReplicationRole == replication.RoleFollower {
+				return nil
+			}
+			_, err := s.Seed(ctx, seed, cfg.SeedMode)
+			return err
+		},
+	})
+	return s, nil
 }
 
 // GetProduct returns a product by ID.
 func (s *ProductService) GetProduct(ctx context.Context, req *product.GetProductRequest) (*product.Product, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	if p, ok := s.store[req.GetId()]; ok {
-		return p, nil
+	mask, err := compileReadMask(&product.Product{}, req.GetReadMask())
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil // not found: return empty (or use status.NotFound in production)
+	p, err := s.get(ctx, req.GetId())
+	if err != nil || p == nil {
+		return nil, err // not found: return empty (or use status.NotFound in production)
+	}
+	mask.Apply(withETag(p))
+	return p, nil
 }
 
-// ListProducts returns products up to the given limit.
+// ListProducts returns products matching the filter, ordered by ID, up to the given limit.
+// When facets are requested they are counted over every matching product.
+// Returned products are pruned to the read mask, if any.
 func (s *ProductService) ListProducts(ctx context.Context, req *product.ListProductsRequest) (*product.ListProductsResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	f, err := s.parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	facets, err := newFacetCounter(req.GetFacets())
+	if err != nil {
+		return nil, err
+	}
+	mask, err := compileReadMask(&product.Product{}, req.GetReadMask())
+	if err != nil {
+		return nil, err
+	}
+	order, err := repository.ParseOrder(req.GetOrderBy())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid order_by: %v", err)
+	}
+	// Reads only the repository, whose queries are consistent on their own,
+	// so listings do not wait for writes to the derived state.
 	limit := req.GetLimit()
 	if limit <= 0 {
 		limit = 10
 	}
+	q := repository.Query{Filter: f, Order: order, Limit: int(limit)}
+	if facets != nil {
+		// Facets count every match, not only the returned page.
+		q.Limit = 0
+	}
 	var list []*product.Product
-	for _, p := range s.store {
-		list = append(list, p)
-		if int32(len(list)) >= limit {
-			break
+	err = s.query(ctx, q, func(p *product.Product) bool {
+		if facets != nil {
+			facets.add(p)
+		}
+		if int32(len(list)) < limit {
+			mask.Apply(withETag(p))
+			list = append(list, p)
+			return true
+		}
+		return facets != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &product.ListProductsResponse{Products: list}
+	if facets != nil {
+		resp.Facets = facets.result()
+	}
+	return resp, nil
+}
+
+// CreateProduct validates and stores a new product. An empty ID is replaced by a generated one.
+func (s *ProductService) CreateProduct(ctx context.Context, req *product.CreateProductRequest) (*product.Product, error) {
+	p := req.GetProduct()
+	if p == nil {
+		return nil, status.Error(codes.InvalidArgument, "product is required")
+	}
+	if p.GetId() == "" {
+		p.Id = newProductID()
+	}
+	if err := s.validateProduct(p); err != nil {
+		return nil, err
+	}
+	defer s.lockProducts(p.GetId())()
+	if err := s.put(ctx, audit.OriginFromContext(ctx), nil, p); err != nil {
+		return nil, err
+	}
+	return withETag(p), nil
+}
+
+// UpdateProduct validates and replaces an existing product. If product.etag is
+// set, the stored product must still have that etag.
+func (s *ProductService) UpdateProduct(ctx context.Context, req *product.UpdateProductRequest) (*product.Product, error) {
+	p := req.GetProduct()
+	if p.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "product.id is required")
+	}
+	if err := s.validateProduct(p); err != nil {
+		return nil, err
+	}
+	defer s.lockProducts(p.GetId())()
+	old, err := s.get(ctx, p.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, status.Errorf(codes.NotFound, "product %q not found", p.GetId())
+	}
+	if err := checkETag(old, p.GetEtag()); err != nil {
+		return nil, err
+	}
+	if err := s.put(ctx, audit.OriginFromContext(ctx), old, p); err != nil {
+		return nil, err
+	}
+	return withETag(p), nil
+}
+
+// DeleteProduct removes a product by ID.
+func (s *ProductService) DeleteProduct(ctx context.Context, req *product.DeleteProductRequest) (*emptypb.Empty, error) {
+	defer s.lockProducts(req.GetId())()
+	old, err := s.get(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, status.Errorf(codes.NotFound, "product %q not found", req.GetId())
+	}
+	if err := s.put(ctx, audit.OriginFromContext(ctx), old, nil); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// put replaces old with p in the repository and keeps derived state in sync.
+// A nil old means p is new, and put fails with AlreadyExists if the
+// repository has a product with its ID after all; a nil p deletes old. The etag of p is cleared, as
+// etags are not stored. The change is recorded in the audit log once it is
+// committed. Callers must hold the lock of the product (lockProducts).
+func (s *ProductService) put(ctx context.Context, origin audit.Origin, old, p *product.Product) error {
+	if p != nil {
+		p.Etag = ""
+	}
+	err := s.repo.Batch(ctx, func(tx repository.Tx) error {
+		switch {
+		case old == nil:
+			return tx.Create(ctx, p)
+		case p != nil:
+			return tx.Put(ctx, p)
 		}
+		return tx.Delete(ctx, old.GetId())
+	})
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return status.Errorf(codes.AlreadyExists, "product %q already exists", p.GetId())
 	}
-	return &product.ListProductsResponse{Products: list}, nil
+	if err != nil {
+		return storageError(err)
+	}
+	s.index(old, p)
+	if err := s.audit.Commit(s.audit.Prepare(origin, old, p)); err != nil {
+		return auditError(err)
+	}
+	return nil
 }
 
-// NewGRPCServer creates a gRPC server with the Product service registered.
-func NewGRPCServer(cfg *config.Config, svc product.ProductServiceServer) *grpc.Server {
-	srv := grpc.NewServer()
-	product.RegisterProductServiceServer(srv, svc)
-	return srv
+// index replaces old with p in the derived state; either may be nil. Callers
+// must hold the lock of the product (lockProducts) or s.mu for writing.
+func (s *ProductService) index(old, p *product.Product) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if old != nil {
+		s.similar.Remove(old.GetId())
+		if s.stats != nil {
+			s.stats.remove(old)
+		}
+	}
+	if p != nil {
+		s.similar.Add(p)
+		if s.stats != nil {
+			s.stats.add(p)
+		}
+	}
 }
 
+// PutAttributeDefinition stores and registers or replaces a custom attribute
+// definition.
+func (s *ProductService) PutAttributeDefinition(ctx context.Context, req *product.PutAttributeDefinitionRequest) (*product.AttributeDefinition, error) {
+	if req.GetDefinition() == nil {
+		return nil, status.Error(codes.InvalidArgument, "definition is required")
+	}
+	if err := s.putDefinitions(ctx, req.GetDefinition()); err != nil {
+		return nil, err
+	}
+	def, _ := s.schema.Get(req.GetDefinition().GetName())
+	return def, nil
+}
+
+// putDefinitions validates defs, stores them in the repository and registers
+// them, one at a time.
+func (s *ProductService) putDefinitions(ctx context.Context, defs ...*product.AttributeDefinition) error {
+	s.defsMu.Lock()
+	defer s.defsMu.Unlock()
+	for _, def := range defs {
+		if err := schema.Check(def); err != nil {
+			return toStatus(err)
+		}
+		if err := s.repo.PutAttributeDefinition(ctx, def); err != nil {
+			return storageError(err)
+		}
+		s.schema.Put(def)
+	}
+	return nil
+}
+
+// ListAttributeDefinitions returns the attribute schema.
+func (s *ProductService) ListAttributeDefinitions(ctx context.Context, req *product.ListAttributeDefinitionsRequest) (*product.ListAttributeDefinitionsResponse, error) {
+	return &product.ListAttributeDefinitionsResponse{Definitions: s.schema.List()}, nil
+}
+
+// ListAuditEvents returns recorded mutations filtered by product, actor and time range.
+func (s *ProductService) ListAuditEvents(ctx context.Context, req *product.ListAuditEventsRequest) (*product.ListAuditEventsResponse, error) {
+	return s.audit.List(req)
+}
+
+// validateProduct checks the core fields of p and its attributes against the schema.
+func (s *ProductService) validateProduct(p *product.Product) error {
+	if err := validateFields(p); err != nil {
+		return err
+	}
+	if err := s.schema.Validate(p.GetAttributes()); err != nil {
+		return toStatus(err)
+	}
+	return nil
+}
+
+// validateFields checks the core fields of p: name, price, tags and categories.
+func validateFields(p *product.Product) error {
+	if p.GetName() == "" {
+		return status.Error(codes.InvalidArgument, "product.name is required")
+	}
+	if !(p.GetPrice() >= 0) || math.IsInf(p.GetPrice(), 0) {
+		return status.Error(codes.InvalidArgument, "product.price must be a finite, non-negative number")
+	}
+	if p.GetPrice() > maxPrice {
+		return status.Errorf(codes.InvalidArgument, "product.price must be at most %g", maxPrice)
+	}
+	if err := checkLabels("product.tags", p.GetTags()); err != nil {
+		return err
+	}
+	return checkLabels("product.categories", p.GetCategories())
+}
+
+// checkLabels validates tags and categories: unique, non-empty and bounded in length.
+func checkLabels(field string, labels []string) error {
+	seen := make(map[string]bool, len(labels))
+	for _, l := range labels {
+		if l == "" || len(l) > maxLabelLength || seen[l] {
+			return status.Errorf(codes.InvalidArgument, "%s must be unique, non-empty and at most %d bytes", field, maxLabelLength)
+		}
+		seen[l] = true
+	}
+	return nil
+}
+
+// parseFilter parses a ListProducts filter and type-checks it against the
+// built-in fields and the attribute schema, so that every referenced
+// attribute is defined and compared with a value of its type.
+func (s *ProductService) parseFilter(expr string) (*filter.Filter, error) {
+	f, err := filter.Parse(expr)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := f.Check(s.attributeType); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return f, nil
+}
+
+// attributeType returns the filter type of the defined attribute name.
+func (s *ProductService) attributeType(name string) (filter.Type, bool) {
+	def, ok := s.schema.Get(name)
+	if !ok {
+		return 0, false
+	}
+	switch def.GetType() {
+	case product.AttributeType_ATTRIBUTE_TYPE_NUMBER:
+		return filter.TypeNumber, true
+	case product.AttributeType_ATTRIBUTE_TYPE_BOOL:
+		return filter.TypeBool, true
+	}
+	return filter.TypeString, true
+}
+
+// lockProducts locks s for a write of the products with the given IDs and
+// returns the function that unlocks it.
+func (s *ProductService) lockProducts(ids ...string) (unlock func()) {
+	s.mu.RLock()
+	unlockIDs := s.ids.lock(ids...)
+	return func() {
+		unlockIDs()
+		s.mu.RUnlock()
+	}
+}
+
+// idLockStripes is the number of locks idLocks spreads product IDs over.
+const idLockStripes = 256
+
+// idLocks serializes writes of the same product without serializing writes
+// of different products, by hashing IDs onto a fixed set of locks.
+type idLocks [idLockStripes]sync.Mutex
+
+// lock locks the stripes of ids in stripe order, so that writes of several
+// products cannot deadlock, and returns the function that unlocks them.
+func (l *idLocks) lock(ids ...string) (unlock func()) {
+	stripes := make([]int, len(ids))
+	for i, id := range ids {
+		h := fnv.New32a()
+		h.Write([]byte(id))
+		stripes[i] = int(h.Sum32() % idLockStripes)
+	}
+	slices.Sort(stripes)
+	stripes = slices.Compact(stripes)
+	for _, i := range stripes {
+		l[i].Lock()
+	}
+	return func() {
+		for _, i := range stripes {
+			l[i].Unlock()
+		}
+	}
+}
+
+// get returns the stored product with the given ID, or nil if there is none.
+func (s *ProductService) get(ctx context.Context, id string) (*product.Product, error) {
+	p, err := s.repo.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return p, nil
+}
+
+// scanPageSize is the number of products read from the repository at a time by scan.
+const scanPageSize = 256
+
+// scan calls fn with every stored product whose ID is greater than after, in
+// ascending ID order, until fn returns false.
+func (s *ProductService) scan(ctx context.Context, after string, fn func(p *product.Product) bool) error {
+	return scanList(ctx, s.repo, after, fn)
+}
+
+// lister lists products in pages, like repository.ProductRepository and
+// repository.View.
+type lister interface {
+	List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error)
+}
+
+// scanList is scan over the products of l.
+func scanList(ctx context.Context, l lister, after string, fn func(p *product.Product) bool) error {
+	for {
+		page, next, err := l.List(ctx, after, scanPageSize)
+		if err != nil {
+			return storageError(err)
+		}
+		for _, p := range page {
+			if !fn(p) {
+				return nil
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		after = next
+	}
+}
+
+// query calls fn with every stored product matching q, in q's order, until fn
+// returns false. It uses the repository's indexes when it has them.
+func (s *ProductService) query(ctx context.Context, q repository.Query, fn func(p *product.Product) bool) error {
+	if _, err := repository.Select(ctx, s.repo, q, fn); err != nil {
+		return storageError(err)
+	}
+	return nil
+}
+
+// compileReadMask compiles a request's read_mask against the message type of
+// resource. Unknown paths are InvalidArgument.
+func compileReadMask(resource proto.Message, mask *fieldmaskpb.FieldMask) (*fieldmask.Mask, error) {
+	m, err := fieldmask.Compile(resource.ProtoReflect().Descriptor(), mask)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid read_mask: %v", err)
+	}
+	return m, nil
+}
+
+// storageError converts a repository error to a status. Errors that already
+// carry a status, such as audit failures, are returned unchanged.
+func storageError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Errorf(codes.Internal, "storage: %v", err)
+}
+
+// auditError reports that committed changes could not be recorded in the
+// audit log. The changes stand, so the error says so.
+func auditError(err error) error {
+	return status.Errorf(codes.Internal, "the change was applied, but its audit event was not recorded: %s", status.Convert(err).Message())
+}
+
+// toStatus converts schema validation errors to InvalidArgument and everything else to Internal.
+func toStatus(err error) error {
+	var verr *schema.ValidationError
+	if errors.As(err, &verr) {
+		return status.Error(codes.InvalidArgument, verr.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func newProductID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "prod-" + hex.EncodeToString(b)
+}
+
+// NewGRPCServer creates a gRPC server with both versions of the Product service, the
+// Admin, Replication and Operations services registered. The unary and stream
+// interceptors and the server options contributed to the value groups
+// "unary_interceptors", "stream_interceptors" and "server_options" are
+// applied in their Order (see package interceptor).
+func NewGRPCServer(svc product.ProductServiceServer, svcV2 productv2.ProductServiceServer, admin product.AdminServiceServer, repl *replication.Server, ops longrunningpb.OperationsServer, unary []interceptor.Unary, stream []interceptor.Stream, opts []interceptor.Option) (*grpc.Server, error) {
+	serverOpts, err := interceptor.ServerOptions(unary, stream, opts)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(serverOpts...)
+	product.RegisterProductServiceServer(srv, svc)
+	productv2.RegisterProductServiceServer(srv, svcV2)
+	product.RegisterAdminServiceServer(srv, admin)
+	replicationpb.RegisterReplicationServiceServer(srv, repl)
+	longrunningpb.RegisterOperationsServer(srv, ops)
+	return srv, nil
+}
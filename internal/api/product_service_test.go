@@ -1,18 +1,71 @@
 package api
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
 	"net"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/backup"
 	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/eventsource"
+	"grpc-go-fx/internal/fixtures"
 	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/idempotency"
+	"grpc-go-fx/internal/interceptor"
+	"grpc-go-fx/internal/operations"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/repository"
 
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "modernc.org/sqlite"
 )
 
+// stored reads a product straight from the service's repository, or returns
+// nil if there is none.
+func stored(t *testing.T, svc *ProductService, id string) *product.Product {
+	t.Helper()
+	p, err := svc.repo.Get(context.Background(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("repository Get(%q): %v", id, err)
+	}
+	return p
+}
+
+// storedCount returns the number of products in the service's repository.
+func storedCount(t *testing.T, svc *ProductService) int {
+	t.Helper()
+	all, _, err := svc.repo.List(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("repository List: %v", err)
+	}
+	return len(all)
+}
+
 func TestNewProductServiceSeedsStore(t *testing.T) {
 	svc := NewProductService()
 	if svc == nil {
@@ -20,13 +73,13 @@ func TestNewProductServiceSeedsStore(t *testing.T) {
 	}
 
 	// The service should be seeded with three known products.
-	if got, want := len(svc.store), 3; got != want {
+	if got, want := storedCount(t, svc), 3; got != want {
 		t.Fatalf("unexpected number of seeded products: got %d, want %d", got, want)
 	}
 
 	for _, id := range []string{"prod-1", "prod-2", "prod-3"} {
-		p, ok := svc.store[id]
-		if !ok {
+		p := stored(t, svc, id)
+		if p == nil {
 			t.Fatalf("expected product with id %q to be present", id)
 		}
 		if p.GetId() != id {
@@ -80,13 +133,13 @@ func TestProductServiceListProducts_DefaultLimit(t *testing.T) {
 	}
 
 	products := resp.GetProducts()
-	if got, want := len(products), len(svc.store); got != want {
+	if got, want := len(products), storedCount(t, svc); got != want {
 		t.Fatalf("unexpected number of products: got %d, want %d", got, want)
 	}
 
 	// Ensure all returned IDs exist in the store.
 	for _, p := range products {
-		if _, ok := svc.store[p.GetId()]; !ok {
+		if stored(t, svc, p.GetId()) == nil {
 			t.Fatalf("ListProducts returned unknown product id %q", p.GetId())
 		}
 	}
@@ -110,25 +163,68 @@ func TestProductServiceListProducts_WithLimit(t *testing.T) {
 	}
 
 	for _, p := range products {
-		if _, ok := svc.store[p.GetId()]; !ok {
+		if stored(t, svc, p.GetId()) == nil {
 			t.Fatalf("ListProducts returned unknown product id %q", p.GetId())
 		}
 	}
 }
 
-func TestNewGRPCServerRegistersProductService(t *testing.T) {
-	cfg := &config.Config{}
-	svc := NewProductService()
-
-	srv := NewGRPCServer(cfg, svc)
-	if srv == nil {
-		t.Fatal("expected non-nil gRPC server")
+// newGRPCServer builds the gRPC server of svc and repl with the API's
+// interceptors configured by cfg, and those in unary, as Module does.
+func newGRPCServer(t *testing.T, cfg *config.Config, svc *ProductService, repl *replication.Server, unary ...interceptor.Unary) *grpc.Server {
+	t.Helper()
+	srv, err := NewGRPCServer(svc, NewProductServiceV2(svc), NewAdminService(svc, backup.NewStore(t.TempDir())), repl, operations.NewServer(operations.NewRegistry(0)),
+		append(UnaryInterceptors(cfg, zap.NewNop(), repl, idempotency.NewStore(0, 0)), unary...), StreamInterceptors(cfg, zap.NewNop()), nil)
+	if err != nil {
+		t.Fatal(err)
 	}
+	return srv
+}
+
+func TestNewGRPCServerRegistersProductService(t *testing.T) {
+	srv := newGRPCServer(t, &config.Config{}, NewProductService(), replication.NewStandaloneServer())
 
 	info := srv.GetServiceInfo()
 	if _, ok := info["product.v1.ProductService"]; !ok {
 		t.Fatalf("ProductService not registered on gRPC server; services: %v", info)
 	}
+	if _, ok := info["product.v2.ProductService"]; !ok {
+		t.Fatalf("v2 ProductService not registered on gRPC server; services: %v", info)
+	}
+	if _, ok := info["product.v1.AdminService"]; !ok {
+		t.Fatalf("AdminService not registered on gRPC server; services: %v", info)
+	}
+	if _, ok := info["product.replication.v1.ReplicationService"]; !ok {
+		t.Fatalf("ReplicationService not registered on gRPC server; services: %v", info)
+	}
+	if _, ok := info["google.longrunning.Operations"]; !ok {
+		t.Fatalf("Operations service not registered on gRPC server; services: %v", info)
+	}
+}
+
+func TestNewGRPCServer_ChainsContributedInterceptors(t *testing.T) {
+	var seen []string
+	conn := serveInProcess(t, NewProductService(), replication.NewStandaloneServer(),
+		interceptor.Unary{Order: interceptor.OrderValidate - 1, Name: "before-validate", Interceptor: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			seen = append(seen, info.FullMethod)
+			return handler(ctx, req)
+		}},
+		interceptor.Unary{Order: interceptor.OrderIdempotency + 1, Name: "panics", Interceptor: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			panic("boom")
+		}},
+	)
+	client := product.NewProductServiceClient(conn)
+	ctx := context.Background()
+
+	if _, err := client.GetProduct(ctx, &product.GetProductRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("invalid request: %v", err)
+	}
+	if _, err := client.GetProduct(ctx, &product.GetProductRequest{Id: "p1"}); status.Code(err) != codes.Internal {
+		t.Fatalf("panicking handler: %v", err)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("interceptor before validation saw %v", seen)
+	}
 }
 
 type stubLifecycle struct {
@@ -139,6 +235,16 @@ func (s *stubLifecycle) Append(h fx.Hook) {
 	s.hooks = append(s.hooks, h)
 }
 
+// stubShutdowner records the shutdowns requested of it.
+type stubShutdowner struct {
+	calls []fx.ShutdownOption
+}
+
+func (s *stubShutdowner) Shutdown(opts ...fx.ShutdownOption) error {
+	s.calls = append(s.calls, opts...)
+	return nil
+}
+
 func TestRegisterGRPCLifecycle_StartsAndStopsServer(t *testing.T) {
 	// Use an ephemeral port on localhost to avoid collisions.
 	lc := &stubLifecycle{}
@@ -148,7 +254,8 @@ func TestRegisterGRPCLifecycle_StartsAndStopsServer(t *testing.T) {
 	srv := grpc.NewServer()
 	product.RegisterProductServiceServer(srv, svc)
 
-	RegisterGRPCLifecycle(lc, srv, cfg)
+	shutdown := &stubShutdowner{}
+	RegisterGRPCLifecycle(lc, shutdown, srv, replication.NewStandaloneServer(), cfg, zap.NewNop())
 	if len(lc.hooks) != 1 {
 		t.Fatalf("expected 1 lifecycle hook, got %d", len(lc.hooks))
 	}
@@ -175,5 +282,725 @@ func TestRegisterGRPCLifecycle_StartsAndStopsServer(t *testing.T) {
 	if err := hook.OnStop(context.Background()); err != nil {
 		t.Fatalf("OnStop returned error: %v", err)
 	}
+	if len(shutdown.calls) != 0 {
+		t.Fatalf("a graceful stop shut the app down: %v", shutdown.calls)
+	}
 }
 
+func TestServe_ShutsDownTheAppWhenTheServerFails(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Accepting on a closed listener fails, and so does Serve.
+	lis.Close()
+	shutdown := &stubShutdowner{}
+	serve(grpc.NewServer(), lis, shutdown, zap.NewNop())
+	if len(shutdown.calls) != 1 || !reflect.DeepEqual(shutdown.calls[0], fx.ExitCode(1)) {
+		t.Fatalf("shutdowns %v, want one with exit code 1", shutdown.calls)
+	}
+
+	srv := grpc.NewServer()
+	srv.Stop()
+	shutdown = &stubShutdowner{}
+	lis, _ = net.Listen("tcp", "127.0.0.1:0")
+	serve(srv, lis, shutdown, zap.NewNop())
+	if len(shutdown.calls) != 0 {
+		t.Fatalf("serving a stopped server shut the app down: %v", shutdown.calls)
+	}
+}
+
+func defineVoltage(t *testing.T, svc *ProductService) {
+	t.Helper()
+	_, err := svc.PutAttributeDefinition(context.Background(), &product.PutAttributeDefinitionRequest{
+		Definition: &product.AttributeDefinition{
+			Name: "voltage",
+			Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER,
+			Constraints: &product.AttributeDefinition_NumberConstraints{
+				NumberConstraints: &product.NumberConstraints{Min: proto.Float64(0), Max: proto.Float64(400)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PutAttributeDefinition returned error: %v", err)
+	}
+}
+
+func voltage(v float64) map[string]*product.AttributeValue {
+	return map[string]*product.AttributeValue{"voltage": {Kind: &product.AttributeValue_NumberValue{NumberValue: v}}}
+}
+
+func TestProductService_LoadsStoredAttributeDefinitions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	svc, _ := NewProductServiceWithRepository(ctx, repo)
+	defineVoltage(t, svc)
+
+	restarted, err := NewProductServiceWithRepository(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = restarted.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Name: "Drill", Price: 50, Attributes: voltage(999)}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("CreateProduct with an out-of-range stored attribute: %v, want InvalidArgument", err)
+	}
+}
+
+func TestProductServiceCreateProduct_ValidatesAttributes(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	defineVoltage(t, svc)
+
+	_, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Name: "Drill", Price: 50, Attributes: voltage(999)}})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for out-of-range attribute, got %v (%v)", got, err)
+	}
+
+	_, err = svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{
+		Name: "Drill", Price: 50,
+		Attributes: map[string]*product.AttributeValue{"color": {Kind: &product.AttributeValue_StringValue{StringValue: "red"}}},
+	}})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for undefined attribute, got %v (%v)", got, err)
+	}
+
+	created, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Name: "Drill", Price: 50, Attributes: voltage(220)}})
+	if err != nil {
+		t.Fatalf("CreateProduct returned error: %v", err)
+	}
+	if created.GetId() == "" {
+		t.Fatal("expected a generated product id")
+	}
+	got, _ := svc.GetProduct(ctx, &product.GetProductRequest{Id: created.GetId()})
+	if got.GetAttributes()["voltage"].GetNumberValue() != 220 {
+		t.Fatalf("stored product has unexpected attributes: %v", got.GetAttributes())
+	}
+}
+
+func TestProductServiceCreateProduct_DuplicateID(t *testing.T) {
+	svc := NewProductService()
+	_, err := svc.CreateProduct(context.Background(), &product.CreateProductRequest{Product: &product.Product{Id: "prod-1", Name: "Dup"}})
+	if got := status.Code(err); got != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists, got %v", got)
+	}
+}
+
+func TestProductServiceUpdateAndDeleteProduct(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+
+	updated, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "prod-1", Name: "Widget A2", Price: 11}})
+	if err != nil {
+		t.Fatalf("UpdateProduct returned error: %v", err)
+	}
+	if updated.GetName() != "Widget A2" {
+		t.Fatalf("unexpected name after update: %q", updated.GetName())
+	}
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "missing", Name: "x"}}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound updating missing product, got %v", err)
+	}
+
+	if _, err := svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "prod-1"}); err != nil {
+		t.Fatalf("DeleteProduct returned error: %v", err)
+	}
+	if stored(t, svc, "prod-1") != nil {
+		t.Fatal("prod-1 still present after delete")
+	}
+	if _, err := svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "prod-1"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound deleting twice, got %v", err)
+	}
+}
+
+func TestProductServiceUpdateProduct_ConcurrentETagChecksAreAtomic(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	etag := withETag(stored(t, svc, "prod-1")).GetEtag()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "prod-1", Name: fmt.Sprint("Widget ", i), Etag: etag}})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else if status.Code(err) != codes.FailedPrecondition {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Fatalf("%d updates with the same etag succeeded, want 1", succeeded)
+	}
+}
+
+func TestProductService_ConcurrentWritesKeepDerivedStateConsistent(t *testing.T) {
+	svc := NewProductService(WithIncrementalStats())
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				id := fmt.Sprintf("w%d-%d", w%4, i%10)
+				p := &product.Product{Id: id, Name: "Item " + id, Price: float64(w*50 + i)}
+				if i%3 == 2 {
+					svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: id})
+					continue
+				}
+				if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: p}); status.Code(err) == codes.NotFound {
+					svc.CreateProduct(ctx, &product.CreateProductRequest{Product: p})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	incremental, err := svc.GetCatalogStats(ctx, &product.GetCatalogStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A filter matching every product forces a scan of the repository.
+	scanned, err := svc.GetCatalogStats(ctx, &product.GetCatalogStatsRequest{Filter: "price >= 0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(incremental, scanned) {
+		t.Fatalf("incremental stats %v differ from the catalog's %v", incremental, scanned)
+	}
+}
+
+func TestProductServiceListProducts_FilterByAttribute(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	defineVoltage(t, svc)
+	for id, v := range map[string]float64{"drill-eu": 230, "drill-us": 110} {
+		if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Id: id, Name: "Drill", Price: 50, Attributes: voltage(v)}}); err != nil {
+			t.Fatalf("CreateProduct(%q) returned error: %v", id, err)
+		}
+	}
+
+	resp, err := svc.ListProducts(ctx, &product.ListProductsRequest{Filter: "attributes.voltage > 200"})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if got := resp.GetProducts(); len(got) != 1 || got[0].GetId() != "drill-eu" {
+		t.Fatalf("unexpected filtered products: %v", got)
+	}
+
+	if _, err := svc.ListProducts(ctx, &product.ListProductsRequest{Filter: "attributes.color = red"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for undefined attribute in filter, got %v", err)
+	}
+	if _, err := svc.ListProducts(ctx, &product.ListProductsRequest{Filter: "price <"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for malformed filter, got %v", err)
+	}
+	for _, expr := range []string{`price = "abc"`, `name > 3`, `attributes.voltage = high`} {
+		if _, err := svc.ListProducts(ctx, &product.ListProductsRequest{Filter: expr}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument for mistyped filter %q, got %v", expr, err)
+		}
+	}
+}
+
+func TestProductServiceListProducts_OrderBy(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	all, err := svc.ListProducts(ctx, &product.ListProductsRequest{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := all.GetProducts()
+	slices.SortFunc(want, func(a, b *product.Product) int { return cmp.Compare(b.GetPrice(), a.GetPrice()) })
+
+	resp, err := svc.ListProducts(ctx, &product.ListProductsRequest{Limit: 3, OrderBy: "price desc"})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	got := resp.GetProducts()
+	if len(got) != 3 {
+		t.Fatalf("got %d products, want 3", len(got))
+	}
+	for i, p := range got {
+		if p.GetPrice() != want[i].GetPrice() {
+			t.Fatalf("product %d has price %v, want %v", i, p.GetPrice(), want[i].GetPrice())
+		}
+	}
+
+	if _, err := svc.ListProducts(ctx, &product.ListProductsRequest{OrderBy: "tags"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unknown order_by field, got %v", err)
+	}
+}
+
+func TestProductServiceMutationsAreAudited(t *testing.T) {
+	svc := NewProductService()
+	ctx := asActor(context.Background(), "alice")
+
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "prod-1", Name: "Widget A", Description: "A useful widget", Price: 12}}); err != nil {
+		t.Fatalf("UpdateProduct returned error: %v", err)
+	}
+	if _, err := svc.DeleteProduct(context.Background(), &product.DeleteProductRequest{Id: "prod-2"}); err != nil {
+		t.Fatalf("DeleteProduct returned error: %v", err)
+	}
+	op, err := svc.PurgeProducts(ctx, &product.PurgeProductsRequest{Filter: `id = "prod-3"`})
+	if err != nil {
+		t.Fatalf("PurgeProducts returned error: %v", err)
+	}
+	waitOperation(t, svc, op, &product.PurgeProductsResponse{})
+
+	resp, err := svc.ListAuditEvents(ctx, &product.ListAuditEventsRequest{})
+	if err != nil {
+		t.Fatalf("ListAuditEvents returned error: %v", err)
+	}
+	type summary struct {
+		actor, id string
+		action    product.AuditEvent_Action
+		changed   string
+	}
+	var got []summary
+	for _, e := range resp.GetEvents() {
+		got = append(got, summary{e.GetActor(), e.GetProductId(), e.GetAction(), strings.Join(e.GetChangedFields(), ",")})
+	}
+	want := []summary{
+		{"alice", "prod-1", product.AuditEvent_ACTION_UPDATE, "price"},
+		{audit.Anonymous, "prod-2", product.AuditEvent_ACTION_DELETE, "id,name,description,price"},
+		{"alice", "prod-3", product.AuditEvent_ACTION_DELETE, "id,name,description,price"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit events:\n got %v\nwant %v", got, want)
+	}
+
+	resp, _ = svc.ListAuditEvents(ctx, &product.ListAuditEventsRequest{Actor: "alice", ProductId: "prod-3"})
+	if len(resp.GetEvents()) != 1 {
+		t.Fatalf("expected one filtered event, got %v", resp.GetEvents())
+	}
+}
+
+// proxyAddr is the peer address of a trusted in-process proxy, such as the
+// gateway, which forwards the actors of its requests.
+type proxyAddr struct{}
+
+func (proxyAddr) Network() string     { return "pipe" }
+func (proxyAddr) String() string      { return "proxy" }
+func (proxyAddr) ForwardsActor() bool { return true }
+
+// asActor returns ctx carrying a request from actor, forwarded by a proxy.
+func asActor(ctx context.Context, actor string) context.Context {
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(audit.ActorMetadataKey, actor))
+	return peer.NewContext(ctx, &peer.Peer{Addr: proxyAddr{}})
+}
+
+type failingSink struct{}
+
+func (failingSink) Append([]*product.AuditEvent) error { return errors.New("disk full") }
+func (failingSink) Close() error                       { return nil }
+
+func TestProductServiceReportsUnauditedMutation(t *testing.T) {
+	log, err := audit.NewLog(failingSink{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewProductService(WithAuditLog(log))
+	_, err = svc.UpdateProduct(context.Background(), &product.UpdateProductRequest{Product: &product.Product{Id: "prod-1", Name: "Changed"}})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal, got %v", err)
+	}
+	// The audit event is written after the commit, so the update stands.
+	if got := stored(t, svc, "prod-1").GetName(); got != "Changed" {
+		t.Fatalf("update not applied: name=%q", got)
+	}
+	resp, err := svc.ListAuditEvents(context.Background(), &product.ListAuditEventsRequest{})
+	if err != nil || len(resp.GetEvents()) != 0 {
+		t.Fatalf("expected no listed events, got %v, %v", resp.GetEvents(), err)
+	}
+}
+
+// failingCommitRepo runs batches to completion but then fails to commit them.
+type failingCommitRepo struct {
+	repository.ProductRepository
+}
+
+func (r failingCommitRepo) Batch(ctx context.Context, fn func(tx repository.Tx) error) error {
+	return r.ProductRepository.Batch(ctx, func(tx repository.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return errors.New("commit failed")
+	})
+}
+
+func TestProductServiceDoesNotAuditFailedWrites(t *testing.T) {
+	svc := NewProductService()
+	svc.repo = failingCommitRepo{svc.repo}
+	ctx := context.Background()
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "prod-1", Name: "Changed"}}); status.Code(err) != codes.Internal {
+		t.Fatalf("UpdateProduct: expected Internal, got %v", err)
+	}
+	if _, err := svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "prod-2"}); status.Code(err) != codes.Internal {
+		t.Fatalf("DeleteProduct: expected Internal, got %v", err)
+	}
+	batch := &product.BatchUpdateProductsRequest{Changes: []*product.ProductChange{
+		{Kind: &product.ProductChange_Create{Create: &product.Product{Id: "new", Name: "New"}}},
+		{Kind: &product.ProductChange_Delete{Delete: "prod-3"}},
+	}}
+	if _, err := svc.BatchUpdateProducts(ctx, batch); status.Code(err) != codes.Internal {
+		t.Fatalf("BatchUpdateProducts: expected Internal, got %v", err)
+	}
+	if _, err := svc.restore(ctx, nil, false); status.Code(err) != codes.Internal {
+		t.Fatalf("restore: expected Internal, got %v", err)
+	}
+	mutations := []*storagepb.Mutation{{Op: &storagepb.Mutation_Delete{Delete: "prod-1"}}}
+	if err := svc.ApplyMutations(ctx, mutations); status.Code(err) != codes.Internal {
+		t.Fatalf("ApplyMutations: expected Internal, got %v", err)
+	}
+	if _, err := svc.Seed(ctx, []*product.Product{{Id: "seeded", Name: "Seeded"}}, fixtures.Upsert); status.Code(err) != codes.Internal {
+		t.Fatalf("Seed: expected Internal, got %v", err)
+	}
+	resp, err := svc.ListAuditEvents(ctx, &product.ListAuditEventsRequest{})
+	if err != nil || len(resp.GetEvents()) != 0 {
+		t.Fatalf("failed writes were audited: %v, %v", resp.GetEvents(), err)
+	}
+}
+
+func TestProductServiceReadMask(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"id", "name", "price"}}
+
+	got, err := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-1", ReadMask: mask})
+	if err != nil {
+		t.Fatalf("GetProduct returned error: %v", err)
+	}
+	if want := (&product.Product{Id: "prod-1", Name: "Widget A", Price: 9.99}); !proto.Equal(got, want) {
+		t.Fatalf("GetProduct with read_mask = %v, want %v", got, want)
+	}
+	if stored(t, svc, "prod-1").GetDescription() == "" {
+		t.Fatal("read_mask pruned the stored product")
+	}
+
+	resp, err := svc.ListProducts(ctx, &product.ListProductsRequest{ReadMask: mask})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	for _, p := range resp.GetProducts() {
+		if p.GetDescription() != "" || p.GetName() == "" {
+			t.Fatalf("ListProducts did not apply read_mask: %v", p)
+		}
+	}
+
+	bad := &fieldmaskpb.FieldMask{Paths: []string{"id", "colour"}}
+	if _, err := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-1", ReadMask: bad}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unknown path, got %v", err)
+	}
+	if _, err := svc.ListProducts(ctx, &product.ListProductsRequest{ReadMask: bad}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unknown path, got %v", err)
+	}
+}
+
+func TestProductService_ReturnedProductsDoNotAliasStorage(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+
+	got, err := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Name = "changed by caller"
+	list, err := svc.ListProducts(ctx, &product.ListProductsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list.GetProducts()[0].Price = -1
+	created, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Id: "prod-9", Name: "New", Price: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created.Name = "changed by caller"
+
+	if p := stored(t, svc, "prod-1"); p.GetName() != "Widget A" || p.GetPrice() != 9.99 {
+		t.Fatalf("stored product changed through a returned pointer: %v", p)
+	}
+	if p := stored(t, svc, "prod-9"); p.GetName() != "New" {
+		t.Fatalf("created product changed through the returned pointer: %v", p)
+	}
+}
+
+func TestNewProductServiceWithRepository_IndexesExistingProducts(t *testing.T) {
+	repo := repository.NewMemory(
+		&product.Product{Id: "a", Name: "Cordless drill", Price: 90},
+		&product.Product{Id: "b", Name: "Hammer drill", Price: 100},
+	)
+	svc, err := NewProductServiceWithRepository(context.Background(), repo, WithIncrementalStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	similar, err := svc.GetSimilarProducts(context.Background(), &product.GetSimilarProductsRequest{Id: "a"})
+	if err != nil || len(similar.GetResults()) != 1 || similar.GetResults()[0].GetProduct().GetId() != "b" {
+		t.Fatalf("GetSimilarProducts = %v, %v", similar, err)
+	}
+	stats, err := svc.GetCatalogStats(context.Background(), &product.GetCatalogStatsRequest{})
+	if err != nil || stats.GetOverall().GetCount() != 2 {
+		t.Fatalf("GetCatalogStats = %v, %v", stats, err)
+	}
+}
+
+func TestNewProductRepository(t *testing.T) {
+	for _, storage := range []string{"", "memory"} {
+		repo, err := NewProductRepository(&stubLifecycle{}, &config.Config{Storage: storage}, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("storage %q: %v", storage, err)
+		}
+		if _, ok := repo.(*repository.Memory); !ok {
+			t.Fatalf("storage %q: got %T, want *repository.Memory", storage, repo)
+		}
+	}
+	repo, err := NewProductRepository(&stubLifecycle{}, &config.Config{Storage: "sharded"}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.(*repository.Sharded); !ok {
+		t.Fatalf("storage \"sharded\": got %T, want *repository.Sharded", repo)
+	}
+	repo, err = NewProductRepository(&stubLifecycle{}, &config.Config{Storage: "versioned"}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.(*repository.Versioned); !ok {
+		t.Fatalf("storage \"versioned\": got %T, want *repository.Versioned", repo)
+	}
+	repo, err = NewProductRepository(&stubLifecycle{}, &config.Config{CacheSize: 10}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.(*repository.Cache); !ok {
+		t.Fatalf("cache size set: got %T, want *repository.Cache", repo)
+	}
+	if _, err := NewProductRepository(&stubLifecycle{}, &config.Config{Storage: "floppy", CacheSize: 10}, nil, nil, nil); err == nil {
+		t.Fatal("expected an error for unknown storage")
+	}
+}
+
+func TestFileStorage_SurvivesRestart(t *testing.T) {
+	testStorageSurvivesRestart(t, "file")
+}
+
+func TestEventStorage_SurvivesRestartAndRebuildsProjections(t *testing.T) {
+	counts := eventsource.NewEventCounts()
+	testStorageSurvivesRestart(t, "events", counts)
+	// Seeding creates the three sample products; the test creates lamp and
+	// deletes prod-1. The second start replays them all from the log.
+	want := map[string]int64{"created": 4, "deleted": 1}
+	if got := counts.Counts(); !maps.Equal(got, want) {
+		t.Fatalf("event counts after rebuild = %v, want %v", got, want)
+	}
+}
+
+// testStorageSurvivesRestart checks that the catalog written through the
+// persistent storage is there after a restart.
+func testStorageSurvivesRestart(t *testing.T, storage string, projections ...eventsource.Projection) {
+	cfg := &config.Config{Storage: storage, DataDir: t.TempDir()}
+	ctx := context.Background()
+	start := func() (*ProductService, *stubLifecycle) {
+		lc := &stubLifecycle{}
+		repo, err := NewProductRepository(lc, cfg, nil, nil, projections)
+		if err != nil {
+			t.Fatal(err)
+		}
+		svc, err := NewProductServiceFromConfig(lc, cfg, repo, operations.NewRegistry(0), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, h := range lc.hooks {
+			if h.OnStart != nil {
+				if err := h.OnStart(ctx); err != nil {
+					t.Fatalf("OnStart: %v", err)
+				}
+			}
+		}
+		return svc, lc
+	}
+	stop := func(lc *stubLifecycle) {
+		for i := len(lc.hooks) - 1; i >= 0; i-- {
+			if h := lc.hooks[i]; h.OnStop != nil {
+				if err := h.OnStop(ctx); err != nil {
+					t.Fatalf("OnStop: %v", err)
+				}
+			}
+		}
+	}
+
+	svc, lc := start()
+	if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Id: "lamp", Name: "Desk lamp", Price: 12}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "prod-1"}); err != nil {
+		t.Fatal(err)
+	}
+	stop(lc)
+
+	svc, lc = start()
+	defer stop(lc)
+	if got := stored(t, svc, "lamp"); got.GetName() != "Desk lamp" {
+		t.Fatalf("created product not persisted: %v", got)
+	}
+	if got := stored(t, svc, "prod-1"); got != nil {
+		t.Fatalf("deleted seed product came back: %v", got)
+	}
+	if _, err := svc.GetSimilarProducts(ctx, &product.GetSimilarProductsRequest{Id: "lamp"}); err != nil {
+		t.Fatalf("similarity index not rebuilt on start: %v", err)
+	}
+}
+
+func TestSQLStorage_MigratesOnStartOrRequiresCurrentSchema(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{
+		Storage:              "sql",
+		DatabaseDriver:       "sqlite",
+		DatabaseDSN:          filepath.Join(t.TempDir(), "products.db"),
+		DatabaseMaxOpenConns: 1,
+	}
+	lc := &stubLifecycle{}
+	if _, err := NewProductRepository(lc, cfg, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := lc.hooks[0].OnStart(ctx); err == nil {
+		t.Fatal("expected start to fail on an unmigrated database")
+	}
+	lc.hooks[0].OnStop(ctx)
+
+	cfg.MigrateOnStart = true
+	lc = &stubLifecycle{}
+	repo, err := NewProductRepository(lc, cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewProductServiceFromConfig(lc, cfg, repo, operations.NewRegistry(0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range lc.hooks {
+		if err := h.OnStart(ctx); err != nil {
+			t.Fatalf("OnStart: %v", err)
+		}
+	}
+	defer lc.hooks[0].OnStop(ctx)
+	if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Id: "lamp", Name: "Desk lamp", Price: 12}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stored(t, svc, "lamp"); got.GetName() != "Desk lamp" {
+		t.Fatalf("product not stored in the database: %v", got)
+	}
+
+	if _, err := NewProductRepository(&stubLifecycle{}, &config.Config{Storage: "sql"}, nil, nil, nil); err == nil {
+		t.Fatal("expected an error without a driver and DSN")
+	}
+}
+
+// BenchmarkProductServiceParallel runs UpdateProduct concurrently with reads
+// of single products and with catalog statistics, which scan the
+// repository. ServiceLock emulates a service-wide lock taken by every write
+// and held by statistics through their scan; PerProduct is ProductService
+// as it is, where writes lock only their product and scans lock nothing.
+func BenchmarkProductServiceParallel(b *testing.B) {
+	const n = 10_000
+	catalog := make([]*product.Product, n)
+	for i := range catalog {
+		catalog[i] = &product.Product{Id: fmt.Sprintf("bench-%05d", i), Name: fmt.Sprint("Item ", i%100), Price: float64(i % 500), Tags: []string{fmt.Sprint("t", i%20)}}
+	}
+	stats := &product.GetCatalogStatsRequest{Filter: "price < 50"}
+	for _, mix := range []struct {
+		name string
+		// writes is the share of operations that are updates, per thousand.
+		writes int
+		stats  bool
+	}{
+		{"Update", 1000, false},
+		{"Get90Update10", 100, false},
+		{"Stats90Update10", 100, true},
+	} {
+		for _, storage := range []struct {
+			name string
+			new  func() repository.ProductRepository
+		}{
+			{"Memory", func() repository.ProductRepository { return repository.NewMemory(catalog...) }},
+			{"Sharded", func() repository.ProductRepository { return repository.NewSharded(0, catalog...) }},
+		} {
+			for _, serialized := range []bool{true, false} {
+				locking := "PerProduct"
+				if serialized {
+					locking = "ServiceLock"
+				}
+				b.Run(mix.name+"/"+storage.name+"/"+locking, func(b *testing.B) {
+					ctx := context.Background()
+					// Audit events are appended to a file and fsynced, as in production.
+					sink, err := audit.OpenFileSink(filepath.Join(b.TempDir(), "audit.jsonl"))
+					if err != nil {
+						b.Fatal(err)
+					}
+					defer sink.Close()
+					log, err := audit.NewLog(sink, 0)
+					if err != nil {
+						b.Fatal(err)
+					}
+					svc, err := NewProductServiceWithRepository(ctx, storage.new(), WithAuditLog(log))
+					if err != nil {
+						b.Fatal(err)
+					}
+					var serviceLock sync.RWMutex
+					var reads, readNanos, writes, writeNanos atomic.Int64
+					b.ResetTimer()
+					b.RunParallel(func(pb *testing.PB) {
+						rng := rand.New(rand.NewPCG(rand.Uint64(), 0))
+						for pb.Next() {
+							p := catalog[rng.IntN(n)]
+							var err error
+							switch {
+							case rng.IntN(1000) < mix.writes:
+								start := time.Now()
+								if serialized {
+									serviceLock.Lock()
+								}
+								_, err = svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: proto.Clone(p).(*product.Product)})
+								if serialized {
+									serviceLock.Unlock()
+								}
+								writes.Add(1)
+								writeNanos.Add(int64(time.Since(start)))
+							case mix.stats:
+								start := time.Now()
+								if serialized {
+									serviceLock.RLock()
+								}
+								_, err = svc.GetCatalogStats(ctx, stats)
+								if serialized {
+									serviceLock.RUnlock()
+								}
+								reads.Add(1)
+								readNanos.Add(int64(time.Since(start)))
+							default:
+								start := time.Now()
+								_, err = svc.GetProduct(ctx, &product.GetProductRequest{Id: p.GetId()})
+								reads.Add(1)
+								readNanos.Add(int64(time.Since(start)))
+							}
+							if err != nil {
+								b.Error(err)
+								return
+							}
+						}
+					})
+					// How long reads and writes take, including any wait for
+					// each other.
+					if n := reads.Load(); n > 0 {
+						b.ReportMetric(float64(readNanos.Load())/float64(n), "ns/read")
+					}
+					if n := writes.Load(); n > 0 {
+						b.ReportMetric(float64(writeNanos.Load())/float64(n), "ns/write")
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"grpc-go-fx/internal/generated/product"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ProductServiceV2 implements productv2.ProductServiceServer on top of a
+// ProductService, so both API versions share storage, schema validation,
+// statistics and the audit log.
+type ProductServiceV2 struct {
+	productv2.UnimplementedProductServiceServer
+	v1 *ProductService
+}
+
+// NewProductServiceV2 creates a v2 service backed by v1.
+func NewProductServiceV2(v1 *ProductService) *ProductServiceV2 {
+	return &ProductServiceV2{v1: v1}
+}
+
+// GetProduct returns a product by ID, or NotFound.
+func (s *ProductServiceV2) GetProduct(ctx context.Context, req *productv2.GetProductRequest) (*productv2.Product, error) {
+	mask, err := compileReadMask(&productv2.Product{}, req.GetReadMask())
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.v1.get(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, status.Errorf(codes.NotFound, "product %q not found", req.GetId())
+	}
+	out, err := productToV2(p)
+	if err != nil {
+		return nil, err
+	}
+	mask.Apply(out)
+	return out, nil
+}
+
+// ListProducts returns a page of products matching the filter, ordered by ID.
+// Products are pruned to the read mask, which may leave out their IDs.
+//
+// If the repository keeps versions (repository.Pinner), every page comes from
+// the version the first page was read from: page tokens carry its number and
+// pin it. Once the version has expired, listing goes on from the current one.
+// Otherwise page tokens are the ID of the last product on the previous page,
+// so pages stay stable while products are added or removed; a page may take
+// several reads of the repository, and writes may land between them.
+func (s *ProductServiceV2) ListProducts(ctx context.Context, req *productv2.ListProductsRequest) (*productv2.ListProductsResponse, error) {
+	f, err := s.v1.parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	mask, err := compileReadMask(&productv2.Product{}, req.GetReadMask())
+	if err != nil {
+		return nil, err
+	}
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+
+	var (
+		from  lister = s.v1.repo
+		after        = req.GetPageToken()
+		view  repository.View
+	)
+	pinner, pinning := repository.Pinning(s.v1.repo)
+	if pinning {
+		view = pinner.Current()
+		if after != "" {
+			var version uint64
+			if version, after, err = parseVersionedPageToken(after); err != nil {
+				return nil, err
+			}
+			if pinned, err := pinner.Pinned(version); err == nil {
+				view = pinned
+			}
+		}
+		from = view
+	}
+	resp := &productv2.ListProductsResponse{}
+	last := ""
+	var convErr error
+	err = scanList(ctx, from, after, func(p *product.Product) bool {
+		if !f.Match(p) {
+			return true
+		}
+		if len(resp.Products) == pageSize {
+			resp.NextPageToken = last
+			return false
+		}
+		var out *productv2.Product
+		if out, convErr = productToV2(p); convErr != nil {
+			return false
+		}
+		mask.Apply(out)
+		resp.Products = append(resp.Products, out)
+		last = p.GetId()
+		return true
+	})
+	if err == nil {
+		err = convErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pinning && resp.NextPageToken != "" {
+		pinner.Pin(view)
+		resp.NextPageToken = versionedPageToken(view.Version(), resp.NextPageToken)
+	}
+	return resp, nil
+}
+
+// versionedPageToken returns the page token that continues after the product
+// with ID last in the given catalog version.
+func versionedPageToken(version uint64, last string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(version, 10) + ":" + last))
+}
+
+// parseVersionedPageToken returns the version and last ID of a token made by
+// versionedPageToken.
+func parseVersionedPageToken(token string) (version uint64, last string, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		v, id, ok := strings.Cut(string(b), ":")
+		if version, err = strconv.ParseUint(v, 10, 64); ok && err == nil {
+			return version, id, nil
+		}
+	}
+	return 0, "", status.Error(codes.InvalidArgument, "invalid page_token")
+}
+
+// CreateProduct translates the product and creates it through v1.
+func (s *ProductServiceV2) CreateProduct(ctx context.Context, req *productv2.CreateProductRequest) (*productv2.Product, error) {
+	p, err := productFromV2(req.GetProduct())
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.v1.CreateProduct(ctx, &product.CreateProductRequest{Product: p})
+	if err != nil {
+		return nil, err
+	}
+	return productToV2(created)
+}
+
+// UpdateProduct translates the product and updates it through v1.
+func (s *ProductServiceV2) UpdateProduct(ctx context.Context, req *productv2.UpdateProductRequest) (*productv2.Product, error) {
+	p, err := productFromV2(req.GetProduct())
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.v1.UpdateProduct(ctx, &product.UpdateProductRequest{Product: p})
+	if err != nil {
+		return nil, err
+	}
+	return productToV2(updated)
+}
+
+// DeleteProduct deletes a product through v1.
+func (s *ProductServiceV2) DeleteProduct(ctx context.Context, req *productv2.DeleteProductRequest) (*emptypb.Empty, error) {
+	return s.v1.DeleteProduct(ctx, &product.DeleteProductRequest{Id: req.GetId()})
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/fixtures"
+	"grpc-go-fx/internal/generated/product"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func usd(units int64, nanos int32) *productv2.Money {
+	return &productv2.Money{CurrencyCode: "USD", Units: units, Nanos: nanos}
+}
+
+func TestProductServiceV2_SharesStorageWithV1(t *testing.T) {
+	svc := NewProductService()
+	v2 := NewProductServiceV2(svc)
+	ctx := context.Background()
+
+	// Seeded v1 products are visible through v2 with Money prices.
+	got, err := v2.GetProduct(ctx, &productv2.GetProductRequest{Id: "prod-1"})
+	if err != nil {
+		t.Fatalf("GetProduct returned error: %v", err)
+	}
+	if got.GetPrice().GetUnits() != 9 || got.GetPrice().GetNanos() != 990000000 || got.GetPrice().GetCurrencyCode() != "USD" {
+		t.Fatalf("unexpected v2 price %v", got.GetPrice())
+	}
+
+	// A product created through v2 is visible through v1.
+	if _, err := v2.CreateProduct(ctx, &productv2.CreateProductRequest{Product: &productv2.Product{Id: "prod-9", Name: "Drill", Price: usd(89, 950000000)}}); err != nil {
+		t.Fatalf("CreateProduct returned error: %v", err)
+	}
+	p, _ := svc.GetProduct(ctx, &product.GetProductRequest{Id: "prod-9"})
+	if p.GetPrice() != 89.95 {
+		t.Fatalf("v1 price = %v, want 89.95", p.GetPrice())
+	}
+
+	// A v1 update is visible through v2.
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "prod-9", Name: "Drill", Price: 79.5}}); err != nil {
+		t.Fatalf("UpdateProduct returned error: %v", err)
+	}
+	got, _ = v2.GetProduct(ctx, &productv2.GetProductRequest{Id: "prod-9"})
+	if got.GetPrice().GetUnits() != 79 || got.GetPrice().GetNanos() != 500000000 {
+		t.Fatalf("unexpected v2 price after v1 update: %v", got.GetPrice())
+	}
+
+	// A v2 delete removes the product for v1.
+	if _, err := v2.DeleteProduct(ctx, &productv2.DeleteProductRequest{Id: "prod-9"}); err != nil {
+		t.Fatalf("DeleteProduct returned error: %v", err)
+	}
+	if _, err := v2.GetProduct(ctx, &productv2.GetProductRequest{Id: "prod-9"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound after delete, got %v", err)
+	}
+	if stored(t, svc, "prod-9") != nil {
+		t.Fatal("product still stored after v2 delete")
+	}
+}
+
+func TestProductServiceV2_RejectsForeignCurrency(t *testing.T) {
+	svc := NewProductService()
+	v2 := NewProductServiceV2(svc)
+	_, err := v2.UpdateProduct(context.Background(), &productv2.UpdateProductRequest{Product: &productv2.Product{
+		Id: "prod-1", Name: "Widget A", Price: &productv2.Money{CurrencyCode: "EUR", Units: 5},
+	}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if stored(t, svc, "prod-1").GetPrice() != 9.99 {
+		t.Fatalf("price changed despite rejection: %v", stored(t, svc, "prod-1").GetPrice())
+	}
+}
+
+func TestProductService_RejectsPricesOutOfRange(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "prod-1", Name: "Widget A", Price: 1e19}}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("v1 price above the maximum: %v, want InvalidArgument", err)
+	}
+	_, err := NewProductServiceV2(svc).UpdateProduct(ctx, &productv2.UpdateProductRequest{Product: &productv2.Product{
+		Id: "prod-1", Name: "Widget A", Price: &productv2.Money{CurrencyCode: CatalogCurrency, Units: math.MaxInt64},
+	}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("v2 price above the maximum: %v, want InvalidArgument", err)
+	}
+
+	op, err := svc.BulkUpdatePrices(ctx, &product.BulkUpdatePricesRequest{
+		Filter: `id = "prod-1"`,
+		Change: &product.BulkUpdatePricesRequest_Multiplier{Multiplier: 1e300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := svc.ops.Wait(ctx, op.GetName(), 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes.Code(done.GetError().GetCode()) != codes.InvalidArgument {
+		t.Fatalf("repricing above the maximum: %v, want InvalidArgument", done.GetError())
+	}
+	if stored(t, svc, "prod-1").GetPrice() != 9.99 {
+		t.Fatalf("price changed despite rejection: %v", stored(t, svc, "prod-1").GetPrice())
+	}
+}
+
+func TestProductServiceV2_ListProductsPaginates(t *testing.T) {
+	v2 := NewProductServiceV2(NewProductService())
+	ctx := context.Background()
+
+	var ids []string
+	req := &productv2.ListProductsRequest{PageSize: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not terminate")
+		}
+		resp, err := v2.ListProducts(ctx, req)
+		if err != nil {
+			t.Fatalf("ListProducts returned error: %v", err)
+		}
+		for _, p := range resp.GetProducts() {
+			ids = append(ids, p.GetId())
+		}
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if len(ids) != 3 || ids[0] != "prod-1" || ids[1] != "prod-2" || ids[2] != "prod-3" {
+		t.Fatalf("unexpected ids across pages: %v", ids)
+	}
+
+	resp, err := v2.ListProducts(ctx, &productv2.ListProductsRequest{Filter: "price < 10"})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if len(resp.GetProducts()) != 2 || resp.GetNextPageToken() != "" {
+		t.Fatalf("unexpected filtered page: %v", resp)
+	}
+}
+
+func TestProductServiceV2_ListProductsPagesThroughOneVersion(t *testing.T) {
+	ctx := context.Background()
+	svc, err := NewProductServiceWithRepository(ctx, repository.NewCache(repository.NewVersioned(repository.VersionedOptions{}, fixtures.Sample()...), repository.CacheOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2 := NewProductServiceV2(svc)
+	first, err := v2.ListProducts(ctx, &productv2.ListProductsRequest{PageSize: 1})
+	if err != nil || len(first.GetProducts()) != 1 || first.GetNextPageToken() == "" {
+		t.Fatalf("first page = %v, %v", first, err)
+	}
+	// Writes after the first page do not show on later pages.
+	svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "prod-2"})
+	svc.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Id: "prod-15", Name: "New", Price: 1}})
+	rest, err := v2.ListProducts(ctx, &productv2.ListProductsRequest{PageSize: 10, PageToken: first.GetNextPageToken()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, p := range rest.GetProducts() {
+		ids = append(ids, p.GetId())
+	}
+	if len(ids) != 2 || ids[0] != "prod-2" || ids[1] != "prod-3" || rest.GetNextPageToken() != "" {
+		t.Fatalf("later pages list %v, want the products as of the first page", ids)
+	}
+	current, _ := v2.ListProducts(ctx, &productv2.ListProductsRequest{PageSize: 10})
+	if len(current.GetProducts()) != 3 || current.GetProducts()[1].GetId() != "prod-15" {
+		t.Fatalf("a new listing = %v, want the current version", current.GetProducts())
+	}
+
+	if _, err := v2.ListProducts(ctx, &productv2.ListProductsRequest{PageToken: "prod-1"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("ListProducts with an ID as page token: %v, want InvalidArgument", err)
+	}
+}
+
+func TestProductServiceV2_ReadMask(t *testing.T) {
+	v2 := NewProductServiceV2(NewProductService())
+	ctx := context.Background()
+
+	got, err := v2.GetProduct(ctx, &productv2.GetProductRequest{Id: "prod-1", ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "price.units"}}})
+	if err != nil {
+		t.Fatalf("GetProduct returned error: %v", err)
+	}
+	if want := (&productv2.Product{Name: "Widget A", Price: &productv2.Money{Units: 9}}); !proto.Equal(got, want) {
+		t.Fatalf("GetProduct with read_mask = %v, want %v", got, want)
+	}
+
+	// Page tokens do not depend on the mask selecting the ID.
+	resp, err := v2.ListProducts(ctx, &productv2.ListProductsRequest{PageSize: 1, ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}}})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if len(resp.GetProducts()) != 1 || resp.GetProducts()[0].GetId() != "" || resp.GetNextPageToken() != "prod-1" {
+		t.Fatalf("unexpected masked page: %v", resp)
+	}
+
+	if _, err := v2.ListProducts(ctx, &productv2.ListProductsRequest{ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"price.amount"}}}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unknown path, got %v", err)
+	}
+}
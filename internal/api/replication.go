@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/replication"
+
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// replicationOrigin is the audit origin of writes a follower applies from its
+// leader.
+var replicationOrigin = audit.Origin{Actor: "replication"}
+
+// NewReplicationLog creates the mutation log of a leader (cfg.ReplicationRole),
+// which NewProductRepository records writes in; other roles get nil.
+func NewReplicationLog(cfg *config.Config) *replication.Log {
+	if cfg.ReplicationRole != replication.RoleLeader {
+		return nil
+	}
+	return replication.NewLog(cfg.ReplicationLogSize)
+}
+
+// NewReplicationServer creates the ReplicationService of the instance's role.
+// A follower connects to cfg.LeaderAddr, sending cfg.ReplicationToken if set,
+// and follows it from OnStart, after the ProductService has loaded, until
+// OnStop.
+func NewReplicationServer(lc fx.Lifecycle, cfg *config.Config, svc *ProductService, log *replication.Log) (*replication.Server, error) {
+	switch cfg.ReplicationRole {
+	case replication.RoleStandalone:
+		return replication.NewStandaloneServer(), nil
+	case replication.RoleLeader:
+		return replication.NewLeaderServer(log, cfg.ReplicationToken, 0), nil
+	}
+	if cfg.LeaderAddr == "" {
+		return nil, errors.New("a replication follower needs the address of its leader")
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if cfg.ReplicationToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(replication.TokenCredentials(cfg.ReplicationToken)))
+	}
+	conn, err := grpc.NewClient(cfg.LeaderAddr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := os.Hostname()
+	f := replication.NewFollower(conn, cfg.LeaderAddr, name+cfg.ServerAddr, svc)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				f.Run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stop context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stop.Done():
+			}
+			return conn.Close()
+		},
+	})
+	return replication.NewFollowerServer(f, cfg.ForwardWrites), nil
+}
+
+// replicationStatusVar is the expvar under which PublishReplicationStatus
+// exposes the replication status of the instance.
+const replicationStatusVar = "replication"
+
+var (
+	publishReplicationStatus sync.Once
+	publishedReplication     atomic.Pointer[replication.Server]
+)
+
+// PublishReplicationStatus exposes the status reported by GetReplicationStatus
+// as the expvar "replication" (served by the gateway at /debug/vars).
+func PublishReplicationStatus(srv *replication.Server) {
+	publishedReplication.Store(srv)
+	publishReplicationStatus.Do(func() {
+		expvar.Publish(replicationStatusVar, expvar.Func(func() any {
+			b, _ := protojson.Marshal(publishedReplication.Load().Status())
+			return json.RawMessage(b)
+		}))
+	})
+}
+
+// ApplyMutations applies the writes of one record of the leader's log while
+// holding the locks of the products it changes: attribute definitions
+// first, then the product changes in one repository batch, audited with the
+// actor "replication". Deletes of products the follower does not have are
+// skipped.
+func (s *ProductService) ApplyMutations(ctx context.Context, mutations []*storagepb.Mutation) error {
+	var ids []string
+	for _, m := range mutations {
+		if id := m.GetPut().GetId(); id != "" {
+			ids = append(ids, id)
+		} else if id := m.GetDelete(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	defer s.lockProducts(ids...)()
+	// A record may change a product more than once; current tracks the
+	// product as of the changes collected so far.
+	current := make(map[string]*product.Product)
+	var changes []catalogChange
+	for _, m := range mutations {
+		if def := m.GetPutAttributeDefinition(); def != nil {
+			if err := s.putDefinitions(ctx, def); err != nil {
+				return err
+			}
+			continue
+		}
+		c := catalogChange{id: m.GetDelete(), p: m.GetPut()}
+		if c.p != nil {
+			c.id = c.p.GetId()
+		}
+		old, seen := current[c.id]
+		if !seen {
+			var err error
+			if old, err = s.get(ctx, c.id); err != nil {
+				return err
+			}
+		}
+		if old == nil && c.p == nil {
+			continue
+		}
+		c.old = old
+		current[c.id] = c.p
+		changes = append(changes, c)
+	}
+	return s.applyChanges(ctx, replicationOrigin, changes)
+}
+
+// ReplaceCatalog puts the leader's attribute definitions and makes the
+// stored catalog equal to a snapshot of the leader's, like restoring a
+// backup. Definitions the leader does not have are kept, since they cannot
+// be deleted.
+func (s *ProductService) ReplaceCatalog(ctx context.Context, defs []*product.AttributeDefinition, products []*product.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.putDefinitions(ctx, defs...); err != nil {
+		return err
+	}
+	_, changes, err := s.diffCatalog(ctx, products)
+	if err != nil {
+		return err
+	}
+	return s.applyChanges(ctx, replicationOrigin, changes)
+}
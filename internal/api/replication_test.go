@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/fixtures"
+	"grpc-go-fx/internal/generated/product"
+	replicationpb "grpc-go-fx/internal/generated/product/replication"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/idempotency"
+	"grpc-go-fx/internal/interceptor"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// serveInProcess serves the full gRPC API of svc with the replication server
+// repl, and the extra unary interceptors, on an in-memory listener and returns
+// a connection to it.
+func serveInProcess(t *testing.T, svc *ProductService, repl *replication.Server, unary ...interceptor.Unary) *grpc.ClientConn {
+	t.Helper()
+	return dialInProcess(t, listenInProcess(t, svc, repl, unary...))
+}
+
+// listenInProcess serves like serveInProcess and returns the listener.
+func listenInProcess(t *testing.T, svc *ProductService, repl *replication.Server, unary ...interceptor.Unary) *bufconn.Listener {
+	t.Helper()
+	srv := newGRPCServer(t, &config.Config{}, svc, repl, unary...)
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis
+}
+
+// dialInProcess returns a connection to lis with the extra dial options.
+func dialInProcess(t *testing.T, lis *bufconn.Listener, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+	conn, err := grpc.NewClient("passthrough:///in-process", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// eventually polls cond until it holds or the test times out.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplication_FollowerAppliesLeaderWrites(t *testing.T) {
+	ctx := context.Background()
+	log := replication.NewLog(0)
+	leader, err := NewProductServiceWithRepository(ctx, log.Wrap(repository.NewMemory(fixtures.Sample()...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaderConn := serveInProcess(t, leader, replication.NewLeaderServer(log, "", 0))
+
+	follower, _ := NewProductServiceWithRepository(ctx, repository.NewMemory())
+	f := replication.NewFollower(leaderConn, "leader:50051", "follower-1", follower)
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Run(runCtx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	inSync := func() bool {
+		return f.Status().GetSequence() == log.Sequence() && storedCount(t, follower) == storedCount(t, leader)
+	}
+	eventually(t, "the initial snapshot", inSync)
+
+	created, err := leader.CreateProduct(ctx, &product.CreateProductRequest{Product: &product.Product{Id: "replicated", Name: "Replicated desk lamp", Price: 30, Tags: []string{"lamp"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leader.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "prod-2"}); err != nil {
+		t.Fatal(err)
+	}
+	defineVoltage(t, leader)
+	eventually(t, "streamed writes", inSync)
+	if defs, _ := follower.ListAttributeDefinitions(ctx, &product.ListAttributeDefinitionsRequest{}); len(defs.GetDefinitions()) != 1 {
+		t.Fatalf("follower attribute definitions %v, want the leader's voltage", defs.GetDefinitions())
+	}
+	created.Etag = ""
+	if !proto.Equal(stored(t, follower, "replicated"), created) || stored(t, follower, "prod-2") != nil {
+		t.Fatal("follower catalog differs from the leader's")
+	}
+	if _, err := follower.GetSimilarProducts(ctx, &product.GetSimilarProductsRequest{Id: "replicated"}); err != nil {
+		t.Fatalf("replicated product missing from the follower's similarity index: %v", err)
+	}
+	events, _ := follower.ListAuditEvents(ctx, &product.ListAuditEventsRequest{ProductId: "replicated"})
+	if len(events.GetEvents()) != 1 || events.GetEvents()[0].GetActor() != "replication" {
+		t.Fatalf("follower audit events %v, want one by the replication actor", events.GetEvents())
+	}
+
+	// Followers reject writes unless they forward them; reads are served
+	// locally either way.
+	rejecting := product.NewProductServiceClient(serveInProcess(t, follower, replication.NewFollowerServer(f, false)))
+	newProduct := &product.CreateProductRequest{Product: &product.Product{Id: "forwarded", Name: "Forwarded", Price: 1}}
+	if _, err := rejecting.CreateProduct(ctx, newProduct); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("CreateProduct on a follower: %v, want FailedPrecondition", err)
+	}
+	if _, err := rejecting.GetProduct(ctx, &product.GetProductRequest{Id: "replicated"}); err != nil {
+		t.Fatalf("GetProduct on a follower: %v", err)
+	}
+
+	forwarding := product.NewProductServiceClient(serveInProcess(t, follower, replication.NewFollowerServer(f, true)))
+	actorCtx := metadata.AppendToOutgoingContext(ctx, audit.ActorMetadataKey, "alice")
+	if _, err := forwarding.CreateProduct(actorCtx, newProduct); err != nil {
+		t.Fatalf("forwarded CreateProduct: %v", err)
+	}
+	if stored(t, leader, "forwarded") == nil {
+		t.Fatal("forwarded write did not reach the leader")
+	}
+	// Without a replication token the leader cannot tell the follower from a
+	// client claiming an actor, so it does not trust the forwarded one.
+	events, _ = leader.ListAuditEvents(ctx, &product.ListAuditEventsRequest{ProductId: "forwarded"})
+	if len(events.GetEvents()) != 1 || events.GetEvents()[0].GetActor() != audit.Anonymous {
+		t.Fatalf("leader audit events %v, want one by %s", events.GetEvents(), audit.Anonymous)
+	}
+	eventually(t, "the forwarded write to replicate", func() bool { return stored(t, follower, "forwarded") != nil })
+	if _, err := forwarding.CreateProduct(actorCtx, newProduct); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("forwarded duplicate CreateProduct: %v, want the leader's AlreadyExists", err)
+	}
+}
+
+// withActor is a unary interceptor that attributes requests to actor, as if
+// they had authenticated as it.
+func withActor(actor string) interceptor.Unary {
+	return interceptor.Unary{Order: interceptor.OrderRecovery + 1, Name: "actor-" + actor, Interceptor: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(audit.WithActor(ctx, actor), req)
+	}}
+}
+
+func TestReplication_ForwardedWritesKeepTheCallersActor(t *testing.T) {
+	ctx := context.Background()
+	log := replication.NewLog(0)
+	leader, err := NewProductServiceWithRepository(ctx, log.Wrap(repository.NewMemory()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := listenInProcess(t, leader, replication.NewLeaderServer(log, "s3cret", 0))
+	authenticated := dialInProcess(t, lis, grpc.WithPerRPCCredentials(replication.TokenCredentials("s3cret")))
+	follower, _ := NewProductServiceWithRepository(ctx, repository.NewMemory())
+	f := replication.NewFollower(authenticated, "leader:50051", "follower-1", follower)
+	forwardingFor := func(actor string) product.ProductServiceClient {
+		return product.NewProductServiceClient(serveInProcess(t, follower, replication.NewFollowerServer(f, true), withActor(actor)))
+	}
+
+	// Both use the same idempotency key, which the leader scopes by actor.
+	keyCtx := metadata.AppendToOutgoingContext(ctx, idempotency.MetadataKey, "k1")
+	for _, actor := range []string{"alice", "bob"} {
+		req := &product.CreateProductRequest{Product: &product.Product{Id: "by-" + actor, Name: "Lamp", Price: 1}}
+		if _, err := forwardingFor(actor).CreateProduct(keyCtx, req); err != nil {
+			t.Fatalf("CreateProduct forwarded for %s: %v", actor, err)
+		}
+		events, _ := leader.ListAuditEvents(ctx, &product.ListAuditEventsRequest{ProductId: "by-" + actor})
+		if len(events.GetEvents()) != 1 || events.GetEvents()[0].GetActor() != actor {
+			t.Fatalf("leader audit events %v, want one by %s", events.GetEvents(), actor)
+		}
+	}
+
+	// Without the token, a forwarded actor is ignored and changes are not
+	// streamed.
+	client := product.NewProductServiceClient(dialInProcess(t, lis))
+	spoofed := metadata.AppendToOutgoingContext(ctx, replication.ForwardedActorMetadataKey, "mallory", replication.TokenMetadataKey, "guess")
+	if _, err := client.CreateProduct(spoofed, &product.CreateProductRequest{Product: &product.Product{Id: "spoofed", Name: "Lamp", Price: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	events, _ := leader.ListAuditEvents(ctx, &product.ListAuditEventsRequest{ProductId: "spoofed"})
+	if len(events.GetEvents()) != 1 || events.GetEvents()[0].GetActor() != audit.Anonymous {
+		t.Fatalf("leader audit events %v, want one by %s", events.GetEvents(), audit.Anonymous)
+	}
+	stream, err := replicationpb.NewReplicationServiceClient(dialInProcess(t, lis)).StreamChanges(ctx, &replicationpb.StreamChangesRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("StreamChanges without the token: %v, want Unauthenticated", err)
+	}
+}
+
+func TestProductService_ApplyMutationsSkipsMissingDeletes(t *testing.T) {
+	ctx := context.Background()
+	svc := NewProductService()
+	before := storedCount(t, svc)
+	p := &product.Product{Id: "new", Name: "New", Price: 5}
+	err := svc.ApplyMutations(ctx, []*storagepb.Mutation{
+		{Op: &storagepb.Mutation_Put{Put: p}},
+		{Op: &storagepb.Mutation_Delete{Delete: "never-existed"}},
+		{Op: &storagepb.Mutation_Delete{Delete: "new"}},
+		{Op: &storagepb.Mutation_Delete{Delete: "prod-1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored(t, svc, "new") != nil || stored(t, svc, "prod-1") != nil || storedCount(t, svc) != before-1 {
+		t.Fatal("mutations not applied in order")
+	}
+}
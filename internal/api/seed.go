@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/fixtures"
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/repository"
+	"grpc-go-fx/internal/validate"
+
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// seedOrigin is the audit origin of products written by Seed.
+var seedOrigin = audit.Origin{Actor: "seed"}
+
+// loadSeed reads the seed catalog configured by cfg: nothing for
+// fixtures.Skip, cfg.SeedPath if set and fixtures.Sample otherwise.
+func loadSeed(cfg *config.Config) ([]*product.Product, error) {
+	switch {
+	case cfg.SeedMode == fixtures.Skip:
+		return nil, nil
+	case cfg.SeedPath == "":
+		return fixtures.Sample(), nil
+	}
+	return fixtures.Load(cfg.SeedPath, checkSeed)
+}
+
+// checkSeed validates a fixture like a created product. Attributes are not
+// checked because the attribute schema is only known once the repository has
+// been loaded.
+func checkSeed(p *product.Product) error {
+	err := validate.Message(p)
+	if err == nil {
+		err = validateFields(p)
+	}
+	if err != nil {
+		return errors.New(status.Convert(err).Message())
+	}
+	return nil
+}
+
+// Seed writes products to the repository according to mode, in one batch, and
+// returns how many it wrote. With fixtures.IfEmpty nothing is written unless
+// the repository is empty; with fixtures.Upsert products equal to the stored
+// ones are left alone. Writes are audited with the actor "seed" once the batch
+// commits.
+func (s *ProductService) Seed(ctx context.Context, products []*product.Product, mode fixtures.Mode) (int, error) {
+	if mode == fixtures.Skip || len(products) == 0 {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mode == fixtures.IfEmpty {
+		existing, _, err := s.repo.List(ctx, "", 1)
+		if err != nil {
+			return 0, storageError(err)
+		}
+		if len(existing) > 0 {
+			return 0, nil
+		}
+	}
+
+	var olds, news []*product.Product
+	err := s.repo.Batch(ctx, func(tx repository.Tx) error {
+		olds, news = nil, nil
+		for _, p := range products {
+			p.Etag = ""
+			old, err := tx.Get(ctx, p.GetId())
+			if errors.Is(err, repository.ErrNotFound) {
+				old, err = nil, nil
+			}
+			if err != nil {
+				return err
+			}
+			if old != nil && proto.Equal(old, p) {
+				continue
+			}
+			if err := tx.Put(ctx, p); err != nil {
+				return err
+			}
+			olds, news = append(olds, old), append(news, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, storageError(err)
+	}
+	events := make([]*product.AuditEvent, len(news))
+	for i := range news {
+		s.index(olds[i], news[i])
+		events[i] = s.audit.Prepare(seedOrigin, olds[i], news[i])
+	}
+	if err := s.audit.Commit(events...); err != nil {
+		return len(news), auditError(err)
+	}
+	return len(news), nil
+}
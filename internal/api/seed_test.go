@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/fixtures"
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/operations"
+	"grpc-go-fx/internal/repository"
+)
+
+// startFromConfig builds a ProductService over a new in-memory repository the
+// way api.Module does and runs its OnStart hooks.
+func startFromConfig(t *testing.T, cfg *config.Config, repo repository.ProductRepository) *ProductService {
+	t.Helper()
+	lc := &stubLifecycle{}
+	svc, err := NewProductServiceFromConfig(lc, cfg, repo, operations.NewRegistry(0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range lc.hooks {
+		if err := h.OnStart(context.Background()); err != nil {
+			t.Fatalf("OnStart: %v", err)
+		}
+	}
+	return svc
+}
+
+func writeFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSeed_IfEmptySeedsOnlyAnEmptyRepository(t *testing.T) {
+	repo := repository.NewMemory()
+	svc := startFromConfig(t, &config.Config{}, repo)
+	if got := storedCount(t, svc); got != len(fixtures.Sample()) {
+		t.Fatalf("empty repository seeded with %d products, want the sample catalog", got)
+	}
+
+	if _, err := svc.DeleteProduct(context.Background(), &product.DeleteProductRequest{Id: "prod-1"}); err != nil {
+		t.Fatal(err)
+	}
+	svc = startFromConfig(t, &config.Config{}, repo)
+	if stored(t, svc, "prod-1") != nil {
+		t.Fatal("non-empty repository reseeded")
+	}
+}
+
+func TestSeed_UpsertOverwritesFixturesOnEveryStart(t *testing.T) {
+	path := writeFixture(t, "catalog.csv", "id,name,price,categories\nlamp,Desk lamp,24.5,lighting\nchair,Office chair,149,furniture\n")
+	cfg := &config.Config{SeedPath: path, SeedMode: fixtures.Upsert}
+	repo := repository.NewMemory(&product.Product{Id: "other", Name: "Kept", Price: 1})
+	ctx := context.Background()
+
+	svc := startFromConfig(t, cfg, repo)
+	if stored(t, svc, "lamp").GetPrice() != 24.5 || stored(t, svc, "other") == nil {
+		t.Fatalf("fixtures not upserted next to existing products")
+	}
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "lamp", Name: "Desk lamp", Price: 99}}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc = startFromConfig(t, cfg, repo)
+	if got := stored(t, svc, "lamp").GetPrice(); got != 24.5 {
+		t.Fatalf("lamp price %v after restart, want the fixture's 24.5", got)
+	}
+	if n, err := svc.Seed(ctx, []*product.Product{stored(t, svc, "lamp"), stored(t, svc, "chair")}, fixtures.Upsert); err != nil || n != 0 {
+		t.Fatalf("upserting unchanged fixtures wrote %d products, err %v", n, err)
+	}
+	stats, err := svc.GetCatalogStats(ctx, &product.GetCatalogStatsRequest{GroupBy: product.GetCatalogStatsRequest_GROUP_BY_CATEGORY})
+	if err != nil || len(stats.GetGroups()) == 0 {
+		t.Fatalf("seeded products missing from derived state: %v, %v", stats, err)
+	}
+
+	events, err := svc.ListAuditEvents(ctx, &product.ListAuditEventsRequest{ProductId: "lamp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := events.GetEvents(); len(e) != 1 || e[0].GetActor() != "seed" || e[0].GetBefore().GetPrice() != 99 {
+		t.Fatalf("unexpected audit trail %v", e)
+	}
+}
+
+func TestSeed_SkipLeavesRepositoryEmpty(t *testing.T) {
+	svc := startFromConfig(t, &config.Config{SeedPath: "missing.json", SeedMode: fixtures.Skip}, repository.NewMemory())
+	if got := storedCount(t, svc); got != 0 {
+		t.Fatalf("repository holds %d products with seeding skipped", got)
+	}
+}
+
+func TestNewProductServiceFromConfig_RejectsInvalidFixtures(t *testing.T) {
+	path := writeFixture(t, "catalog.json", `[
+		{"id": "ok", "name": "Fine", "price": 1},
+		{"id": "no name", "price": 2},
+		{"id": "dup", "name": "Tags", "tags": ["a", "a"]}
+	]`)
+	_, err := NewProductServiceFromConfig(&stubLifecycle{}, &config.Config{SeedPath: path}, repository.NewMemory(), operations.NewRegistry(0), nil)
+	var errs *fixtures.Errors
+	if !errors.As(err, &errs) || len(errs.Rows) != 2 {
+		t.Fatalf("got %v, want two invalid rows", err)
+	}
+	msg := err.Error()
+	for _, s := range []string{`row 2 id "no name": invalid Product: id must match`, "name must be set", `row 3 id "dup": product.tags must be unique`} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("error missing %q:\n%s", s, msg)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSimilarResults is the number of results returned when max_results is 0.
+const defaultSimilarResults = 10
+
+// GetSimilarProducts scores every other product matching the filter against
+// the requested one using the incrementally maintained similarity index.
+func (s *ProductService) GetSimilarProducts(ctx context.Context, req *product.GetSimilarProductsRequest) (*product.GetSimilarProductsResponse, error) {
+	f, err := s.parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	n := int(req.GetMaxResults())
+	if n <= 0 {
+		n = defaultSimilarResults
+	}
+
+	var keep func(id string) bool
+	if f != nil {
+		matched := make(map[string]bool)
+		err := s.query(ctx, repository.Query{Filter: f}, func(p *product.Product) bool {
+			matched[p.GetId()] = true
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		keep = func(id string) bool { return matched[id] }
+	}
+	s.indexMu.RLock()
+	results, ok := s.similar.Similar(req.GetId(), n, keep)
+	s.indexMu.RUnlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "product %q not found", req.GetId())
+	}
+	resp := &product.GetSimilarProductsResponse{}
+	for _, r := range results {
+		p, err := s.get(ctx, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			// Deleted since it was scored.
+			continue
+		}
+		resp.Results = append(resp.Results, &product.SimilarProduct{
+			Product:    withETag(p),
+			Score:      r.Score,
+			TextScore:  r.Text,
+			LabelScore: r.Labels,
+			PriceScore: r.Price,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/similarity"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetSimilarProducts(t *testing.T) {
+	svc := NewProductService()
+	ctx := context.Background()
+	for _, p := range []*product.Product{
+		{Id: "lamp-1", Name: "Desk lamp", Description: "LED desk lamp", Price: 30, Categories: []string{"lighting"}},
+		{Id: "lamp-2", Name: "Floor lamp", Description: "LED floor lamp", Price: 80, Categories: []string{"lighting"}},
+		{Id: "lamp-3", Name: "Desk lamp", Description: "Halogen desk lamp", Price: 25, Categories: []string{"lighting"}},
+	} {
+		if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: p}); err != nil {
+			t.Fatalf("CreateProduct returned error: %v", err)
+		}
+	}
+
+	resp, err := svc.GetSimilarProducts(ctx, &product.GetSimilarProductsRequest{Id: "lamp-1", MaxResults: 2})
+	if err != nil {
+		t.Fatalf("GetSimilarProducts returned error: %v", err)
+	}
+	if len(resp.GetResults()) != 2 || resp.GetResults()[0].GetProduct().GetId() != "lamp-3" || resp.GetResults()[1].GetProduct().GetId() != "lamp-2" {
+		t.Fatalf("unexpected results %v", resp.GetResults())
+	}
+	if r := resp.GetResults()[0]; r.GetLabelScore() != 1 || r.GetTextScore() <= 0 || r.GetScore() <= resp.GetResults()[1].GetScore() {
+		t.Fatalf("unexpected scores %v", r)
+	}
+	if etag := resp.GetResults()[0].GetProduct().GetEtag(); etag == "" {
+		t.Fatal("similar product returned without an etag")
+	}
+
+	// Writes are reflected without a rebuild.
+	if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "lamp-3", Name: "Garden hose", Price: 25}}); err != nil {
+		t.Fatalf("UpdateProduct returned error: %v", err)
+	}
+	if _, err := svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "lamp-2"}); err != nil {
+		t.Fatalf("DeleteProduct returned error: %v", err)
+	}
+	// The renamed product shares only a price range with lamp-1.
+	resp, _ = svc.GetSimilarProducts(ctx, &product.GetSimilarProductsRequest{Id: "lamp-1", Filter: `id = "lamp*"`})
+	if len(resp.GetResults()) != 0 {
+		t.Fatalf("expected no similar products, got %v", resp.GetResults())
+	}
+
+	if _, err := svc.GetSimilarProducts(ctx, &product.GetSimilarProductsRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestGetSimilarProducts_UsesConfiguredWeights(t *testing.T) {
+	svc := NewProductService(WithSimilarityWeights(similarity.Weights{Price: 1}))
+	ctx := context.Background()
+	for _, p := range []*product.Product{
+		{Id: "prod-4", Name: "Widget Pro", Price: 10},
+		{Id: "prod-5", Name: "Widget Max", Price: 50},
+		{Id: "prod-6", Name: "Unrelated", Price: 10},
+	} {
+		if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: p}); err != nil {
+			t.Fatalf("CreateProduct returned error: %v", err)
+		}
+	}
+	resp, err := svc.GetSimilarProducts(ctx, &product.GetSimilarProductsRequest{Id: "prod-1"})
+	if err != nil {
+		t.Fatalf("GetSimilarProducts returned error: %v", err)
+	}
+	// With only price weighted, the widget priced closest to Widget A (9.99)
+	// ranks first; the unrelated product at the same price is not similar.
+	if got := resp.GetResults(); len(got) != 2 || got[0].GetProduct().GetId() != "prod-4" || got[0].GetScore() != got[0].GetPriceScore() {
+		t.Fatalf("unexpected results %v", got)
+	}
+}
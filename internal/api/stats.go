@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/repository"
+)
+
+// defaultHistogramBounds are used when GetCatalogStats is called without histogram_bounds.
+var defaultHistogramBounds = []float64{10, 25, 50, 100, 250}
+
+// GetCatalogStats computes price statistics from one query of the repository.
+// Unfiltered requests with the default histogram are answered from the
+// incrementally maintained aggregates when WithIncrementalStats is enabled.
+func (s *ProductService) GetCatalogStats(ctx context.Context, req *product.GetCatalogStatsRequest) (*product.GetCatalogStatsResponse, error) {
+	f, err := s.parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	bounds := req.GetHistogramBounds()
+	if err := checkBounds(bounds, "histogram_bounds"); err != nil {
+		return nil, err
+	}
+	custom := len(bounds) > 0
+	if !custom {
+		bounds = defaultHistogramBounds
+	}
+
+	if s.stats != nil && f == nil && !custom {
+		s.indexMu.RLock()
+		defer s.indexMu.RUnlock()
+		return s.stats.response(req.GetGroupBy()), nil
+	}
+
+	agg := newCatalogAggregates(bounds)
+	err = s.query(ctx, repository.Query{Filter: f}, func(p *product.Product) bool {
+		agg.add(p)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return agg.response(req.GetGroupBy()), nil
+}
+
+// catalogAggregates holds price aggregates for the whole catalog and per
+// category and tag. It supports removal so that it can be maintained
+// incrementally on every write.
+type catalogAggregates struct {
+	bounds     []float64
+	overall    *priceAggregate
+	byCategory map[string]*priceAggregate
+	byTag      map[string]*priceAggregate
+}
+
+func newCatalogAggregates(bounds []float64) *catalogAggregates {
+	return &catalogAggregates{
+		bounds:     bounds,
+		overall:    newPriceAggregate(bounds),
+		byCategory: make(map[string]*priceAggregate),
+		byTag:      make(map[string]*priceAggregate),
+	}
+}
+
+func (c *catalogAggregates) add(p *product.Product)    { c.apply(p, 1) }
+func (c *catalogAggregates) remove(p *product.Product) { c.apply(p, -1) }
+
+func (c *catalogAggregates) apply(p *product.Product, delta int64) {
+	c.overall.apply(p.GetPrice(), delta)
+	c.applyGroups(c.byCategory, p.GetCategories(), p.GetPrice(), delta)
+	c.applyGroups(c.byTag, p.GetTags(), p.GetPrice(), delta)
+}
+
+func (c *catalogAggregates) applyGroups(groups map[string]*priceAggregate, keys []string, price float64, delta int64) {
+	if len(keys) == 0 {
+		keys = []string{""}
+	}
+	for _, k := range keys {
+		g, ok := groups[k]
+		if !ok {
+			g = newPriceAggregate(c.bounds)
+			groups[k] = g
+		}
+		g.apply(price, delta)
+		if g.count == 0 {
+			delete(groups, k)
+		}
+	}
+}
+
+func (c *catalogAggregates) response(groupBy product.GetCatalogStatsRequest_GroupBy) *product.GetCatalogStatsResponse {
+	resp := &product.GetCatalogStatsResponse{Overall: c.overall.toProto()}
+	var groups map[string]*priceAggregate
+	switch groupBy {
+	case product.GetCatalogStatsRequest_GROUP_BY_CATEGORY:
+		groups = c.byCategory
+	case product.GetCatalogStatsRequest_GROUP_BY_TAG:
+		groups = c.byTag
+	}
+	for k, g := range groups {
+		resp.Groups = append(resp.Groups, &product.StatsGroup{Key: k, Stats: g.toProto()})
+	}
+	sort.Slice(resp.Groups, func(i, j int) bool { return resp.Groups[i].GetKey() < resp.Groups[j].GetKey() })
+	return resp
+}
+
+// exactSumPrec is the precision of priceAggregate.sum: enough bits to hold
+// the sum of any float64 values exactly, from the smallest subnormal to the
+// largest finite value with room for carries.
+const exactSumPrec = 1074 + 1024 + 64
+
+// priceAggregate tracks count, sum, histogram and the multiset of prices, so
+// min and max stay exact when products are removed. The sum is exact, so
+// that it does not drift from the catalog's however many products are added
+// and removed. It is only mutated with the service's indexMu held for
+// writing, so reads with it held for reading never modify it.
+type priceAggregate struct {
+	bounds   []float64
+	count    int64
+	sum      big.Float
+	buckets  []int64
+	prices   map[float64]int64
+	min, max float64
+}
+
+func newPriceAggregate(bounds []float64) *priceAggregate {
+	a := &priceAggregate{bounds: bounds, buckets: make([]int64, len(bounds)+1), prices: make(map[float64]int64)}
+	a.sum.SetPrec(exactSumPrec)
+	return a
+}
+
+func (a *priceAggregate) apply(price float64, delta int64) {
+	a.count += delta
+	var change big.Float
+	change.SetPrec(exactSumPrec).SetInt64(delta)
+	a.sum.Add(&a.sum, change.Mul(&change, big.NewFloat(price)))
+	a.buckets[bucketIndex(a.bounds, price)] += delta
+	a.prices[price] += delta
+	switch {
+	case a.prices[price] <= 0:
+		delete(a.prices, price)
+		if price == a.min || price == a.max {
+			a.recomputeExtremes()
+		}
+	case a.count == 1:
+		a.min, a.max = price, price
+	default:
+		a.min, a.max = min(a.min, price), max(a.max, price)
+	}
+}
+
+// recomputeExtremes scans the distinct prices; it only runs when the current
+// minimum or maximum is removed.
+func (a *priceAggregate) recomputeExtremes() {
+	first := true
+	for p := range a.prices {
+		if first {
+			a.min, a.max, first = p, p, false
+			continue
+		}
+		a.min, a.max = min(a.min, p), max(a.max, p)
+	}
+}
+
+func (a *priceAggregate) toProto() *product.PriceStats {
+	out := &product.PriceStats{Count: a.count, Histogram: priceBuckets(a.bounds, a.buckets)}
+	if a.count > 0 {
+		avg, _ := new(big.Float).Quo(&a.sum, new(big.Float).SetInt64(a.count)).Float64()
+		out.MinPrice, out.MaxPrice, out.AveragePrice = a.min, a.max, avg
+	}
+	return out
+}
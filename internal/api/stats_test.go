@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func seedCategorizedProducts(t *testing.T, svc *ProductService) {
+	t.Helper()
+	ctx := context.Background()
+	products := []*product.Product{
+		{Id: "c-1", Name: "Drill", Price: 40, Categories: []string{"power-tools"}, Tags: []string{"acme"}},
+		{Id: "c-2", Name: "Saw", Price: 120, Categories: []string{"power-tools", "outdoor"}},
+		{Id: "c-3", Name: "Rake", Price: 20, Categories: []string{"outdoor"}, Tags: []string{"acme"}},
+	}
+	for _, p := range products {
+		if _, err := svc.CreateProduct(ctx, &product.CreateProductRequest{Product: p}); err != nil {
+			t.Fatalf("CreateProduct(%q) returned error: %v", p.GetId(), err)
+		}
+	}
+}
+
+func TestGetCatalogStats_Overall(t *testing.T) {
+	svc := NewProductService()
+	resp, err := svc.GetCatalogStats(context.Background(), &product.GetCatalogStatsRequest{})
+	if err != nil {
+		t.Fatalf("GetCatalogStats returned error: %v", err)
+	}
+	o := resp.GetOverall()
+	if o.GetCount() != 3 || o.GetMinPrice() != 4.99 || o.GetMaxPrice() != 19.99 {
+		t.Fatalf("unexpected overall stats: %v", o)
+	}
+	if want := (9.99 + 19.99 + 4.99) / 3; math.Abs(o.GetAveragePrice()-want) > 1e-9 {
+		t.Fatalf("unexpected average: got %v, want %v", o.GetAveragePrice(), want)
+	}
+	if got, want := len(o.GetHistogram()), len(defaultHistogramBounds)+1; got != want {
+		t.Fatalf("unexpected histogram size: got %d, want %d", got, want)
+	}
+	if o.GetHistogram()[0].GetCount() != 2 || o.GetHistogram()[1].GetCount() != 1 {
+		t.Fatalf("unexpected histogram: %v", o.GetHistogram())
+	}
+	if len(resp.GetGroups()) != 0 {
+		t.Fatalf("expected no groups without group_by, got %v", resp.GetGroups())
+	}
+}
+
+func TestGetCatalogStats_FilterAndGroupByCategory(t *testing.T) {
+	svc := NewProductService()
+	seedCategorizedProducts(t, svc)
+
+	resp, err := svc.GetCatalogStats(context.Background(), &product.GetCatalogStatsRequest{
+		Filter:          "categories:*",
+		GroupBy:         product.GetCatalogStatsRequest_GROUP_BY_CATEGORY,
+		HistogramBounds: []float64{100},
+	})
+	if err != nil {
+		t.Fatalf("GetCatalogStats returned error: %v", err)
+	}
+	if got := resp.GetOverall().GetCount(); got != 3 {
+		t.Fatalf("unexpected filtered count: got %d, want 3", got)
+	}
+	groups := resp.GetGroups()
+	if len(groups) != 2 || groups[0].GetKey() != "outdoor" || groups[1].GetKey() != "power-tools" {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+	outdoor := groups[0].GetStats()
+	if outdoor.GetCount() != 2 || outdoor.GetMinPrice() != 20 || outdoor.GetMaxPrice() != 120 || outdoor.GetAveragePrice() != 70 {
+		t.Fatalf("unexpected outdoor stats: %v", outdoor)
+	}
+	if h := outdoor.GetHistogram(); len(h) != 2 || h[0].GetCount() != 1 || h[1].GetCount() != 1 {
+		t.Fatalf("unexpected outdoor histogram: %v", h)
+	}
+}
+
+func TestGetCatalogStats_GroupByTagIncludesUntagged(t *testing.T) {
+	svc := NewProductService()
+	seedCategorizedProducts(t, svc)
+
+	resp, err := svc.GetCatalogStats(context.Background(), &product.GetCatalogStatsRequest{GroupBy: product.GetCatalogStatsRequest_GROUP_BY_TAG})
+	if err != nil {
+		t.Fatalf("GetCatalogStats returned error: %v", err)
+	}
+	groups := resp.GetGroups()
+	if len(groups) != 2 || groups[0].GetKey() != "" || groups[0].GetStats().GetCount() != 4 || groups[1].GetKey() != "acme" {
+		t.Fatalf("unexpected tag groups: %v", groups)
+	}
+}
+
+func TestGetCatalogStats_RejectsBadBounds(t *testing.T) {
+	svc := NewProductService()
+	_, err := svc.GetCatalogStats(context.Background(), &product.GetCatalogStatsRequest{HistogramBounds: []float64{5, 1}})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", got)
+	}
+}
+
+func TestGetCatalogStats_IncrementalMatchesScan(t *testing.T) {
+	ctx := context.Background()
+	incremental := NewProductService(WithIncrementalStats())
+	scanned := NewProductService()
+
+	for _, svc := range []*ProductService{incremental, scanned} {
+		seedCategorizedProducts(t, svc)
+		// Remove the current maximum and minimum so the extremes must be recomputed.
+		if _, err := svc.DeleteProduct(ctx, &product.DeleteProductRequest{Id: "c-2"}); err != nil {
+			t.Fatalf("DeleteProduct returned error: %v", err)
+		}
+		if _, err := svc.UpdateProduct(ctx, &product.UpdateProductRequest{Product: &product.Product{Id: "prod-3", Name: "Gizmo C", Price: 75, Categories: []string{"outdoor"}}}); err != nil {
+			t.Fatalf("UpdateProduct returned error: %v", err)
+		}
+	}
+
+	for _, groupBy := range []product.GetCatalogStatsRequest_GroupBy{
+		product.GetCatalogStatsRequest_GROUP_BY_UNSPECIFIED,
+		product.GetCatalogStatsRequest_GROUP_BY_CATEGORY,
+		product.GetCatalogStatsRequest_GROUP_BY_TAG,
+	} {
+		req := &product.GetCatalogStatsRequest{GroupBy: groupBy}
+		got, err := incremental.GetCatalogStats(ctx, req)
+		if err != nil {
+			t.Fatalf("GetCatalogStats (incremental) returned error: %v", err)
+		}
+		want, err := scanned.GetCatalogStats(ctx, req)
+		if err != nil {
+			t.Fatalf("GetCatalogStats (scan) returned error: %v", err)
+		}
+		// Sums are exact, so the order they were accumulated in does not matter.
+		gotAvg, wantAvg := takeAverages(got), takeAverages(want)
+		for i := range wantAvg {
+			if gotAvg[i] != wantAvg[i] {
+				t.Fatalf("group_by %v: average %d differs: got %v, want %v", groupBy, i, gotAvg[i], wantAvg[i])
+			}
+		}
+		if !proto.Equal(got, want) {
+			t.Fatalf("group_by %v: incremental stats differ from scan:\n got %v\nwant %v", groupBy, got, want)
+		}
+	}
+	if got := incremental.stats.overall.max; got != 75 {
+		t.Fatalf("unexpected incremental max after deleting the maximum: got %v, want 75", got)
+	}
+}
+
+// takeAverages returns the overall and per-group averages and clears them in resp.
+func takeAverages(resp *product.GetCatalogStatsResponse) []float64 {
+	avgs := []float64{resp.GetOverall().GetAveragePrice()}
+	resp.GetOverall().AveragePrice = 0
+	for _, g := range resp.GetGroups() {
+		avgs = append(avgs, g.GetStats().GetAveragePrice())
+		g.GetStats().AveragePrice = 0
+	}
+	return avgs
+}
+
+func TestPriceAggregate_DoesNotDrift(t *testing.T) {
+	a := newPriceAggregate(defaultHistogramBounds)
+	a.apply(3.3, 1)
+	// Updates that would leave a float64 running sum off by far more than
+	// an ulp: 0.1 is absorbed by 1e17, and 1e17 then removed.
+	for range 100000 {
+		a.apply(1e17, 1)
+		a.apply(0.1, 1)
+		a.apply(1e17, -1)
+		a.apply(0.1, -1)
+	}
+	a.apply(1.1, 1)
+	if got := a.toProto().GetAveragePrice(); got != (3.3+1.1)/2 {
+		t.Fatalf("average %v after many updates, want %v", got, (3.3+1.1)/2)
+	}
+}
@@ -0,0 +1,369 @@
+// Package audit records who changed which product, when and how, and answers
+// queries over the recorded events.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// ActorMetadataKey carries the identity of a caller authenticated by a
+	// proxy in front of the API. It is trusted only on connections whose peer
+	// address is a ProxyAddr that forwards actors; a verified TLS client
+	// certificate takes precedence over it.
+	ActorMetadataKey = "x-authenticated-user"
+	// Anonymous is the actor recorded when the caller is unknown.
+	Anonymous = "anonymous"
+	// DefaultMaxEvents is how many of the most recent events a Log keeps in
+	// memory when no limit is configured.
+	DefaultMaxEvents = 10_000
+
+	defaultPageSize = 100
+)
+
+// Origin describes the request behind a mutation.
+type Origin struct {
+	Actor  string
+	Method string
+}
+
+// OriginFromContext returns the actor and gRPC method of the request in ctx.
+func OriginFromContext(ctx context.Context) Origin {
+	method, _ := grpc.Method(ctx)
+	return Origin{Actor: ActorFromContext(ctx), Method: method}
+}
+
+// ProxyAddr is implemented by the peer addresses of connections from a proxy
+// running in the same process, such as the HTTP gateway. Clients cannot
+// choose the peer address of their connection, so a proxy that authenticates
+// its own clients can pass their identity on in ActorMetadataKey.
+type ProxyAddr interface {
+	net.Addr
+	// ForwardsActor reports whether the proxy sets ActorMetadataKey to an
+	// authenticated identity and never forwards a client's own value.
+	ForwardsActor() bool
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx whose request is attributed to actor by
+// ActorFromContext. It is for code that authenticated the caller itself,
+// such as a replication leader accepting the actors its followers forward
+// writes for.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor, else the subject
+// common name of a verified TLS client certificate, else the
+// ActorMetadataKey metadata if the peer is a ProxyAddr that forwards actors,
+// else Anonymous. Metadata from any other peer is ignored, since clients can
+// set it to anything.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return Anonymous
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		if cert := verifiedLeaf(info.State.VerifiedChains); cert != nil && cert.Subject.CommonName != "" {
+			return cert.Subject.CommonName
+		}
+	}
+	if addr, ok := p.Addr.(ProxyAddr); ok && addr.ForwardsActor() {
+		if vals := metadata.ValueFromIncomingContext(ctx, ActorMetadataKey); len(vals) > 0 && vals[0] != "" {
+			return vals[0]
+		}
+	}
+	return Anonymous
+}
+
+func verifiedLeaf(chains [][]*x509.Certificate) *x509.Certificate {
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+	return chains[0][0]
+}
+
+// Log assigns IDs and timestamps to events and writes them to a Sink. It keeps
+// the most recent events in memory for ListAuditEvents and reads older ones
+// back from a sink that implements Source.
+type Log struct {
+	sink      Sink
+	maxEvents int
+	now       func() time.Time
+
+	mu sync.RWMutex
+	// events holds the most recent events in ID order.
+	events []*product.AuditEvent
+	// evicted is set once older events have been dropped from events.
+	evicted bool
+	lastID  uint64
+}
+
+// NewLog creates a Log writing to sink and keeping the maxEvents most recent
+// events (DefaultMaxEvents if maxEvents is not positive) in memory. Events
+// previously written to a sink that implements Source are loaded first. A nil
+// sink keeps events in memory only, so events evicted from memory are lost.
+func NewLog(sink Sink, maxEvents int) (*Log, error) {
+	if maxEvents <= 0 {
+		maxEvents = DefaultMaxEvents
+	}
+	l := &Log{sink: sink, maxEvents: maxEvents, now: time.Now}
+	if src, ok := sink.(Source); ok {
+		err := src.Scan(func(e *product.AuditEvent) bool {
+			l.appendLocked(e)
+			if n := id(e); n > l.lastID {
+				l.lastID = n
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Prepare returns the event for the change from before to after; a nil before
+// is a create and a nil after a delete. The event is written by Commit once
+// the change is committed.
+func (l *Log) Prepare(origin Origin, before, after *product.Product) *product.AuditEvent {
+	e := &product.AuditEvent{
+		Actor:         origin.Actor,
+		Method:        origin.Method,
+		ChangedFields: Diff(before, after),
+	}
+	switch {
+	case before == nil:
+		e.Action = product.AuditEvent_ACTION_CREATE
+		e.ProductId = after.GetId()
+	case after == nil:
+		e.Action = product.AuditEvent_ACTION_DELETE
+		e.ProductId = before.GetId()
+	default:
+		e.Action = product.AuditEvent_ACTION_UPDATE
+		e.ProductId = after.GetId()
+	}
+	if before != nil {
+		e.Before = proto.Clone(before).(*product.Product)
+	}
+	if after != nil {
+		e.After = proto.Clone(after).(*product.Product)
+	}
+	if e.Actor == "" {
+		e.Actor = Anonymous
+	}
+	return e
+}
+
+// Commit assigns IDs and timestamps to events made by Prepare and writes them
+// to the sink in one append. Call it only after the changes the events
+// describe are committed, so that the log never records a change that did
+// not happen. If it fails, the events are not listed and their IDs are not
+// reused.
+func (l *Log) Commit(events ...*product.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := timestamppb.New(l.now())
+	for _, e := range events {
+		l.lastID++
+		e.Id = strconv.FormatUint(l.lastID, 10)
+		e.Time = now
+	}
+	if l.sink != nil {
+		if err := l.sink.Append(events); err != nil {
+			return status.Errorf(codes.Internal, "write audit events: %v", err)
+		}
+	}
+	for _, e := range events {
+		l.appendLocked(e)
+	}
+	return nil
+}
+
+// Record commits the event for a change from before to after that is already
+// committed.
+func (l *Log) Record(origin Origin, before, after *product.Product) error {
+	return l.Commit(l.Prepare(origin, before, after))
+}
+
+// appendLocked appends e to the events in memory, evicting the oldest one if
+// there are too many.
+func (l *Log) appendLocked(e *product.AuditEvent) {
+	l.events = append(l.events, e)
+	if len(l.events) > l.maxEvents {
+		l.events[0] = nil
+		l.events = l.events[1:]
+		l.evicted = true
+	}
+}
+
+// List returns the events matching req in the order they were recorded.
+// Page tokens are event IDs.
+func (l *Log) List(req *product.ListAuditEventsRequest) (*product.ListAuditEventsResponse, error) {
+	var after uint64
+	if tok := req.GetPageToken(); tok != "" {
+		n, err := strconv.ParseUint(tok, 10, 64)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		after = n
+	}
+	var start, end time.Time
+	if req.GetStartTime() != nil {
+		start = req.GetStartTime().AsTime()
+	}
+	if req.GetEndTime() != nil {
+		end = req.GetEndTime().AsTime()
+		if !start.IsZero() && !end.After(start) {
+			return nil, status.Error(codes.InvalidArgument, "end_time must be after start_time")
+		}
+	}
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	resp := &product.ListAuditEventsResponse{}
+	add := func(e *product.AuditEvent) bool {
+		t := e.GetTime().AsTime()
+		if (req.GetProductId() != "" && e.GetProductId() != req.GetProductId()) ||
+			(req.GetActor() != "" && e.GetActor() != req.GetActor()) ||
+			(!start.IsZero() && t.Before(start)) ||
+			(!end.IsZero() && !t.Before(end)) {
+			return true
+		}
+		if len(resp.Events) == pageSize {
+			resp.NextPageToken = resp.Events[pageSize-1].GetId()
+			return false
+		}
+		resp.Events = append(resp.Events, e)
+		return true
+	}
+
+	l.mu.RLock()
+	src, ok := l.sink.(Source)
+	if ok && l.evicted && id(l.events[0]) > after+1 {
+		// The page starts before the events in memory.
+		l.mu.RUnlock()
+		err := src.Scan(func(e *product.AuditEvent) bool {
+			return id(e) <= after || add(e)
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "read audit events: %v", err)
+		}
+		return resp, nil
+	}
+	defer l.mu.RUnlock()
+	// Events are appended in ID order; skip straight past the page token.
+	i := sort.Search(len(l.events), func(i int) bool { return id(l.events[i]) > after })
+	for _, e := range l.events[i:] {
+		if !add(e) {
+			break
+		}
+	}
+	return resp, nil
+}
+
+// Close closes the sink.
+func (l *Log) Close() error {
+	if l.sink == nil {
+		return nil
+	}
+	return l.sink.Close()
+}
+
+func id(e *product.AuditEvent) uint64 {
+	n, _ := strconv.ParseUint(e.GetId(), 10, 64)
+	return n
+}
+
+// Diff returns the Product fields that differ between before and after, in
+// field order. Map entries are compared per key and reported as
+// "<field>.<key>" in key order. A nil product compares as empty.
+func Diff(before, after *product.Product) []string {
+	b, a := before.ProtoReflect(), after.ProtoReflect()
+	var changed []string
+	fields := b.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsMap() {
+			changed = append(changed, diffMap(string(fd.Name()), fd, b.Get(fd).Map(), a.Get(fd).Map())...)
+			continue
+		}
+		if !equalValues(fd, b.Get(fd), a.Get(fd)) {
+			changed = append(changed, string(fd.Name()))
+		}
+	}
+	return changed
+}
+
+func diffMap(name string, fd protoreflect.FieldDescriptor, b, a protoreflect.Map) []string {
+	keys := map[string]bool{}
+	collect := func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys[k.String()] = true
+		return true
+	}
+	b.Range(collect)
+	a.Range(collect)
+	var changed []string
+	for k := range keys {
+		mk := protoreflect.ValueOfString(k).MapKey()
+		if b.Has(mk) != a.Has(mk) || (b.Has(mk) && !equalScalar(fd.MapValue(), b.Get(mk), a.Get(mk))) {
+			changed = append(changed, name+"."+k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func equalValues(fd protoreflect.FieldDescriptor, x, y protoreflect.Value) bool {
+	if !fd.IsList() {
+		return equalScalar(fd, x, y)
+	}
+	lx, ly := x.List(), y.List()
+	if lx.Len() != ly.Len() {
+		return false
+	}
+	for i := 0; i < lx.Len(); i++ {
+		if !equalScalar(fd, lx.Get(i), ly.Get(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalScalar(fd protoreflect.FieldDescriptor, x, y protoreflect.Value) bool {
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		return proto.Equal(x.Message().Interface(), y.Message().Interface())
+	case protoreflect.BytesKind:
+		return bytes.Equal(x.Bytes(), y.Bytes())
+	}
+	return x.Interface() == y.Interface()
+}
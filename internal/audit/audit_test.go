@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newTestLog(t *testing.T) (*Log, *time.Time) {
+	t.Helper()
+	l, err := NewLog(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0).UTC()
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func ids(events []*product.AuditEvent) []string {
+	out := make([]string, len(events))
+	for i, e := range events {
+		out[i] = e.GetId()
+	}
+	return out
+}
+
+func TestRecord_ActionsAndDiff(t *testing.T) {
+	l, _ := newTestLog(t)
+	origin := Origin{Actor: "alice", Method: "/product.v1.ProductService/UpdateProduct"}
+	v1 := &product.Product{Id: "p", Name: "Lamp", Price: 10, Tags: []string{"a"}}
+	v2 := &product.Product{Id: "p", Name: "Lamp", Price: 12, Tags: []string{"a", "b"}, Attributes: map[string]*product.AttributeValue{
+		"color": {Kind: &product.AttributeValue_StringValue{StringValue: "red"}},
+	}}
+
+	for _, step := range [][2]*product.Product{{nil, v1}, {v1, v2}, {v2, nil}} {
+		if err := l.Record(origin, step[0], step[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Mutating the caller's product afterwards must not change the record.
+	v1.Name = "changed"
+
+	resp, err := l.List(&product.ListAuditEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := resp.GetEvents()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(got))
+	}
+	wantActions := []product.AuditEvent_Action{product.AuditEvent_ACTION_CREATE, product.AuditEvent_ACTION_UPDATE, product.AuditEvent_ACTION_DELETE}
+	for i, e := range got {
+		if e.GetAction() != wantActions[i] || e.GetProductId() != "p" || e.GetActor() != "alice" || e.GetMethod() != origin.Method {
+			t.Errorf("event %d: unexpected %v", i, e)
+		}
+	}
+	if got[0].GetBefore() != nil || got[0].GetAfter().GetName() != "Lamp" || got[2].GetAfter() != nil {
+		t.Errorf("unexpected snapshots: %v", got)
+	}
+	if want := []string{"price", "attributes.color", "tags"}; !reflect.DeepEqual(got[1].GetChangedFields(), want) {
+		t.Errorf("update diff = %v, want %v", got[1].GetChangedFields(), want)
+	}
+	if want := []string{"id", "name", "price", "tags"}; !reflect.DeepEqual(got[0].GetChangedFields(), want) {
+		t.Errorf("create diff = %v, want %v", got[0].GetChangedFields(), want)
+	}
+}
+
+func TestList_FiltersAndPages(t *testing.T) {
+	l, now := newTestLog(t)
+	record := func(actor, id string) {
+		if err := l.Record(Origin{Actor: actor}, nil, &product.Product{Id: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+		*now = now.Add(time.Minute)
+	}
+	start := *now
+	record("alice", "p1") // 1 @ +0m
+	record("bob", "p1")   // 2 @ +1m
+	record("alice", "p2") // 3 @ +2m
+	record("alice", "p1") // 4 @ +3m
+	record("", "p3")      // 5 @ +4m
+
+	tests := []struct {
+		name string
+		req  *product.ListAuditEventsRequest
+		want []string
+	}{
+		{"product", &product.ListAuditEventsRequest{ProductId: "p1"}, []string{"1", "2", "4"}},
+		{"actor", &product.ListAuditEventsRequest{Actor: "alice"}, []string{"1", "3", "4"}},
+		{"anonymous", &product.ListAuditEventsRequest{Actor: Anonymous}, []string{"5"}},
+		{"time range", &product.ListAuditEventsRequest{
+			StartTime: timestamppb.New(start.Add(time.Minute)),
+			EndTime:   timestamppb.New(start.Add(3 * time.Minute)),
+		}, []string{"2", "3"}},
+		{"combined", &product.ListAuditEventsRequest{ProductId: "p1", Actor: "alice", StartTime: timestamppb.New(start.Add(time.Second))}, []string{"4"}},
+	}
+	for _, tt := range tests {
+		resp, err := l.List(tt.req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := ids(resp.GetEvents()); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var pages [][]string
+	req := &product.ListAuditEventsRequest{Actor: "alice", PageSize: 2}
+	for {
+		resp, err := l.List(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, ids(resp.GetEvents()))
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if want := [][]string{{"1", "3"}, {"4"}}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestList_InvalidRequests(t *testing.T) {
+	l, now := newTestLog(t)
+	for _, req := range []*product.ListAuditEventsRequest{
+		{PageToken: "nope"},
+		{StartTime: timestamppb.New(*now), EndTime: timestamppb.New(now.Add(-time.Second))},
+	} {
+		if _, err := l.List(req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("List(%v): expected InvalidArgument, got %v", req, err)
+		}
+	}
+}
+
+// proxyAddr is the address of a connection from an in-process proxy.
+type proxyAddr bool
+
+func (proxyAddr) Network() string       { return "pipe" }
+func (proxyAddr) String() string        { return "proxy" }
+func (a proxyAddr) ForwardsActor() bool { return bool(a) }
+
+func TestActorFromContext(t *testing.T) {
+	withActor := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ActorMetadataKey, "carol"))
+	from := func(addr net.Addr) context.Context {
+		return peer.NewContext(withActor, &peer.Peer{Addr: addr})
+	}
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"no peer or metadata", context.Background(), Anonymous},
+		{"metadata without peer", withActor, Anonymous},
+		{"metadata from a remote client", from(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000}), Anonymous},
+		{"metadata from a proxy that does not forward actors", from(proxyAddr(false)), Anonymous},
+		{"metadata from a proxy that forwards actors", from(proxyAddr(true)), "carol"},
+	} {
+		if got := ActorFromContext(tc.ctx); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
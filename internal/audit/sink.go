@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Sink persists audit events. Append is called with events in ID order and
+// must not retain them after returning.
+type Sink interface {
+	Append(events []*product.AuditEvent) error
+	Close() error
+}
+
+// Source is implemented by sinks that can read back the events they were
+// given, so that the audit history survives restarts and events no longer
+// kept in memory can still be listed.
+type Source interface {
+	// Scan calls fn with every event in the order they were appended, until
+	// fn returns false. It may run concurrently with Append.
+	Scan(fn func(e *product.AuditEvent) bool) error
+}
+
+// FileSink appends events to a file as JSON lines and fsyncs the file after
+// every append, so events reported as recorded survive a crash.
+type FileSink struct {
+	path string
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64 // bytes of complete lines in f
+	failed error // set once a failed write could not be undone
+}
+
+// tailChunk is how much of the file OpenFileSink reads at a time, from the
+// end, to find the last complete line.
+const tailChunk = 4 << 10
+
+// OpenFileSink opens (creating if needed) the JSON lines file at path for
+// appending. A partial last line, left behind by a crash in the middle of a
+// write, is truncated.
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	fi, err := f.Stat()
+	if err == nil {
+		size, err = lastLineEnd(f, fi.Size())
+	}
+	if err == nil && size != fi.Size() {
+		err = f.Truncate(size)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileSink{path: path, f: f, size: size}, nil
+}
+
+// lastLineEnd returns the offset just past the last newline among the first
+// size bytes of f, reading backwards from size.
+func lastLineEnd(f *os.File, size int64) (int64, error) {
+	buf := make([]byte, tailChunk)
+	for end := size; end > 0; {
+		start := max(end-tailChunk, 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// Append writes each event as a single line and syncs the file. If the write
+// fails, whatever part of it reached the file is truncated away, so a later
+// append does not follow a partial line.
+func (s *FileSink) Append(events []*product.AuditEvent) error {
+	var buf []byte
+	for _, e := range events {
+		b, err := protojson.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed != nil {
+		return s.failed
+	}
+	n, err := s.f.Write(buf)
+	if err == nil && n < len(buf) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		if terr := s.f.Truncate(s.size); terr != nil {
+			s.failed = fmt.Errorf("audit sink: write failed (%v) and the file could not be repaired: %w", err, terr)
+		}
+		return err
+	}
+	s.size += int64(n)
+	return s.f.Sync()
+}
+
+// Scan reads the events in the file one line at a time. A last line still
+// being written is skipped.
+func (s *FileSink) Scan(fn func(e *product.AuditEvent) bool) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		e := &product.AuditEvent{}
+		if err := protojson.Unmarshal(line, e); err != nil {
+			return fmt.Errorf("%s:%d: %w", s.path, n, err)
+		}
+		if !fn(e) {
+			return nil
+		}
+	}
+}
+
+// Close syncs and closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.f.Sync(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
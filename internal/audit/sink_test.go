@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+func TestFileSink_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	open := func() *Log {
+		sink, err := OpenFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := NewLog(sink, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+
+	l := open()
+	for _, id := range []string{"p1", "p2"} {
+		if err := l.Record(Origin{Actor: "alice"}, nil, &product.Product{Id: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l = open()
+	defer l.Close()
+	if err := l.Record(Origin{Actor: "bob"}, &product.Product{Id: "p1", Name: "p1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := l.List(&product.ListAuditEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(resp.GetEvents()), []string{"1", "2", "3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ids after restart = %v, want %v", got, want)
+	}
+	if e := resp.GetEvents()[0]; e.GetActor() != "alice" || e.GetAfter().GetName() != "p1" || e.GetTime() == nil {
+		t.Fatalf("event not restored: %v", e)
+	}
+}
+
+func TestFileSink_TruncatesPartialLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := OpenFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Append([]*product.AuditEvent{{Id: "1", ProductId: "p1"}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	// Simulate a crash in the middle of writing the second event, after more
+	// of it reached the file than OpenFileSink reads at a time.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"2","productId":"` + strings.Repeat("p", 3*tailChunk))
+	f.Close()
+
+	sink, err = OpenFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Append([]*product.AuditEvent{{Id: "2", ProductId: "p2"}}); err != nil {
+		t.Fatal(err)
+	}
+	events, err := scanAll(sink)
+	if err != nil {
+		t.Fatalf("Scan after recovery: %v", err)
+	}
+	if got, want := ids(events), []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ids = %v, want %v", got, want)
+	}
+}
+
+func TestLog_ListsEventsEvictedFromMemoryFromTheSink(t *testing.T) {
+	sink, err := OpenFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewLog(sink, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for _, id := range []string{"p1", "p2", "p3", "p4", "p5"} {
+		if err := l.Record(Origin{Actor: "alice"}, nil, &product.Product{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(l.events) != 2 {
+		t.Fatalf("%d events in memory, want 2", len(l.events))
+	}
+
+	var got []string
+	req := &product.ListAuditEventsRequest{PageSize: 2}
+	for {
+		resp, err := l.List(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ids(resp.GetEvents())...)
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if want := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("listed ids = %v, want %v", got, want)
+	}
+	resp, err := l.List(&product.ListAuditEventsRequest{ProductId: "p2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(resp.GetEvents()), []string{"2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("events of p2 = %v, want %v", got, want)
+	}
+}
+
+func TestLog_WithoutSourceKeepsOnlyRecentEvents(t *testing.T) {
+	l, err := NewLog(nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"p1", "p2", "p3"} {
+		if err := l.Record(Origin{}, nil, &product.Product{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := l.List(&product.ListAuditEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(resp.GetEvents()), []string{"2", "3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ids = %v, want %v", got, want)
+	}
+}
+
+func scanAll(src Source) ([]*product.AuditEvent, error) {
+	var events []*product.AuditEvent
+	err := src.Scan(func(e *product.AuditEvent) bool {
+		events = append(events, e)
+		return true
+	})
+	return events, err
+}
@@ -0,0 +1,157 @@
+// Package backup stores point-in-time copies of the catalog in a local
+// directory, one subdirectory per backup, with a checksum and metadata.
+package backup
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/wal"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	catalogFile  = "catalog.pb"
+	metadataFile = "metadata.json"
+	// formatVersion is written to the metadata of new backups; Open rejects
+	// other versions.
+	formatVersion = 1
+)
+
+var (
+	// ErrNotFound is returned by Open when there is no backup with the ID.
+	ErrNotFound = errors.New("backup not found")
+	// ErrCorrupt is returned by Open when a backup does not match its
+	// metadata.
+	ErrCorrupt = errors.New("backup is corrupt")
+)
+
+// validID matches the IDs Create assigns: the UTC creation time and a random
+// suffix.
+var validID = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z-[0-9a-f]{6}$`)
+
+// Metadata describes a backup. It is stored next to the products as
+// metadata.json.
+type Metadata struct {
+	ID          string    `json:"id"`
+	Format      int       `json:"format"`
+	CreateTime  time.Time `json:"create_time"`
+	Description string    `json:"description,omitempty"`
+	Products    int       `json:"products"`
+	// Size and SHA256 are the size and hex SHA-256 of catalog.pb.
+	Size   int64  `json:"size_bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// Store keeps backups in a directory, which is created on the first Create.
+type Store struct {
+	dir string
+	now func() time.Time
+}
+
+// NewStore creates a Store of the backups in dir.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, now: time.Now}
+}
+
+// Create writes products as a new backup and returns its metadata. The
+// backup is written to a temporary directory that is renamed into place once
+// every file is synced, so a crash never leaves a partial backup behind
+// under a valid ID.
+func (s *Store) Create(products []*product.Product, description string) (Metadata, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(&storagepb.CatalogBackup{Products: products})
+	if err != nil {
+		return Metadata{}, err
+	}
+	sum := sha256.Sum256(data)
+	now := s.now().UTC().Truncate(time.Second)
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	meta := Metadata{
+		ID:          now.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix),
+		Format:      formatVersion,
+		CreateTime:  now,
+		Description: description,
+		Products:    len(products),
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+	}
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return Metadata{}, err
+	}
+	tmp, err := os.MkdirTemp(s.dir, ".tmp-")
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := wal.WriteFile(filepath.Join(tmp, catalogFile), data); err != nil {
+		return Metadata{}, err
+	}
+	if err := wal.WriteFile(filepath.Join(tmp, metadataFile), append(metaJSON, '\n')); err != nil {
+		return Metadata{}, err
+	}
+	if err := wal.SyncDir(tmp); err != nil {
+		return Metadata{}, err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, meta.ID)); err != nil {
+		return Metadata{}, err
+	}
+	return meta, wal.SyncDir(s.dir)
+}
+
+// Open reads the backup with the given ID and verifies it against its
+// metadata. It returns ErrNotFound if there is no such backup and an error
+// wrapping ErrCorrupt if the products do not match the recorded size,
+// checksum or count.
+func (s *Store) Open(id string) (Metadata, []*product.Product, error) {
+	if !validID.MatchString(id) {
+		return Metadata{}, nil, ErrNotFound
+	}
+	dir := filepath.Join(s.dir, id)
+	metaJSON, err := os.ReadFile(filepath.Join(dir, metadataFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return Metadata{}, nil, ErrNotFound
+	}
+	if err != nil {
+		return Metadata{}, nil, err
+	}
+	var meta Metadata
+	if err := json.Unmarshal(metaJSON, &meta); err != nil {
+		return Metadata{}, nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, metadataFile, err)
+	}
+	if meta.Format != formatVersion || meta.ID != id {
+		return Metadata{}, nil, fmt.Errorf("%w: %s describes backup %q in format %d", ErrCorrupt, metadataFile, meta.ID, meta.Format)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, catalogFile))
+	if err != nil {
+		return Metadata{}, nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != meta.Size || hex.EncodeToString(sum[:]) != meta.SHA256 {
+		return Metadata{}, nil, fmt.Errorf("%w: %s does not match its size or checksum", ErrCorrupt, catalogFile)
+	}
+	var catalog storagepb.CatalogBackup
+	if err := proto.Unmarshal(data, &catalog); err != nil {
+		return Metadata{}, nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, catalogFile, err)
+	}
+	if len(catalog.GetProducts()) != meta.Products {
+		return Metadata{}, nil, fmt.Errorf("%w: %s holds %d products, not %d", ErrCorrupt, catalogFile, len(catalog.GetProducts()), meta.Products)
+	}
+	return meta, catalog.GetProducts(), nil
+}
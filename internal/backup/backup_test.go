@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	s := NewStore(filepath.Join(t.TempDir(), "backups"))
+	s.now = func() time.Time { return time.Date(2026, 10, 19, 10, 15, 0, 0, time.UTC) }
+	return s
+}
+
+func TestStore_CreateAndOpen(t *testing.T) {
+	s := testStore(t)
+	products := []*product.Product{
+		{Id: "a", Name: "A", Price: 1, Tags: []string{"x"}},
+		{Id: "b", Name: "B", Price: 2},
+	}
+	meta, err := s.Create(products, "before repricing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validID.MatchString(meta.ID) || meta.ID[:16] != "20261019T101500Z" || meta.Products != 2 || meta.Description != "before repricing" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+
+	got, restored, err := s.Open(meta.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != meta {
+		t.Fatalf("Open returned metadata %+v, want %+v", got, meta)
+	}
+	if len(restored) != 2 || !proto.Equal(restored[0], products[0]) || !proto.Equal(restored[1], products[1]) {
+		t.Fatalf("Open returned products %v", restored)
+	}
+
+	entries, _ := os.ReadDir(s.dir)
+	if len(entries) != 1 || entries[0].Name() != meta.ID {
+		t.Fatalf("backup directory holds %v, want only %s", entries, meta.ID)
+	}
+}
+
+func TestStore_OpenRejectsCorruptBackups(t *testing.T) {
+	for name, corrupt := range map[string]func(dir string){
+		"flipped byte": func(dir string) {
+			path := filepath.Join(dir, catalogFile)
+			data, _ := os.ReadFile(path)
+			data[len(data)/2] ^= 0xff
+			os.WriteFile(path, data, 0o600)
+		},
+		"truncated": func(dir string) {
+			os.Truncate(filepath.Join(dir, catalogFile), 3)
+		},
+		"missing catalog": func(dir string) {
+			os.Remove(filepath.Join(dir, catalogFile))
+		},
+		"unreadable metadata": func(dir string) {
+			os.WriteFile(filepath.Join(dir, metadataFile), []byte("{"), 0o600)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := testStore(t)
+			meta, err := s.Create([]*product.Product{{Id: "a", Name: "A", Description: "a longer description"}}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			corrupt(filepath.Join(s.dir, meta.ID))
+			if _, _, err := s.Open(meta.ID); !errors.Is(err, ErrCorrupt) {
+				t.Fatalf("got %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestStore_OpenUnknownID(t *testing.T) {
+	s := testStore(t)
+	for _, id := range []string{"20261019T101500Z-000000", "../etc", ""} {
+		if _, _, err := s.Open(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) = %v, want ErrNotFound", id, err)
+		}
+	}
+}
@@ -1,9 +1,175 @@
 package config
 
+import (
+	"time"
+
+	"grpc-go-fx/internal/fixtures"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/repository"
+	"grpc-go-fx/internal/similarity"
+
+	"go.uber.org/zap/zapcore"
+)
+
 // Config holds addresses for the Product API.
 type Config struct {
 	// ServerAddr is the listen address for the gRPC API server (e.g. ":50051").
 	ServerAddr string
 	// HTTPGatewayAddr is the listen address for the HTTP/JSON gateway (e.g. ":8080").
 	HTTPGatewayAddr string
+	// Storage selects the product repository: "memory" (the default when
+	// empty) keeps products in process memory behind one lock; "sharded"
+	// keeps them in memory partitioned over Shards separately locked shards;
+	// "versioned" keeps them in memory as immutable versions read without
+	// locks;
+	// "file" persists them in DataDir; "events" records every change as an
+	// event in DataDir and serves the catalog as a projection of the events;
+	// "sql" stores them in the database given by DatabaseDriver and
+	// DatabaseDSN.
+	Storage string
+	// Shards is the number of shards of the sharded store; zero means
+	// repository.DefaultShards.
+	Shards int
+	// VersionPinTTL is how long the versioned store keeps a catalog version
+	// that a page token refers to; zero means repository.DefaultPinTTL.
+	VersionPinTTL time.Duration
+	// DataDir is the directory of the file store's write-ahead log and
+	// snapshot, and of the event store's log.
+	DataDir string
+	// FsyncPolicy says when the file store fsyncs its log; the zero value
+	// syncs every write.
+	FsyncPolicy repository.SyncPolicy
+	// FsyncInterval is how often the log is synced under
+	// repository.SyncInterval; zero means repository.DefaultSyncInterval.
+	FsyncInterval time.Duration
+	// SnapshotThreshold is the number of log records after which the file
+	// store snapshots the catalog and truncates its log; zero means
+	// repository.DefaultSnapshotThreshold and negative snapshots only on shutdown.
+	SnapshotThreshold int
+	// DatabaseDriver is the database/sql driver name of the SQL store, e.g.
+	// "sqlite" or "pgx". The driver must be linked into the binary.
+	DatabaseDriver string
+	// DatabaseDSN is the data source name passed to the driver.
+	DatabaseDSN string
+	// MigrateOnStart applies pending schema migrations when the SQL store is
+	// opened; otherwise startup fails unless the schema is current.
+	MigrateOnStart bool
+	// DatabaseMaxOpenConns limits open connections; zero means no limit.
+	DatabaseMaxOpenConns int
+	// DatabaseMaxIdleConns is the number of idle connections kept; zero
+	// means the database/sql default of 2, negative keeps none.
+	DatabaseMaxIdleConns int
+	// DatabaseConnMaxLifetime closes connections after this long; zero
+	// means connections are reused forever.
+	DatabaseConnMaxLifetime time.Duration
+	// DatabaseConnMaxIdleTime closes connections idle for this long; zero
+	// means they are not closed for being idle.
+	DatabaseConnMaxIdleTime time.Duration
+	// CacheSize is the number of products and misses cached in front of the
+	// repository; zero disables the cache.
+	CacheSize int
+	// CacheTTL is how long a cached product is served; zero means
+	// repository.DefaultCacheTTL.
+	CacheTTL time.Duration
+	// CacheNegativeTTL is how long a missing ID is cached; zero means
+	// repository.DefaultCacheNegativeTTL and negative disables negative caching.
+	CacheNegativeTTL time.Duration
+	// BackupDir is the directory AdminService writes backups to and
+	// restores them from.
+	BackupDir string
+	// ReplicationRole makes the instance a leader that streams its writes to
+	// followers, or a read-only follower of the leader at LeaderAddr; the
+	// zero value does not replicate.
+	ReplicationRole replication.Role
+	// LeaderAddr is the gRPC address of a follower's leader.
+	LeaderAddr string
+	// ReplicationLogSize is the number of recent writes a leader keeps for
+	// followers; a follower that falls further behind loads a snapshot. Zero
+	// means replication.DefaultLogSize.
+	ReplicationLogSize int
+	// ForwardWrites makes a follower forward writes to its leader instead of
+	// rejecting them.
+	ForwardWrites bool
+	// ReplicationToken authenticates followers to their leader. A leader
+	// with a token streams changes only to followers sending it, and audits
+	// the writes they forward with the callers' actors rather than the
+	// follower's; leader and followers must share it.
+	ReplicationToken string
+	// OutboxPublisher enables the transactional outbox and selects where its
+	// relay publishes events: "stdout", "file" (OutboxFile) or "webhook"
+	// (OutboxWebhookURL); empty disables it. Followers never publish: their
+	// leader does.
+	OutboxPublisher string
+	// OutboxPath is the log of the outbox; empty keeps it in memory, so
+	// events not yet delivered are lost on restart.
+	OutboxPath string
+	// OutboxFile is the JSON lines file of the "file" publisher.
+	OutboxFile string
+	// OutboxWebhookURL is the URL the "webhook" publisher POSTs events to.
+	OutboxWebhookURL string
+	// OutboxDeadLetterPath is the JSON lines file events that cannot be
+	// delivered are appended to; empty writes them to standard error.
+	OutboxDeadLetterPath string
+	// OutboxSource is the CloudEvents source of the events; empty means
+	// outbox.DefaultSource.
+	OutboxSource string
+	// OutboxMaxAttempts is the number of deliveries tried before an event is
+	// dead-lettered; zero means outbox.DefaultMaxAttempts.
+	OutboxMaxAttempts int
+	// OutboxMinBackoff and OutboxMaxBackoff bound the delay between
+	// deliveries of an event; zero means outbox.DefaultMinBackoff and
+	// outbox.DefaultMaxBackoff.
+	OutboxMinBackoff time.Duration
+	OutboxMaxBackoff time.Duration
+	// SeedPath is a JSON, YAML or CSV fixture file with the catalog to seed
+	// the repository with; empty means the built-in sample catalog.
+	SeedPath string
+	// SeedMode says when the seed catalog is written; the zero value seeds
+	// an empty repository only.
+	SeedMode fixtures.Mode
+	// IncrementalStats maintains catalog statistics on every write so that
+	// unfiltered GetCatalogStats calls do not scan the catalog.
+	IncrementalStats bool
+	// OperationRetention is how long finished long-running operations stay
+	// queryable; zero means operations.DefaultRetention.
+	OperationRetention time.Duration
+	// IdempotencyWindow is how long the outcome of a request sent with an
+	// Idempotency-Key is replayed; zero means idempotency.DefaultWindow.
+	IdempotencyWindow time.Duration
+	// IdempotencyMaxEntries bounds the number of outcomes kept, evicting the
+	// least recently used; zero means idempotency.DefaultMaxEntries.
+	IdempotencyMaxEntries int
+	// RPCTimeout is the deadline given to unary calls that arrive without
+	// one; zero leaves them without a deadline.
+	RPCTimeout time.Duration
+	// RPCMethodTimeouts overrides RPCTimeout for the full method or service
+	// names it maps, streaming methods included (see deadline.Timeouts).
+	RPCMethodTimeouts map[string]time.Duration
+	// LogFormat is the encoding of the log: "json" (the default when empty)
+	// or "console".
+	LogFormat string
+	// LogLevel is the lowest level logged; the zero value is info.
+	LogLevel zapcore.Level
+	// AccessLog logs every gRPC call and HTTP request.
+	AccessLog bool
+	// AccessLogSampleInitial and AccessLogSampleThereafter sample the
+	// access log (see logging.AccessLogOptions); zero
+	// AccessLogSampleInitial logs every call.
+	AccessLogSampleInitial    int
+	AccessLogSampleThereafter int
+	// AuditLogPath is the JSON lines file audit events are appended to; empty
+	// keeps them in memory only.
+	AuditLogPath string
+	// AuditMaxEvents is how many of the most recent audit events are kept in
+	// memory; older ones are read back from AuditLogPath. Zero means
+	// audit.DefaultMaxEvents.
+	AuditMaxEvents int
+	// TrustActorHeader makes the gateway's requests audited with the actor in
+	// their X-Authenticated-User header. Set it only if every HTTP request
+	// passes through an authenticating proxy that sets the header and strips
+	// the one sent by the client; otherwise clients could choose their actor.
+	TrustActorHeader bool
+	// SimilarityWeights weighs text, label and price similarity in
+	// GetSimilarProducts; the zero value means similarity.DefaultWeights.
+	SimilarityWeights similarity.Weights
 }
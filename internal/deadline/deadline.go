@@ -0,0 +1,108 @@
+// Package deadline gives gRPC requests that arrive without a deadline a
+// default one, configured per method, so that a client that never gives up
+// cannot hold a handler forever.
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Timeouts are the deadlines given to requests without one.
+type Timeouts struct {
+	// Default is the timeout of unary methods not in Methods; zero leaves
+	// them without a deadline. It does not apply to streams, which may
+	// rightly run for as long as the client listens.
+	Default time.Duration
+	// Methods maps full method names, e.g.
+	// "/product.v1.ProductService/ListProducts", or service names, e.g.
+	// "product.v1.AdminService", to the timeout of their calls, streaming
+	// or not. A method's entry takes precedence over its service's; zero
+	// exempts the calls from Default.
+	Methods map[string]time.Duration
+}
+
+// For returns the timeout of calls to the full method name method without a
+// deadline of their own, or zero if they get none.
+func (t Timeouts) For(method string, streaming bool) time.Duration {
+	if d, ok := t.Methods[method]; ok {
+		return d
+	}
+	service, _, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if d, ok := t.Methods[service]; ok {
+		return d
+	}
+	if streaming {
+		return 0
+	}
+	return t.Default
+}
+
+// ParseMethods parses comma-separated name=duration pairs, such as
+// "/product.v1.ProductService/ListProducts=2s,product.v1.AdminService=5m",
+// into Timeouts.Methods.
+func ParseMethods(s string) (map[string]time.Duration, error) {
+	methods := make(map[string]time.Duration)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid method timeout %q: want name=duration", pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid method timeout %q: %v", pair, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("invalid method timeout %q: negative", pair)
+		}
+		methods[strings.TrimSpace(name)] = d
+	}
+	return methods, nil
+}
+
+// UnaryServerInterceptor calls the handler with the timeout t gives the
+// method if the request has no deadline.
+func UnaryServerInterceptor(t Timeouts) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := withDefault(ctx, t.For(info.FullMethod, false))
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor calls the handler with the timeout t gives the
+// method if the stream has no deadline.
+func StreamServerInterceptor(t Timeouts) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := withDefault(ss.Context(), t.For(info.FullMethod, true))
+		defer cancel()
+		if ctx == ss.Context() {
+			return handler(srv, ss)
+		}
+		return handler(srv, &stream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// withDefault returns ctx with a timeout of d, unless d is zero or ctx
+// already has a deadline.
+func withDefault(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// stream is a grpc.ServerStream with the context of its handler replaced.
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context { return s.ctx }
@@ -0,0 +1,98 @@
+package deadline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestTimeouts_For(t *testing.T) {
+	timeouts := Timeouts{
+		Default: time.Second,
+		Methods: map[string]time.Duration{
+			"/product.v1.ProductService/ListProducts":   2 * time.Second,
+			"product.v1.AdminService":                   time.Minute,
+			"/product.v1.AdminService/RestoreBackup":    0,
+			"product.replication.v1.ReplicationService": time.Hour,
+		},
+	}
+	for _, tc := range []struct {
+		method    string
+		streaming bool
+		want      time.Duration
+	}{
+		{"/product.v1.ProductService/ListProducts", false, 2 * time.Second},
+		{"/product.v1.ProductService/GetProduct", false, time.Second},
+		{"/product.v1.AdminService/CreateBackup", false, time.Minute},
+		{"/product.v1.AdminService/RestoreBackup", false, 0},
+		{"/product.v1.ProductService/Watch", true, 0},
+		{"/product.replication.v1.ReplicationService/Subscribe", true, time.Hour},
+	} {
+		if got := timeouts.For(tc.method, tc.streaming); got != tc.want {
+			t.Errorf("For(%s, %v) = %v, want %v", tc.method, tc.streaming, got, tc.want)
+		}
+	}
+}
+
+func TestParseMethods(t *testing.T) {
+	m, err := ParseMethods(" /a.B/C=2s, a.D=0 ,")
+	if err != nil || len(m) != 2 || m["/a.B/C"] != 2*time.Second || m["a.D"] != 0 {
+		t.Fatalf("ParseMethods = %v, %v", m, err)
+	}
+	for _, s := range []string{"a.B", "=1s", "a.B=soon", "a.B=-1s"} {
+		if _, err := ParseMethods(s); err == nil {
+			t.Errorf("ParseMethods(%q) accepted", s)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	intercept := UnaryServerInterceptor(Timeouts{Default: time.Minute})
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Get"}
+	remaining := func(ctx context.Context) time.Duration {
+		var d time.Duration
+		intercept(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			if dl, ok := ctx.Deadline(); ok {
+				d = time.Until(dl)
+			}
+			return nil, nil
+		})
+		return d
+	}
+	if d := remaining(context.Background()); d <= 59*time.Second || d > time.Minute {
+		t.Fatalf("request without a deadline got %v", d)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if d := remaining(ctx); d <= 59*time.Minute {
+		t.Fatalf("request with an hour's deadline got %v", d)
+	}
+}
+
+// serverStream is a grpc.ServerStream with only a context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	intercept := StreamServerInterceptor(Timeouts{Default: time.Minute, Methods: map[string]time.Duration{"/svc/Sync": time.Second}})
+	hasDeadline := func(method string) bool {
+		var ok bool
+		intercept(nil, serverStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: method}, func(srv any, ss grpc.ServerStream) error {
+			_, ok = ss.Context().Deadline()
+			return nil
+		})
+		return ok
+	}
+	if hasDeadline("/svc/Watch") {
+		t.Error("the default timeout applied to a stream")
+	}
+	if !hasDeadline("/svc/Sync") {
+		t.Error("a stream's own timeout did not apply")
+	}
+}
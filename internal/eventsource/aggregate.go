@@ -0,0 +1,105 @@
+package eventsource
+
+import (
+	"fmt"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Aggregate is a product as of the last of its events.
+type Aggregate struct {
+	// Product is nil before the product's first event and after Deleted.
+	Product *product.Product
+	// Version is the number of events applied.
+	Version uint64
+}
+
+// Replay returns the aggregate of a product's events, in order.
+func Replay(events []*storagepb.ProductEvent) (Aggregate, error) {
+	var a Aggregate
+	for _, e := range events {
+		if err := a.Apply(e); err != nil {
+			return Aggregate{}, err
+		}
+	}
+	return a, nil
+}
+
+// Apply applies the next event of the product to a, or returns an error if e
+// does not follow a's version or cannot happen to the product in its state.
+func (a *Aggregate) Apply(e *storagepb.ProductEvent) error {
+	if e.GetVersion() != a.Version+1 {
+		return fmt.Errorf("product %q: event version %d follows %d", e.GetProductId(), e.GetVersion(), a.Version)
+	}
+	exists := a.Product != nil
+	switch c := e.GetChange().(type) {
+	case *storagepb.ProductEvent_Created:
+		if exists {
+			return fmt.Errorf("product %q: created again at version %d", e.GetProductId(), e.GetVersion())
+		}
+		a.Product = clone(c.Created.GetProduct())
+	case *storagepb.ProductEvent_Deleted:
+		if !exists {
+			return fmt.Errorf("product %q: deleted at version %d before it was created", e.GetProductId(), e.GetVersion())
+		}
+		a.Product = nil
+	default:
+		if !exists {
+			return fmt.Errorf("product %q: changed at version %d before it was created", e.GetProductId(), e.GetVersion())
+		}
+		switch c := c.(type) {
+		case *storagepb.ProductEvent_Renamed:
+			a.Product.Name = c.Renamed.GetName()
+		case *storagepb.ProductEvent_PriceChanged:
+			a.Product.Price = c.PriceChanged.GetPrice()
+		case *storagepb.ProductEvent_Updated:
+			a.Product = clone(c.Updated.GetProduct())
+		default:
+			return fmt.Errorf("product %q: event %d has no change", e.GetProductId(), e.GetSequence())
+		}
+	}
+	a.Version++
+	return nil
+}
+
+// Changes returns the events that turn the aggregate's product into p, or
+// delete it if p is nil, with versions following a's and without sequence
+// numbers or times. It returns no events if nothing changes.
+func (a Aggregate) Changes(id string, p *product.Product) []*storagepb.ProductEvent {
+	var events []*storagepb.ProductEvent
+	add := func(e *storagepb.ProductEvent) {
+		e.ProductId = id
+		e.Version = a.Version + uint64(len(events)) + 1
+		events = append(events, e)
+	}
+	old := a.Product
+	switch {
+	case old == nil && p == nil:
+	case old == nil:
+		add(&storagepb.ProductEvent{Change: &storagepb.ProductEvent_Created{Created: &storagepb.ProductCreated{Product: clone(p)}}})
+	case p == nil:
+		add(&storagepb.ProductEvent{Change: &storagepb.ProductEvent_Deleted{Deleted: &storagepb.Deleted{}}})
+	default:
+		if p.GetName() != old.GetName() {
+			add(&storagepb.ProductEvent{Change: &storagepb.ProductEvent_Renamed{Renamed: &storagepb.Renamed{OldName: old.GetName(), Name: p.GetName()}}})
+		}
+		if p.GetPrice() != old.GetPrice() {
+			add(&storagepb.ProductEvent{Change: &storagepb.ProductEvent_PriceChanged{PriceChanged: &storagepb.PriceChanged{OldPrice: old.GetPrice(), Price: p.GetPrice()}}})
+		}
+		// The renames and price changes above are followed by the product
+		// they lead to.
+		renamed := clone(old)
+		renamed.Name, renamed.Price = p.GetName(), p.GetPrice()
+		if !proto.Equal(renamed, p) {
+			add(&storagepb.ProductEvent{Change: &storagepb.ProductEvent_Updated{Updated: &storagepb.ProductUpdated{Product: clone(p)}}})
+		}
+	}
+	return events
+}
+
+func clone(p *product.Product) *product.Product {
+	return proto.Clone(p).(*product.Product)
+}
@@ -0,0 +1,70 @@
+package eventsource
+
+import (
+	"slices"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// kinds returns the names of the changes of events.
+func kinds(events []*storagepb.ProductEvent) []string {
+	var names []string
+	for _, e := range events {
+		names = append(names, string(e.ProtoReflect().WhichOneof(changeOneof).Name()))
+	}
+	return names
+}
+
+func TestAggregate_ChangesReplayToTheWrittenProduct(t *testing.T) {
+	steps := []struct {
+		p    *product.Product
+		want []string
+	}{
+		{&product.Product{Id: "lamp", Name: "Lamp", Price: 10}, []string{"created"}},
+		{&product.Product{Id: "lamp", Name: "Lamp", Price: 10}, nil},
+		{&product.Product{Id: "lamp", Name: "Desk lamp", Price: 12}, []string{"renamed", "price_changed"}},
+		{&product.Product{Id: "lamp", Name: "Desk lamp", Price: 12, Tags: []string{"lighting"}}, []string{"updated"}},
+		{&product.Product{Id: "lamp", Name: "Lamp", Price: 9, Description: "Bright"}, []string{"renamed", "price_changed", "updated"}},
+		{nil, []string{"deleted"}},
+		{nil, nil},
+		{&product.Product{Id: "lamp", Name: "Lamp again"}, []string{"created"}},
+	}
+	var history []*storagepb.ProductEvent
+	for i, step := range steps {
+		agg, err := Replay(history)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		events := agg.Changes("lamp", step.p)
+		if got := kinds(events); !slices.Equal(got, step.want) {
+			t.Fatalf("step %d: changes %v, want %v", i, got, step.want)
+		}
+		history = append(history, events...)
+		agg, err = Replay(history)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if !proto.Equal(agg.Product, step.p) || agg.Version != uint64(len(history)) {
+			t.Fatalf("step %d: replayed %v at version %d, want %v at %d", i, agg.Product, agg.Version, step.p, len(history))
+		}
+	}
+}
+
+func TestAggregate_RejectsImpossibleHistories(t *testing.T) {
+	created := &storagepb.ProductEvent{ProductId: "lamp", Version: 1, Change: &storagepb.ProductEvent_Created{Created: &storagepb.ProductCreated{Product: &product.Product{Id: "lamp"}}}}
+	for name, history := range map[string][]*storagepb.ProductEvent{
+		"version gap":        {created, {ProductId: "lamp", Version: 3, Change: &storagepb.ProductEvent_Deleted{Deleted: &storagepb.Deleted{}}}},
+		"created twice":      {created, {ProductId: "lamp", Version: 2, Change: created.Change}},
+		"renamed first":      {{ProductId: "lamp", Version: 1, Change: &storagepb.ProductEvent_Renamed{Renamed: &storagepb.Renamed{Name: "Lamp"}}}},
+		"deleted first":      {{ProductId: "lamp", Version: 1, Change: &storagepb.ProductEvent_Deleted{Deleted: &storagepb.Deleted{}}}},
+		"event of no change": {created, {ProductId: "lamp", Version: 2}},
+	} {
+		if _, err := Replay(history); err == nil {
+			t.Errorf("%s: replayed without an error", name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package eventsource stores the catalog as a log of events, one stream per
+// product: ProductCreated, Renamed, PriceChanged, ProductUpdated and Deleted.
+// A product's current state is the replay of its stream (the aggregate), and
+// everything read from the catalog comes from projections, read models that
+// the log's events are applied to in order. The catalog itself is one such
+// projection; projections are rebuilt from scratch by replaying the whole log.
+package eventsource
+
+import (
+	"context"
+	"errors"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+)
+
+// ErrVersionConflict is returned by Store.Append for an event whose version
+// does not directly follow the last stored event of its product.
+var ErrVersionConflict = errors.New("event version conflict")
+
+// Store is an append-only log of product events. It also stores the custom
+// attribute definitions of the catalog, which are not events of a product.
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Append stores events atomically after the last stored one, numbering
+	// them (Sequence) in order. The Version of each event must be one more
+	// than that of the product's previous event (or 1 for its first event),
+	// or nothing is stored and Append returns ErrVersionConflict.
+	Append(ctx context.Context, events []*storagepb.ProductEvent) error
+	// Load returns the events of one product in order.
+	Load(ctx context.Context, productID string) ([]*storagepb.ProductEvent, error)
+	// Read calls fn with every event with a sequence number greater than
+	// after, in order, until fn returns false.
+	Read(ctx context.Context, after uint64, fn func(e *storagepb.ProductEvent) bool) error
+	// PutAttributeDefinition stores def, replacing any definition with the
+	// same name.
+	PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error
+	// AttributeDefinitions returns the stored definitions in name order.
+	AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error)
+}
+
+// Projection is a read model built from the log. Events passed to it must
+// not be modified or retained unless cloned.
+type Projection interface {
+	// Reset empties the projection before the log is replayed into it.
+	Reset()
+	// Apply updates the projection with the next event of the log.
+	Apply(e *storagepb.ProductEvent)
+}
+
+// BatchProjection is implemented by projections that apply the events of one
+// write together, so that their readers never see part of it. Repository
+// calls ApplyBatch, rather than Apply for each event, after a write.
+type BatchProjection interface {
+	Projection
+	ApplyBatch(events []*storagepb.ProductEvent)
+}
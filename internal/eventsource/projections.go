@@ -0,0 +1,129 @@
+package eventsource
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/repository"
+)
+
+// Catalog is the projection of the current products: a repository.Memory,
+// so the catalog is indexed for queries like any other store. Reset swaps in
+// an empty one, which readers see until the log has been replayed into it.
+type Catalog struct {
+	repo atomic.Pointer[repository.Memory]
+}
+
+// NewCatalog creates an empty Catalog.
+func NewCatalog() *Catalog {
+	c := &Catalog{}
+	c.Reset()
+	return c
+}
+
+func (c *Catalog) products() *repository.Memory {
+	return c.repo.Load()
+}
+
+// Reset implements Projection.
+func (c *Catalog) Reset() {
+	c.repo.Store(repository.NewMemory())
+}
+
+// Apply implements Projection.
+func (c *Catalog) Apply(e *storagepb.ProductEvent) {
+	apply(context.Background(), c.products(), e)
+}
+
+// ApplyBatch implements BatchProjection with one batch of the catalog, so
+// reads see all of the events or none.
+func (c *Catalog) ApplyBatch(events []*storagepb.ProductEvent) {
+	ctx := context.Background()
+	c.products().Batch(ctx, func(tx repository.Tx) error {
+		for _, e := range events {
+			apply(ctx, tx, e)
+		}
+		return nil
+	})
+}
+
+// catalogWriter is implemented by repository.Memory and its Tx.
+type catalogWriter interface {
+	Get(ctx context.Context, id string) (*product.Product, error)
+	Put(ctx context.Context, p *product.Product) error
+	Delete(ctx context.Context, id string) error
+}
+
+// apply applies e to the products in w.
+func apply(ctx context.Context, w catalogWriter, e *storagepb.ProductEvent) {
+	switch ch := e.GetChange().(type) {
+	case *storagepb.ProductEvent_Created:
+		w.Put(ctx, ch.Created.GetProduct())
+	case *storagepb.ProductEvent_Updated:
+		w.Put(ctx, ch.Updated.GetProduct())
+	case *storagepb.ProductEvent_Deleted:
+		w.Delete(ctx, e.GetProductId())
+	case *storagepb.ProductEvent_Renamed:
+		if p, err := w.Get(ctx, e.GetProductId()); err == nil {
+			p.Name = ch.Renamed.GetName()
+			w.Put(ctx, p)
+		}
+	case *storagepb.ProductEvent_PriceChanged:
+		if p, err := w.Get(ctx, e.GetProductId()); err == nil {
+			p.Price = ch.PriceChanged.GetPrice()
+			w.Put(ctx, p)
+		}
+	}
+}
+
+// EventCounts is a projection counting the events of the log by kind
+// ("created", "renamed", "price_changed", "updated" and "deleted").
+type EventCounts struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	last   uint64
+}
+
+// NewEventCounts creates an empty EventCounts.
+func NewEventCounts() *EventCounts {
+	return &EventCounts{counts: make(map[string]int64)}
+}
+
+// Reset implements Projection.
+func (c *EventCounts) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts, c.last = make(map[string]int64), 0
+}
+
+// Apply implements Projection.
+func (c *EventCounts) Apply(e *storagepb.ProductEvent) {
+	change := e.ProtoReflect().WhichOneof(changeOneof)
+	if change == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[string(change.Name())]++
+	c.last = e.GetSequence()
+}
+
+var changeOneof = (&storagepb.ProductEvent{}).ProtoReflect().Descriptor().Oneofs().ByName("change")
+
+// Counts returns the number of events of each kind.
+func (c *EventCounts) Counts() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.counts)
+}
+
+// Sequence returns the sequence number of the last event applied.
+func (c *EventCounts) Sequence() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
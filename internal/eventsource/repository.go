@@ -0,0 +1,202 @@
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Repository is a repository.ProductRepository whose source of truth is a
+// Store. A write replays the aggregate of every product it changes, appends
+// the events that turn it into the written product and applies them to the
+// projections; reads are answered by the Catalog projection.
+//
+// Writes, and Rebuild, are serialized. Reads do not wait for them, except
+// for the Catalog's own locking.
+type Repository struct {
+	store       Store
+	catalog     *Catalog
+	projections []Projection
+	now         func() time.Time
+	mu          sync.Mutex
+}
+
+// NewRepository creates a Repository over store that maintains the catalog
+// and projections. Call Rebuild to load them from the events already stored.
+func NewRepository(store Store, projections ...Projection) *Repository {
+	c := NewCatalog()
+	return &Repository{
+		store:       store,
+		catalog:     c,
+		projections: append([]Projection{c}, projections...),
+		now:         time.Now,
+	}
+}
+
+// Rebuild resets every projection and replays the whole log into them.
+// Reads during a rebuild see a partly rebuilt catalog.
+func (r *Repository) Rebuild(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.projections {
+		p.Reset()
+	}
+	return r.store.Read(ctx, 0, func(e *storagepb.ProductEvent) bool {
+		for _, p := range r.projections {
+			p.Apply(e)
+		}
+		return true
+	})
+}
+
+// History returns the events of the product with the given ID, or
+// repository.ErrNotFound if it never existed.
+func (r *Repository) History(ctx context.Context, id string) ([]*storagepb.ProductEvent, error) {
+	events, err := r.store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return events, nil
+}
+
+// Get implements repository.ProductRepository.
+func (r *Repository) Get(ctx context.Context, id string) (*product.Product, error) {
+	return r.catalog.products().Get(ctx, id)
+}
+
+// List implements repository.ProductRepository.
+func (r *Repository) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	return r.catalog.products().List(ctx, cursor, limit)
+}
+
+// Query implements repository.Querier with the indexes of the catalog.
+func (r *Repository) Query(ctx context.Context, q repository.Query, fn func(p *product.Product) bool) (repository.Plan, error) {
+	return r.catalog.products().Query(ctx, q, fn)
+}
+
+// Put implements repository.ProductRepository.
+func (r *Repository) Put(ctx context.Context, p *product.Product) error {
+	return r.Batch(ctx, func(tx repository.Tx) error { return tx.Put(ctx, p) })
+}
+
+// Delete implements repository.ProductRepository.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	return r.Batch(ctx, func(tx repository.Tx) error { return tx.Delete(ctx, id) })
+}
+
+// Batch implements repository.ProductRepository. All events of the batch are
+// appended at once, so they are stored, and applied, together or not at all;
+// the Catalog applies them in one batch, so reads see the whole write or
+// none of it.
+// fn must not call r's write methods.
+func (r *Repository) Batch(ctx context.Context, fn func(tx repository.Tx) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tx := &eventTx{r: r, writes: make(map[string]*product.Product)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(tx.writes))
+	for id := range tx.writes {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var events []*storagepb.ProductEvent
+	now := timestamppb.New(r.now())
+	for _, id := range ids {
+		history, err := r.store.Load(ctx, id)
+		if err != nil {
+			return err
+		}
+		agg, err := Replay(history)
+		if err != nil {
+			return fmt.Errorf("event store: %w", err)
+		}
+		for _, e := range agg.Changes(id, tx.writes[id]) {
+			e.Time = now
+			events = append(events, e)
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := r.store.Append(ctx, events); err != nil {
+		return err
+	}
+	for _, p := range r.projections {
+		if bp, ok := p.(BatchProjection); ok {
+			bp.ApplyBatch(events)
+			continue
+		}
+		for _, e := range events {
+			p.Apply(e)
+		}
+	}
+	return nil
+}
+
+// AttributeDefinitions implements repository.ProductRepository.
+func (r *Repository) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	return r.store.AttributeDefinitions(ctx)
+}
+
+// PutAttributeDefinition implements repository.ProductRepository by storing
+// def in the Store.
+func (r *Repository) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.store.PutAttributeDefinition(ctx, def)
+}
+
+// eventTx stages the writes of a Batch over the catalog; a nil entry is a
+// delete.
+type eventTx struct {
+	r      *Repository
+	writes map[string]*product.Product
+}
+
+func (tx *eventTx) Get(ctx context.Context, id string) (*product.Product, error) {
+	if p, staged := tx.writes[id]; staged {
+		if p == nil {
+			return nil, repository.ErrNotFound
+		}
+		return clone(p), nil
+	}
+	return tx.r.Get(ctx, id)
+}
+
+func (tx *eventTx) Put(ctx context.Context, p *product.Product) error {
+	tx.writes[p.GetId()] = clone(p)
+	return nil
+}
+
+func (tx *eventTx) Create(ctx context.Context, p *product.Product) error {
+	_, err := tx.Get(ctx, p.GetId())
+	switch {
+	case err == nil:
+		return repository.ErrAlreadyExists
+	case !errors.Is(err, repository.ErrNotFound):
+		return err
+	}
+	return tx.Put(ctx, p)
+}
+
+func (tx *eventTx) Delete(ctx context.Context, id string) error {
+	if _, err := tx.Get(ctx, id); err != nil {
+		return err
+	}
+	tx.writes[id] = nil
+	return nil
+}
@@ -0,0 +1,181 @@
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// recorder is a projection that keeps the sequence numbers applied since
+// its last Reset.
+type recorder struct {
+	resets int
+	seqs   []uint64
+}
+
+func (r *recorder) Reset() { r.resets++; r.seqs = nil }
+
+func (r *recorder) Apply(e *storagepb.ProductEvent) { r.seqs = append(r.seqs, e.GetSequence()) }
+
+// listAll returns every product of r in ID order.
+func listAll(t *testing.T, r repository.ProductRepository) []*product.Product {
+	t.Helper()
+	page, _, err := r.List(context.Background(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestRepository_MatchesMemory(t *testing.T) {
+	ctx := context.Background()
+	r, m := NewRepository(NewMemoryStore()), repository.NewMemory()
+	write := func(fn func(tx repository.Tx) error) {
+		t.Helper()
+		errR, errM := r.Batch(ctx, fn), m.Batch(ctx, fn)
+		if fmt.Sprint(errR) != fmt.Sprint(errM) {
+			t.Fatalf("Batch: %v, memory store: %v", errR, errM)
+		}
+	}
+	for i := range 50 {
+		write(func(tx repository.Tx) error {
+			return tx.Put(ctx, &product.Product{Id: fmt.Sprintf("p%02d", i), Name: fmt.Sprint("Lamp ", i%7), Price: float64(i % 5), Tags: []string{fmt.Sprint("t", i%3)}})
+		})
+	}
+	for i := 0; i < 50; i += 4 {
+		write(func(tx repository.Tx) error {
+			p, err := tx.Get(ctx, fmt.Sprintf("p%02d", i))
+			if err != nil {
+				return err
+			}
+			p.Name, p.Tags = "Chair", []string{"t9"}
+			if err := tx.Put(ctx, p); err != nil {
+				return err
+			}
+			return tx.Delete(ctx, fmt.Sprintf("p%02d", i+1))
+		})
+	}
+	write(func(tx repository.Tx) error { return tx.Delete(ctx, "p01") })
+	write(func(tx repository.Tx) error {
+		tx.Put(ctx, &product.Product{Id: "rolled back"})
+		return errors.New("boom")
+	})
+
+	got, want := listAll(t, r), listAll(t, m)
+	if !slices.EqualFunc(got, want, func(a, b *product.Product) bool { return proto.Equal(a, b) }) {
+		t.Fatalf("listed %v, want %v", got, want)
+	}
+	flt, _ := filter.Parse(`tags = "t9" AND price < 3`)
+	var ids []string
+	if _, err := r.Query(ctx, repository.Query{Filter: flt}, func(p *product.Product) bool {
+		ids = append(ids, p.GetId())
+		return true
+	}); err != nil || len(ids) == 0 {
+		t.Fatalf("Query = %v, %v", ids, err)
+	}
+	if _, err := r.Get(ctx, "rolled back"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Get of a rolled-back put: %v", err)
+	}
+}
+
+func TestRepository_RebuildsProjectionsFromScratch(t *testing.T) {
+	ctx := context.Background()
+	store, counts, rec := NewMemoryStore(), NewEventCounts(), &recorder{}
+	r := NewRepository(store, counts, rec)
+	r.Put(ctx, &product.Product{Id: "a", Name: "A", Price: 1})
+	r.Put(ctx, &product.Product{Id: "b", Name: "B"})
+	r.Batch(ctx, func(tx repository.Tx) error {
+		tx.Put(ctx, &product.Product{Id: "a", Name: "A2", Price: 2})
+		return tx.Delete(ctx, "b")
+	})
+	r.Put(ctx, &product.Product{Id: "a", Name: "A2", Price: 2})
+	before := listAll(t, r)
+	if !slices.Equal(rec.seqs, []uint64{1, 2, 3, 4, 5}) {
+		t.Fatalf("projection applied %v", rec.seqs)
+	}
+
+	// A new repository over the same log rebuilds the same state.
+	counts2, rec2 := NewEventCounts(), &recorder{}
+	r2 := NewRepository(store, counts2, rec2)
+	if err := r2.Rebuild(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := listAll(t, r2); !slices.EqualFunc(got, before, func(a, b *product.Product) bool { return proto.Equal(a, b) }) {
+		t.Fatalf("rebuilt catalog %v, want %v", got, before)
+	}
+	want := map[string]int64{"created": 2, "renamed": 1, "price_changed": 1, "deleted": 1}
+	if got := counts2.Counts(); !maps.Equal(got, want) || counts2.Sequence() != 5 {
+		t.Fatalf("rebuilt counts %v at %d, want %v at 5", got, counts2.Sequence(), want)
+	}
+
+	// Rebuilding again starts over rather than applying the log twice.
+	if err := r.Rebuild(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if rec.resets != 1 || len(rec.seqs) != 5 || !maps.Equal(counts.Counts(), want) {
+		t.Fatalf("after a second rebuild: %d resets, %v applied, counts %v", rec.resets, rec.seqs, counts.Counts())
+	}
+	history, err := r.History(ctx, "b")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("History(b) = %v, %v", kinds(history), err)
+	}
+	if _, err := r.History(ctx, "never"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("History of a product never stored: %v", err)
+	}
+}
+
+func TestRepository_FailedAppendChangesNothing(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	r := NewRepository(NewFileStore(filepath.Join(t.TempDir(), "events.log")), rec)
+	if err := r.Put(ctx, &product.Product{Id: "a"}); err == nil {
+		t.Fatal("Put succeeded on a store that is not open")
+	}
+	if _, err := r.Get(ctx, "a"); !errors.Is(err, repository.ErrNotFound) || len(rec.seqs) != 0 {
+		t.Fatalf("failed write applied: %v, %v", err, rec.seqs)
+	}
+}
+
+func TestRepository_ReadersSeeWholeBatches(t *testing.T) {
+	ctx := context.Background()
+	r := NewRepository(NewMemoryStore())
+	r.Put(ctx, &product.Product{Id: "a", Name: "A", Price: 1})
+
+	// Each batch moves the one product to the other ID and reprices it, so a
+	// reader that sees part of one finds zero or two products.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		from, to := "a", "b"
+		for i := range 200 {
+			r.Batch(ctx, func(tx repository.Tx) error {
+				if err := tx.Delete(ctx, from); err != nil {
+					return err
+				}
+				return tx.Put(ctx, &product.Product{Id: to, Name: "A", Price: float64(i + 2)})
+			})
+			from, to = to, from
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if page := listAll(t, r); len(page) != 1 {
+			t.Fatalf("a reader saw part of a batch: %v", page)
+		}
+	}
+}
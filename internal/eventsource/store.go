@@ -0,0 +1,260 @@
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/wal"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// MemoryStore is a Store that keeps events in memory, indexed by product.
+// Events it returns must not be modified.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	events    []*storagepb.ProductEvent
+	byProduct map[string][]*storagepb.ProductEvent
+	defs      map[string]*product.AttributeDefinition
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		byProduct: make(map[string][]*storagepb.ProductEvent),
+		defs:      make(map[string]*product.AttributeDefinition),
+	}
+}
+
+// Append implements Store. The store keeps events, which must not be
+// modified afterwards.
+func (s *MemoryStore) Append(ctx context.Context, events []*storagepb.ProductEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.number(events); err != nil {
+		return err
+	}
+	s.add(events)
+	return nil
+}
+
+// number checks the versions of events and sets their sequence numbers.
+// Callers must hold s.mu for writing.
+func (s *MemoryStore) number(events []*storagepb.ProductEvent) error {
+	versions := make(map[string]uint64)
+	for _, e := range events {
+		id := e.GetProductId()
+		v, ok := versions[id]
+		if !ok {
+			v = uint64(len(s.byProduct[id]))
+		}
+		if e.GetVersion() != v+1 {
+			return fmt.Errorf("%w: product %q is at version %d, not %d", ErrVersionConflict, id, v, e.GetVersion()-1)
+		}
+		versions[id] = v + 1
+	}
+	for i, e := range events {
+		e.Sequence = uint64(len(s.events) + i + 1)
+	}
+	return nil
+}
+
+// add stores numbered events. Callers must hold s.mu for writing.
+func (s *MemoryStore) add(events []*storagepb.ProductEvent) {
+	for _, e := range events {
+		s.events = append(s.events, e)
+		s.byProduct[e.GetProductId()] = append(s.byProduct[e.GetProductId()], e)
+	}
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(ctx context.Context, productID string) ([]*storagepb.ProductEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := s.byProduct[productID]
+	return events[:len(events):len(events)], nil
+}
+
+// Read implements Store. fn runs without the store locked, on the events
+// stored when Read was called.
+func (s *MemoryStore) Read(ctx context.Context, after uint64, fn func(e *storagepb.ProductEvent) bool) error {
+	s.mu.RLock()
+	events := s.events[min(after, uint64(len(s.events))):]
+	s.mu.RUnlock()
+	for _, e := range events {
+		if !fn(e) {
+			break
+		}
+	}
+	return nil
+}
+
+// PutAttributeDefinition implements Store.
+func (s *MemoryStore) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putDefinition(def)
+	return nil
+}
+
+// putDefinition stores a copy of def. Callers must hold s.mu for writing.
+func (s *MemoryStore) putDefinition(def *product.AttributeDefinition) {
+	s.defs[def.GetName()] = proto.Clone(def).(*product.AttributeDefinition)
+}
+
+// AttributeDefinitions implements Store.
+func (s *MemoryStore) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	defs := make([]*product.AttributeDefinition, 0, len(s.defs))
+	for _, def := range s.defs {
+		defs = append(defs, proto.Clone(def).(*product.AttributeDefinition))
+	}
+	slices.SortFunc(defs, func(a, b *product.AttributeDefinition) int { return strings.Compare(a.GetName(), b.GetName()) })
+	return defs, nil
+}
+
+// FileStore is a Store that appends the events of every Append, and every
+// attribute definition put, to a file as one CRC-checked frame, fsynced
+// before the call returns, and keeps them in memory as well. Open reads the
+// file and drops a torn last frame.
+type FileStore struct {
+	MemoryStore
+	path string
+	file *os.File
+}
+
+// NewFileStore creates a FileStore for the file at path; call Open before
+// using it.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		MemoryStore: MemoryStore{
+			byProduct: make(map[string][]*storagepb.ProductEvent),
+			defs:      make(map[string]*product.AttributeDefinition),
+		},
+		path: path,
+	}
+}
+
+// Open reads the events stored in the file, creating it and its directory if
+// needed.
+func (s *FileStore) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.events, s.byProduct, s.defs = nil, make(map[string][]*storagepb.ProductEvent), make(map[string]*product.AttributeDefinition)
+	off := 0
+	for off < len(data) {
+		batch := &storagepb.EventBatch{}
+		n, err := wal.Decode(data[off:], batch)
+		if err != nil {
+			break
+		}
+		for i, e := range batch.GetEvents() {
+			if want := uint64(len(s.events) + i + 1); e.GetSequence() != want {
+				f.Close()
+				return fmt.Errorf("%s: event %d where %d was expected", s.path, e.GetSequence(), want)
+			}
+		}
+		s.add(batch.GetEvents())
+		for _, def := range batch.GetAttributeDefinitions() {
+			s.putDefinition(def)
+		}
+		off += n
+	}
+	if off < len(data) {
+		if err := f.Truncate(int64(off)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if _, err := f.Seek(int64(off), io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	return nil
+}
+
+// Close closes the file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// Append implements Store.
+func (s *FileStore) Append(ctx context.Context, events []*storagepb.ProductEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("event store is not open")
+	}
+	if err := s.number(events); err != nil {
+		return err
+	}
+	if err := s.write(&storagepb.EventBatch{Events: events}); err != nil {
+		return err
+	}
+	s.add(events)
+	return nil
+}
+
+// PutAttributeDefinition implements Store.
+func (s *FileStore) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("event store is not open")
+	}
+	if err := s.write(&storagepb.EventBatch{AttributeDefinitions: []*product.AttributeDefinition{def}}); err != nil {
+		return err
+	}
+	s.putDefinition(def)
+	return nil
+}
+
+// write appends batch to the file as one frame and fsyncs it. Callers must
+// hold s.mu for writing.
+func (s *FileStore) write(batch *storagepb.EventBatch) error {
+	frame, err := wal.Encode(batch)
+	if err == nil {
+		_, err = s.file.Write(frame)
+	}
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// The frame may be partly written; a torn frame is dropped on the
+		// next Open, but nothing may follow it.
+		s.file.Close()
+		s.file = nil
+		return fmt.Errorf("event store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+)
+
+func created(id string, version uint64) *storagepb.ProductEvent {
+	return &storagepb.ProductEvent{ProductId: id, Version: version, Change: &storagepb.ProductEvent_Created{Created: &storagepb.ProductCreated{Product: &product.Product{Id: id}}}}
+}
+
+func deleted(id string, version uint64) *storagepb.ProductEvent {
+	return &storagepb.ProductEvent{ProductId: id, Version: version, Change: &storagepb.ProductEvent_Deleted{Deleted: &storagepb.Deleted{}}}
+}
+
+// sequences returns the sequence numbers of the events of s after after.
+func sequences(t *testing.T, s Store, after uint64) []uint64 {
+	t.Helper()
+	var seqs []uint64
+	if err := s.Read(context.Background(), after, func(e *storagepb.ProductEvent) bool {
+		seqs = append(seqs, e.GetSequence())
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return seqs
+}
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	if err := s.Append(ctx, []*storagepb.ProductEvent{created("a", 1), created("b", 1), deleted("a", 2)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, events := range [][]*storagepb.ProductEvent{
+		{created("c", 1), created("a", 2)},
+		{created("c", 2)},
+		{created("c", 1), deleted("c", 1)},
+	} {
+		if err := s.Append(ctx, events); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("Append(%v) = %v, want ErrVersionConflict", kinds(events), err)
+		}
+	}
+	if err := s.Append(ctx, []*storagepb.ProductEvent{created("a", 3)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(t, s, 0); len(got) != 4 || got[3] != 4 {
+		t.Fatalf("sequences %v after a failed append", got)
+	}
+	if got := sequences(t, s, 2); len(got) != 2 || got[0] != 3 {
+		t.Fatalf("sequences after 2: %v", got)
+	}
+	history, err := s.Load(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := kinds(history); len(got) != 3 || got[1] != "deleted" {
+		t.Fatalf("history of a: %v", got)
+	}
+	if history, _ := s.Load(ctx, "c"); len(history) != 0 {
+		t.Fatalf("history of a product never stored: %v", history)
+	}
+
+	for _, def := range []*product.AttributeDefinition{
+		{Name: "voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_STRING},
+		{Name: "color", Type: product.AttributeType_ATTRIBUTE_TYPE_STRING},
+		{Name: "voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER},
+	} {
+		if err := s.PutAttributeDefinition(ctx, def); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkDefinitions(t, s)
+	if got := sequences(t, s, 0); len(got) != 4 {
+		t.Fatalf("putting definitions changed the events: %v", got)
+	}
+}
+
+// checkDefinitions checks that s holds the definitions put by testStore.
+func checkDefinitions(t *testing.T, s Store) {
+	t.Helper()
+	defs, err := s.AttributeDefinitions(context.Background())
+	if err != nil || len(defs) != 2 || defs[0].GetName() != "color" ||
+		defs[1].GetName() != "voltage" || defs[1].GetType() != product.AttributeType_ATTRIBUTE_TYPE_NUMBER {
+		t.Fatalf("AttributeDefinitions = %v, %v", defs, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "events.log")
+	s := NewFileStore(path)
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(context.Background(), []*storagepb.ProductEvent{created("d", 1)}); err == nil {
+		t.Fatal("appended to a closed store")
+	}
+
+	// A frame torn by a crash is dropped, and appends continue after the
+	// last whole one.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{40, 0, 0, 0, 1, 2})
+	f.Close()
+	s = NewFileStore(path)
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Append(context.Background(), []*storagepb.ProductEvent{deleted("b", 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(t, s, 0); len(got) != 5 || got[4] != 5 {
+		t.Fatalf("sequences after reopening: %v", got)
+	}
+	s.Close()
+	s = NewFileStore(path)
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := sequences(t, s, 0); len(got) != 5 {
+		t.Fatalf("sequences after reopening again: %v", got)
+	}
+	checkDefinitions(t, s)
+}
@@ -0,0 +1,114 @@
+// Package fieldmask applies read masks (google.protobuf.FieldMask) to
+// responses, clearing every field the caller did not ask for.
+package fieldmask
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Wildcard is the read mask path that selects every field.
+const Wildcard = "*"
+
+// Mask is a compiled read mask: a tree of selected fields. A nil Mask selects
+// every field.
+type Mask struct {
+	// fields maps a selected field to the mask of its sub-fields; a nil
+	// sub-mask selects the whole field.
+	fields map[protoreflect.Name]*Mask
+}
+
+// Compile checks the paths of mask against md and returns the compiled mask.
+// Paths use proto field names separated by dots; only singular message fields
+// can be traversed. An empty mask, or one containing Wildcard, compiles to nil.
+func Compile(md protoreflect.MessageDescriptor, mask *fieldmaskpb.FieldMask) (*Mask, error) {
+	paths := mask.GetPaths()
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	root := &Mask{fields: map[protoreflect.Name]*Mask{}}
+	wildcard := false
+	for _, path := range paths {
+		if path == Wildcard {
+			wildcard = true
+			continue
+		}
+		if err := root.add(md, path); err != nil {
+			return nil, err
+		}
+	}
+	if wildcard {
+		return nil, nil
+	}
+	return root, nil
+}
+
+// add checks every segment of path against md, then merges path into m. A
+// path under a field that m already selects as a whole adds nothing.
+func (m *Mask) add(md protoreflect.MessageDescriptor, path string) error {
+	segments := strings.Split(path, ".")
+	names := make([]protoreflect.Name, len(segments))
+	for i, seg := range segments {
+		fd := md.Fields().ByName(protoreflect.Name(seg))
+		if fd == nil {
+			return fmt.Errorf("unknown field %q in path %q", seg, path)
+		}
+		if i < len(segments)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return fmt.Errorf("path %q: %q has no sub-fields", path, seg)
+			}
+			md = fd.Message()
+		}
+		names[i] = fd.Name()
+	}
+
+	node := m
+	for i, name := range names {
+		sub, seen := node.fields[name]
+		if seen && sub == nil {
+			// A shorter path already selects the whole field.
+			return nil
+		}
+		if i == len(names)-1 {
+			node.fields[name] = nil
+			return nil
+		}
+		if sub == nil {
+			sub = &Mask{fields: map[protoreflect.Name]*Mask{}}
+			node.fields[name] = sub
+		}
+		node = sub
+	}
+	return nil
+}
+
+// Apply clears every field of msg that m does not select. It modifies msg in
+// place, so callers must not pass shared messages.
+func (m *Mask) Apply(msg proto.Message) {
+	if m == nil || msg == nil {
+		return
+	}
+	m.apply(msg.ProtoReflect())
+}
+
+func (m *Mask) apply(msg protoreflect.Message) {
+	var unselected []protoreflect.FieldDescriptor
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		sub, ok := m.fields[fd.Name()]
+		switch {
+		case !ok:
+			unselected = append(unselected, fd)
+		case sub != nil:
+			sub.apply(v.Message())
+		}
+		return true
+	})
+	// Fields are cleared after Range, which must not see the message change.
+	for _, fd := range unselected {
+		msg.Clear(fd)
+	}
+}
@@ -0,0 +1,92 @@
+package fieldmask
+
+import (
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func compile(t *testing.T, m proto.Message, paths ...string) *Mask {
+	t.Helper()
+	mask, err := Compile(m.ProtoReflect().Descriptor(), &fieldmaskpb.FieldMask{Paths: paths})
+	if err != nil {
+		t.Fatalf("Compile(%v): %v", paths, err)
+	}
+	return mask
+}
+
+func widget() *product.Product {
+	return &product.Product{
+		Id:          "prod-1",
+		Name:        "Widget",
+		Description: "A long description",
+		Price:       9.99,
+		Tags:        []string{"acme"},
+		Attributes:  map[string]*product.AttributeValue{"color": {Kind: &product.AttributeValue_StringValue{StringValue: "red"}}},
+	}
+}
+
+func TestApply_KeepsOnlySelectedFields(t *testing.T) {
+	p := widget()
+	compile(t, p, "id", "name", "price").Apply(p)
+	want := &product.Product{Id: "prod-1", Name: "Widget", Price: 9.99}
+	if !proto.Equal(p, want) {
+		t.Fatalf("got %v, want %v", p, want)
+	}
+}
+
+func TestApply_NestedPaths(t *testing.T) {
+	p := &productv2.Product{Id: "prod-1", Name: "Widget", Price: &productv2.Money{CurrencyCode: "USD", Units: 9, Nanos: 990000000}}
+	compile(t, p, "id", "price.units").Apply(p)
+	want := &productv2.Product{Id: "prod-1", Price: &productv2.Money{Units: 9}}
+	if !proto.Equal(p, want) {
+		t.Fatalf("got %v, want %v", p, want)
+	}
+
+	// A path selecting the whole field wins over a nested one, in either order.
+	for _, paths := range [][]string{{"price.units", "price"}, {"price", "price.units"}} {
+		p := &productv2.Product{Name: "Widget", Price: &productv2.Money{CurrencyCode: "USD", Units: 9}}
+		compile(t, p, paths...).Apply(p)
+		if p.GetPrice().GetCurrencyCode() != "USD" || p.GetName() != "" {
+			t.Fatalf("paths %v: got %v", paths, p)
+		}
+	}
+}
+
+func TestCompile_EmptyOrWildcardSelectsEverything(t *testing.T) {
+	for _, paths := range [][]string{nil, {"*"}, {"id", "*"}} {
+		p := widget()
+		compile(t, p, paths...).Apply(p)
+		if !proto.Equal(p, widget()) {
+			t.Fatalf("paths %v pruned the message: %v", paths, p)
+		}
+	}
+}
+
+func TestCompile_RejectsUnknownPaths(t *testing.T) {
+	md := (&productv2.Product{}).ProtoReflect().Descriptor()
+	for _, path := range []string{"nope", "price.nope", "name.length", "tags.first", "attributes.color", "", "price."} {
+		if _, err := Compile(md, &fieldmaskpb.FieldMask{Paths: []string{path}}); err == nil {
+			t.Errorf("expected an error for path %q", path)
+		}
+	}
+}
+
+func TestCompile_RejectsUnknownPathsUnderSelectedFields(t *testing.T) {
+	md := (&productv2.Product{}).ProtoReflect().Descriptor()
+	for _, paths := range [][]string{{"price", "price.nope"}, {"price", "price.units.x"}, {"price", "price."}} {
+		if _, err := Compile(md, &fieldmaskpb.FieldMask{Paths: paths}); err == nil {
+			t.Errorf("expected an error for paths %q", paths)
+		}
+	}
+	// A valid path under a selected field is covered by it.
+	p := &productv2.Product{Id: "prod-1", Price: &productv2.Money{CurrencyCode: "USD", Units: 9, Nanos: 990000000}}
+	compile(t, p, "price", "price.units").Apply(p)
+	if want := (&productv2.Product{Price: &productv2.Money{CurrencyCode: "USD", Units: 9, Nanos: 990000000}}); !proto.Equal(p, want) {
+		t.Fatalf("got %v, want %v", p, want)
+	}
+}
@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the type of a field that a filter compares.
+type Type int
+
+const (
+	TypeString Type = iota
+	TypeNumber
+	TypeBool
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeNumber:
+		return "number"
+	case TypeBool:
+		return "bool"
+	}
+	return "string"
+}
+
+// TypeError reports a comparison that cannot match any product because the
+// field and the value differ in type, or a field that does not exist.
+type TypeError struct {
+	Field string
+	Msg   string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("invalid filter on %s: %s", e.Field, e.Msg)
+}
+
+// Check type-checks every comparison of f against the product schema: the
+// built-in fields have fixed types, and attrType returns the type of the
+// attribute name, or false if it is not defined. Numbers compare with
+// unquoted numeric values, bools with true or false (by =, != and : only),
+// and strings with anything but an unquoted number; any field may be tested
+// for presence with ":*". It returns the first *TypeError, or nil.
+func (f *Filter) Check(attrType func(name string) (Type, bool)) error {
+	if f == nil {
+		return nil
+	}
+	var err error
+	Walk(f.Expr, func(c *Comparison) {
+		if err == nil {
+			err = c.check(attrType)
+		}
+	})
+	return err
+}
+
+func (c *Comparison) check(attrType func(name string) (Type, bool)) error {
+	var typ Type
+	switch c.Field {
+	case "price":
+		typ = TypeNumber
+	case "id", "name", "description", "tags", "categories":
+		typ = TypeString
+	default:
+		name := strings.TrimPrefix(c.Field, attributesPrefix)
+		t, ok := attrType(name)
+		if !ok {
+			return &TypeError{Field: c.Field, Msg: fmt.Sprintf("undefined attribute %q", name)}
+		}
+		typ = t
+	}
+	v := c.Value
+	if c.Op == OpHas && v.Text == "*" && !v.Quoted {
+		return nil
+	}
+	switch typ {
+	case TypeNumber:
+		if !v.IsNumber {
+			return &TypeError{Field: c.Field, Msg: fmt.Sprintf("%s is a number, not %s", c.Field, v.describe())}
+		}
+	case TypeBool:
+		if v.Quoted || (v.Text != "true" && v.Text != "false") {
+			return &TypeError{Field: c.Field, Msg: fmt.Sprintf("%s is a bool, not %s", c.Field, v.describe())}
+		}
+		if c.Op != OpEq && c.Op != OpNe && c.Op != OpHas {
+			return &TypeError{Field: c.Field, Msg: fmt.Sprintf("bools do not support %s", c.Op)}
+		}
+	default:
+		if v.IsNumber {
+			return &TypeError{Field: c.Field, Msg: fmt.Sprintf("%s is a string, not the number %s; quote the value to compare text", c.Field, v.Text)}
+		}
+	}
+	return nil
+}
+
+// describe names the literal in a type error.
+func (l Literal) describe() string {
+	if l.Quoted {
+		return fmt.Sprintf("the string %q", l.Text)
+	}
+	return fmt.Sprintf("%q", l.Text)
+}
@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+const attributesPrefix = "attributes."
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindNumber
+	kindBool
+	kindList
+)
+
+// value is a product field resolved for comparison.
+type value struct {
+	kind valueKind
+	str  string
+	num  float64
+	b    bool
+	list []string
+}
+
+func checkField(name string) error {
+	switch name {
+	case "id", "name", "description", "price", "tags", "categories":
+		return nil
+	}
+	if attr, ok := strings.CutPrefix(name, attributesPrefix); ok && attr != "" && !strings.Contains(attr, ".") {
+		return nil
+	}
+	return fmt.Errorf("unknown field %q", name)
+}
+
+func lookup(p *product.Product, field string) (value, bool) {
+	switch field {
+	case "id":
+		return value{kind: kindString, str: p.GetId()}, true
+	case "name":
+		return value{kind: kindString, str: p.GetName()}, true
+	case "description":
+		return value{kind: kindString, str: p.GetDescription()}, true
+	case "price":
+		return value{kind: kindNumber, num: p.GetPrice()}, true
+	case "tags":
+		if len(p.GetTags()) == 0 {
+			return value{}, false
+		}
+		return value{kind: kindList, list: p.GetTags()}, true
+	case "categories":
+		if len(p.GetCategories()) == 0 {
+			return value{}, false
+		}
+		return value{kind: kindList, list: p.GetCategories()}, true
+	}
+	attr := p.GetAttributes()[strings.TrimPrefix(field, attributesPrefix)]
+	switch k := attr.GetKind().(type) {
+	case *product.AttributeValue_StringValue:
+		return value{kind: kindString, str: k.StringValue}, true
+	case *product.AttributeValue_EnumValue:
+		return value{kind: kindString, str: k.EnumValue}, true
+	case *product.AttributeValue_NumberValue:
+		return value{kind: kindNumber, num: k.NumberValue}, true
+	case *product.AttributeValue_BoolValue:
+		return value{kind: kindBool, b: k.BoolValue}, true
+	}
+	return value{}, false
+}
+
+func (v value) compare(op Op, lit Literal) bool {
+	switch v.kind {
+	case kindNumber:
+		if !lit.IsNumber {
+			return false
+		}
+		return compareOrdered(op, v.num, lit.Number)
+	case kindBool:
+		want := lit.Text == "true"
+		if lit.Quoted || (!want && lit.Text != "false") {
+			return false
+		}
+		switch op {
+		case OpEq, OpHas:
+			return v.b == want
+		case OpNe:
+			return v.b != want
+		}
+		return false
+	case kindList:
+		// A list matches when any element matches; != matches when none is equal.
+		if op == OpNe {
+			return !v.compare(OpEq, lit)
+		}
+		for _, item := range v.list {
+			if (value{kind: kindString, str: item}).compare(op, lit) {
+				return true
+			}
+		}
+		return false
+	default:
+		if op == OpEq || op == OpHas {
+			if prefix, ok := strings.CutSuffix(lit.Text, "*"); ok {
+				return strings.HasPrefix(v.str, prefix)
+			}
+		}
+		return compareOrdered(op, v.str, lit.Text)
+	}
+}
+
+func compareOrdered[T string | float64](op Op, a, b T) bool {
+	switch op {
+	case OpEq, OpHas:
+		return a == b
+	case OpNe:
+		return a != b
+	case OpLt:
+		return a < b
+	case OpLe:
+		return a <= b
+	case OpGt:
+		return a > b
+	case OpGe:
+		return a >= b
+	}
+	return false
+}
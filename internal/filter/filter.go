@@ -0,0 +1,304 @@
+// Package filter parses and evaluates ListProducts filter expressions.
+//
+// The grammar is a small subset of AIP-160:
+//
+//	expr       = and { "OR" and }
+//	and        = unary { "AND" unary }
+//	unary      = [ "NOT" ] primary
+//	primary    = "(" expr ")" | comparison
+//	comparison = field op value
+//	op         = "=" | "!=" | "<" | "<=" | ">" | ">=" | ":"
+//	value      = quoted string | number | true | false | bare word | "*"
+//
+// NOT binds tighter than AND, which binds tighter than OR. Fields are id, name,
+// description, price, tags, categories and attributes.<name>. A string value
+// ending in "*" used with "=" matches by prefix, and "field:*" tests that a
+// field is present. Repeated fields such as tags match when any element
+// matches ("tags:acme"). Numbers are finite; bare words such as inf and nan
+// are strings.
+// Comparisons against an absent field are always false. Filter.Check rejects
+// comparisons of values with fields of another type, which would never match.
+package filter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+// Op is a comparison operator.
+type Op string
+
+const (
+	OpEq  Op = "="
+	OpNe  Op = "!="
+	OpLt  Op = "<"
+	OpLe  Op = "<="
+	OpGt  Op = ">"
+	OpGe  Op = ">="
+	OpHas Op = ":"
+)
+
+// Expr is a node of a parsed filter expression.
+type Expr interface {
+	Match(p *product.Product) bool
+}
+
+// And matches when every term matches.
+type And struct{ Terms []Expr }
+
+// Or matches when any term matches.
+type Or struct{ Terms []Expr }
+
+// Not inverts X.
+type Not struct{ X Expr }
+
+// Comparison compares a product field with a literal.
+type Comparison struct {
+	Field string
+	Op    Op
+	Value Literal
+}
+
+// Literal is a filter value. Text holds the literal as written (unquoted);
+// Number and IsNumber are set when Text parses as a float.
+type Literal struct {
+	Text     string
+	Quoted   bool
+	Number   float64
+	IsNumber bool
+}
+
+// Filter is a parsed filter expression. A nil *Filter matches every product.
+type Filter struct {
+	Expr Expr
+	src  string
+}
+
+// Match reports whether p satisfies the filter.
+func (f *Filter) Match(p *product.Product) bool {
+	if f == nil || f.Expr == nil {
+		return true
+	}
+	return f.Expr.Match(p)
+}
+
+// String returns the source expression.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.src
+}
+
+// Attributes returns the attribute names referenced by the filter, in order of appearance.
+func (f *Filter) Attributes() []string {
+	if f == nil {
+		return nil
+	}
+	var names []string
+	Walk(f.Expr, func(c *Comparison) {
+		if name, ok := strings.CutPrefix(c.Field, attributesPrefix); ok {
+			names = append(names, name)
+		}
+	})
+	return names
+}
+
+// Walk calls fn for every comparison in e.
+func Walk(e Expr, fn func(*Comparison)) {
+	switch e := e.(type) {
+	case *And:
+		for _, t := range e.Terms {
+			Walk(t, fn)
+		}
+	case *Or:
+		for _, t := range e.Terms {
+			Walk(t, fn)
+		}
+	case *Not:
+		Walk(e.X, fn)
+	case *Comparison:
+		fn(e)
+	}
+}
+
+func (e *And) Match(p *product.Product) bool {
+	for _, t := range e.Terms {
+		if !t.Match(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Or) Match(p *product.Product) bool {
+	for _, t := range e.Terms {
+		if t.Match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Not) Match(p *product.Product) bool { return !e.X.Match(p) }
+
+func (c *Comparison) Match(p *product.Product) bool {
+	v, ok := lookup(p, c.Field)
+	if !ok {
+		return false
+	}
+	if c.Op == OpHas && c.Value.Text == "*" && !c.Value.Quoted {
+		return true
+	}
+	return v.compare(c.Op, c.Value)
+}
+
+// SyntaxError reports an invalid filter expression.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter at offset %d: %s", e.Pos, e.Msg)
+}
+
+// MaxDepth is the deepest nesting of parentheses Parse accepts.
+const MaxDepth = 64
+
+// Parse parses a filter expression. An empty or blank expression yields a nil
+// *Filter, which matches everything.
+func Parse(src string) (*Filter, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return &Filter{Expr: e, src: src}, nil
+}
+
+type parser struct {
+	toks  []token
+	i     int
+	depth int // of the parentheses around the current token
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	terms := []Expr{first}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		t, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &Or{Terms: terms}, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	terms := []Expr{first}
+	for p.peek().isKeyword("AND") {
+		p.next()
+		t, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &And{Terms: terms}, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().isKeyword("NOT") {
+		p.next()
+		x, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		if p.depth == MaxDepth {
+			return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("parentheses nested deeper than %d", MaxDepth)}
+		}
+		p.depth++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return nil, &SyntaxError{Pos: c.pos, Msg: "expected )"}
+		}
+		p.depth--
+		return e, nil
+	case tokWord:
+		if t.quoted {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "expected a field name"}
+		}
+		if err := checkField(t.text); err != nil {
+			return nil, &SyntaxError{Pos: t.pos, Msg: err.Error()}
+		}
+		op := p.next()
+		if op.kind != tokOp {
+			return nil, &SyntaxError{Pos: op.pos, Msg: fmt.Sprintf("expected an operator after %q", t.text)}
+		}
+		v := p.next()
+		if v.kind != tokWord {
+			return nil, &SyntaxError{Pos: v.pos, Msg: "expected a value"}
+		}
+		lit := Literal{Text: v.text, Quoted: v.quoted}
+		if n, err := strconv.ParseFloat(v.text, 64); err == nil && !v.quoted && !math.IsInf(n, 0) && !math.IsNaN(n) {
+			lit.Number, lit.IsNumber = n, true
+		}
+		return &Comparison{Field: t.text, Op: Op(op.text), Value: lit}, nil
+	case tokEOF:
+		return nil, &SyntaxError{Pos: t.pos, Msg: "unexpected end of expression"}
+	default:
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+}
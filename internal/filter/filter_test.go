@@ -0,0 +1,174 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+func testProduct() *product.Product {
+	return &product.Product{
+		Id: "prod-1", Name: "Widget A", Description: "A useful widget", Price: 9.99,
+		Tags: []string{"acme", "tools"},
+		Attributes: map[string]*product.AttributeValue{
+			"voltage": {Kind: &product.AttributeValue_NumberValue{NumberValue: 220}},
+			"fabric":  {Kind: &product.AttributeValue_EnumValue{EnumValue: "wool"}},
+			"fragile": {Kind: &product.AttributeValue_BoolValue{BoolValue: true}},
+		},
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	f, err := Parse("   ")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if f != nil {
+		t.Fatalf("expected nil filter for blank expression, got %v", f)
+	}
+	if !f.Match(testProduct()) {
+		t.Fatal("nil filter should match every product")
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	p := testProduct()
+	cases := map[string]bool{
+		`id = "prod-1"`:                                true,
+		`id = prod-2`:                                  false,
+		`price < 10`:                                   true,
+		`price >= 10`:                                  false,
+		`name = "Wid*"`:                                true,
+		`name = "Gad*"`:                                false,
+		`attributes.voltage = 220`:                     true,
+		`attributes.voltage > 110 AND price < 5`:       false,
+		`attributes.voltage > 110 OR price < 5`:        true,
+		`attributes.fabric = wool`:                     true,
+		`attributes.fabric != wool`:                    false,
+		`attributes.fragile = true`:                    true,
+		`attributes.isbn:*`:                            false,
+		`attributes.voltage:*`:                         true,
+		`NOT attributes.isbn:*`:                        true,
+		`(price < 5 OR price > 9) AND NOT id = prod-2`: true,
+		`tags:acme`:                                    true,
+		`tags = "too*"`:                                true,
+		`tags:garden`:                                  false,
+		`tags != garden`:                               true,
+		`tags != acme`:                                 false,
+		`attributes.fabric != café`:                    true,
+		`description = inf`:                            false,
+	}
+	for expr, want := range cases {
+		f, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", expr, err)
+		}
+		if got := f.Match(p); got != want {
+			t.Fatalf("Match(%q): got %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestParse_SyntaxErrors(t *testing.T) {
+	for _, expr := range []string{
+		`price <`,
+		`price 10`,
+		`(price < 10`,
+		`color = red`,
+		`name = "unterminated`,
+		`price < 10 AND`,
+		`price ! 10`,
+	} {
+		_, err := Parse(expr)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Fatalf("Parse(%q): expected *SyntaxError, got %v", expr, err)
+		}
+	}
+}
+
+func TestParse_Words(t *testing.T) {
+	for expr, want := range map[string]Literal{
+		`attributes.fabric = café`: {Text: "café"},
+		`attributes.fabric = 日本`:   {Text: "日本"},
+		`attributes.size = inf`:    {Text: "inf"},
+		`attributes.size = NaN`:    {Text: "NaN"},
+		`attributes.size = 1e400`:  {Text: "1e400"},
+		`attributes.size = -2.5e3`: {Text: "-2.5e3", Number: -2500, IsNumber: true},
+	} {
+		f, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", expr, err)
+		}
+		if got := f.Expr.(*Comparison).Value; got != want {
+			t.Errorf("Parse(%q) value = %+v, want %+v", expr, got, want)
+		}
+	}
+	var serr *SyntaxError
+	if _, err := Parse("name = caf\xe9"); !errors.As(err, &serr) {
+		t.Fatalf("Parse of invalid UTF-8: expected *SyntaxError, got %v", err)
+	}
+}
+
+func TestParse_LimitsNesting(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "price < 10" + strings.Repeat(")", depth)
+	}
+	if _, err := Parse(nested(MaxDepth)); err != nil {
+		t.Fatalf("Parse at the nesting limit: %v", err)
+	}
+	for _, expr := range []string{nested(MaxDepth + 1), strings.Repeat("(", 1<<20)} {
+		var serr *SyntaxError
+		if _, err := Parse(expr); !errors.As(err, &serr) {
+			t.Fatalf("Parse of %d bytes nested too deep: expected *SyntaxError, got %v", len(expr), err)
+		}
+	}
+}
+
+func TestFilter_Attributes(t *testing.T) {
+	f, err := Parse(`attributes.voltage > 1 AND (price < 2 OR NOT attributes.fabric = wool)`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	got := f.Attributes()
+	if len(got) != 2 || got[0] != "voltage" || got[1] != "fabric" {
+		t.Fatalf("unexpected attributes: %v", got)
+	}
+}
+
+func TestFilter_Check(t *testing.T) {
+	attrs := map[string]Type{"voltage": TypeNumber, "fabric": TypeString, "fragile": TypeBool}
+	attrType := func(name string) (Type, bool) {
+		typ, ok := attrs[name]
+		return typ, ok
+	}
+	for expr, ok := range map[string]bool{
+		`price < 10 AND name = "Wid*"`:     true,
+		`id = "123" OR tags:acme`:          true,
+		`attributes.voltage >= 110`:        true,
+		`attributes.fabric = wool`:         true,
+		`attributes.fragile != false`:      true,
+		`price:* AND attributes.fragile:*`: true,
+		`price = "abc"`:                    false,
+		`price = abc`:                      false,
+		`name > 3`:                         false,
+		`tags = 2024`:                      false,
+		`attributes.voltage = high`:        false,
+		`attributes.fabric < 3`:            false,
+		`attributes.fragile = "true"`:      false,
+		`attributes.fragile > false`:       false,
+		`NOT attributes.isbn:*`:            false,
+	} {
+		f, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", expr, err)
+		}
+		err = f.Check(attrType)
+		var terr *TypeError
+		if ok != (err == nil) || (err != nil && !errors.As(err, &terr)) {
+			t.Errorf("Check(%q) = %v", expr, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	quoted bool
+	pos    int
+}
+
+func (t token) isKeyword(kw string) bool {
+	return t.kind == tokWord && !t.quoted && t.text == kw
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for ; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				b.WriteByte(src[i])
+			}
+			if i >= len(src) {
+				return nil, &SyntaxError{Pos: start, Msg: "unterminated string"}
+			}
+			i++
+			toks = append(toks, token{kind: tokWord, text: b.String(), quoted: true, pos: start})
+		case c == '=' || c == ':':
+			toks = append(toks, token{kind: tokOp, text: string(c), pos: i})
+			i++
+		case c == '!' || c == '<' || c == '>':
+			if i+1 < len(src) && src[i+1] == '=' {
+				toks = append(toks, token{kind: tokOp, text: src[i : i+2], pos: i})
+				i += 2
+				continue
+			}
+			if c == '!' {
+				return nil, &SyntaxError{Pos: i, Msg: `expected "!="`}
+			}
+			toks = append(toks, token{kind: tokOp, text: string(c), pos: i})
+			i++
+		default:
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if !isWordRune(r) {
+					break
+				}
+				i += size
+			}
+			if i == start {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+			toks = append(toks, token{kind: tokWord, text: src[start:i], pos: start})
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._-+*", r))
+}
@@ -0,0 +1,417 @@
+// Package fixtures loads seed catalogs from JSON, YAML or CSV files and
+// reports every invalid row, not just the first.
+package fixtures
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"go.yaml.in/yaml/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Mode says how a seed catalog is written to the repository on start.
+type Mode int
+
+const (
+	// IfEmpty seeds only a repository that holds no products.
+	IfEmpty Mode = iota
+	// Upsert writes every fixture on each start, replacing products with the
+	// same ID and leaving other products alone.
+	Upsert
+	// Skip never seeds.
+	Skip
+)
+
+var modeNames = []string{"if-empty", "upsert", "skip"}
+
+func (m Mode) String() string {
+	if m < 0 || int(m) >= len(modeNames) {
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+	return modeNames[m]
+}
+
+// ParseMode parses "if-empty", "upsert" or "skip".
+func ParseMode(s string) (Mode, error) {
+	for i, name := range modeNames {
+		if s == name {
+			return Mode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown seed mode %q: want %s", s, strings.Join(modeNames, ", "))
+}
+
+//go:embed sample.json
+var sample []byte
+
+// Sample returns the built-in catalog of three products used when no fixture
+// file is configured.
+func Sample() []*product.Product {
+	ps, err := Decode(sample, JSON, nil)
+	if err != nil {
+		panic("fixtures: invalid sample catalog: " + err.Error())
+	}
+	return ps
+}
+
+// Format is the encoding of a fixture file.
+type Format string
+
+const (
+	// JSON files hold an array of products in their protojson form.
+	JSON Format = "json"
+	// YAML files hold a sequence of products with the same fields as JSON.
+	YAML Format = "yaml"
+	// CSV files have a header row naming the columns id, name, description,
+	// price, tags and categories, in any order, plus one attr.NAME column per
+	// attribute. Tags and categories are separated by "|". An attribute
+	// column may end in :string, :number, :bool or :enum; otherwise "true"
+	// and "false" are read as booleans, numbers as numbers and anything else
+	// as a string. Empty cells are left unset.
+	CSV Format = "csv"
+)
+
+// FormatOf returns the format implied by the extension of path.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".csv":
+		return CSV, nil
+	}
+	return "", fmt.Errorf("%s: unknown fixture format: want a .json, .yaml, .yml or .csv file", path)
+}
+
+// Load reads the fixture file at path. check, if not nil, validates each
+// product; see Decode.
+func Load(path string, check func(*product.Product) error) ([]*product.Product, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := Decode(data, format, check)
+	var rowErrs *Errors
+	if errors.As(err, &rowErrs) {
+		rowErrs.Path = path
+	} else if err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+	}
+	return ps, err
+}
+
+// Decode parses fixtures in the given format. Every product needs an ID, IDs
+// must be unique, and check, if not nil, must accept it. If any row is
+// invalid, Decode returns an *Errors listing all of them and no products.
+func Decode(data []byte, format Format, check func(*product.Product) error) ([]*product.Product, error) {
+	var rows []row
+	var err error
+	switch format {
+	case JSON:
+		rows, err = jsonRows(data)
+	case YAML:
+		rows, err = yamlRows(data)
+	case CSV:
+		rows, err = csvRows(data)
+	default:
+		return nil, fmt.Errorf("unknown fixture format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	errs := &Errors{}
+	firstRow := make(map[string]int)
+	ps := make([]*product.Product, 0, len(rows))
+	for i, r := range rows {
+		fail := func(err error) {
+			errs.Rows = append(errs.Rows, &RowError{Row: i + 1, Line: r.line, ID: r.p.GetId(), Err: err})
+		}
+		switch {
+		case r.err != nil:
+			fail(r.err)
+		case r.p.GetId() == "":
+			fail(errors.New("id is required"))
+		case firstRow[r.p.GetId()] != 0:
+			fail(fmt.Errorf("duplicate id, first used on row %d", firstRow[r.p.GetId()]))
+		default:
+			firstRow[r.p.GetId()] = i + 1
+			if check != nil {
+				if err := check(r.p); err != nil {
+					fail(err)
+					continue
+				}
+			}
+			ps = append(ps, r.p)
+		}
+	}
+	if len(errs.Rows) > 0 {
+		return nil, errs
+	}
+	return ps, nil
+}
+
+// RowError is an invalid row of a fixture file.
+type RowError struct {
+	// Row is the 1-based position of the product in the file.
+	Row int
+	// Line is the line the row starts on, or 0 if unknown.
+	Line int
+	// ID is the product ID, if it could be read.
+	ID  string
+	Err error
+}
+
+func (e *RowError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "row %d", e.Row)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, " (line %d)", e.Line)
+	}
+	if e.ID != "" {
+		fmt.Fprintf(&b, " id %q", e.ID)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// Errors lists every invalid row of a fixture file.
+type Errors struct {
+	// Path is the file, if the fixtures were read by Load.
+	Path string
+	Rows []*RowError
+}
+
+func (e *Errors) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		b.WriteString(e.Path + ": ")
+	}
+	fmt.Fprintf(&b, "%d invalid fixture rows", len(e.Rows))
+	for _, r := range e.Rows {
+		b.WriteString("\n\t" + r.Error())
+	}
+	return b.String()
+}
+
+// row is a decoded product or the reason it could not be decoded.
+type row struct {
+	p    *product.Product
+	line int
+	err  error
+}
+
+func jsonRows(data []byte) ([]row, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, fmt.Errorf("want a JSON array of products: %w", err)
+	}
+	rows := make([]row, len(raws))
+	for i, raw := range raws {
+		rows[i] = protoRow(raw, 0)
+	}
+	return rows, nil
+}
+
+func yamlRows(data []byte) ([]row, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	seq := doc.Content[0]
+	if seq.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: want a sequence of products", seq.Line)
+	}
+	rows := make([]row, len(seq.Content))
+	for i, item := range seq.Content {
+		// Products go through JSON so that YAML fixtures accept exactly the
+		// fields and spellings of JSON ones.
+		var v any
+		if err := item.Decode(&v); err != nil {
+			rows[i] = row{line: item.Line, err: err}
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			rows[i] = row{line: item.Line, err: err}
+			continue
+		}
+		rows[i] = protoRow(raw, item.Line)
+	}
+	return rows, nil
+}
+
+func protoRow(raw []byte, line int) row {
+	p := &product.Product{}
+	if err := protojson.Unmarshal(raw, p); err != nil {
+		// Keep the ID for the error report if the rest is malformed.
+		var id struct{ ID string }
+		json.Unmarshal(raw, &id)
+		return row{p: &product.Product{Id: id.ID}, line: line, err: err}
+	}
+	return row{p: p, line: line}
+}
+
+// labelSeparator separates tags and categories in a CSV cell.
+const labelSeparator = "|"
+
+func csvRows(data []byte) ([]row, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]func(p *product.Product, cell string) error, len(header))
+	for i, name := range header {
+		set, err := csvColumn(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("header column %d: %w", i+1, err)
+		}
+		columns[i] = set
+	}
+
+	var rows []row
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, err
+			}
+			// A malformed line cannot be resynchronized reliably.
+			rows = append(rows, row{p: &product.Product{}, line: perr.Line, err: perr.Err})
+			return rows, nil
+		}
+		line, _ := r.FieldPos(0)
+		p := &product.Product{}
+		rowErr := func() error {
+			if len(record) != len(header) {
+				return fmt.Errorf("has %d fields, want %d", len(record), len(header))
+			}
+			for i, cell := range record {
+				if cell == "" {
+					continue
+				}
+				if err := columns[i](p, cell); err != nil {
+					return fmt.Errorf("%s: %w", header[i], err)
+				}
+			}
+			return nil
+		}()
+		rows = append(rows, row{p: p, line: line, err: rowErr})
+	}
+}
+
+// csvColumn returns the function that sets the named column on a product.
+func csvColumn(name string) (func(p *product.Product, cell string) error, error) {
+	switch name {
+	case "id":
+		return func(p *product.Product, cell string) error { p.Id = cell; return nil }, nil
+	case "name":
+		return func(p *product.Product, cell string) error { p.Name = cell; return nil }, nil
+	case "description":
+		return func(p *product.Product, cell string) error { p.Description = cell; return nil }, nil
+	case "price":
+		return func(p *product.Product, cell string) error {
+			v, err := strconv.ParseFloat(cell, 64)
+			p.Price = v
+			return err
+		}, nil
+	case "tags":
+		return func(p *product.Product, cell string) error {
+			p.Tags = strings.Split(cell, labelSeparator)
+			return nil
+		}, nil
+	case "categories":
+		return func(p *product.Product, cell string) error {
+			p.Categories = strings.Split(cell, labelSeparator)
+			return nil
+		}, nil
+	}
+	attr, ok := strings.CutPrefix(name, "attr.")
+	if !ok || attr == "" {
+		return nil, fmt.Errorf("unknown column %q", name)
+	}
+	attr, kind, _ := strings.Cut(attr, ":")
+	parse, err := attributeParser(kind)
+	if err != nil {
+		return nil, fmt.Errorf("column %q: %w", name, err)
+	}
+	return func(p *product.Product, cell string) error {
+		v, err := parse(cell)
+		if err != nil {
+			return err
+		}
+		if p.Attributes == nil {
+			p.Attributes = make(map[string]*product.AttributeValue)
+		}
+		p.Attributes[attr] = v
+		return nil
+	}, nil
+}
+
+func attributeParser(kind string) (func(string) (*product.AttributeValue, error), error) {
+	str := func(s string) (*product.AttributeValue, error) {
+		return &product.AttributeValue{Kind: &product.AttributeValue_StringValue{StringValue: s}}, nil
+	}
+	num := func(s string) (*product.AttributeValue, error) {
+		v, err := strconv.ParseFloat(s, 64)
+		return &product.AttributeValue{Kind: &product.AttributeValue_NumberValue{NumberValue: v}}, err
+	}
+	boolean := func(s string) (*product.AttributeValue, error) {
+		v, err := strconv.ParseBool(s)
+		return &product.AttributeValue{Kind: &product.AttributeValue_BoolValue{BoolValue: v}}, err
+	}
+	switch kind {
+	case "":
+		return func(s string) (*product.AttributeValue, error) {
+			if s == "true" || s == "false" {
+				return boolean(s)
+			}
+			if v, err := num(s); err == nil {
+				return v, nil
+			}
+			return str(s)
+		}, nil
+	case "string":
+		return str, nil
+	case "number":
+		return num, nil
+	case "bool":
+		return boolean, nil
+	case "enum":
+		return func(s string) (*product.AttributeValue, error) {
+			return &product.AttributeValue{Kind: &product.AttributeValue_EnumValue{EnumValue: s}}, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown attribute type %q: want string, number, bool or enum", kind)
+}
@@ -0,0 +1,125 @@
+package fixtures
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLoad_FormatsAgree(t *testing.T) {
+	want, err := Load(filepath.Join("testdata", "catalog.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 2 || want[0].GetAttributes()["watts"].GetNumberValue() != 40 {
+		t.Fatalf("unexpected JSON fixtures %v", want)
+	}
+	for _, name := range []string{"catalog.yaml", "catalog.csv"} {
+		got, err := Load(filepath.Join("testdata", name), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d products, want %d", name, len(got), len(want))
+		}
+		for i := range want {
+			if !proto.Equal(got[i], want[i]) {
+				t.Errorf("%s row %d = %v, want %v", name, i+1, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestLoad_ReportsEveryInvalidRow(t *testing.T) {
+	check := func(p *product.Product) error {
+		if p.GetPrice() < 0 {
+			return errors.New("price must not be negative")
+		}
+		return nil
+	}
+	ps, err := Load(filepath.Join("testdata", "invalid.yaml"), check)
+	var errs *Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want *Errors", err)
+	}
+	if ps != nil {
+		t.Fatalf("products returned despite errors: %v", ps)
+	}
+	var got []string
+	for _, r := range errs.Rows {
+		got = append(got, fmt.Sprintf("%d@%d %s", r.Row, r.Line, r.ID))
+	}
+	if want := "[2@4  3@6 ok 4@9 bad-price 5@12 negative]"; fmt.Sprint(got) != want {
+		t.Fatalf("row errors %v, want %s", got, want)
+	}
+	msg := err.Error()
+	for _, s := range []string{"invalid.yaml: 4 invalid fixture rows", "row 2 (line 4): id is required", `row 3 (line 6) id "ok": duplicate id, first used on row 1`, "price must not be negative"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("error message missing %q:\n%s", s, msg)
+		}
+	}
+}
+
+func TestDecode_CSV(t *testing.T) {
+	data := "id,name,price,tags,attr.size:string,attr.color:enum,attr.fragile\n" +
+		"a,A,1,x|y,10,red,true\n" +
+		"b,B,oops,,,,\n" +
+		"c,C,3\n"
+	_, err := Decode([]byte(data), CSV, nil)
+	var errs *Errors
+	if !errors.As(err, &errs) || len(errs.Rows) != 2 {
+		t.Fatalf("got %v, want two row errors", err)
+	}
+	if r := errs.Rows[0]; r.Row != 2 || r.Line != 3 || !strings.Contains(r.Error(), "price") {
+		t.Errorf("unexpected first error %v", r)
+	}
+	if r := errs.Rows[1]; r.Row != 3 || !strings.Contains(r.Error(), "has 3 fields, want 7") {
+		t.Errorf("unexpected second error %v", r)
+	}
+
+	ps, err := Decode([]byte("id,name,price,tags,attr.size:string,attr.color:enum,attr.fragile\na,A,1,x|y,10,red,true\n"), CSV, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := ps[0].GetAttributes()
+	if fmt.Sprint(ps[0].GetTags()) != "[x y]" || attrs["size"].GetStringValue() != "10" ||
+		attrs["color"].GetEnumValue() != "red" || !attrs["fragile"].GetBoolValue() {
+		t.Fatalf("unexpected product %v", ps[0])
+	}
+
+	for _, header := range []string{"id,colour", "id,attr.x:date"} {
+		if _, err := Decode([]byte(header+"\n"), CSV, nil); err == nil {
+			t.Errorf("expected an error for header %q", header)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	ps := Sample()
+	if len(ps) != 3 || ps[0].GetId() != "prod-1" || ps[1].GetPrice() != 19.99 {
+		t.Fatalf("unexpected sample catalog %v", ps)
+	}
+}
+
+func TestFormatOfAndParseMode(t *testing.T) {
+	if f, err := FormatOf("seed/DEV.YML"); err != nil || f != YAML {
+		t.Fatalf("FormatOf = %v, %v", f, err)
+	}
+	if _, err := FormatOf("seed.xml"); err == nil {
+		t.Fatal("expected an error for .xml")
+	}
+	for _, m := range []Mode{IfEmpty, Upsert, Skip} {
+		if got, err := ParseMode(m.String()); err != nil || got != m {
+			t.Errorf("ParseMode(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseMode("always"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
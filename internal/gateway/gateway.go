@@ -2,13 +2,34 @@ package gateway
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net"
 	"net/http"
+	"strings"
 
+	"grpc-go-fx/internal/audit"
 	"grpc-go-fx/internal/config"
+	operationsgw "grpc-go-fx/internal/generated/longrunningpb"
 	"grpc-go-fx/internal/generated/product"
+	replicationpb "grpc-go-fx/internal/generated/product/replication"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+	"grpc-go-fx/internal/idempotency"
+	"grpc-go-fx/internal/logging"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 // Module wires the HTTP/JSON gateway for the ProductService into the FX app lifecycle.
@@ -17,27 +38,187 @@ import (
 // generate_unbound_methods=true, which results in POST endpoints like:
 //   - POST /product.v1.ProductService/GetProduct
 //   - POST /product.v1.ProductService/ListProducts
+//   - POST /product.v2.ProductService/GetProduct
+//   - POST /product.v1.AdminService/CreateBackup
+//   - POST /product.replication.v1.ReplicationService/GetReplicationStatus
+//
+// The google.longrunning.Operations service keeps the HTTP bindings declared in
+// operations.proto, e.g. GET /v1/operations/{id} and POST /v1/operations/{id}:cancel.
+//
+// Requests reach the *grpc.Server provided by api.Module over an in-memory
+// connection, so they pass through the same interceptors as gRPC clients.
+//
+// A ?fields=id,name query parameter sets the read_mask of methods that have one.
 var Module = fx.Module("gateway",
+	fx.Provide(NewInProcessConn),
 	fx.Provide(NewServeMux),
 	fx.Invoke(RegisterGatewayLifecycle),
 )
 
-// NewServeMux builds a grpc-gateway ServeMux and registers the ProductService handlers.
-func NewServeMux(svc product.ProductServiceServer) (*runtime.ServeMux, error) {
-	mux := runtime.NewServeMux()
+const (
+	// fieldsParam is the query parameter mapped to a request's read_mask.
+	fieldsParam = "fields"
+	// fieldsMetadataKey carries fieldsParam from the ServeMux to
+	// readMaskInterceptor on the in-process connection.
+	fieldsMetadataKey = "gateway-fields"
+	// readMaskField is the request field that fieldsParam sets.
+	readMaskField = "read_mask"
+)
+
+// NewInProcessConn serves srv on an in-memory listener from OnStart and returns
+// a client connection to it. The connection is closed on OnStop; the listener
+// is closed when srv stops. If the server fails, the failure is logged to log
+// and the app shut down through shutdown. The server trusts the actor
+// forwarded in the X-Authenticated-User header only if cfg.TrustActorHeader is
+// set.
+func NewInProcessConn(lc fx.Lifecycle, shutdown fx.Shutdowner, cfg *config.Config, srv *grpc.Server, log *zap.Logger) (*grpc.ClientConn, error) {
+	lis := newPipeListener(pipeAddr{forwardsActor: cfg.TrustActorHeader})
+	conn, err := grpc.NewClient("passthrough:///in-process",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.dial(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(readMaskInterceptor),
+	)
+	if err != nil {
+		return nil, err
+	}
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			go serveInProcess(srv, lis, shutdown, log)
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return conn.Close()
+		},
+	})
+	return conn, nil
+}
+
+// NewServeMux builds a grpc-gateway ServeMux that forwards v1 and v2
+// ProductService, AdminService, ReplicationService and Operations requests over conn and serves
+// expvars at GET /debug/vars.
+func NewServeMux(conn *grpc.ClientConn) (*runtime.ServeMux, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMetadata(fieldsMetadata),
+		runtime.WithForwardResponseRewriter(omitUnpopulatedWhenMasked),
+	)
 	ctx := context.Background()
 
-	// Register handlers that translate HTTP/JSON requests into gRPC calls
-	// handled by the in-process ProductServiceServer implementation.
-	if err := product.RegisterProductServiceHandlerServer(ctx, mux, svc); err != nil {
+	// Register handlers that translate HTTP/JSON requests into gRPC calls.
+	if err := product.RegisterProductServiceHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	if err := productv2.RegisterProductServiceHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	if err := product.RegisterAdminServiceHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	if err := replicationpb.RegisterReplicationServiceHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	if err := operationsgw.RegisterOperationsHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	// Published expvars, such as the product cache's counters.
+	if err := mux.HandlePath(http.MethodGet, "/debug/vars", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		expvar.Handler().ServeHTTP(w, r)
+	}); err != nil {
 		return nil, err
 	}
 
 	return mux, nil
 }
 
-// RegisterGatewayLifecycle starts and stops the HTTP gateway with the FX lifecycle.
-func RegisterGatewayLifecycle(lc fx.Lifecycle, cfg *config.Config, mux *runtime.ServeMux) {
+// incomingHeaderMatcher forwards the Idempotency-Key, X-Authenticated-User and
+// X-Request-Id headers as gRPC metadata in addition to the headers forwarded
+// by default.
+func incomingHeaderMatcher(key string) (string, bool) {
+	switch key {
+	case http.CanonicalHeaderKey(idempotency.MetadataKey):
+		return idempotency.MetadataKey, true
+	case http.CanonicalHeaderKey(audit.ActorMetadataKey):
+		return audit.ActorMetadataKey, true
+	case logging.RequestIDHeader:
+		return logging.RequestIDKey, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaderMatcher returns the replay marker as a plain Idempotent-Replayed
+// header and drops the request ID, which the access log's handler has set
+// already; other metadata keeps the default Grpc-Metadata- prefix.
+func outgoingHeaderMatcher(key string) (string, bool) {
+	switch key {
+	case idempotency.ReplayedKey:
+		return http.CanonicalHeaderKey(key), true
+	case logging.RequestIDKey:
+		return "", false
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// fieldsMetadata forwards the fields query parameter, if present, as metadata.
+func fieldsMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	fields, ok := r.URL.Query()[fieldsParam]
+	if !ok {
+		return nil
+	}
+	return metadata.Pairs(fieldsMetadataKey, strings.Join(fields, ","))
+}
+
+// omitUnpopulatedWhenMasked marshals responses to requests that carry the
+// fields parameter without the zero values the gateway emits by default, so
+// that fields left out by the mask are left out of the JSON as well.
+func omitUnpopulatedWhenMasked(ctx context.Context, resp proto.Message) (any, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get(fieldsMetadataKey)) == 0 {
+		return resp, nil
+	}
+	b, err := protojson.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(b), nil
+}
+
+// readMaskInterceptor turns the fields query parameter forwarded by
+// fieldsMetadata into the request's read_mask. A read_mask sent in the body
+// takes precedence. Methods without a read_mask reject the parameter.
+func readMaskInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	fields := md.Get(fieldsMetadataKey)
+	if len(fields) == 0 {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	md = md.Copy()
+	md.Delete(fieldsMetadataKey)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	m := req.(proto.Message).ProtoReflect()
+	fd := m.Descriptor().Fields().ByName(readMaskField)
+	if fd == nil || fd.Message() == nil || fd.Message().FullName() != "google.protobuf.FieldMask" {
+		return status.Errorf(codes.InvalidArgument, "the %s parameter is not supported by %s", fieldsParam, method)
+	}
+	if !m.Has(fd) {
+		mask := &fieldmaskpb.FieldMask{}
+		for _, f := range fields {
+			for _, path := range strings.Split(f, ",") {
+				if path = strings.TrimSpace(path); path != "" {
+					mask.Paths = append(mask.Paths, path)
+				}
+			}
+		}
+		m.Set(fd, protoreflect.ValueOfMessage(mask.ProtoReflect()))
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// RegisterGatewayLifecycle starts and stops the HTTP gateway with the FX
+// lifecycle. Requests are logged by access, if it is not nil, and the server's
+// errors to log. If the server fails, the app is shut down through shutdown.
+func RegisterGatewayLifecycle(lc fx.Lifecycle, shutdown fx.Shutdowner, cfg *config.Config, mux *runtime.ServeMux, log *zap.Logger, access *logging.AccessLog) {
 	var srv *http.Server
 
 	lc.Append(fx.Hook{
@@ -47,15 +228,17 @@ func RegisterGatewayLifecycle(lc fx.Lifecycle, cfg *config.Config, mux *runtime.
 				return nil
 			}
 
+			lis, err := net.Listen("tcp", cfg.HTTPGatewayAddr)
+			if err != nil {
+				return err
+			}
 			srv = &http.Server{
-				Addr:    cfg.HTTPGatewayAddr,
-				Handler: mux,
+				Handler:  access.Handler(mux),
+				ErrorLog: zap.NewStdLog(log.Named("http")),
 			}
+			log.Info("HTTP gateway listening", zap.Stringer("addr", lis.Addr()))
 
-			go func() {
-				// http.ErrServerClosed is expected on graceful shutdown.
-				_ = srv.ListenAndServe()
-			}()
+			go serveHTTP(srv, lis, shutdown, log)
 
 			return nil
 		},
@@ -68,3 +251,29 @@ func RegisterGatewayLifecycle(lc fx.Lifecycle, cfg *config.Config, mux *runtime.
 	})
 }
 
+// serveInProcess serves srv on lis. If it fails other than by being stopped,
+// the app is shut down with exit code 1.
+func serveInProcess(srv *grpc.Server, lis net.Listener, shutdown fx.Shutdowner, log *zap.Logger) {
+	err := srv.Serve(lis)
+	if err == nil || errors.Is(err, grpc.ErrServerStopped) {
+		return
+	}
+	fail("in-process gRPC server failed", err, shutdown, log)
+}
+
+// serveHTTP serves srv on lis. If it fails other than by being shut down, the
+// app is shut down with exit code 1.
+func serveHTTP(srv *http.Server, lis net.Listener, shutdown fx.Shutdowner, log *zap.Logger) {
+	// http.ErrServerClosed is expected on graceful shutdown.
+	if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		fail("HTTP gateway failed", err, shutdown, log)
+	}
+}
+
+// fail logs err with msg and shuts the app down with exit code 1.
+func fail(msg string, err error, shutdown fx.Shutdowner, log *zap.Logger) {
+	log.Error(msg, zap.Error(err))
+	if err := shutdown.Shutdown(fx.ExitCode(1)); err != nil {
+		log.Error("shutting down after a server failed", zap.Error(err))
+	}
+}
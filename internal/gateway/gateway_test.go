@@ -3,24 +3,78 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"grpc-go-fx/internal/api"
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/backup"
 	"grpc-go-fx/internal/config"
 	"grpc-go-fx/internal/generated/product"
+	productv2 "grpc-go-fx/internal/generated/product/v2"
+	"grpc-go-fx/internal/idempotency"
+	"grpc-go-fx/internal/interceptor"
+	"grpc-go-fx/internal/logging"
+	"grpc-go-fx/internal/operations"
+	"grpc-go-fx/internal/replication"
+	"grpc-go-fx/internal/repository"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
+// newTestConn serves svc (as v1 and v2, with an AdminService backing up to a
+// temporary directory) and ops on a gRPC server built by api.NewGRPCServer, with
+// the extra unary interceptors, and returns an in-process connection to it.
+func newTestConn(t *testing.T, svc *api.ProductService, ops longrunningpb.OperationsServer, unary ...interceptor.Unary) *grpc.ClientConn {
+	t.Helper()
+	return newTestConnWithConfig(t, &config.Config{}, svc, ops, unary...)
+}
+
+// newTestConnWithConfig is newTestConn with the gateway and server configured
+// by cfg.
+func newTestConnWithConfig(t *testing.T, cfg *config.Config, svc *api.ProductService, ops longrunningpb.OperationsServer, unary ...interceptor.Unary) *grpc.ClientConn {
+	t.Helper()
+	repl := replication.NewStandaloneServer()
+	srv, err := api.NewGRPCServer(svc, api.NewProductServiceV2(svc), api.NewAdminService(svc, backup.NewStore(t.TempDir())), repl, ops,
+		append(api.UnaryInterceptors(cfg, zap.NewNop(), repl, idempotency.NewStore(0, 0)), unary...), api.StreamInterceptors(cfg, zap.NewNop()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lc := &stubLifecycle{}
+	conn, err := NewInProcessConn(lc, &stubShutdowner{}, cfg, srv, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewInProcessConn returned error: %v", err)
+	}
+	for _, h := range lc.hooks {
+		if err := h.OnStart(context.Background()); err != nil {
+			t.Fatalf("OnStart returned error: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, h := range lc.hooks {
+			_ = h.OnStop(context.Background())
+		}
+		srv.Stop()
+	})
+	return conn
+}
+
 func TestNewServeMux_RegistersHandlers(t *testing.T) {
 	svc := api.NewProductService()
 
-	mux, err := NewServeMux(svc)
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(operations.NewRegistry(0))))
 	if err != nil {
 		t.Fatalf("NewServeMux returned error: %v", err)
 	}
@@ -31,7 +85,7 @@ func TestNewServeMux_RegistersHandlers(t *testing.T) {
 
 func TestGateway_GetProductViaHTTP(t *testing.T) {
 	svc := api.NewProductService()
-	mux, err := NewServeMux(svc)
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(operations.NewRegistry(0))))
 	if err != nil {
 		t.Fatalf("NewServeMux returned error: %v", err)
 	}
@@ -59,9 +113,39 @@ func TestGateway_GetProductViaHTTP(t *testing.T) {
 	}
 }
 
+func TestGateway_ServesCacheStats(t *testing.T) {
+	cache := repository.NewCache(repository.NewMemory(&product.Product{Id: "prod-1", Name: "Widget"}), repository.CacheOptions{})
+	svc, err := api.NewProductServiceWithRepository(context.Background(), cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.PublishCacheStats(cache)
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(operations.NewRegistry(0))))
+	if err != nil {
+		t.Fatalf("NewServeMux returned error: %v", err)
+	}
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/product.v1.ProductService/GetProduct", strings.NewReader(`{"id":"prod-1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars struct {
+		Cache repository.CacheStats `json:"product_cache"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("failed to unmarshal /debug/vars: %v (body=%s)", err, rr.Body.String())
+	}
+	if vars.Cache.Hits != 1 || vars.Cache.Misses != 1 || vars.Cache.Entries != 1 {
+		t.Fatalf("unexpected cache stats %+v", vars.Cache)
+	}
+}
+
 func TestGateway_ListProductsViaHTTP(t *testing.T) {
 	svc := api.NewProductService()
-	mux, err := NewServeMux(svc)
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(operations.NewRegistry(0))))
 	if err != nil {
 		t.Fatalf("NewServeMux returned error: %v", err)
 	}
@@ -94,6 +178,277 @@ func TestGateway_ListProductsViaHTTP(t *testing.T) {
 	}
 }
 
+func TestGateway_ServesBothVersions(t *testing.T) {
+	svc := api.NewProductService()
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(operations.NewRegistry(0))))
+	if err != nil {
+		t.Fatalf("NewServeMux returned error: %v", err)
+	}
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/product.v2.ProductService/CreateProduct",
+		`{"product":{"id":"prod-9","name":"Drill","price":{"currencyCode":"USD","units":"89","nanos":950000000}}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("v2 CreateProduct: status %d, body=%s", rr.Code, rr.Body.String())
+	}
+
+	rr = post("/product.v1.ProductService/GetProduct", `{"id":"prod-9"}`)
+	var p1 product.Product
+	if err := json.Unmarshal(rr.Body.Bytes(), &p1); err != nil || p1.GetPrice() != 89.95 {
+		t.Fatalf("v1 GetProduct: err=%v price=%v body=%s", err, p1.GetPrice(), rr.Body.String())
+	}
+
+	rr = post("/product.v2.ProductService/GetProduct", `{"id":"prod-1"}`)
+	var p2 productv2.Product
+	if err := protojson.Unmarshal(rr.Body.Bytes(), &p2); err != nil {
+		t.Fatalf("v2 GetProduct: %v (body=%s)", err, rr.Body.String())
+	}
+	if p2.GetPrice().GetUnits() != 9 || p2.GetPrice().GetNanos() != 990000000 {
+		t.Fatalf("unexpected v2 price %v", p2.GetPrice())
+	}
+
+	// v2 field rules are enforced by the shared interceptor chain.
+	if rr = post("/product.v2.ProductService/CreateProduct", `{"product":{"name":"No price"}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a product without price, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr = post("/product.v2.ProductService/GetProduct", `{"id":"missing"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown product, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGateway_FieldsQueryParameterSetsReadMask(t *testing.T) {
+	svc := api.NewProductService()
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(operations.NewRegistry(0))))
+	if err != nil {
+		t.Fatalf("NewServeMux returned error: %v", err)
+	}
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/product.v1.ProductService/GetProduct?fields=id,name,price", `{"id":"prod-1"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["description"]; ok || got["name"] != "Widget A" || got["price"] != 9.99 {
+		t.Fatalf("unexpected masked product: %v", got)
+	}
+
+	rr = post("/product.v2.ProductService/ListProducts?fields=name&fields=price.units", `{"pageSize":2}`)
+	var page productv2.ListProductsResponse
+	if err := protojson.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("v2 ListProducts: %v (body=%s)", err, rr.Body.String())
+	}
+	if p := page.GetProducts()[0]; p.GetId() != "" || p.GetName() == "" || p.GetPrice().GetUnits() != 9 || p.GetPrice().GetCurrencyCode() != "" {
+		t.Fatalf("unexpected masked product: %v", p)
+	}
+
+	if rr = post("/product.v1.ProductService/GetProduct?fields=id,colour", `{"id":"prod-1"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown path, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr = post("/product.v1.ProductService/ListAttributeDefinitions?fields=name", `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a method without read_mask, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGateway_OperationsViaHTTP(t *testing.T) {
+	reg := operations.NewRegistry(0)
+	defer reg.Close(context.Background())
+	svc := api.NewProductService(api.WithOperations(reg))
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(reg)))
+	if err != nil {
+		t.Fatalf("NewServeMux returned error: %v", err)
+	}
+
+	body := `{"filter":"price < 10","multiplier":2}`
+	req := httptest.NewRequest(http.MethodPost, "/product.v1.ProductService/BulkUpdatePrices", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d, want %d. body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var started struct{ Name string }
+	if err := json.Unmarshal(rr.Body.Bytes(), &started); err != nil || started.Name == "" {
+		t.Fatalf("expected an operation name, got body=%s (err=%v)", rr.Body.String(), err)
+	}
+	if _, err := reg.Wait(context.Background(), started.Name, 2*time.Second); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/"+started.Name, nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d, want %d. body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var got struct {
+		Name     string
+		Done     bool
+		Response struct {
+			UpdatedCount string `json:"updatedCount"`
+		}
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal response body: %v (body=%s)", err, rr.Body.String())
+	}
+	if got.Name != started.Name || !got.Done || got.Response.UpdatedCount != "2" {
+		t.Fatalf("unexpected operation: %s", rr.Body.String())
+	}
+}
+
+func TestGateway_ValidationErrorViaHTTP(t *testing.T) {
+	svc := api.NewProductService()
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(operations.NewRegistry(0))))
+	if err != nil {
+		t.Fatalf("NewServeMux returned error: %v", err)
+	}
+
+	body := `{"product":{"price":-1}}`
+	req := httptest.NewRequest(http.MethodPost, "/product.v1.ProductService/CreateProduct", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code: got %d, want %d. body=%s", rr.Code, http.StatusBadRequest, rr.Body.String())
+	}
+
+	var got struct {
+		Details []struct {
+			Type            string `json:"@type"`
+			FieldViolations []struct{ Field string }
+		}
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal response body: %v (body=%s)", err, rr.Body.String())
+	}
+	if len(got.Details) != 1 || got.Details[0].Type != "type.googleapis.com/google.rpc.BadRequest" {
+		t.Fatalf("expected a BadRequest detail, got body=%s", rr.Body.String())
+	}
+	var fields []string
+	for _, v := range got.Details[0].FieldViolations {
+		fields = append(fields, v.Field)
+	}
+	if strings.Join(fields, ",") != "product.name,product.price" {
+		t.Fatalf("unexpected field violations %v", fields)
+	}
+}
+
+func TestGateway_IdempotencyKeyHeader(t *testing.T) {
+	svc := api.NewProductService()
+	mux, err := NewServeMux(newTestConn(t, svc, operations.NewServer(operations.NewRegistry(0))))
+	if err != nil {
+		t.Fatalf("NewServeMux returned error: %v", err)
+	}
+	create := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/product.v1.ProductService/CreateProduct", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	body := `{"product":{"name":"Lamp","price":12}}`
+	first := create("retry-1", body)
+	if first.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d, want %d. body=%s", first.Code, http.StatusOK, first.Body.String())
+	}
+	second := create("retry-1", body)
+	if second.Code != http.StatusOK || second.Body.String() != first.Body.String() {
+		t.Fatalf("expected the original response to be replayed, got %d %s", second.Code, second.Body.String())
+	}
+	if got := second.Header().Get("Idempotent-Replayed"); got != "true" {
+		t.Fatalf("expected Idempotent-Replayed: true, got %q", got)
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("first response must not be marked as replayed")
+	}
+
+	resp, err := svc.ListProducts(context.Background(), &product.ListProductsRequest{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(resp.GetProducts()); got != 4 {
+		t.Fatalf("expected a single product to be created, catalog has %d products", got)
+	}
+
+	if rr := create("retry-1", `{"product":{"name":"Desk","price":12}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected key reuse with another payload to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGateway_AuditActorHeader(t *testing.T) {
+	for _, trust := range []bool{true, false} {
+		svc := api.NewProductService()
+		mux, err := NewServeMux(newTestConnWithConfig(t, &config.Config{TrustActorHeader: trust}, svc, operations.NewServer(operations.NewRegistry(0))))
+		if err != nil {
+			t.Fatalf("NewServeMux returned error: %v", err)
+		}
+
+		body := `{"id":"prod-2"}`
+		req := httptest.NewRequest(http.MethodPost, "/product.v1.ProductService/DeleteProduct", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Authenticated-User", "dana")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status code: got %d, want %d. body=%s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+
+		resp, err := svc.ListAuditEvents(context.Background(), &product.ListAuditEventsRequest{ProductId: "prod-2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The header is only trusted when an authenticating proxy sets it.
+		want := audit.Anonymous
+		if trust {
+			want = "dana"
+		}
+		events := resp.GetEvents()
+		if len(events) != 1 || events[0].GetActor() != want || events[0].GetMethod() != "/product.v1.ProductService/DeleteProduct" {
+			t.Fatalf("trust=%v: unexpected audit events: %v", trust, events)
+		}
+	}
+}
+
+func TestGateway_RequestIDHeader(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	access := logging.NewAccessLog(zap.New(core), logging.AccessLogOptions{})
+	mux, err := NewServeMux(newTestConn(t, api.NewProductService(), operations.NewServer(operations.NewRegistry(0)), logging.UnaryInterceptors(access)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/product.v1.ProductService/GetProduct", strings.NewReader(`{"id":"prod-1"}`))
+	req.Header.Set("X-Request-Id", "trace-42")
+	rr := httptest.NewRecorder()
+	access.Handler(mux).ServeHTTP(rr, req)
+
+	if got := rr.Header().Values("X-Request-Id"); len(got) != 1 || got[0] != "trace-42" {
+		t.Fatalf("X-Request-Id %v", got)
+	}
+	if got := rr.Header().Get("Grpc-Metadata-X-Request-Id"); got != "" {
+		t.Fatalf("request ID also returned as gRPC metadata: %q", got)
+	}
+	if n := logs.FilterField(zap.String("request_id", "trace-42")).Len(); n != 2 {
+		t.Fatalf("%d entries with the request ID, want the gRPC call and the HTTP request: %v", n, logs.All())
+	}
+}
+
 type stubLifecycle struct {
 	hooks []fx.Hook
 }
@@ -102,12 +457,55 @@ func (s *stubLifecycle) Append(h fx.Hook) {
 	s.hooks = append(s.hooks, h)
 }
 
+// stubShutdowner records the shutdowns requested of it.
+type stubShutdowner struct {
+	calls []fx.ShutdownOption
+}
+
+func (s *stubShutdowner) Shutdown(opts ...fx.ShutdownOption) error {
+	s.calls = append(s.calls, opts...)
+	return nil
+}
+
+func TestServe_ShutsDownTheAppWhenAServerFails(t *testing.T) {
+	// Accepting on a closed listener fails, and so does Serve.
+	closed := func() net.Listener {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lis.Close()
+		return lis
+	}
+	for name, serve := range map[string]func(fx.Shutdowner){
+		"in-process": func(s fx.Shutdowner) { serveInProcess(grpc.NewServer(), closed(), s, zap.NewNop()) },
+		"HTTP":       func(s fx.Shutdowner) { serveHTTP(&http.Server{}, closed(), s, zap.NewNop()) },
+	} {
+		shutdown := &stubShutdowner{}
+		serve(shutdown)
+		if len(shutdown.calls) != 1 || !reflect.DeepEqual(shutdown.calls[0], fx.ExitCode(1)) {
+			t.Fatalf("%s: shutdowns %v, want one with exit code 1", name, shutdown.calls)
+		}
+	}
+
+	srv := grpc.NewServer()
+	srv.Stop()
+	shutdown := &stubShutdowner{}
+	serveInProcess(srv, newPipeListener(pipeAddr{}), shutdown, zap.NewNop())
+	httpSrv := &http.Server{}
+	httpSrv.Close()
+	serveHTTP(httpSrv, newPipeListener(pipeAddr{}), shutdown, zap.NewNop())
+	if len(shutdown.calls) != 0 {
+		t.Fatalf("serving a stopped server shut the app down: %v", shutdown.calls)
+	}
+}
+
 func TestRegisterGatewayLifecycle_NoAddrConfigured(t *testing.T) {
 	lc := &stubLifecycle{}
 	cfg := &config.Config{HTTPGatewayAddr: ""}
 	mux := runtime.NewServeMux()
 
-	RegisterGatewayLifecycle(lc, cfg, mux)
+	RegisterGatewayLifecycle(lc, &stubShutdowner{}, cfg, mux, zap.NewNop(), nil)
 	if len(lc.hooks) != 1 {
 		t.Fatalf("expected 1 lifecycle hook, got %d", len(lc.hooks))
 	}
@@ -128,7 +526,7 @@ func TestRegisterGatewayLifecycle_StartsAndStopsHTTPServer(t *testing.T) {
 	cfg := &config.Config{HTTPGatewayAddr: "127.0.0.1:0"}
 	mux := runtime.NewServeMux()
 
-	RegisterGatewayLifecycle(lc, cfg, mux)
+	RegisterGatewayLifecycle(lc, &stubShutdowner{}, cfg, mux, zap.NewNop(), nil)
 	if len(lc.hooks) != 1 {
 		t.Fatalf("expected 1 lifecycle hook, got %d", len(lc.hooks))
 	}
@@ -146,4 +544,3 @@ func TestRegisterGatewayLifecycle_StartsAndStopsHTTPServer(t *testing.T) {
 		t.Fatalf("OnStop returned error: %v", err)
 	}
 }
-
@@ -0,0 +1,83 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// errListenerClosed is returned by Accept and dial once a pipeListener is
+// closed.
+var errListenerClosed = errors.New("in-process listener closed")
+
+// pipeListener is a net.Listener whose connections are the server ends of
+// net.Pipe pairs created by dial, so that the gateway reaches the gRPC server
+// without a socket.
+type pipeListener struct {
+	addr      pipeAddr
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener(addr pipeAddr) *pipeListener {
+	return &pipeListener{addr: addr, conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+// Accept waits for the next connection dialled by dial.
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+// Close makes Accept and dial fail; connections already accepted stay open.
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return l.addr }
+
+// dial returns the client end of a new connection, once Accept has taken
+// the server end.
+func (l *pipeListener) dial(ctx context.Context) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- &pipeConn{Conn: server, addr: l.addr}:
+		return client, nil
+	case <-l.closed:
+	case <-ctx.Done():
+	}
+	server.Close()
+	client.Close()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errListenerClosed
+}
+
+// pipeConn is the server end of a pipe, which reports the listener's address
+// as both of its addresses so that the server sees the gateway as its peer.
+type pipeConn struct {
+	net.Conn
+	addr pipeAddr
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.addr }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.addr }
+
+// pipeAddr is the address of a pipeListener and its connections. It is an
+// audit.ProxyAddr, so the server takes the actor of gateway requests from the
+// forwarded X-Authenticated-User header if forwardsActor is set.
+type pipeAddr struct {
+	forwardsActor bool
+}
+
+func (pipeAddr) Network() string       { return "pipe" }
+func (pipeAddr) String() string        { return "in-process" }
+func (a pipeAddr) ForwardsActor() bool { return a.forwardsActor }
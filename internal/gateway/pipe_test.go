@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPipeListener(t *testing.T) {
+	lis := newPipeListener(pipeAddr{})
+	ctx := context.Background()
+	go func() {
+		c, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(c, c)
+	}()
+	c, err := lis.dial(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("echoed %q, %v", buf, err)
+	}
+	c.Close()
+
+	// Nobody accepts: dial gives up with its context.
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := lis.dial(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("dial without Accept: %v", err)
+	}
+
+	lis.Close()
+	if _, err := lis.Accept(); !errors.Is(err, errListenerClosed) {
+		t.Fatalf("Accept after Close: %v", err)
+	}
+	if _, err := lis.dial(ctx); !errors.Is(err, errListenerClosed) {
+		t.Fatalf("dial after Close: %v", err)
+	}
+}
@@ -7,8 +7,12 @@
 package product
 
 import (
+	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,12 +25,183 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type AttributeType int32
+
+const (
+	AttributeType_ATTRIBUTE_TYPE_UNSPECIFIED AttributeType = 0
+	AttributeType_ATTRIBUTE_TYPE_STRING      AttributeType = 1
+	AttributeType_ATTRIBUTE_TYPE_NUMBER      AttributeType = 2
+	AttributeType_ATTRIBUTE_TYPE_ENUM        AttributeType = 3
+	AttributeType_ATTRIBUTE_TYPE_BOOL        AttributeType = 4
+)
+
+// Enum value maps for AttributeType.
+var (
+	AttributeType_name = map[int32]string{
+		0: "ATTRIBUTE_TYPE_UNSPECIFIED",
+		1: "ATTRIBUTE_TYPE_STRING",
+		2: "ATTRIBUTE_TYPE_NUMBER",
+		3: "ATTRIBUTE_TYPE_ENUM",
+		4: "ATTRIBUTE_TYPE_BOOL",
+	}
+	AttributeType_value = map[string]int32{
+		"ATTRIBUTE_TYPE_UNSPECIFIED": 0,
+		"ATTRIBUTE_TYPE_STRING":      1,
+		"ATTRIBUTE_TYPE_NUMBER":      2,
+		"ATTRIBUTE_TYPE_ENUM":        3,
+		"ATTRIBUTE_TYPE_BOOL":        4,
+	}
+)
+
+func (x AttributeType) Enum() *AttributeType {
+	p := new(AttributeType)
+	*p = x
+	return p
+}
+
+func (x AttributeType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AttributeType) Descriptor() protoreflect.EnumDescriptor {
+	return file_product_proto_enumTypes[0].Descriptor()
+}
+
+func (AttributeType) Type() protoreflect.EnumType {
+	return &file_product_proto_enumTypes[0]
+}
+
+func (x AttributeType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AttributeType.Descriptor instead.
+func (AttributeType) EnumDescriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{0}
+}
+
+type GetCatalogStatsRequest_GroupBy int32
+
+const (
+	GetCatalogStatsRequest_GROUP_BY_UNSPECIFIED GetCatalogStatsRequest_GroupBy = 0
+	GetCatalogStatsRequest_GROUP_BY_CATEGORY    GetCatalogStatsRequest_GroupBy = 1
+	GetCatalogStatsRequest_GROUP_BY_TAG         GetCatalogStatsRequest_GroupBy = 2
+)
+
+// Enum value maps for GetCatalogStatsRequest_GroupBy.
+var (
+	GetCatalogStatsRequest_GroupBy_name = map[int32]string{
+		0: "GROUP_BY_UNSPECIFIED",
+		1: "GROUP_BY_CATEGORY",
+		2: "GROUP_BY_TAG",
+	}
+	GetCatalogStatsRequest_GroupBy_value = map[string]int32{
+		"GROUP_BY_UNSPECIFIED": 0,
+		"GROUP_BY_CATEGORY":    1,
+		"GROUP_BY_TAG":         2,
+	}
+)
+
+func (x GetCatalogStatsRequest_GroupBy) Enum() *GetCatalogStatsRequest_GroupBy {
+	p := new(GetCatalogStatsRequest_GroupBy)
+	*p = x
+	return p
+}
+
+func (x GetCatalogStatsRequest_GroupBy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (GetCatalogStatsRequest_GroupBy) Descriptor() protoreflect.EnumDescriptor {
+	return file_product_proto_enumTypes[1].Descriptor()
+}
+
+func (GetCatalogStatsRequest_GroupBy) Type() protoreflect.EnumType {
+	return &file_product_proto_enumTypes[1]
+}
+
+func (x GetCatalogStatsRequest_GroupBy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use GetCatalogStatsRequest_GroupBy.Descriptor instead.
+func (GetCatalogStatsRequest_GroupBy) EnumDescriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{13, 0}
+}
+
+type AuditEvent_Action int32
+
+const (
+	AuditEvent_ACTION_UNSPECIFIED AuditEvent_Action = 0
+	AuditEvent_ACTION_CREATE      AuditEvent_Action = 1
+	AuditEvent_ACTION_UPDATE      AuditEvent_Action = 2
+	AuditEvent_ACTION_DELETE      AuditEvent_Action = 3
+)
+
+// Enum value maps for AuditEvent_Action.
+var (
+	AuditEvent_Action_name = map[int32]string{
+		0: "ACTION_UNSPECIFIED",
+		1: "ACTION_CREATE",
+		2: "ACTION_UPDATE",
+		3: "ACTION_DELETE",
+	}
+	AuditEvent_Action_value = map[string]int32{
+		"ACTION_UNSPECIFIED": 0,
+		"ACTION_CREATE":      1,
+		"ACTION_UPDATE":      2,
+		"ACTION_DELETE":      3,
+	}
+)
+
+func (x AuditEvent_Action) Enum() *AuditEvent_Action {
+	p := new(AuditEvent_Action)
+	*p = x
+	return p
+}
+
+func (x AuditEvent_Action) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AuditEvent_Action) Descriptor() protoreflect.EnumDescriptor {
+	return file_product_proto_enumTypes[2].Descriptor()
+}
+
+func (AuditEvent_Action) Type() protoreflect.EnumType {
+	return &file_product_proto_enumTypes[2]
+}
+
+func (x AuditEvent_Action) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AuditEvent_Action.Descriptor instead.
+func (AuditEvent_Action) EnumDescriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{28, 0}
+}
+
+// Field rules (see validate.proto) are enforced on every request before the
+// service method runs.
 type Product struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
-	Price         float64                `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id          string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name        string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Price       float64                `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
+	// Custom attributes keyed by attribute definition name. Every key must be
+	// registered in the attribute schema and every value must satisfy it.
+	Attributes map[string]*AttributeValue `protobuf:"bytes,5,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Free-form labels used for filtering (`tags:acme`) and facet counts.
+	Tags []string `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	// Category names the product belongs to, e.g. "power-tools".
+	Categories []string `protobuf:"bytes,7,rep,name=categories,proto3" json:"categories,omitempty"`
+	// etag is a checksum of the other fields, set by the server on products
+	// returned by GetProduct, ListProducts, CreateProduct, UpdateProduct and
+	// BatchUpdateProducts. It is never stored. When set on an update, the
+	// update fails with FAILED_PRECONDITION unless the stored product still has
+	// this etag.
+	Etag          string `protobuf:"bytes,8,opt,name=etag,proto3" json:"etag,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -89,27 +264,62 @@ func (x *Product) GetPrice() float64 {
 	return 0
 }
 
-type GetProductRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+func (x *Product) GetAttributes() map[string]*AttributeValue {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *Product) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Product) GetCategories() []string {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *Product) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
+// AttributeValue is a typed value of a custom product attribute.
+type AttributeValue struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*AttributeValue_StringValue
+	//	*AttributeValue_NumberValue
+	//	*AttributeValue_BoolValue
+	//	*AttributeValue_EnumValue
+	Kind          isAttributeValue_Kind `protobuf_oneof:"kind"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetProductRequest) Reset() {
-	*x = GetProductRequest{}
+func (x *AttributeValue) Reset() {
+	*x = AttributeValue{}
 	mi := &file_product_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetProductRequest) String() string {
+func (x *AttributeValue) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetProductRequest) ProtoMessage() {}
+func (*AttributeValue) ProtoMessage() {}
 
-func (x *GetProductRequest) ProtoReflect() protoreflect.Message {
+func (x *AttributeValue) ProtoReflect() protoreflect.Message {
 	mi := &file_product_proto_msgTypes[1]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -121,39 +331,118 @@ func (x *GetProductRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetProductRequest.ProtoReflect.Descriptor instead.
-func (*GetProductRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use AttributeValue.ProtoReflect.Descriptor instead.
+func (*AttributeValue) Descriptor() ([]byte, []int) {
 	return file_product_proto_rawDescGZIP(), []int{1}
 }
 
-func (x *GetProductRequest) GetId() string {
+func (x *AttributeValue) GetKind() isAttributeValue_Kind {
 	if x != nil {
-		return x.Id
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *AttributeValue) GetStringValue() string {
+	if x != nil {
+		if x, ok := x.Kind.(*AttributeValue_StringValue); ok {
+			return x.StringValue
+		}
 	}
 	return ""
 }
 
-type ListProductsRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Limit         int32                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+func (x *AttributeValue) GetNumberValue() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*AttributeValue_NumberValue); ok {
+			return x.NumberValue
+		}
+	}
+	return 0
+}
+
+func (x *AttributeValue) GetBoolValue() bool {
+	if x != nil {
+		if x, ok := x.Kind.(*AttributeValue_BoolValue); ok {
+			return x.BoolValue
+		}
+	}
+	return false
+}
+
+func (x *AttributeValue) GetEnumValue() string {
+	if x != nil {
+		if x, ok := x.Kind.(*AttributeValue_EnumValue); ok {
+			return x.EnumValue
+		}
+	}
+	return ""
+}
+
+type isAttributeValue_Kind interface {
+	isAttributeValue_Kind()
+}
+
+type AttributeValue_StringValue struct {
+	StringValue string `protobuf:"bytes,1,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+type AttributeValue_NumberValue struct {
+	NumberValue float64 `protobuf:"fixed64,2,opt,name=number_value,json=numberValue,proto3,oneof"`
+}
+
+type AttributeValue_BoolValue struct {
+	BoolValue bool `protobuf:"varint,3,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type AttributeValue_EnumValue struct {
+	// enum_value holds one of the allowed values of an ENUM attribute.
+	EnumValue string `protobuf:"bytes,4,opt,name=enum_value,json=enumValue,proto3,oneof"`
+}
+
+func (*AttributeValue_StringValue) isAttributeValue_Kind() {}
+
+func (*AttributeValue_NumberValue) isAttributeValue_Kind() {}
+
+func (*AttributeValue_BoolValue) isAttributeValue_Kind() {}
+
+func (*AttributeValue_EnumValue) isAttributeValue_Kind() {}
+
+// AttributeDefinition describes a custom attribute that products may carry.
+type AttributeDefinition struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name is the attribute key: lowercase letters, digits and underscores, starting with a letter.
+	Name        string        `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type        AttributeType `protobuf:"varint,2,opt,name=type,proto3,enum=product.v1.AttributeType" json:"type,omitempty"`
+	Description string        `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	// required attributes must be present on every product written after the definition is registered.
+	Required bool `protobuf:"varint,4,opt,name=required,proto3" json:"required,omitempty"`
+	// Constraints matching type. Setting constraints for another type is rejected.
+	//
+	// Types that are valid to be assigned to Constraints:
+	//
+	//	*AttributeDefinition_StringConstraints
+	//	*AttributeDefinition_NumberConstraints
+	//	*AttributeDefinition_EnumConstraints
+	Constraints   isAttributeDefinition_Constraints `protobuf_oneof:"constraints"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ListProductsRequest) Reset() {
-	*x = ListProductsRequest{}
+func (x *AttributeDefinition) Reset() {
+	*x = AttributeDefinition{}
 	mi := &file_product_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListProductsRequest) String() string {
+func (x *AttributeDefinition) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListProductsRequest) ProtoMessage() {}
+func (*AttributeDefinition) ProtoMessage() {}
 
-func (x *ListProductsRequest) ProtoReflect() protoreflect.Message {
+func (x *AttributeDefinition) ProtoReflect() protoreflect.Message {
 	mi := &file_product_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -165,39 +454,120 @@ func (x *ListProductsRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListProductsRequest.ProtoReflect.Descriptor instead.
-func (*ListProductsRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use AttributeDefinition.ProtoReflect.Descriptor instead.
+func (*AttributeDefinition) Descriptor() ([]byte, []int) {
 	return file_product_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *ListProductsRequest) GetLimit() int32 {
+func (x *AttributeDefinition) GetName() string {
 	if x != nil {
-		return x.Limit
+		return x.Name
 	}
-	return 0
+	return ""
 }
 
-type ListProductsResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Products      []*Product             `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+func (x *AttributeDefinition) GetType() AttributeType {
+	if x != nil {
+		return x.Type
+	}
+	return AttributeType_ATTRIBUTE_TYPE_UNSPECIFIED
+}
+
+func (x *AttributeDefinition) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *AttributeDefinition) GetRequired() bool {
+	if x != nil {
+		return x.Required
+	}
+	return false
+}
+
+func (x *AttributeDefinition) GetConstraints() isAttributeDefinition_Constraints {
+	if x != nil {
+		return x.Constraints
+	}
+	return nil
+}
+
+func (x *AttributeDefinition) GetStringConstraints() *StringConstraints {
+	if x != nil {
+		if x, ok := x.Constraints.(*AttributeDefinition_StringConstraints); ok {
+			return x.StringConstraints
+		}
+	}
+	return nil
+}
+
+func (x *AttributeDefinition) GetNumberConstraints() *NumberConstraints {
+	if x != nil {
+		if x, ok := x.Constraints.(*AttributeDefinition_NumberConstraints); ok {
+			return x.NumberConstraints
+		}
+	}
+	return nil
+}
+
+func (x *AttributeDefinition) GetEnumConstraints() *EnumConstraints {
+	if x != nil {
+		if x, ok := x.Constraints.(*AttributeDefinition_EnumConstraints); ok {
+			return x.EnumConstraints
+		}
+	}
+	return nil
+}
+
+type isAttributeDefinition_Constraints interface {
+	isAttributeDefinition_Constraints()
+}
+
+type AttributeDefinition_StringConstraints struct {
+	StringConstraints *StringConstraints `protobuf:"bytes,5,opt,name=string_constraints,json=stringConstraints,proto3,oneof"`
+}
+
+type AttributeDefinition_NumberConstraints struct {
+	NumberConstraints *NumberConstraints `protobuf:"bytes,6,opt,name=number_constraints,json=numberConstraints,proto3,oneof"`
+}
+
+type AttributeDefinition_EnumConstraints struct {
+	EnumConstraints *EnumConstraints `protobuf:"bytes,7,opt,name=enum_constraints,json=enumConstraints,proto3,oneof"`
+}
+
+func (*AttributeDefinition_StringConstraints) isAttributeDefinition_Constraints() {}
+
+func (*AttributeDefinition_NumberConstraints) isAttributeDefinition_Constraints() {}
+
+func (*AttributeDefinition_EnumConstraints) isAttributeDefinition_Constraints() {}
+
+type StringConstraints struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// min_length and max_length count Unicode code points; 0 means no limit.
+	MinLength int32 `protobuf:"varint,1,opt,name=min_length,json=minLength,proto3" json:"min_length,omitempty"`
+	MaxLength int32 `protobuf:"varint,2,opt,name=max_length,json=maxLength,proto3" json:"max_length,omitempty"`
+	// pattern is an RE2 regular expression the whole value must match.
+	Pattern       string `protobuf:"bytes,3,opt,name=pattern,proto3" json:"pattern,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ListProductsResponse) Reset() {
-	*x = ListProductsResponse{}
+func (x *StringConstraints) Reset() {
+	*x = StringConstraints{}
 	mi := &file_product_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListProductsResponse) String() string {
+func (x *StringConstraints) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListProductsResponse) ProtoMessage() {}
+func (*StringConstraints) ProtoMessage() {}
 
-func (x *ListProductsResponse) ProtoReflect() protoreflect.Message {
+func (x *StringConstraints) ProtoReflect() protoreflect.Message {
 	mi := &file_product_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -209,89 +579,2608 @@ func (x *ListProductsResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListProductsResponse.ProtoReflect.Descriptor instead.
-func (*ListProductsResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use StringConstraints.ProtoReflect.Descriptor instead.
+func (*StringConstraints) Descriptor() ([]byte, []int) {
 	return file_product_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *ListProductsResponse) GetProducts() []*Product {
+func (x *StringConstraints) GetMinLength() int32 {
 	if x != nil {
-		return x.Products
+		return x.MinLength
 	}
-	return nil
+	return 0
 }
 
-var File_product_proto protoreflect.FileDescriptor
+func (x *StringConstraints) GetMaxLength() int32 {
+	if x != nil {
+		return x.MaxLength
+	}
+	return 0
+}
 
-const file_product_proto_rawDesc = "" +
-	"\n" +
-	"\rproduct.proto\x12\n" +
-	"product.v1\"e\n" +
-	"\aProduct\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
-	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
-	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x14\n" +
-	"\x05price\x18\x04 \x01(\x01R\x05price\"#\n" +
-	"\x11GetProductRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"+\n" +
-	"\x13ListProductsRequest\x12\x14\n" +
-	"\x05limit\x18\x01 \x01(\x05R\x05limit\"G\n" +
-	"\x14ListProductsResponse\x12/\n" +
-	"\bproducts\x18\x01 \x03(\v2\x13.product.v1.ProductR\bproducts2\xa5\x01\n" +
-	"\x0eProductService\x12@\n" +
-	"\n" +
-	"GetProduct\x12\x1d.product.v1.GetProductRequest\x1a\x13.product.v1.Product\x12Q\n" +
-	"\fListProducts\x12\x1f.product.v1.ListProductsRequest\x1a .product.v1.ListProductsResponseB/Z-grpc-go-fx/internal/generated/product;productb\x06proto3"
+func (x *StringConstraints) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
 
-var (
-	file_product_proto_rawDescOnce sync.Once
-	file_product_proto_rawDescData []byte
-)
+type NumberConstraints struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Min           *float64               `protobuf:"fixed64,1,opt,name=min,proto3,oneof" json:"min,omitempty"`
+	Max           *float64               `protobuf:"fixed64,2,opt,name=max,proto3,oneof" json:"max,omitempty"`
+	IntegerOnly   bool                   `protobuf:"varint,3,opt,name=integer_only,json=integerOnly,proto3" json:"integer_only,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-func file_product_proto_rawDescGZIP() []byte {
-	file_product_proto_rawDescOnce.Do(func() {
-		file_product_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_product_proto_rawDesc), len(file_product_proto_rawDesc)))
-	})
-	return file_product_proto_rawDescData
+func (x *NumberConstraints) Reset() {
+	*x = NumberConstraints{}
+	mi := &file_product_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-var file_product_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
-var file_product_proto_goTypes = []any{
-	(*Product)(nil),              // 0: product.v1.Product
-	(*GetProductRequest)(nil),    // 1: product.v1.GetProductRequest
-	(*ListProductsRequest)(nil),  // 2: product.v1.ListProductsRequest
-	(*ListProductsResponse)(nil), // 3: product.v1.ListProductsResponse
+func (x *NumberConstraints) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
-var file_product_proto_depIdxs = []int32{
-	0, // 0: product.v1.ListProductsResponse.products:type_name -> product.v1.Product
-	1, // 1: product.v1.ProductService.GetProduct:input_type -> product.v1.GetProductRequest
-	2, // 2: product.v1.ProductService.ListProducts:input_type -> product.v1.ListProductsRequest
-	0, // 3: product.v1.ProductService.GetProduct:output_type -> product.v1.Product
-	3, // 4: product.v1.ProductService.ListProducts:output_type -> product.v1.ListProductsResponse
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+
+func (*NumberConstraints) ProtoMessage() {}
+
+func (x *NumberConstraints) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func init() { file_product_proto_init() }
-func file_product_proto_init() {
-	if File_product_proto != nil {
-		return
+// Deprecated: Use NumberConstraints.ProtoReflect.Descriptor instead.
+func (*NumberConstraints) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NumberConstraints) GetMin() float64 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *NumberConstraints) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+func (x *NumberConstraints) GetIntegerOnly() bool {
+	if x != nil {
+		return x.IntegerOnly
+	}
+	return false
+}
+
+type EnumConstraints struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AllowedValues []string               `protobuf:"bytes,1,rep,name=allowed_values,json=allowedValues,proto3" json:"allowed_values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnumConstraints) Reset() {
+	*x = EnumConstraints{}
+	mi := &file_product_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnumConstraints) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnumConstraints) ProtoMessage() {}
+
+func (x *EnumConstraints) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnumConstraints.ProtoReflect.Descriptor instead.
+func (*EnumConstraints) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EnumConstraints) GetAllowedValues() []string {
+	if x != nil {
+		return x.AllowedValues
+	}
+	return nil
+}
+
+type GetProductRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// read_mask selects the product fields to return, e.g. "id,name,price";
+	// empty or "*" returns every field. Unknown paths are rejected.
+	ReadMask      *fieldmaskpb.FieldMask `protobuf:"bytes,2,opt,name=read_mask,json=readMask,proto3" json:"read_mask,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetProductRequest) Reset() {
+	*x = GetProductRequest{}
+	mi := &file_product_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProductRequest) ProtoMessage() {}
+
+func (x *GetProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProductRequest.ProtoReflect.Descriptor instead.
+func (*GetProductRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetProductRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetProductRequest) GetReadMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.ReadMask
+	}
+	return nil
+}
+
+type ListProductsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// limit caps the number of products returned; 0 means 10.
+	Limit int32 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	// filter restricts the result set, e.g. `price < 20 AND attributes.voltage = 220`.
+	// See internal/filter for the grammar.
+	Filter string `protobuf:"bytes,2,opt,name=filter,proto3" json:"filter,omitempty"`
+	// facets, when set, requests facet counts computed over every product
+	// matching filter (not only the returned page).
+	Facets *FacetOptions `protobuf:"bytes,3,opt,name=facets,proto3" json:"facets,omitempty"`
+	// read_mask selects the fields of each returned product, as in
+	// GetProductRequest.read_mask. It does not affect facets.
+	ReadMask *fieldmaskpb.FieldMask `protobuf:"bytes,4,opt,name=read_mask,json=readMask,proto3" json:"read_mask,omitempty"`
+	// order_by orders the products by `id`, `name` or `price`, optionally
+	// followed by `asc` or `desc`, e.g. `price desc`. Products with equal
+	// values are ordered by id in the same direction. Empty means `id`.
+	OrderBy       string `protobuf:"bytes,5,opt,name=order_by,json=orderBy,proto3" json:"order_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProductsRequest) Reset() {
+	*x = ListProductsRequest{}
+	mi := &file_product_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProductsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProductsRequest) ProtoMessage() {}
+
+func (x *ListProductsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProductsRequest.ProtoReflect.Descriptor instead.
+func (*ListProductsRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListProductsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListProductsRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *ListProductsRequest) GetFacets() *FacetOptions {
+	if x != nil {
+		return x.Facets
+	}
+	return nil
+}
+
+func (x *ListProductsRequest) GetReadMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.ReadMask
+	}
+	return nil
+}
+
+func (x *ListProductsRequest) GetOrderBy() string {
+	if x != nil {
+		return x.OrderBy
+	}
+	return ""
+}
+
+type FacetOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// tags requests a count per tag.
+	Tags bool `protobuf:"varint,1,opt,name=tags,proto3" json:"tags,omitempty"`
+	// max_tags caps the number of tag counts returned (highest counts first); 0 means 20.
+	MaxTags int32 `protobuf:"varint,2,opt,name=max_tags,json=maxTags,proto3" json:"max_tags,omitempty"`
+	// price_bucket_bounds are strictly ascending bucket boundaries. N bounds yield
+	// N+1 buckets: (-inf, b0), [b0, b1), ..., [bN-1, +inf). Empty means no price facet.
+	PriceBucketBounds []float64 `protobuf:"fixed64,3,rep,packed,name=price_bucket_bounds,json=priceBucketBounds,proto3" json:"price_bucket_bounds,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *FacetOptions) Reset() {
+	*x = FacetOptions{}
+	mi := &file_product_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FacetOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FacetOptions) ProtoMessage() {}
+
+func (x *FacetOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FacetOptions.ProtoReflect.Descriptor instead.
+func (*FacetOptions) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FacetOptions) GetTags() bool {
+	if x != nil {
+		return x.Tags
+	}
+	return false
+}
+
+func (x *FacetOptions) GetMaxTags() int32 {
+	if x != nil {
+		return x.MaxTags
+	}
+	return 0
+}
+
+func (x *FacetOptions) GetPriceBucketBounds() []float64 {
+	if x != nil {
+		return x.PriceBucketBounds
+	}
+	return nil
+}
+
+type ListProductsResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Products []*Product             `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+	// facets is set when the request asked for facets.
+	Facets        *Facets `protobuf:"bytes,2,opt,name=facets,proto3" json:"facets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProductsResponse) Reset() {
+	*x = ListProductsResponse{}
+	mi := &file_product_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProductsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProductsResponse) ProtoMessage() {}
+
+func (x *ListProductsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProductsResponse.ProtoReflect.Descriptor instead.
+func (*ListProductsResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListProductsResponse) GetProducts() []*Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+func (x *ListProductsResponse) GetFacets() *Facets {
+	if x != nil {
+		return x.Facets
+	}
+	return nil
+}
+
+type Facets struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tags          []*TagCount            `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty"`
+	PriceBuckets  []*PriceBucket         `protobuf:"bytes,2,rep,name=price_buckets,json=priceBuckets,proto3" json:"price_buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Facets) Reset() {
+	*x = Facets{}
+	mi := &file_product_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Facets) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Facets) ProtoMessage() {}
+
+func (x *Facets) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Facets.ProtoReflect.Descriptor instead.
+func (*Facets) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Facets) GetTags() []*TagCount {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Facets) GetPriceBuckets() []*PriceBucket {
+	if x != nil {
+		return x.PriceBuckets
+	}
+	return nil
+}
+
+type TagCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TagCount) Reset() {
+	*x = TagCount{}
+	mi := &file_product_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TagCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TagCount) ProtoMessage() {}
+
+func (x *TagCount) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TagCount.ProtoReflect.Descriptor instead.
+func (*TagCount) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *TagCount) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *TagCount) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type PriceBucket struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// min is the inclusive lower bound; unset for the first bucket.
+	Min *float64 `protobuf:"fixed64,1,opt,name=min,proto3,oneof" json:"min,omitempty"`
+	// max is the exclusive upper bound; unset for the last bucket.
+	Max           *float64 `protobuf:"fixed64,2,opt,name=max,proto3,oneof" json:"max,omitempty"`
+	Count         int64    `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PriceBucket) Reset() {
+	*x = PriceBucket{}
+	mi := &file_product_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PriceBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriceBucket) ProtoMessage() {}
+
+func (x *PriceBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriceBucket.ProtoReflect.Descriptor instead.
+func (*PriceBucket) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PriceBucket) GetMin() float64 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *PriceBucket) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+func (x *PriceBucket) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type GetCatalogStatsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// filter restricts the products included, using the ListProducts grammar.
+	Filter  string                         `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	GroupBy GetCatalogStatsRequest_GroupBy `protobuf:"varint,2,opt,name=group_by,json=groupBy,proto3,enum=product.v1.GetCatalogStatsRequest_GroupBy" json:"group_by,omitempty"`
+	// histogram_bounds are strictly ascending price bucket boundaries, as in
+	// FacetOptions.price_bucket_bounds. Empty means the server defaults.
+	HistogramBounds []float64 `protobuf:"fixed64,3,rep,packed,name=histogram_bounds,json=histogramBounds,proto3" json:"histogram_bounds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *GetCatalogStatsRequest) Reset() {
+	*x = GetCatalogStatsRequest{}
+	mi := &file_product_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCatalogStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCatalogStatsRequest) ProtoMessage() {}
+
+func (x *GetCatalogStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCatalogStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetCatalogStatsRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetCatalogStatsRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *GetCatalogStatsRequest) GetGroupBy() GetCatalogStatsRequest_GroupBy {
+	if x != nil {
+		return x.GroupBy
+	}
+	return GetCatalogStatsRequest_GROUP_BY_UNSPECIFIED
+}
+
+func (x *GetCatalogStatsRequest) GetHistogramBounds() []float64 {
+	if x != nil {
+		return x.HistogramBounds
+	}
+	return nil
+}
+
+type GetCatalogStatsResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Overall *PriceStats            `protobuf:"bytes,1,opt,name=overall,proto3" json:"overall,omitempty"`
+	// groups holds one entry per category or tag, ordered by key. Products with
+	// several categories or tags count towards each of them; products with none
+	// are reported under the empty key.
+	Groups        []*StatsGroup `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCatalogStatsResponse) Reset() {
+	*x = GetCatalogStatsResponse{}
+	mi := &file_product_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCatalogStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCatalogStatsResponse) ProtoMessage() {}
+
+func (x *GetCatalogStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCatalogStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetCatalogStatsResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetCatalogStatsResponse) GetOverall() *PriceStats {
+	if x != nil {
+		return x.Overall
+	}
+	return nil
+}
+
+func (x *GetCatalogStatsResponse) GetGroups() []*StatsGroup {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type StatsGroup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Stats         *PriceStats            `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsGroup) Reset() {
+	*x = StatsGroup{}
+	mi := &file_product_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsGroup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsGroup) ProtoMessage() {}
+
+func (x *StatsGroup) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsGroup.ProtoReflect.Descriptor instead.
+func (*StatsGroup) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *StatsGroup) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *StatsGroup) GetStats() *PriceStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type PriceStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Count int64                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	// min, max and average are zero when count is zero.
+	MinPrice      float64        `protobuf:"fixed64,2,opt,name=min_price,json=minPrice,proto3" json:"min_price,omitempty"`
+	MaxPrice      float64        `protobuf:"fixed64,3,opt,name=max_price,json=maxPrice,proto3" json:"max_price,omitempty"`
+	AveragePrice  float64        `protobuf:"fixed64,4,opt,name=average_price,json=averagePrice,proto3" json:"average_price,omitempty"`
+	Histogram     []*PriceBucket `protobuf:"bytes,5,rep,name=histogram,proto3" json:"histogram,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PriceStats) Reset() {
+	*x = PriceStats{}
+	mi := &file_product_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PriceStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriceStats) ProtoMessage() {}
+
+func (x *PriceStats) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriceStats.ProtoReflect.Descriptor instead.
+func (*PriceStats) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PriceStats) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *PriceStats) GetMinPrice() float64 {
+	if x != nil {
+		return x.MinPrice
+	}
+	return 0
+}
+
+func (x *PriceStats) GetMaxPrice() float64 {
+	if x != nil {
+		return x.MaxPrice
+	}
+	return 0
+}
+
+func (x *PriceStats) GetAveragePrice() float64 {
+	if x != nil {
+		return x.AveragePrice
+	}
+	return 0
+}
+
+func (x *PriceStats) GetHistogram() []*PriceBucket {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
+type BulkImportProductsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// products are validated one by one by the operation, which reports invalid
+	// items as failures instead of rejecting the whole request.
+	Products []*Product `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+	// upsert replaces existing products instead of reporting them as failures.
+	Upsert        bool `protobuf:"varint,2,opt,name=upsert,proto3" json:"upsert,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkImportProductsRequest) Reset() {
+	*x = BulkImportProductsRequest{}
+	mi := &file_product_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkImportProductsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkImportProductsRequest) ProtoMessage() {}
+
+func (x *BulkImportProductsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkImportProductsRequest.ProtoReflect.Descriptor instead.
+func (*BulkImportProductsRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *BulkImportProductsRequest) GetProducts() []*Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+func (x *BulkImportProductsRequest) GetUpsert() bool {
+	if x != nil {
+		return x.Upsert
+	}
+	return false
+}
+
+type BulkImportProductsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ImportedCount int64                  `protobuf:"varint,1,opt,name=imported_count,json=importedCount,proto3" json:"imported_count,omitempty"`
+	// failures lists the products that were rejected; the rest were imported.
+	Failures      []*BulkItemFailure `protobuf:"bytes,2,rep,name=failures,proto3" json:"failures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkImportProductsResponse) Reset() {
+	*x = BulkImportProductsResponse{}
+	mi := &file_product_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkImportProductsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkImportProductsResponse) ProtoMessage() {}
+
+func (x *BulkImportProductsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkImportProductsResponse.ProtoReflect.Descriptor instead.
+func (*BulkImportProductsResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *BulkImportProductsResponse) GetImportedCount() int64 {
+	if x != nil {
+		return x.ImportedCount
+	}
+	return 0
+}
+
+func (x *BulkImportProductsResponse) GetFailures() []*BulkItemFailure {
+	if x != nil {
+		return x.Failures
+	}
+	return nil
+}
+
+type BulkItemFailure struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// index of the item in the request.
+	Index         int32  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Id            string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Message       string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkItemFailure) Reset() {
+	*x = BulkItemFailure{}
+	mi := &file_product_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkItemFailure) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkItemFailure) ProtoMessage() {}
+
+func (x *BulkItemFailure) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkItemFailure.ProtoReflect.Descriptor instead.
+func (*BulkItemFailure) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *BulkItemFailure) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *BulkItemFailure) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BulkItemFailure) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type BulkUpdatePricesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// filter selects the products to reprice, using the ListProducts grammar.
+	Filter string `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	// Types that are valid to be assigned to Change:
+	//
+	//	*BulkUpdatePricesRequest_Multiplier
+	//	*BulkUpdatePricesRequest_Delta
+	Change        isBulkUpdatePricesRequest_Change `protobuf_oneof:"change"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkUpdatePricesRequest) Reset() {
+	*x = BulkUpdatePricesRequest{}
+	mi := &file_product_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkUpdatePricesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkUpdatePricesRequest) ProtoMessage() {}
+
+func (x *BulkUpdatePricesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkUpdatePricesRequest.ProtoReflect.Descriptor instead.
+func (*BulkUpdatePricesRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *BulkUpdatePricesRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *BulkUpdatePricesRequest) GetChange() isBulkUpdatePricesRequest_Change {
+	if x != nil {
+		return x.Change
+	}
+	return nil
+}
+
+func (x *BulkUpdatePricesRequest) GetMultiplier() float64 {
+	if x != nil {
+		if x, ok := x.Change.(*BulkUpdatePricesRequest_Multiplier); ok {
+			return x.Multiplier
+		}
+	}
+	return 0
+}
+
+func (x *BulkUpdatePricesRequest) GetDelta() float64 {
+	if x != nil {
+		if x, ok := x.Change.(*BulkUpdatePricesRequest_Delta); ok {
+			return x.Delta
+		}
+	}
+	return 0
+}
+
+type isBulkUpdatePricesRequest_Change interface {
+	isBulkUpdatePricesRequest_Change()
+}
+
+type BulkUpdatePricesRequest_Multiplier struct {
+	// multiplier scales prices, e.g. 1.1 for +10%.
+	Multiplier float64 `protobuf:"fixed64,2,opt,name=multiplier,proto3,oneof"`
+}
+
+type BulkUpdatePricesRequest_Delta struct {
+	// delta is added to prices; results below zero are clamped to zero.
+	Delta float64 `protobuf:"fixed64,3,opt,name=delta,proto3,oneof"`
+}
+
+func (*BulkUpdatePricesRequest_Multiplier) isBulkUpdatePricesRequest_Change() {}
+
+func (*BulkUpdatePricesRequest_Delta) isBulkUpdatePricesRequest_Change() {}
+
+type BulkUpdatePricesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UpdatedCount  int64                  `protobuf:"varint,1,opt,name=updated_count,json=updatedCount,proto3" json:"updated_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkUpdatePricesResponse) Reset() {
+	*x = BulkUpdatePricesResponse{}
+	mi := &file_product_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkUpdatePricesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkUpdatePricesResponse) ProtoMessage() {}
+
+func (x *BulkUpdatePricesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkUpdatePricesResponse.ProtoReflect.Descriptor instead.
+func (*BulkUpdatePricesResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *BulkUpdatePricesResponse) GetUpdatedCount() int64 {
+	if x != nil {
+		return x.UpdatedCount
+	}
+	return 0
+}
+
+type PurgeProductsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// filter selects the products to delete, using the ListProducts grammar.
+	Filter string `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	// force must be set to purge with an empty filter, i.e. the whole catalog.
+	Force         bool `protobuf:"varint,2,opt,name=force,proto3" json:"force,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeProductsRequest) Reset() {
+	*x = PurgeProductsRequest{}
+	mi := &file_product_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeProductsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeProductsRequest) ProtoMessage() {}
+
+func (x *PurgeProductsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeProductsRequest.ProtoReflect.Descriptor instead.
+func (*PurgeProductsRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *PurgeProductsRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *PurgeProductsRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
+type PurgeProductsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PurgedCount   int64                  `protobuf:"varint,1,opt,name=purged_count,json=purgedCount,proto3" json:"purged_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeProductsResponse) Reset() {
+	*x = PurgeProductsResponse{}
+	mi := &file_product_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeProductsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeProductsResponse) ProtoMessage() {}
+
+func (x *PurgeProductsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeProductsResponse.ProtoReflect.Descriptor instead.
+func (*PurgeProductsResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *PurgeProductsResponse) GetPurgedCount() int64 {
+	if x != nil {
+		return x.PurgedCount
+	}
+	return 0
+}
+
+// BulkOperationMetadata reports the progress of a bulk operation.
+type BulkOperationMetadata struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	CreateTime     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=create_time,json=createTime,proto3" json:"create_time,omitempty"`
+	UpdateTime     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=update_time,json=updateTime,proto3" json:"update_time,omitempty"`
+	TotalItems     int64                  `protobuf:"varint,3,opt,name=total_items,json=totalItems,proto3" json:"total_items,omitempty"`
+	ProcessedItems int64                  `protobuf:"varint,4,opt,name=processed_items,json=processedItems,proto3" json:"processed_items,omitempty"`
+	FailedItems    int64                  `protobuf:"varint,5,opt,name=failed_items,json=failedItems,proto3" json:"failed_items,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *BulkOperationMetadata) Reset() {
+	*x = BulkOperationMetadata{}
+	mi := &file_product_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkOperationMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkOperationMetadata) ProtoMessage() {}
+
+func (x *BulkOperationMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkOperationMetadata.ProtoReflect.Descriptor instead.
+func (*BulkOperationMetadata) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *BulkOperationMetadata) GetCreateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreateTime
+	}
+	return nil
+}
+
+func (x *BulkOperationMetadata) GetUpdateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdateTime
+	}
+	return nil
+}
+
+func (x *BulkOperationMetadata) GetTotalItems() int64 {
+	if x != nil {
+		return x.TotalItems
+	}
+	return 0
+}
+
+func (x *BulkOperationMetadata) GetProcessedItems() int64 {
+	if x != nil {
+		return x.ProcessedItems
+	}
+	return 0
+}
+
+func (x *BulkOperationMetadata) GetFailedItems() int64 {
+	if x != nil {
+		return x.FailedItems
+	}
+	return 0
+}
+
+type GetSimilarProductsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id of the product to find similar products for.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// max_results caps the number of products returned; 0 means 10.
+	MaxResults int32 `protobuf:"varint,2,opt,name=max_results,json=maxResults,proto3" json:"max_results,omitempty"`
+	// filter restricts the candidates, using the ListProducts grammar, e.g. `price < 50`.
+	Filter        string `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSimilarProductsRequest) Reset() {
+	*x = GetSimilarProductsRequest{}
+	mi := &file_product_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSimilarProductsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSimilarProductsRequest) ProtoMessage() {}
+
+func (x *GetSimilarProductsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSimilarProductsRequest.ProtoReflect.Descriptor instead.
+func (*GetSimilarProductsRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *GetSimilarProductsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetSimilarProductsRequest) GetMaxResults() int32 {
+	if x != nil {
+		return x.MaxResults
+	}
+	return 0
+}
+
+func (x *GetSimilarProductsRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+type GetSimilarProductsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// results are ordered by descending score. Products that share nothing
+	// with the requested one are not returned.
+	Results       []*SimilarProduct `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSimilarProductsResponse) Reset() {
+	*x = GetSimilarProductsResponse{}
+	mi := &file_product_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSimilarProductsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSimilarProductsResponse) ProtoMessage() {}
+
+func (x *GetSimilarProductsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSimilarProductsResponse.ProtoReflect.Descriptor instead.
+func (*GetSimilarProductsResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *GetSimilarProductsResponse) GetResults() []*SimilarProduct {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type SimilarProduct struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Product *Product               `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	// score is the weighted average of the signals below, in [0, 1].
+	Score float64 `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	// text_score is the cosine similarity of the TF-IDF vectors of name and description.
+	TextScore float64 `protobuf:"fixed64,3,opt,name=text_score,json=textScore,proto3" json:"text_score,omitempty"`
+	// label_score is the Jaccard similarity of the category and tag sets.
+	LabelScore float64 `protobuf:"fixed64,4,opt,name=label_score,json=labelScore,proto3" json:"label_score,omitempty"`
+	// price_score is 1 for equal prices, falling to 0 as the difference
+	// approaches the higher price. It is 0 if text_score is, so that price alone
+	// does not make products similar.
+	PriceScore    float64 `protobuf:"fixed64,5,opt,name=price_score,json=priceScore,proto3" json:"price_score,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SimilarProduct) Reset() {
+	*x = SimilarProduct{}
+	mi := &file_product_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SimilarProduct) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SimilarProduct) ProtoMessage() {}
+
+func (x *SimilarProduct) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SimilarProduct.ProtoReflect.Descriptor instead.
+func (*SimilarProduct) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *SimilarProduct) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+func (x *SimilarProduct) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SimilarProduct) GetTextScore() float64 {
+	if x != nil {
+		return x.TextScore
+	}
+	return 0
+}
+
+func (x *SimilarProduct) GetLabelScore() float64 {
+	if x != nil {
+		return x.LabelScore
+	}
+	return 0
+}
+
+func (x *SimilarProduct) GetPriceScore() float64 {
+	if x != nil {
+		return x.PriceScore
+	}
+	return 0
+}
+
+// AuditEvent records a single product mutation.
+type AuditEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id increases with every recorded event.
+	Id   string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Time *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	// actor identifies the authenticated caller; "anonymous" when unknown.
+	Actor string `protobuf:"bytes,3,opt,name=actor,proto3" json:"actor,omitempty"`
+	// method is the full gRPC method that caused the change, e.g.
+	// "/product.v1.ProductService/UpdateProduct". Changes made by a bulk
+	// operation carry the method that started it.
+	Method    string            `protobuf:"bytes,4,opt,name=method,proto3" json:"method,omitempty"`
+	ProductId string            `protobuf:"bytes,5,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Action    AuditEvent_Action `protobuf:"varint,6,opt,name=action,proto3,enum=product.v1.AuditEvent_Action" json:"action,omitempty"`
+	// before is unset for CREATE and after is unset for DELETE.
+	Before *Product `protobuf:"bytes,7,opt,name=before,proto3" json:"before,omitempty"`
+	After  *Product `protobuf:"bytes,8,opt,name=after,proto3" json:"after,omitempty"`
+	// changed_fields lists the product fields that differ between before and
+	// after, with attributes reported per key, e.g. "price", "attributes.color".
+	ChangedFields []string `protobuf:"bytes,9,rep,name=changed_fields,json=changedFields,proto3" json:"changed_fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditEvent) Reset() {
+	*x = AuditEvent{}
+	mi := &file_product_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditEvent) ProtoMessage() {}
+
+func (x *AuditEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditEvent.ProtoReflect.Descriptor instead.
+func (*AuditEvent) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *AuditEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *AuditEvent) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetAction() AuditEvent_Action {
+	if x != nil {
+		return x.Action
+	}
+	return AuditEvent_ACTION_UNSPECIFIED
+}
+
+func (x *AuditEvent) GetBefore() *Product {
+	if x != nil {
+		return x.Before
+	}
+	return nil
+}
+
+func (x *AuditEvent) GetAfter() *Product {
+	if x != nil {
+		return x.After
+	}
+	return nil
+}
+
+func (x *AuditEvent) GetChangedFields() []string {
+	if x != nil {
+		return x.ChangedFields
+	}
+	return nil
+}
+
+type ListAuditEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Filters; empty fields match every event.
+	ProductId string `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Actor     string `protobuf:"bytes,2,opt,name=actor,proto3" json:"actor,omitempty"`
+	// start_time is inclusive and end_time exclusive.
+	StartTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	// page_size defaults to 100.
+	PageSize      int32  `protobuf:"varint,5,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string `protobuf:"bytes,6,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAuditEventsRequest) Reset() {
+	*x = ListAuditEventsRequest{}
+	mi := &file_product_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAuditEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAuditEventsRequest) ProtoMessage() {}
+
+func (x *ListAuditEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAuditEventsRequest.ProtoReflect.Descriptor instead.
+func (*ListAuditEventsRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *ListAuditEventsRequest) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *ListAuditEventsRequest) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *ListAuditEventsRequest) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *ListAuditEventsRequest) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *ListAuditEventsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListAuditEventsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListAuditEventsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*AuditEvent          `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAuditEventsResponse) Reset() {
+	*x = ListAuditEventsResponse{}
+	mi := &file_product_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAuditEventsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAuditEventsResponse) ProtoMessage() {}
+
+func (x *ListAuditEventsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAuditEventsResponse.ProtoReflect.Descriptor instead.
+func (*ListAuditEventsResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *ListAuditEventsResponse) GetEvents() []*AuditEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *ListAuditEventsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type CreateProductRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// product to create. If product.id is empty, the server assigns one.
+	Product       *Product `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateProductRequest) Reset() {
+	*x = CreateProductRequest{}
+	mi := &file_product_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateProductRequest) ProtoMessage() {}
+
+func (x *CreateProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateProductRequest.ProtoReflect.Descriptor instead.
+func (*CreateProductRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *CreateProductRequest) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+type UpdateProductRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// product replaces the stored product with the same id.
+	Product       *Product `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateProductRequest) Reset() {
+	*x = UpdateProductRequest{}
+	mi := &file_product_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateProductRequest) ProtoMessage() {}
+
+func (x *UpdateProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateProductRequest.ProtoReflect.Descriptor instead.
+func (*UpdateProductRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *UpdateProductRequest) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+type DeleteProductRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteProductRequest) Reset() {
+	*x = DeleteProductRequest{}
+	mi := &file_product_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteProductRequest) ProtoMessage() {}
+
+func (x *DeleteProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteProductRequest.ProtoReflect.Descriptor instead.
+func (*DeleteProductRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *DeleteProductRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type BatchUpdateProductsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// changes are applied together; each product may be changed at most once.
+	// At most 1000 changes are accepted.
+	Changes       []*ProductChange `protobuf:"bytes,1,rep,name=changes,proto3" json:"changes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchUpdateProductsRequest) Reset() {
+	*x = BatchUpdateProductsRequest{}
+	mi := &file_product_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchUpdateProductsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchUpdateProductsRequest) ProtoMessage() {}
+
+func (x *BatchUpdateProductsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchUpdateProductsRequest.ProtoReflect.Descriptor instead.
+func (*BatchUpdateProductsRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *BatchUpdateProductsRequest) GetChanges() []*ProductChange {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+// ProductChange is a single write in a BatchUpdateProducts request.
+type ProductChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*ProductChange_Create
+	//	*ProductChange_Update
+	//	*ProductChange_Delete
+	Kind isProductChange_Kind `protobuf_oneof:"kind"`
+	// etag, if set, must equal the etag of the stored product. For updates,
+	// update.etag is used when it is empty. It cannot be set on creates.
+	Etag          string `protobuf:"bytes,4,opt,name=etag,proto3" json:"etag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductChange) Reset() {
+	*x = ProductChange{}
+	mi := &file_product_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductChange) ProtoMessage() {}
+
+func (x *ProductChange) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductChange.ProtoReflect.Descriptor instead.
+func (*ProductChange) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *ProductChange) GetKind() isProductChange_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *ProductChange) GetCreate() *Product {
+	if x != nil {
+		if x, ok := x.Kind.(*ProductChange_Create); ok {
+			return x.Create
+		}
+	}
+	return nil
+}
+
+func (x *ProductChange) GetUpdate() *Product {
+	if x != nil {
+		if x, ok := x.Kind.(*ProductChange_Update); ok {
+			return x.Update
+		}
+	}
+	return nil
+}
+
+func (x *ProductChange) GetDelete() string {
+	if x != nil {
+		if x, ok := x.Kind.(*ProductChange_Delete); ok {
+			return x.Delete
+		}
+	}
+	return ""
+}
+
+func (x *ProductChange) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
+type isProductChange_Kind interface {
+	isProductChange_Kind()
+}
+
+type ProductChange_Create struct {
+	// create adds a product that must not exist yet. If its id is empty, the
+	// server assigns one.
+	Create *Product `protobuf:"bytes,1,opt,name=create,proto3,oneof"`
+}
+
+type ProductChange_Update struct {
+	// update replaces a product that must exist.
+	Update *Product `protobuf:"bytes,2,opt,name=update,proto3,oneof"`
+}
+
+type ProductChange_Delete struct {
+	// delete removes the product with this id, which must exist.
+	Delete string `protobuf:"bytes,3,opt,name=delete,proto3,oneof"`
+}
+
+func (*ProductChange_Create) isProductChange_Kind() {}
+
+func (*ProductChange_Update) isProductChange_Kind() {}
+
+func (*ProductChange_Delete) isProductChange_Kind() {}
+
+type BatchUpdateProductsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// products holds the created and updated products, with their new etags,
+	// in request order. Deletes have no entry.
+	Products      []*Product `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchUpdateProductsResponse) Reset() {
+	*x = BatchUpdateProductsResponse{}
+	mi := &file_product_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchUpdateProductsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchUpdateProductsResponse) ProtoMessage() {}
+
+func (x *BatchUpdateProductsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchUpdateProductsResponse.ProtoReflect.Descriptor instead.
+func (*BatchUpdateProductsResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *BatchUpdateProductsResponse) GetProducts() []*Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+type PutAttributeDefinitionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Definition    *AttributeDefinition   `protobuf:"bytes,1,opt,name=definition,proto3" json:"definition,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PutAttributeDefinitionRequest) Reset() {
+	*x = PutAttributeDefinitionRequest{}
+	mi := &file_product_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PutAttributeDefinitionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PutAttributeDefinitionRequest) ProtoMessage() {}
+
+func (x *PutAttributeDefinitionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PutAttributeDefinitionRequest.ProtoReflect.Descriptor instead.
+func (*PutAttributeDefinitionRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *PutAttributeDefinitionRequest) GetDefinition() *AttributeDefinition {
+	if x != nil {
+		return x.Definition
+	}
+	return nil
+}
+
+type ListAttributeDefinitionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAttributeDefinitionsRequest) Reset() {
+	*x = ListAttributeDefinitionsRequest{}
+	mi := &file_product_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAttributeDefinitionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAttributeDefinitionsRequest) ProtoMessage() {}
+
+func (x *ListAttributeDefinitionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAttributeDefinitionsRequest.ProtoReflect.Descriptor instead.
+func (*ListAttributeDefinitionsRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{38}
+}
+
+type ListAttributeDefinitionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Definitions   []*AttributeDefinition `protobuf:"bytes,1,rep,name=definitions,proto3" json:"definitions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAttributeDefinitionsResponse) Reset() {
+	*x = ListAttributeDefinitionsResponse{}
+	mi := &file_product_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAttributeDefinitionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAttributeDefinitionsResponse) ProtoMessage() {}
+
+func (x *ListAttributeDefinitionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAttributeDefinitionsResponse.ProtoReflect.Descriptor instead.
+func (*ListAttributeDefinitionsResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *ListAttributeDefinitionsResponse) GetDefinitions() []*AttributeDefinition {
+	if x != nil {
+		return x.Definitions
+	}
+	return nil
+}
+
+var File_product_proto protoreflect.FileDescriptor
+
+const file_product_proto_rawDesc = "" +
+	"\n" +
+	"\rproduct.proto\x12\n" +
+	"product.v1\x1a#google/longrunning/operations.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a google/protobuf/field_mask.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x0evalidate.proto\"\xa6\x03\n" +
+	"\aProduct\x124\n" +
+	"\x02id\x18\x01 \x01(\tB$\xc2\xf3\x18 \x18@2\x1c^[A-Za-z0-9][A-Za-z0-9._-]*$R\x02id\x12\x1d\n" +
+	"\x04name\x18\x02 \x01(\tB\t\xc2\xf3\x18\x05\b\x01\x18\xc8\x01R\x04name\x12)\n" +
+	"\vdescription\x18\x03 \x01(\tB\a\xc2\xf3\x18\x03\x18\xd0\x0fR\vdescription\x12#\n" +
+	"\x05price\x18\x04 \x01(\x01B\r\xc2\xf3\x18\t!\x00\x00\x00\x00\x00\x00\x00\x00R\x05price\x12C\n" +
+	"\n" +
+	"attributes\x18\x05 \x03(\v2#.product.v1.Product.AttributesEntryR\n" +
+	"attributes\x12\x1a\n" +
+	"\x04tags\x18\x06 \x03(\tB\x06\xc2\xf3\x18\x02\x18@R\x04tags\x12&\n" +
+	"\n" +
+	"categories\x18\a \x03(\tB\x06\xc2\xf3\x18\x02\x18@R\n" +
+	"categories\x12\x12\n" +
+	"\x04etag\x18\b \x01(\tR\x04etag\x1aY\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x120\n" +
+	"\x05value\x18\x02 \x01(\v2\x1a.product.v1.AttributeValueR\x05value:\x028\x01\"\xa4\x01\n" +
+	"\x0eAttributeValue\x12#\n" +
+	"\fstring_value\x18\x01 \x01(\tH\x00R\vstringValue\x12#\n" +
+	"\fnumber_value\x18\x02 \x01(\x01H\x00R\vnumberValue\x12\x1f\n" +
+	"\n" +
+	"bool_value\x18\x03 \x01(\bH\x00R\tboolValue\x12\x1f\n" +
+	"\n" +
+	"enum_value\x18\x04 \x01(\tH\x00R\tenumValueB\x06\n" +
+	"\x04kind\"\xb9\x03\n" +
+	"\x13AttributeDefinition\x122\n" +
+	"\x04name\x18\x01 \x01(\tB\x1e\xc2\xf3\x18\x1a\b\x012\x16^[a-z][a-z0-9_]{0,62}$R\x04name\x127\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x19.product.v1.AttributeTypeB\b\xc2\xf3\x18\x04\b\x01@\x01R\x04type\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1a\n" +
+	"\brequired\x18\x04 \x01(\bR\brequired\x12N\n" +
+	"\x12string_constraints\x18\x05 \x01(\v2\x1d.product.v1.StringConstraintsH\x00R\x11stringConstraints\x12N\n" +
+	"\x12number_constraints\x18\x06 \x01(\v2\x1d.product.v1.NumberConstraintsH\x00R\x11numberConstraints\x12H\n" +
+	"\x10enum_constraints\x18\a \x01(\v2\x1b.product.v1.EnumConstraintsH\x00R\x0fenumConstraintsB\r\n" +
+	"\vconstraints\"k\n" +
+	"\x11StringConstraints\x12\x1d\n" +
+	"\n" +
+	"min_length\x18\x01 \x01(\x05R\tminLength\x12\x1d\n" +
+	"\n" +
+	"max_length\x18\x02 \x01(\x05R\tmaxLength\x12\x18\n" +
+	"\apattern\x18\x03 \x01(\tR\apattern\"t\n" +
+	"\x11NumberConstraints\x12\x15\n" +
+	"\x03min\x18\x01 \x01(\x01H\x00R\x03min\x88\x01\x01\x12\x15\n" +
+	"\x03max\x18\x02 \x01(\x01H\x01R\x03max\x88\x01\x01\x12!\n" +
+	"\finteger_only\x18\x03 \x01(\bR\vintegerOnlyB\x06\n" +
+	"\x04_minB\x06\n" +
+	"\x04_max\"8\n" +
+	"\x0fEnumConstraints\x12%\n" +
+	"\x0eallowed_values\x18\x01 \x03(\tR\rallowedValues\"d\n" +
+	"\x11GetProductRequest\x12\x16\n" +
+	"\x02id\x18\x01 \x01(\tB\x06\xc2\xf3\x18\x02\b\x01R\x02id\x127\n" +
+	"\tread_mask\x18\x02 \x01(\v2\x1a.google.protobuf.FieldMaskR\breadMask\"\xe1\x01\n" +
+	"\x13ListProductsRequest\x12#\n" +
+	"\x05limit\x18\x01 \x01(\x05B\r\xc2\xf3\x18\t!\x00\x00\x00\x00\x00\x00\x00\x00R\x05limit\x12\x1f\n" +
+	"\x06filter\x18\x02 \x01(\tB\a\xc2\xf3\x18\x03\x18\xd0\x0fR\x06filter\x120\n" +
+	"\x06facets\x18\x03 \x01(\v2\x18.product.v1.FacetOptionsR\x06facets\x127\n" +
+	"\tread_mask\x18\x04 \x01(\v2\x1a.google.protobuf.FieldMaskR\breadMask\x12\x19\n" +
+	"\border_by\x18\x05 \x01(\tR\aorderBy\"|\n" +
+	"\fFacetOptions\x12\x12\n" +
+	"\x04tags\x18\x01 \x01(\bR\x04tags\x12(\n" +
+	"\bmax_tags\x18\x02 \x01(\x05B\r\xc2\xf3\x18\t!\x00\x00\x00\x00\x00\x00\x00\x00R\amaxTags\x12.\n" +
+	"\x13price_bucket_bounds\x18\x03 \x03(\x01R\x11priceBucketBounds\"s\n" +
+	"\x14ListProductsResponse\x12/\n" +
+	"\bproducts\x18\x01 \x03(\v2\x13.product.v1.ProductR\bproducts\x12*\n" +
+	"\x06facets\x18\x02 \x01(\v2\x12.product.v1.FacetsR\x06facets\"p\n" +
+	"\x06Facets\x12(\n" +
+	"\x04tags\x18\x01 \x03(\v2\x14.product.v1.TagCountR\x04tags\x12<\n" +
+	"\rprice_buckets\x18\x02 \x03(\v2\x17.product.v1.PriceBucketR\fpriceBuckets\"2\n" +
+	"\bTagCount\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\"a\n" +
+	"\vPriceBucket\x12\x15\n" +
+	"\x03min\x18\x01 \x01(\x01H\x00R\x03min\x88\x01\x01\x12\x15\n" +
+	"\x03max\x18\x02 \x01(\x01H\x01R\x03max\x88\x01\x01\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x03R\x05countB\x06\n" +
+	"\x04_minB\x06\n" +
+	"\x04_max\"\x81\x02\n" +
+	"\x16GetCatalogStatsRequest\x12\x1f\n" +
+	"\x06filter\x18\x01 \x01(\tB\a\xc2\xf3\x18\x03\x18\xd0\x0fR\x06filter\x12M\n" +
+	"\bgroup_by\x18\x02 \x01(\x0e2*.product.v1.GetCatalogStatsRequest.GroupByB\x06\xc2\xf3\x18\x02@\x01R\agroupBy\x12)\n" +
+	"\x10histogram_bounds\x18\x03 \x03(\x01R\x0fhistogramBounds\"L\n" +
+	"\aGroupBy\x12\x18\n" +
+	"\x14GROUP_BY_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11GROUP_BY_CATEGORY\x10\x01\x12\x10\n" +
+	"\fGROUP_BY_TAG\x10\x02\"{\n" +
+	"\x17GetCatalogStatsResponse\x120\n" +
+	"\aoverall\x18\x01 \x01(\v2\x16.product.v1.PriceStatsR\aoverall\x12.\n" +
+	"\x06groups\x18\x02 \x03(\v2\x16.product.v1.StatsGroupR\x06groups\"L\n" +
+	"\n" +
+	"StatsGroup\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05stats\x18\x02 \x01(\v2\x16.product.v1.PriceStatsR\x05stats\"\xb8\x01\n" +
+	"\n" +
+	"PriceStats\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x03R\x05count\x12\x1b\n" +
+	"\tmin_price\x18\x02 \x01(\x01R\bminPrice\x12\x1b\n" +
+	"\tmax_price\x18\x03 \x01(\x01R\bmaxPrice\x12#\n" +
+	"\raverage_price\x18\x04 \x01(\x01R\faveragePrice\x125\n" +
+	"\thistogram\x18\x05 \x03(\v2\x17.product.v1.PriceBucketR\thistogram\"l\n" +
+	"\x19BulkImportProductsRequest\x127\n" +
+	"\bproducts\x18\x01 \x03(\v2\x13.product.v1.ProductB\x06\xc2\xf3\x18\x02H\x01R\bproducts\x12\x16\n" +
+	"\x06upsert\x18\x02 \x01(\bR\x06upsert\"|\n" +
+	"\x1aBulkImportProductsResponse\x12%\n" +
+	"\x0eimported_count\x18\x01 \x01(\x03R\rimportedCount\x127\n" +
+	"\bfailures\x18\x02 \x03(\v2\x1b.product.v1.BulkItemFailureR\bfailures\"Q\n" +
+	"\x0fBulkItemFailure\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\x8d\x01\n" +
+	"\x17BulkUpdatePricesRequest\x12\x1f\n" +
+	"\x06filter\x18\x01 \x01(\tB\a\xc2\xf3\x18\x03\x18\xd0\x0fR\x06filter\x12/\n" +
+	"\n" +
+	"multiplier\x18\x02 \x01(\x01B\r\xc2\xf3\x18\t!\x00\x00\x00\x00\x00\x00\x00\x00H\x00R\n" +
+	"multiplier\x12\x16\n" +
+	"\x05delta\x18\x03 \x01(\x01H\x00R\x05deltaB\b\n" +
+	"\x06change\"?\n" +
+	"\x18BulkUpdatePricesResponse\x12#\n" +
+	"\rupdated_count\x18\x01 \x01(\x03R\fupdatedCount\"M\n" +
+	"\x14PurgeProductsRequest\x12\x1f\n" +
+	"\x06filter\x18\x01 \x01(\tB\a\xc2\xf3\x18\x03\x18\xd0\x0fR\x06filter\x12\x14\n" +
+	"\x05force\x18\x02 \x01(\bR\x05force\":\n" +
+	"\x15PurgeProductsResponse\x12!\n" +
+	"\fpurged_count\x18\x01 \x01(\x03R\vpurgedCount\"\xfe\x01\n" +
+	"\x15BulkOperationMetadata\x12;\n" +
+	"\vcreate_time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"createTime\x12;\n" +
+	"\vupdate_time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"updateTime\x12\x1f\n" +
+	"\vtotal_items\x18\x03 \x01(\x03R\n" +
+	"totalItems\x12'\n" +
+	"\x0fprocessed_items\x18\x04 \x01(\x03R\x0eprocessedItems\x12!\n" +
+	"\ffailed_items\x18\x05 \x01(\x03R\vfailedItems\"\x8d\x01\n" +
+	"\x19GetSimilarProductsRequest\x12\x16\n" +
+	"\x02id\x18\x01 \x01(\tB\x06\xc2\xf3\x18\x02\b\x01R\x02id\x127\n" +
+	"\vmax_results\x18\x02 \x01(\x05B\x16\xc2\xf3\x18\x12!\x00\x00\x00\x00\x00\x00\x00\x00)\x00\x00\x00\x00\x00\x00Y@R\n" +
+	"maxResults\x12\x1f\n" +
+	"\x06filter\x18\x03 \x01(\tB\a\xc2\xf3\x18\x03\x18\xd0\x0fR\x06filter\"R\n" +
+	"\x1aGetSimilarProductsResponse\x124\n" +
+	"\aresults\x18\x01 \x03(\v2\x1a.product.v1.SimilarProductR\aresults\"\xb6\x01\n" +
+	"\x0eSimilarProduct\x12-\n" +
+	"\aproduct\x18\x01 \x01(\v2\x13.product.v1.ProductR\aproduct\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\x12\x1d\n" +
+	"\n" +
+	"text_score\x18\x03 \x01(\x01R\ttextScore\x12\x1f\n" +
+	"\vlabel_score\x18\x04 \x01(\x01R\n" +
+	"labelScore\x12\x1f\n" +
+	"\vprice_score\x18\x05 \x01(\x01R\n" +
+	"priceScore\"\xaa\x03\n" +
+	"\n" +
+	"AuditEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x14\n" +
+	"\x05actor\x18\x03 \x01(\tR\x05actor\x12\x16\n" +
+	"\x06method\x18\x04 \x01(\tR\x06method\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x05 \x01(\tR\tproductId\x125\n" +
+	"\x06action\x18\x06 \x01(\x0e2\x1d.product.v1.AuditEvent.ActionR\x06action\x12+\n" +
+	"\x06before\x18\a \x01(\v2\x13.product.v1.ProductR\x06before\x12)\n" +
+	"\x05after\x18\b \x01(\v2\x13.product.v1.ProductR\x05after\x12%\n" +
+	"\x0echanged_fields\x18\t \x03(\tR\rchangedFields\"Y\n" +
+	"\x06Action\x12\x16\n" +
+	"\x12ACTION_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rACTION_CREATE\x10\x01\x12\x11\n" +
+	"\rACTION_UPDATE\x10\x02\x12\x11\n" +
+	"\rACTION_DELETE\x10\x03\"\x93\x02\n" +
+	"\x16ListAuditEventsRequest\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x01 \x01(\tR\tproductId\x12\x14\n" +
+	"\x05actor\x18\x02 \x01(\tR\x05actor\x129\n" +
+	"\n" +
+	"start_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x123\n" +
+	"\tpage_size\x18\x05 \x01(\x05B\x16\xc2\xf3\x18\x12!\x00\x00\x00\x00\x00\x00\x00\x00)\x00\x00\x00\x00\x00@\x8f@R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x06 \x01(\tR\tpageToken\"q\n" +
+	"\x17ListAuditEventsResponse\x12.\n" +
+	"\x06events\x18\x01 \x03(\v2\x16.product.v1.AuditEventR\x06events\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"M\n" +
+	"\x14CreateProductRequest\x125\n" +
+	"\aproduct\x18\x01 \x01(\v2\x13.product.v1.ProductB\x06\xc2\xf3\x18\x02\b\x01R\aproduct\"M\n" +
+	"\x14UpdateProductRequest\x125\n" +
+	"\aproduct\x18\x01 \x01(\v2\x13.product.v1.ProductB\x06\xc2\xf3\x18\x02\b\x01R\aproduct\".\n" +
+	"\x14DeleteProductRequest\x12\x16\n" +
+	"\x02id\x18\x01 \x01(\tB\x06\xc2\xf3\x18\x02\b\x01R\x02id\"Y\n" +
+	"\x1aBatchUpdateProductsRequest\x12;\n" +
+	"\achanges\x18\x01 \x03(\v2\x19.product.v1.ProductChangeB\x06\xc2\xf3\x18\x02\b\x01R\achanges\"\xa3\x01\n" +
+	"\rProductChange\x12-\n" +
+	"\x06create\x18\x01 \x01(\v2\x13.product.v1.ProductH\x00R\x06create\x12-\n" +
+	"\x06update\x18\x02 \x01(\v2\x13.product.v1.ProductH\x00R\x06update\x12\x18\n" +
+	"\x06delete\x18\x03 \x01(\tH\x00R\x06delete\x12\x12\n" +
+	"\x04etag\x18\x04 \x01(\tR\x04etagB\x06\n" +
+	"\x04kind\"N\n" +
+	"\x1bBatchUpdateProductsResponse\x12/\n" +
+	"\bproducts\x18\x01 \x03(\v2\x13.product.v1.ProductR\bproducts\"h\n" +
+	"\x1dPutAttributeDefinitionRequest\x12G\n" +
+	"\n" +
+	"definition\x18\x01 \x01(\v2\x1f.product.v1.AttributeDefinitionB\x06\xc2\xf3\x18\x02\b\x01R\n" +
+	"definition\"!\n" +
+	"\x1fListAttributeDefinitionsRequest\"e\n" +
+	" ListAttributeDefinitionsResponse\x12A\n" +
+	"\vdefinitions\x18\x01 \x03(\v2\x1f.product.v1.AttributeDefinitionR\vdefinitions*\x97\x01\n" +
+	"\rAttributeType\x12\x1e\n" +
+	"\x1aATTRIBUTE_TYPE_UNSPECIFIED\x10\x00\x12\x19\n" +
+	"\x15ATTRIBUTE_TYPE_STRING\x10\x01\x12\x19\n" +
+	"\x15ATTRIBUTE_TYPE_NUMBER\x10\x02\x12\x17\n" +
+	"\x13ATTRIBUTE_TYPE_ENUM\x10\x03\x12\x17\n" +
+	"\x13ATTRIBUTE_TYPE_BOOL\x10\x042\xaf\v\n" +
+	"\x0eProductService\x12E\n" +
+	"\n" +
+	"GetProduct\x12\x1d.product.v1.GetProductRequest\x1a\x13.product.v1.Product\"\x03\x90\x02\x01\x12V\n" +
+	"\fListProducts\x12\x1f.product.v1.ListProductsRequest\x1a .product.v1.ListProductsResponse\"\x03\x90\x02\x01\x12F\n" +
+	"\rCreateProduct\x12 .product.v1.CreateProductRequest\x1a\x13.product.v1.Product\x12F\n" +
+	"\rUpdateProduct\x12 .product.v1.UpdateProductRequest\x1a\x13.product.v1.Product\x12I\n" +
+	"\rDeleteProduct\x12 .product.v1.DeleteProductRequest\x1a\x16.google.protobuf.Empty\x12f\n" +
+	"\x13BatchUpdateProducts\x12&.product.v1.BatchUpdateProductsRequest\x1a'.product.v1.BatchUpdateProductsResponse\x12i\n" +
+	"\x16PutAttributeDefinition\x12).product.v1.PutAttributeDefinitionRequest\x1a\x1f.product.v1.AttributeDefinition\"\x03\x90\x02\x02\x12z\n" +
+	"\x18ListAttributeDefinitions\x12+.product.v1.ListAttributeDefinitionsRequest\x1a,.product.v1.ListAttributeDefinitionsResponse\"\x03\x90\x02\x01\x12_\n" +
+	"\x0fGetCatalogStats\x12\".product.v1.GetCatalogStatsRequest\x1a#.product.v1.GetCatalogStatsResponse\"\x03\x90\x02\x01\x12h\n" +
+	"\x12GetSimilarProducts\x12%.product.v1.GetSimilarProductsRequest\x1a&.product.v1.GetSimilarProductsResponse\"\x03\x90\x02\x01\x12_\n" +
+	"\x0fListAuditEvents\x12\".product.v1.ListAuditEventsRequest\x1a#.product.v1.ListAuditEventsResponse\"\x03\x90\x02\x01\x12\x92\x01\n" +
+	"\x12BulkImportProducts\x12%.product.v1.BulkImportProductsRequest\x1a\x1d.google.longrunning.Operation\"6\xcaA3\n" +
+	"\x1aBulkImportProductsResponse\x12\x15BulkOperationMetadata\x12\x8c\x01\n" +
+	"\x10BulkUpdatePrices\x12#.product.v1.BulkUpdatePricesRequest\x1a\x1d.google.longrunning.Operation\"4\xcaA1\n" +
+	"\x18BulkUpdatePricesResponse\x12\x15BulkOperationMetadata\x12\x83\x01\n" +
+	"\rPurgeProducts\x12 .product.v1.PurgeProductsRequest\x1a\x1d.google.longrunning.Operation\"1\xcaA.\n" +
+	"\x15PurgeProductsResponse\x12\x15BulkOperationMetadataB/Z-grpc-go-fx/internal/generated/product;productb\x06proto3"
+
+var (
+	file_product_proto_rawDescOnce sync.Once
+	file_product_proto_rawDescData []byte
+)
+
+func file_product_proto_rawDescGZIP() []byte {
+	file_product_proto_rawDescOnce.Do(func() {
+		file_product_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_product_proto_rawDesc), len(file_product_proto_rawDesc)))
+	})
+	return file_product_proto_rawDescData
+}
+
+var file_product_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_product_proto_msgTypes = make([]protoimpl.MessageInfo, 41)
+var file_product_proto_goTypes = []any{
+	(AttributeType)(0),                       // 0: product.v1.AttributeType
+	(GetCatalogStatsRequest_GroupBy)(0),      // 1: product.v1.GetCatalogStatsRequest.GroupBy
+	(AuditEvent_Action)(0),                   // 2: product.v1.AuditEvent.Action
+	(*Product)(nil),                          // 3: product.v1.Product
+	(*AttributeValue)(nil),                   // 4: product.v1.AttributeValue
+	(*AttributeDefinition)(nil),              // 5: product.v1.AttributeDefinition
+	(*StringConstraints)(nil),                // 6: product.v1.StringConstraints
+	(*NumberConstraints)(nil),                // 7: product.v1.NumberConstraints
+	(*EnumConstraints)(nil),                  // 8: product.v1.EnumConstraints
+	(*GetProductRequest)(nil),                // 9: product.v1.GetProductRequest
+	(*ListProductsRequest)(nil),              // 10: product.v1.ListProductsRequest
+	(*FacetOptions)(nil),                     // 11: product.v1.FacetOptions
+	(*ListProductsResponse)(nil),             // 12: product.v1.ListProductsResponse
+	(*Facets)(nil),                           // 13: product.v1.Facets
+	(*TagCount)(nil),                         // 14: product.v1.TagCount
+	(*PriceBucket)(nil),                      // 15: product.v1.PriceBucket
+	(*GetCatalogStatsRequest)(nil),           // 16: product.v1.GetCatalogStatsRequest
+	(*GetCatalogStatsResponse)(nil),          // 17: product.v1.GetCatalogStatsResponse
+	(*StatsGroup)(nil),                       // 18: product.v1.StatsGroup
+	(*PriceStats)(nil),                       // 19: product.v1.PriceStats
+	(*BulkImportProductsRequest)(nil),        // 20: product.v1.BulkImportProductsRequest
+	(*BulkImportProductsResponse)(nil),       // 21: product.v1.BulkImportProductsResponse
+	(*BulkItemFailure)(nil),                  // 22: product.v1.BulkItemFailure
+	(*BulkUpdatePricesRequest)(nil),          // 23: product.v1.BulkUpdatePricesRequest
+	(*BulkUpdatePricesResponse)(nil),         // 24: product.v1.BulkUpdatePricesResponse
+	(*PurgeProductsRequest)(nil),             // 25: product.v1.PurgeProductsRequest
+	(*PurgeProductsResponse)(nil),            // 26: product.v1.PurgeProductsResponse
+	(*BulkOperationMetadata)(nil),            // 27: product.v1.BulkOperationMetadata
+	(*GetSimilarProductsRequest)(nil),        // 28: product.v1.GetSimilarProductsRequest
+	(*GetSimilarProductsResponse)(nil),       // 29: product.v1.GetSimilarProductsResponse
+	(*SimilarProduct)(nil),                   // 30: product.v1.SimilarProduct
+	(*AuditEvent)(nil),                       // 31: product.v1.AuditEvent
+	(*ListAuditEventsRequest)(nil),           // 32: product.v1.ListAuditEventsRequest
+	(*ListAuditEventsResponse)(nil),          // 33: product.v1.ListAuditEventsResponse
+	(*CreateProductRequest)(nil),             // 34: product.v1.CreateProductRequest
+	(*UpdateProductRequest)(nil),             // 35: product.v1.UpdateProductRequest
+	(*DeleteProductRequest)(nil),             // 36: product.v1.DeleteProductRequest
+	(*BatchUpdateProductsRequest)(nil),       // 37: product.v1.BatchUpdateProductsRequest
+	(*ProductChange)(nil),                    // 38: product.v1.ProductChange
+	(*BatchUpdateProductsResponse)(nil),      // 39: product.v1.BatchUpdateProductsResponse
+	(*PutAttributeDefinitionRequest)(nil),    // 40: product.v1.PutAttributeDefinitionRequest
+	(*ListAttributeDefinitionsRequest)(nil),  // 41: product.v1.ListAttributeDefinitionsRequest
+	(*ListAttributeDefinitionsResponse)(nil), // 42: product.v1.ListAttributeDefinitionsResponse
+	nil,                                      // 43: product.v1.Product.AttributesEntry
+	(*fieldmaskpb.FieldMask)(nil),            // 44: google.protobuf.FieldMask
+	(*timestamppb.Timestamp)(nil),            // 45: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),                    // 46: google.protobuf.Empty
+	(*longrunningpb.Operation)(nil),          // 47: google.longrunning.Operation
+}
+var file_product_proto_depIdxs = []int32{
+	43, // 0: product.v1.Product.attributes:type_name -> product.v1.Product.AttributesEntry
+	0,  // 1: product.v1.AttributeDefinition.type:type_name -> product.v1.AttributeType
+	6,  // 2: product.v1.AttributeDefinition.string_constraints:type_name -> product.v1.StringConstraints
+	7,  // 3: product.v1.AttributeDefinition.number_constraints:type_name -> product.v1.NumberConstraints
+	8,  // 4: product.v1.AttributeDefinition.enum_constraints:type_name -> product.v1.EnumConstraints
+	44, // 5: product.v1.GetProductRequest.read_mask:type_name -> google.protobuf.FieldMask
+	11, // 6: product.v1.ListProductsRequest.facets:type_name -> product.v1.FacetOptions
+	44, // 7: product.v1.ListProductsRequest.read_mask:type_name -> google.protobuf.FieldMask
+	3,  // 8: product.v1.ListProductsResponse.products:type_name -> product.v1.Product
+	13, // 9: product.v1.ListProductsResponse.facets:type_name -> product.v1.Facets
+	14, // 10: product.v1.Facets.tags:type_name -> product.v1.TagCount
+	15, // 11: product.v1.Facets.price_buckets:type_name -> product.v1.PriceBucket
+	1,  // 12: product.v1.GetCatalogStatsRequest.group_by:type_name -> product.v1.GetCatalogStatsRequest.GroupBy
+	19, // 13: product.v1.GetCatalogStatsResponse.overall:type_name -> product.v1.PriceStats
+	18, // 14: product.v1.GetCatalogStatsResponse.groups:type_name -> product.v1.StatsGroup
+	19, // 15: product.v1.StatsGroup.stats:type_name -> product.v1.PriceStats
+	15, // 16: product.v1.PriceStats.histogram:type_name -> product.v1.PriceBucket
+	3,  // 17: product.v1.BulkImportProductsRequest.products:type_name -> product.v1.Product
+	22, // 18: product.v1.BulkImportProductsResponse.failures:type_name -> product.v1.BulkItemFailure
+	45, // 19: product.v1.BulkOperationMetadata.create_time:type_name -> google.protobuf.Timestamp
+	45, // 20: product.v1.BulkOperationMetadata.update_time:type_name -> google.protobuf.Timestamp
+	30, // 21: product.v1.GetSimilarProductsResponse.results:type_name -> product.v1.SimilarProduct
+	3,  // 22: product.v1.SimilarProduct.product:type_name -> product.v1.Product
+	45, // 23: product.v1.AuditEvent.time:type_name -> google.protobuf.Timestamp
+	2,  // 24: product.v1.AuditEvent.action:type_name -> product.v1.AuditEvent.Action
+	3,  // 25: product.v1.AuditEvent.before:type_name -> product.v1.Product
+	3,  // 26: product.v1.AuditEvent.after:type_name -> product.v1.Product
+	45, // 27: product.v1.ListAuditEventsRequest.start_time:type_name -> google.protobuf.Timestamp
+	45, // 28: product.v1.ListAuditEventsRequest.end_time:type_name -> google.protobuf.Timestamp
+	31, // 29: product.v1.ListAuditEventsResponse.events:type_name -> product.v1.AuditEvent
+	3,  // 30: product.v1.CreateProductRequest.product:type_name -> product.v1.Product
+	3,  // 31: product.v1.UpdateProductRequest.product:type_name -> product.v1.Product
+	38, // 32: product.v1.BatchUpdateProductsRequest.changes:type_name -> product.v1.ProductChange
+	3,  // 33: product.v1.ProductChange.create:type_name -> product.v1.Product
+	3,  // 34: product.v1.ProductChange.update:type_name -> product.v1.Product
+	3,  // 35: product.v1.BatchUpdateProductsResponse.products:type_name -> product.v1.Product
+	5,  // 36: product.v1.PutAttributeDefinitionRequest.definition:type_name -> product.v1.AttributeDefinition
+	5,  // 37: product.v1.ListAttributeDefinitionsResponse.definitions:type_name -> product.v1.AttributeDefinition
+	4,  // 38: product.v1.Product.AttributesEntry.value:type_name -> product.v1.AttributeValue
+	9,  // 39: product.v1.ProductService.GetProduct:input_type -> product.v1.GetProductRequest
+	10, // 40: product.v1.ProductService.ListProducts:input_type -> product.v1.ListProductsRequest
+	34, // 41: product.v1.ProductService.CreateProduct:input_type -> product.v1.CreateProductRequest
+	35, // 42: product.v1.ProductService.UpdateProduct:input_type -> product.v1.UpdateProductRequest
+	36, // 43: product.v1.ProductService.DeleteProduct:input_type -> product.v1.DeleteProductRequest
+	37, // 44: product.v1.ProductService.BatchUpdateProducts:input_type -> product.v1.BatchUpdateProductsRequest
+	40, // 45: product.v1.ProductService.PutAttributeDefinition:input_type -> product.v1.PutAttributeDefinitionRequest
+	41, // 46: product.v1.ProductService.ListAttributeDefinitions:input_type -> product.v1.ListAttributeDefinitionsRequest
+	16, // 47: product.v1.ProductService.GetCatalogStats:input_type -> product.v1.GetCatalogStatsRequest
+	28, // 48: product.v1.ProductService.GetSimilarProducts:input_type -> product.v1.GetSimilarProductsRequest
+	32, // 49: product.v1.ProductService.ListAuditEvents:input_type -> product.v1.ListAuditEventsRequest
+	20, // 50: product.v1.ProductService.BulkImportProducts:input_type -> product.v1.BulkImportProductsRequest
+	23, // 51: product.v1.ProductService.BulkUpdatePrices:input_type -> product.v1.BulkUpdatePricesRequest
+	25, // 52: product.v1.ProductService.PurgeProducts:input_type -> product.v1.PurgeProductsRequest
+	3,  // 53: product.v1.ProductService.GetProduct:output_type -> product.v1.Product
+	12, // 54: product.v1.ProductService.ListProducts:output_type -> product.v1.ListProductsResponse
+	3,  // 55: product.v1.ProductService.CreateProduct:output_type -> product.v1.Product
+	3,  // 56: product.v1.ProductService.UpdateProduct:output_type -> product.v1.Product
+	46, // 57: product.v1.ProductService.DeleteProduct:output_type -> google.protobuf.Empty
+	39, // 58: product.v1.ProductService.BatchUpdateProducts:output_type -> product.v1.BatchUpdateProductsResponse
+	5,  // 59: product.v1.ProductService.PutAttributeDefinition:output_type -> product.v1.AttributeDefinition
+	42, // 60: product.v1.ProductService.ListAttributeDefinitions:output_type -> product.v1.ListAttributeDefinitionsResponse
+	17, // 61: product.v1.ProductService.GetCatalogStats:output_type -> product.v1.GetCatalogStatsResponse
+	29, // 62: product.v1.ProductService.GetSimilarProducts:output_type -> product.v1.GetSimilarProductsResponse
+	33, // 63: product.v1.ProductService.ListAuditEvents:output_type -> product.v1.ListAuditEventsResponse
+	47, // 64: product.v1.ProductService.BulkImportProducts:output_type -> google.longrunning.Operation
+	47, // 65: product.v1.ProductService.BulkUpdatePrices:output_type -> google.longrunning.Operation
+	47, // 66: product.v1.ProductService.PurgeProducts:output_type -> google.longrunning.Operation
+	53, // [53:67] is the sub-list for method output_type
+	39, // [39:53] is the sub-list for method input_type
+	39, // [39:39] is the sub-list for extension type_name
+	39, // [39:39] is the sub-list for extension extendee
+	0,  // [0:39] is the sub-list for field type_name
+}
+
+func init() { file_product_proto_init() }
+func file_product_proto_init() {
+	if File_product_proto != nil {
+		return
+	}
+	file_validate_proto_init()
+	file_product_proto_msgTypes[1].OneofWrappers = []any{
+		(*AttributeValue_StringValue)(nil),
+		(*AttributeValue_NumberValue)(nil),
+		(*AttributeValue_BoolValue)(nil),
+		(*AttributeValue_EnumValue)(nil),
+	}
+	file_product_proto_msgTypes[2].OneofWrappers = []any{
+		(*AttributeDefinition_StringConstraints)(nil),
+		(*AttributeDefinition_NumberConstraints)(nil),
+		(*AttributeDefinition_EnumConstraints)(nil),
+	}
+	file_product_proto_msgTypes[4].OneofWrappers = []any{}
+	file_product_proto_msgTypes[12].OneofWrappers = []any{}
+	file_product_proto_msgTypes[20].OneofWrappers = []any{
+		(*BulkUpdatePricesRequest_Multiplier)(nil),
+		(*BulkUpdatePricesRequest_Delta)(nil),
+	}
+	file_product_proto_msgTypes[35].OneofWrappers = []any{
+		(*ProductChange_Create)(nil),
+		(*ProductChange_Update)(nil),
+		(*ProductChange_Delete)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_product_proto_rawDesc), len(file_product_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      3,
+			NumMessages:   41,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_product_proto_goTypes,
 		DependencyIndexes: file_product_proto_depIdxs,
+		EnumInfos:         file_product_proto_enumTypes,
 		MessageInfos:      file_product_proto_msgTypes,
 	}.Build()
 	File_product_proto = out.File
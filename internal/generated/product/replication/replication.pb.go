@@ -0,0 +1,693 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v6.33.4
+// source: replication/replication.proto
+
+package replicationpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	product "grpc-go-fx/internal/generated/product"
+	storage "grpc-go-fx/internal/generated/product/storage"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ReplicationStatus_Role int32
+
+const (
+	ReplicationStatus_ROLE_UNSPECIFIED ReplicationStatus_Role = 0
+	ReplicationStatus_ROLE_STANDALONE  ReplicationStatus_Role = 1
+	ReplicationStatus_ROLE_LEADER      ReplicationStatus_Role = 2
+	ReplicationStatus_ROLE_FOLLOWER    ReplicationStatus_Role = 3
+)
+
+// Enum value maps for ReplicationStatus_Role.
+var (
+	ReplicationStatus_Role_name = map[int32]string{
+		0: "ROLE_UNSPECIFIED",
+		1: "ROLE_STANDALONE",
+		2: "ROLE_LEADER",
+		3: "ROLE_FOLLOWER",
+	}
+	ReplicationStatus_Role_value = map[string]int32{
+		"ROLE_UNSPECIFIED": 0,
+		"ROLE_STANDALONE":  1,
+		"ROLE_LEADER":      2,
+		"ROLE_FOLLOWER":    3,
+	}
+)
+
+func (x ReplicationStatus_Role) Enum() *ReplicationStatus_Role {
+	p := new(ReplicationStatus_Role)
+	*p = x
+	return p
+}
+
+func (x ReplicationStatus_Role) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ReplicationStatus_Role) Descriptor() protoreflect.EnumDescriptor {
+	return file_replication_replication_proto_enumTypes[0].Descriptor()
+}
+
+func (ReplicationStatus_Role) Type() protoreflect.EnumType {
+	return &file_replication_replication_proto_enumTypes[0]
+}
+
+func (x ReplicationStatus_Role) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ReplicationStatus_Role.Descriptor instead.
+func (ReplicationStatus_Role) EnumDescriptor() ([]byte, []int) {
+	return file_replication_replication_proto_rawDescGZIP(), []int{4, 0}
+}
+
+type StreamChangesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// log_id and after_sequence are the position of the last write the
+	// follower applied, or empty and 0 for a follower that has none.
+	LogId         string `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	AfterSequence uint64 `protobuf:"varint,2,opt,name=after_sequence,json=afterSequence,proto3" json:"after_sequence,omitempty"`
+	// follower names the follower in the leader's status, e.g. its address.
+	Follower      string `protobuf:"bytes,3,opt,name=follower,proto3" json:"follower,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamChangesRequest) Reset() {
+	*x = StreamChangesRequest{}
+	mi := &file_replication_replication_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamChangesRequest) ProtoMessage() {}
+
+func (x *StreamChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_replication_replication_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamChangesRequest.ProtoReflect.Descriptor instead.
+func (*StreamChangesRequest) Descriptor() ([]byte, []int) {
+	return file_replication_replication_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StreamChangesRequest) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+func (x *StreamChangesRequest) GetAfterSequence() uint64 {
+	if x != nil {
+		return x.AfterSequence
+	}
+	return 0
+}
+
+func (x *StreamChangesRequest) GetFollower() string {
+	if x != nil {
+		return x.Follower
+	}
+	return ""
+}
+
+// ReplicationMessage carries a write, a part of a snapshot or, with neither,
+// a heartbeat sent while the leader is idle.
+type ReplicationMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// log_id identifies the leader's mutation log; it changes when the leader
+	// restarts, and sequence numbers restart with it.
+	LogId string `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	// leader_sequence is the sequence of the leader's latest write when the
+	// message was sent.
+	LeaderSequence uint64 `protobuf:"varint,2,opt,name=leader_sequence,json=leaderSequence,proto3" json:"leader_sequence,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*ReplicationMessage_Record
+	//	*ReplicationMessage_Snapshot
+	Payload       isReplicationMessage_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReplicationMessage) Reset() {
+	*x = ReplicationMessage{}
+	mi := &file_replication_replication_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplicationMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicationMessage) ProtoMessage() {}
+
+func (x *ReplicationMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_replication_replication_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicationMessage.ProtoReflect.Descriptor instead.
+func (*ReplicationMessage) Descriptor() ([]byte, []int) {
+	return file_replication_replication_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ReplicationMessage) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+func (x *ReplicationMessage) GetLeaderSequence() uint64 {
+	if x != nil {
+		return x.LeaderSequence
+	}
+	return 0
+}
+
+func (x *ReplicationMessage) GetPayload() isReplicationMessage_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *ReplicationMessage) GetRecord() *storage.WALRecord {
+	if x != nil {
+		if x, ok := x.Payload.(*ReplicationMessage_Record); ok {
+			return x.Record
+		}
+	}
+	return nil
+}
+
+func (x *ReplicationMessage) GetSnapshot() *SnapshotChunk {
+	if x != nil {
+		if x, ok := x.Payload.(*ReplicationMessage_Snapshot); ok {
+			return x.Snapshot
+		}
+	}
+	return nil
+}
+
+type isReplicationMessage_Payload interface {
+	isReplicationMessage_Payload()
+}
+
+type ReplicationMessage_Record struct {
+	Record *storage.WALRecord `protobuf:"bytes,3,opt,name=record,proto3,oneof"`
+}
+
+type ReplicationMessage_Snapshot struct {
+	Snapshot *SnapshotChunk `protobuf:"bytes,4,opt,name=snapshot,proto3,oneof"`
+}
+
+func (*ReplicationMessage_Record) isReplicationMessage_Payload() {}
+
+func (*ReplicationMessage_Snapshot) isReplicationMessage_Payload() {}
+
+// SnapshotChunk is part of the catalog as of the write with the given
+// sequence. The products of all chunks up to the one marked last replace the
+// follower's catalog; records after sequence follow. The first chunk also
+// carries every custom attribute definition.
+type SnapshotChunk struct {
+	state                protoimpl.MessageState         `protogen:"open.v1"`
+	Sequence             uint64                         `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Products             []*product.Product             `protobuf:"bytes,2,rep,name=products,proto3" json:"products,omitempty"`
+	Last                 bool                           `protobuf:"varint,3,opt,name=last,proto3" json:"last,omitempty"`
+	AttributeDefinitions []*product.AttributeDefinition `protobuf:"bytes,4,rep,name=attribute_definitions,json=attributeDefinitions,proto3" json:"attribute_definitions,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *SnapshotChunk) Reset() {
+	*x = SnapshotChunk{}
+	mi := &file_replication_replication_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotChunk) ProtoMessage() {}
+
+func (x *SnapshotChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_replication_replication_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotChunk.ProtoReflect.Descriptor instead.
+func (*SnapshotChunk) Descriptor() ([]byte, []int) {
+	return file_replication_replication_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SnapshotChunk) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *SnapshotChunk) GetProducts() []*product.Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+func (x *SnapshotChunk) GetLast() bool {
+	if x != nil {
+		return x.Last
+	}
+	return false
+}
+
+func (x *SnapshotChunk) GetAttributeDefinitions() []*product.AttributeDefinition {
+	if x != nil {
+		return x.AttributeDefinitions
+	}
+	return nil
+}
+
+type GetReplicationStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetReplicationStatusRequest) Reset() {
+	*x = GetReplicationStatusRequest{}
+	mi := &file_replication_replication_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetReplicationStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetReplicationStatusRequest) ProtoMessage() {}
+
+func (x *GetReplicationStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_replication_replication_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetReplicationStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetReplicationStatusRequest) Descriptor() ([]byte, []int) {
+	return file_replication_replication_proto_rawDescGZIP(), []int{3}
+}
+
+type ReplicationStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Role  ReplicationStatus_Role `protobuf:"varint,1,opt,name=role,proto3,enum=product.replication.v1.ReplicationStatus_Role" json:"role,omitempty"`
+	// log_id is the leader's mutation log: the leader's own, or for a follower
+	// the one it applies.
+	LogId string `protobuf:"bytes,2,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	// sequence is the leader's latest write, or the latest write a follower
+	// applied.
+	Sequence uint64 `protobuf:"varint,3,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Follower only.
+	LeaderAddress string `protobuf:"bytes,4,opt,name=leader_address,json=leaderAddress,proto3" json:"leader_address,omitempty"`
+	// connected is set while the follower is streaming from the leader.
+	Connected bool `protobuf:"varint,5,opt,name=connected,proto3" json:"connected,omitempty"`
+	// leader_sequence is the leader's latest write as last heard from it, and
+	// lag the number of writes the follower has yet to apply.
+	LeaderSequence  uint64                 `protobuf:"varint,6,opt,name=leader_sequence,json=leaderSequence,proto3" json:"leader_sequence,omitempty"`
+	Lag             uint64                 `protobuf:"varint,7,opt,name=lag,proto3" json:"lag,omitempty"`
+	LastContactTime *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=last_contact_time,json=lastContactTime,proto3" json:"last_contact_time,omitempty"`
+	// snapshots_loaded counts the times the follower replaced its catalog with
+	// a snapshot of the leader's.
+	SnapshotsLoaded int64 `protobuf:"varint,9,opt,name=snapshots_loaded,json=snapshotsLoaded,proto3" json:"snapshots_loaded,omitempty"`
+	// last_error is why the follower last lost its stream from the leader.
+	LastError string `protobuf:"bytes,11,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	// Leader only: the followers streaming from it.
+	Followers     []*FollowerStatus `protobuf:"bytes,10,rep,name=followers,proto3" json:"followers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReplicationStatus) Reset() {
+	*x = ReplicationStatus{}
+	mi := &file_replication_replication_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplicationStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicationStatus) ProtoMessage() {}
+
+func (x *ReplicationStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_replication_replication_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicationStatus.ProtoReflect.Descriptor instead.
+func (*ReplicationStatus) Descriptor() ([]byte, []int) {
+	return file_replication_replication_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ReplicationStatus) GetRole() ReplicationStatus_Role {
+	if x != nil {
+		return x.Role
+	}
+	return ReplicationStatus_ROLE_UNSPECIFIED
+}
+
+func (x *ReplicationStatus) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+func (x *ReplicationStatus) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *ReplicationStatus) GetLeaderAddress() string {
+	if x != nil {
+		return x.LeaderAddress
+	}
+	return ""
+}
+
+func (x *ReplicationStatus) GetConnected() bool {
+	if x != nil {
+		return x.Connected
+	}
+	return false
+}
+
+func (x *ReplicationStatus) GetLeaderSequence() uint64 {
+	if x != nil {
+		return x.LeaderSequence
+	}
+	return 0
+}
+
+func (x *ReplicationStatus) GetLag() uint64 {
+	if x != nil {
+		return x.Lag
+	}
+	return 0
+}
+
+func (x *ReplicationStatus) GetLastContactTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastContactTime
+	}
+	return nil
+}
+
+func (x *ReplicationStatus) GetSnapshotsLoaded() int64 {
+	if x != nil {
+		return x.SnapshotsLoaded
+	}
+	return 0
+}
+
+func (x *ReplicationStatus) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *ReplicationStatus) GetFollowers() []*FollowerStatus {
+	if x != nil {
+		return x.Followers
+	}
+	return nil
+}
+
+type FollowerStatus struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Follower string                 `protobuf:"bytes,1,opt,name=follower,proto3" json:"follower,omitempty"`
+	// peer is the follower's network address.
+	Peer string `protobuf:"bytes,2,opt,name=peer,proto3" json:"peer,omitempty"`
+	// sequence is the latest write sent to the follower, and lag the number
+	// of writes not yet sent.
+	Sequence      uint64                 `protobuf:"varint,3,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Lag           uint64                 `protobuf:"varint,4,opt,name=lag,proto3" json:"lag,omitempty"`
+	ConnectTime   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=connect_time,json=connectTime,proto3" json:"connect_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FollowerStatus) Reset() {
+	*x = FollowerStatus{}
+	mi := &file_replication_replication_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FollowerStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FollowerStatus) ProtoMessage() {}
+
+func (x *FollowerStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_replication_replication_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FollowerStatus.ProtoReflect.Descriptor instead.
+func (*FollowerStatus) Descriptor() ([]byte, []int) {
+	return file_replication_replication_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *FollowerStatus) GetFollower() string {
+	if x != nil {
+		return x.Follower
+	}
+	return ""
+}
+
+func (x *FollowerStatus) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *FollowerStatus) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *FollowerStatus) GetLag() uint64 {
+	if x != nil {
+		return x.Lag
+	}
+	return 0
+}
+
+func (x *FollowerStatus) GetConnectTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ConnectTime
+	}
+	return nil
+}
+
+var File_replication_replication_proto protoreflect.FileDescriptor
+
+const file_replication_replication_proto_rawDesc = "" +
+	"\n" +
+	"\x1dreplication/replication.proto\x12\x16product.replication.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\rproduct.proto\x1a\x15storage/storage.proto\"p\n" +
+	"\x14StreamChangesRequest\x12\x15\n" +
+	"\x06log_id\x18\x01 \x01(\tR\x05logId\x12%\n" +
+	"\x0eafter_sequence\x18\x02 \x01(\x04R\rafterSequence\x12\x1a\n" +
+	"\bfollower\x18\x03 \x01(\tR\bfollower\"\xdd\x01\n" +
+	"\x12ReplicationMessage\x12\x15\n" +
+	"\x06log_id\x18\x01 \x01(\tR\x05logId\x12'\n" +
+	"\x0fleader_sequence\x18\x02 \x01(\x04R\x0eleaderSequence\x127\n" +
+	"\x06record\x18\x03 \x01(\v2\x1d.product.storage.v1.WALRecordH\x00R\x06record\x12C\n" +
+	"\bsnapshot\x18\x04 \x01(\v2%.product.replication.v1.SnapshotChunkH\x00R\bsnapshotB\t\n" +
+	"\apayload\"\xc6\x01\n" +
+	"\rSnapshotChunk\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12/\n" +
+	"\bproducts\x18\x02 \x03(\v2\x13.product.v1.ProductR\bproducts\x12\x12\n" +
+	"\x04last\x18\x03 \x01(\bR\x04last\x12T\n" +
+	"\x15attribute_definitions\x18\x04 \x03(\v2\x1f.product.v1.AttributeDefinitionR\x14attributeDefinitions\"\x1d\n" +
+	"\x1bGetReplicationStatusRequest\"\xb9\x04\n" +
+	"\x11ReplicationStatus\x12B\n" +
+	"\x04role\x18\x01 \x01(\x0e2..product.replication.v1.ReplicationStatus.RoleR\x04role\x12\x15\n" +
+	"\x06log_id\x18\x02 \x01(\tR\x05logId\x12\x1a\n" +
+	"\bsequence\x18\x03 \x01(\x04R\bsequence\x12%\n" +
+	"\x0eleader_address\x18\x04 \x01(\tR\rleaderAddress\x12\x1c\n" +
+	"\tconnected\x18\x05 \x01(\bR\tconnected\x12'\n" +
+	"\x0fleader_sequence\x18\x06 \x01(\x04R\x0eleaderSequence\x12\x10\n" +
+	"\x03lag\x18\a \x01(\x04R\x03lag\x12F\n" +
+	"\x11last_contact_time\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x0flastContactTime\x12)\n" +
+	"\x10snapshots_loaded\x18\t \x01(\x03R\x0fsnapshotsLoaded\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\v \x01(\tR\tlastError\x12D\n" +
+	"\tfollowers\x18\n" +
+	" \x03(\v2&.product.replication.v1.FollowerStatusR\tfollowers\"U\n" +
+	"\x04Role\x12\x14\n" +
+	"\x10ROLE_UNSPECIFIED\x10\x00\x12\x13\n" +
+	"\x0fROLE_STANDALONE\x10\x01\x12\x0f\n" +
+	"\vROLE_LEADER\x10\x02\x12\x11\n" +
+	"\rROLE_FOLLOWER\x10\x03\"\xad\x01\n" +
+	"\x0eFollowerStatus\x12\x1a\n" +
+	"\bfollower\x18\x01 \x01(\tR\bfollower\x12\x12\n" +
+	"\x04peer\x18\x02 \x01(\tR\x04peer\x12\x1a\n" +
+	"\bsequence\x18\x03 \x01(\x04R\bsequence\x12\x10\n" +
+	"\x03lag\x18\x04 \x01(\x04R\x03lag\x12=\n" +
+	"\fconnect_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vconnectTime2\xfe\x01\n" +
+	"\x12ReplicationService\x12k\n" +
+	"\rStreamChanges\x12,.product.replication.v1.StreamChangesRequest\x1a*.product.replication.v1.ReplicationMessage0\x01\x12{\n" +
+	"\x14GetReplicationStatus\x123.product.replication.v1.GetReplicationStatusRequest\x1a).product.replication.v1.ReplicationStatus\"\x03\x90\x02\x01BAZ?grpc-go-fx/internal/generated/product/replication;replicationpbb\x06proto3"
+
+var (
+	file_replication_replication_proto_rawDescOnce sync.Once
+	file_replication_replication_proto_rawDescData []byte
+)
+
+func file_replication_replication_proto_rawDescGZIP() []byte {
+	file_replication_replication_proto_rawDescOnce.Do(func() {
+		file_replication_replication_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_replication_replication_proto_rawDesc), len(file_replication_replication_proto_rawDesc)))
+	})
+	return file_replication_replication_proto_rawDescData
+}
+
+var file_replication_replication_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_replication_replication_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_replication_replication_proto_goTypes = []any{
+	(ReplicationStatus_Role)(0),         // 0: product.replication.v1.ReplicationStatus.Role
+	(*StreamChangesRequest)(nil),        // 1: product.replication.v1.StreamChangesRequest
+	(*ReplicationMessage)(nil),          // 2: product.replication.v1.ReplicationMessage
+	(*SnapshotChunk)(nil),               // 3: product.replication.v1.SnapshotChunk
+	(*GetReplicationStatusRequest)(nil), // 4: product.replication.v1.GetReplicationStatusRequest
+	(*ReplicationStatus)(nil),           // 5: product.replication.v1.ReplicationStatus
+	(*FollowerStatus)(nil),              // 6: product.replication.v1.FollowerStatus
+	(*storage.WALRecord)(nil),           // 7: product.storage.v1.WALRecord
+	(*product.Product)(nil),             // 8: product.v1.Product
+	(*product.AttributeDefinition)(nil), // 9: product.v1.AttributeDefinition
+	(*timestamppb.Timestamp)(nil),       // 10: google.protobuf.Timestamp
+}
+var file_replication_replication_proto_depIdxs = []int32{
+	7,  // 0: product.replication.v1.ReplicationMessage.record:type_name -> product.storage.v1.WALRecord
+	3,  // 1: product.replication.v1.ReplicationMessage.snapshot:type_name -> product.replication.v1.SnapshotChunk
+	8,  // 2: product.replication.v1.SnapshotChunk.products:type_name -> product.v1.Product
+	9,  // 3: product.replication.v1.SnapshotChunk.attribute_definitions:type_name -> product.v1.AttributeDefinition
+	0,  // 4: product.replication.v1.ReplicationStatus.role:type_name -> product.replication.v1.ReplicationStatus.Role
+	10, // 5: product.replication.v1.ReplicationStatus.last_contact_time:type_name -> google.protobuf.Timestamp
+	6,  // 6: product.replication.v1.ReplicationStatus.followers:type_name -> product.replication.v1.FollowerStatus
+	10, // 7: product.replication.v1.FollowerStatus.connect_time:type_name -> google.protobuf.Timestamp
+	1,  // 8: product.replication.v1.ReplicationService.StreamChanges:input_type -> product.replication.v1.StreamChangesRequest
+	4,  // 9: product.replication.v1.ReplicationService.GetReplicationStatus:input_type -> product.replication.v1.GetReplicationStatusRequest
+	2,  // 10: product.replication.v1.ReplicationService.StreamChanges:output_type -> product.replication.v1.ReplicationMessage
+	5,  // 11: product.replication.v1.ReplicationService.GetReplicationStatus:output_type -> product.replication.v1.ReplicationStatus
+	10, // [10:12] is the sub-list for method output_type
+	8,  // [8:10] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_replication_replication_proto_init() }
+func file_replication_replication_proto_init() {
+	if File_replication_replication_proto != nil {
+		return
+	}
+	file_replication_replication_proto_msgTypes[1].OneofWrappers = []any{
+		(*ReplicationMessage_Record)(nil),
+		(*ReplicationMessage_Snapshot)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_replication_replication_proto_rawDesc), len(file_replication_replication_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_replication_replication_proto_goTypes,
+		DependencyIndexes: file_replication_replication_proto_depIdxs,
+		EnumInfos:         file_replication_replication_proto_enumTypes,
+		MessageInfos:      file_replication_replication_proto_msgTypes,
+	}.Build()
+	File_replication_replication_proto = out.File
+	file_replication_replication_proto_goTypes = nil
+	file_replication_replication_proto_depIdxs = nil
+}
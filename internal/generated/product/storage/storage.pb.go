@@ -0,0 +1,1389 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v6.33.4
+// source: storage/storage.proto
+
+package storagepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	product "grpc-go-fx/internal/generated/product"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// WALRecord is one entry of the write-ahead log: the writes of a single
+// repository call or Batch, applied together on recovery.
+type WALRecord struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sequence numbers start at 1 and increase by one per record, continuing
+	// across compactions.
+	Sequence      uint64      `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Mutations     []*Mutation `protobuf:"bytes,2,rep,name=mutations,proto3" json:"mutations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WALRecord) Reset() {
+	*x = WALRecord{}
+	mi := &file_storage_storage_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WALRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WALRecord) ProtoMessage() {}
+
+func (x *WALRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WALRecord.ProtoReflect.Descriptor instead.
+func (*WALRecord) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *WALRecord) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *WALRecord) GetMutations() []*Mutation {
+	if x != nil {
+		return x.Mutations
+	}
+	return nil
+}
+
+// Mutation creates, replaces or deletes one product, or creates or replaces
+// one custom attribute definition.
+type Mutation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Op:
+	//
+	//	*Mutation_Put
+	//	*Mutation_Delete
+	//	*Mutation_PutAttributeDefinition
+	Op            isMutation_Op `protobuf_oneof:"op"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Mutation) Reset() {
+	*x = Mutation{}
+	mi := &file_storage_storage_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Mutation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Mutation) ProtoMessage() {}
+
+func (x *Mutation) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Mutation.ProtoReflect.Descriptor instead.
+func (*Mutation) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Mutation) GetOp() isMutation_Op {
+	if x != nil {
+		return x.Op
+	}
+	return nil
+}
+
+func (x *Mutation) GetPut() *product.Product {
+	if x != nil {
+		if x, ok := x.Op.(*Mutation_Put); ok {
+			return x.Put
+		}
+	}
+	return nil
+}
+
+func (x *Mutation) GetDelete() string {
+	if x != nil {
+		if x, ok := x.Op.(*Mutation_Delete); ok {
+			return x.Delete
+		}
+	}
+	return ""
+}
+
+func (x *Mutation) GetPutAttributeDefinition() *product.AttributeDefinition {
+	if x != nil {
+		if x, ok := x.Op.(*Mutation_PutAttributeDefinition); ok {
+			return x.PutAttributeDefinition
+		}
+	}
+	return nil
+}
+
+type isMutation_Op interface {
+	isMutation_Op()
+}
+
+type Mutation_Put struct {
+	Put *product.Product `protobuf:"bytes,1,opt,name=put,proto3,oneof"`
+}
+
+type Mutation_Delete struct {
+	// delete is the ID of the product to delete.
+	Delete string `protobuf:"bytes,2,opt,name=delete,proto3,oneof"`
+}
+
+type Mutation_PutAttributeDefinition struct {
+	PutAttributeDefinition *product.AttributeDefinition `protobuf:"bytes,3,opt,name=put_attribute_definition,json=putAttributeDefinition,proto3,oneof"`
+}
+
+func (*Mutation_Put) isMutation_Op() {}
+
+func (*Mutation_Delete) isMutation_Op() {}
+
+func (*Mutation_PutAttributeDefinition) isMutation_Op() {}
+
+// Snapshot is the whole catalog as of the WAL record with the given sequence
+// number. Recovery loads it and replays only later records.
+type Snapshot struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Sequence uint64                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Products []*product.Product     `protobuf:"bytes,2,rep,name=products,proto3" json:"products,omitempty"`
+	// attribute_definitions are in name order.
+	AttributeDefinitions []*product.AttributeDefinition `protobuf:"bytes,3,rep,name=attribute_definitions,json=attributeDefinitions,proto3" json:"attribute_definitions,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	mi := &file_storage_storage_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Snapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snapshot) ProtoMessage() {}
+
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Snapshot) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Snapshot) GetProducts() []*product.Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+func (x *Snapshot) GetAttributeDefinitions() []*product.AttributeDefinition {
+	if x != nil {
+		return x.AttributeDefinitions
+	}
+	return nil
+}
+
+// CatalogBackup is the catalog.pb file of a backup: every product, in ID
+// order.
+type CatalogBackup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Products      []*product.Product     `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CatalogBackup) Reset() {
+	*x = CatalogBackup{}
+	mi := &file_storage_storage_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CatalogBackup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CatalogBackup) ProtoMessage() {}
+
+func (x *CatalogBackup) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CatalogBackup.ProtoReflect.Descriptor instead.
+func (*CatalogBackup) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CatalogBackup) GetProducts() []*product.Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+// EventBatch is one entry of the event log of the event-sourced product
+// store (internal/eventsource): the events of a single repository call or
+// Batch, stored together, or the custom attribute definitions put by one
+// call. Definitions are not events of a product; replaying the log puts them
+// in order.
+type EventBatch struct {
+	state                protoimpl.MessageState         `protogen:"open.v1"`
+	Events               []*ProductEvent                `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	AttributeDefinitions []*product.AttributeDefinition `protobuf:"bytes,2,rep,name=attribute_definitions,json=attributeDefinitions,proto3" json:"attribute_definitions,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *EventBatch) Reset() {
+	*x = EventBatch{}
+	mi := &file_storage_storage_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventBatch) ProtoMessage() {}
+
+func (x *EventBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventBatch.ProtoReflect.Descriptor instead.
+func (*EventBatch) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EventBatch) GetEvents() []*ProductEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *EventBatch) GetAttributeDefinitions() []*product.AttributeDefinition {
+	if x != nil {
+		return x.AttributeDefinitions
+	}
+	return nil
+}
+
+// ProductEvent is a change to one product, the aggregate the product store's
+// events belong to. Replaying a product's events in order yields its current
+// state.
+type ProductEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// sequence numbers the events of the whole log, starting at 1.
+	Sequence  uint64 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	ProductId string `protobuf:"bytes,2,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	// version is the number of events of the product up to and including
+	// this one.
+	Version uint64                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	Time    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	// Types that are valid to be assigned to Change:
+	//
+	//	*ProductEvent_Created
+	//	*ProductEvent_PriceChanged
+	//	*ProductEvent_Renamed
+	//	*ProductEvent_Updated
+	//	*ProductEvent_Deleted
+	Change        isProductEvent_Change `protobuf_oneof:"change"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductEvent) Reset() {
+	*x = ProductEvent{}
+	mi := &file_storage_storage_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductEvent) ProtoMessage() {}
+
+func (x *ProductEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductEvent.ProtoReflect.Descriptor instead.
+func (*ProductEvent) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ProductEvent) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *ProductEvent) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *ProductEvent) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ProductEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *ProductEvent) GetChange() isProductEvent_Change {
+	if x != nil {
+		return x.Change
+	}
+	return nil
+}
+
+func (x *ProductEvent) GetCreated() *ProductCreated {
+	if x != nil {
+		if x, ok := x.Change.(*ProductEvent_Created); ok {
+			return x.Created
+		}
+	}
+	return nil
+}
+
+func (x *ProductEvent) GetPriceChanged() *PriceChanged {
+	if x != nil {
+		if x, ok := x.Change.(*ProductEvent_PriceChanged); ok {
+			return x.PriceChanged
+		}
+	}
+	return nil
+}
+
+func (x *ProductEvent) GetRenamed() *Renamed {
+	if x != nil {
+		if x, ok := x.Change.(*ProductEvent_Renamed); ok {
+			return x.Renamed
+		}
+	}
+	return nil
+}
+
+func (x *ProductEvent) GetUpdated() *ProductUpdated {
+	if x != nil {
+		if x, ok := x.Change.(*ProductEvent_Updated); ok {
+			return x.Updated
+		}
+	}
+	return nil
+}
+
+func (x *ProductEvent) GetDeleted() *Deleted {
+	if x != nil {
+		if x, ok := x.Change.(*ProductEvent_Deleted); ok {
+			return x.Deleted
+		}
+	}
+	return nil
+}
+
+type isProductEvent_Change interface {
+	isProductEvent_Change()
+}
+
+type ProductEvent_Created struct {
+	Created *ProductCreated `protobuf:"bytes,5,opt,name=created,proto3,oneof"`
+}
+
+type ProductEvent_PriceChanged struct {
+	PriceChanged *PriceChanged `protobuf:"bytes,6,opt,name=price_changed,json=priceChanged,proto3,oneof"`
+}
+
+type ProductEvent_Renamed struct {
+	Renamed *Renamed `protobuf:"bytes,7,opt,name=renamed,proto3,oneof"`
+}
+
+type ProductEvent_Updated struct {
+	Updated *ProductUpdated `protobuf:"bytes,8,opt,name=updated,proto3,oneof"`
+}
+
+type ProductEvent_Deleted struct {
+	Deleted *Deleted `protobuf:"bytes,9,opt,name=deleted,proto3,oneof"`
+}
+
+func (*ProductEvent_Created) isProductEvent_Change() {}
+
+func (*ProductEvent_PriceChanged) isProductEvent_Change() {}
+
+func (*ProductEvent_Renamed) isProductEvent_Change() {}
+
+func (*ProductEvent_Updated) isProductEvent_Change() {}
+
+func (*ProductEvent_Deleted) isProductEvent_Change() {}
+
+// ProductCreated starts the life of a product, or a new one after Deleted.
+type ProductCreated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Product       *product.Product       `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductCreated) Reset() {
+	*x = ProductCreated{}
+	mi := &file_storage_storage_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductCreated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductCreated) ProtoMessage() {}
+
+func (x *ProductCreated) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductCreated.ProtoReflect.Descriptor instead.
+func (*ProductCreated) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ProductCreated) GetProduct() *product.Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+type PriceChanged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OldPrice      float64                `protobuf:"fixed64,1,opt,name=old_price,json=oldPrice,proto3" json:"old_price,omitempty"`
+	Price         float64                `protobuf:"fixed64,2,opt,name=price,proto3" json:"price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PriceChanged) Reset() {
+	*x = PriceChanged{}
+	mi := &file_storage_storage_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PriceChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriceChanged) ProtoMessage() {}
+
+func (x *PriceChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriceChanged.ProtoReflect.Descriptor instead.
+func (*PriceChanged) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PriceChanged) GetOldPrice() float64 {
+	if x != nil {
+		return x.OldPrice
+	}
+	return 0
+}
+
+func (x *PriceChanged) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+type Renamed struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OldName       string                 `protobuf:"bytes,1,opt,name=old_name,json=oldName,proto3" json:"old_name,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Renamed) Reset() {
+	*x = Renamed{}
+	mi := &file_storage_storage_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Renamed) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Renamed) ProtoMessage() {}
+
+func (x *Renamed) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Renamed.ProtoReflect.Descriptor instead.
+func (*Renamed) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Renamed) GetOldName() string {
+	if x != nil {
+		return x.OldName
+	}
+	return ""
+}
+
+func (x *Renamed) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// ProductUpdated records a change to any field other than the ID, name and
+// price. product is the whole product after the change; it follows the
+// Renamed and PriceChanged events of the same write, so its name and price
+// are the new ones.
+type ProductUpdated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Product       *product.Product       `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductUpdated) Reset() {
+	*x = ProductUpdated{}
+	mi := &file_storage_storage_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductUpdated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductUpdated) ProtoMessage() {}
+
+func (x *ProductUpdated) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductUpdated.ProtoReflect.Descriptor instead.
+func (*ProductUpdated) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ProductUpdated) GetProduct() *product.Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+type Deleted struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Deleted) Reset() {
+	*x = Deleted{}
+	mi := &file_storage_storage_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Deleted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Deleted) ProtoMessage() {}
+
+func (x *Deleted) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Deleted.ProtoReflect.Descriptor instead.
+func (*Deleted) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{10}
+}
+
+// OutboxRecord is one entry of the outbox log (internal/outbox). The messages
+// of a write are prepared before the write commits and then committed or
+// aborted; committed messages are pending until they are delivered or
+// dead-lettered.
+type OutboxRecord struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Entry:
+	//
+	//	*OutboxRecord_Start
+	//	*OutboxRecord_Prepared
+	//	*OutboxRecord_Committed
+	//	*OutboxRecord_Aborted
+	//	*OutboxRecord_Delivered
+	//	*OutboxRecord_DeadLettered
+	//	*OutboxRecord_Failed
+	Entry         isOutboxRecord_Entry `protobuf_oneof:"entry"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutboxRecord) Reset() {
+	*x = OutboxRecord{}
+	mi := &file_storage_storage_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutboxRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutboxRecord) ProtoMessage() {}
+
+func (x *OutboxRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutboxRecord.ProtoReflect.Descriptor instead.
+func (*OutboxRecord) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *OutboxRecord) GetEntry() isOutboxRecord_Entry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+func (x *OutboxRecord) GetStart() *OutboxStart {
+	if x != nil {
+		if x, ok := x.Entry.(*OutboxRecord_Start); ok {
+			return x.Start
+		}
+	}
+	return nil
+}
+
+func (x *OutboxRecord) GetPrepared() *OutboxBatch {
+	if x != nil {
+		if x, ok := x.Entry.(*OutboxRecord_Prepared); ok {
+			return x.Prepared
+		}
+	}
+	return nil
+}
+
+func (x *OutboxRecord) GetCommitted() uint64 {
+	if x != nil {
+		if x, ok := x.Entry.(*OutboxRecord_Committed); ok {
+			return x.Committed
+		}
+	}
+	return 0
+}
+
+func (x *OutboxRecord) GetAborted() uint64 {
+	if x != nil {
+		if x, ok := x.Entry.(*OutboxRecord_Aborted); ok {
+			return x.Aborted
+		}
+	}
+	return 0
+}
+
+func (x *OutboxRecord) GetDelivered() uint64 {
+	if x != nil {
+		if x, ok := x.Entry.(*OutboxRecord_Delivered); ok {
+			return x.Delivered
+		}
+	}
+	return 0
+}
+
+func (x *OutboxRecord) GetDeadLettered() uint64 {
+	if x != nil {
+		if x, ok := x.Entry.(*OutboxRecord_DeadLettered); ok {
+			return x.DeadLettered
+		}
+	}
+	return 0
+}
+
+func (x *OutboxRecord) GetFailed() *OutboxAttempt {
+	if x != nil {
+		if x, ok := x.Entry.(*OutboxRecord_Failed); ok {
+			return x.Failed
+		}
+	}
+	return nil
+}
+
+type isOutboxRecord_Entry interface {
+	isOutboxRecord_Entry()
+}
+
+type OutboxRecord_Start struct {
+	// start begins the log, and begins it again after it was truncated.
+	Start *OutboxStart `protobuf:"bytes,1,opt,name=start,proto3,oneof"`
+}
+
+type OutboxRecord_Prepared struct {
+	Prepared *OutboxBatch `protobuf:"bytes,2,opt,name=prepared,proto3,oneof"`
+}
+
+type OutboxRecord_Committed struct {
+	// committed and aborted are the ID of the first message of a batch.
+	Committed uint64 `protobuf:"varint,3,opt,name=committed,proto3,oneof"`
+}
+
+type OutboxRecord_Aborted struct {
+	Aborted uint64 `protobuf:"varint,4,opt,name=aborted,proto3,oneof"`
+}
+
+type OutboxRecord_Delivered struct {
+	// delivered and dead_lettered are the ID of a message.
+	Delivered uint64 `protobuf:"varint,5,opt,name=delivered,proto3,oneof"`
+}
+
+type OutboxRecord_DeadLettered struct {
+	DeadLettered uint64 `protobuf:"varint,6,opt,name=dead_lettered,json=deadLettered,proto3,oneof"`
+}
+
+type OutboxRecord_Failed struct {
+	Failed *OutboxAttempt `protobuf:"bytes,7,opt,name=failed,proto3,oneof"`
+}
+
+func (*OutboxRecord_Start) isOutboxRecord_Entry() {}
+
+func (*OutboxRecord_Prepared) isOutboxRecord_Entry() {}
+
+func (*OutboxRecord_Committed) isOutboxRecord_Entry() {}
+
+func (*OutboxRecord_Aborted) isOutboxRecord_Entry() {}
+
+func (*OutboxRecord_Delivered) isOutboxRecord_Entry() {}
+
+func (*OutboxRecord_DeadLettered) isOutboxRecord_Entry() {}
+
+func (*OutboxRecord_Failed) isOutboxRecord_Entry() {}
+
+// OutboxStart identifies the outbox and the IDs it gives messages next.
+type OutboxStart struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LogId         string                 `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	NextId        uint64                 `protobuf:"varint,2,opt,name=next_id,json=nextId,proto3" json:"next_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutboxStart) Reset() {
+	*x = OutboxStart{}
+	mi := &file_storage_storage_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutboxStart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutboxStart) ProtoMessage() {}
+
+func (x *OutboxStart) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutboxStart.ProtoReflect.Descriptor instead.
+func (*OutboxStart) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *OutboxStart) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+func (x *OutboxStart) GetNextId() uint64 {
+	if x != nil {
+		return x.NextId
+	}
+	return 0
+}
+
+// OutboxBatch is the messages of one write, with consecutive IDs.
+type OutboxBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*OutboxMessage       `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutboxBatch) Reset() {
+	*x = OutboxBatch{}
+	mi := &file_storage_storage_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutboxBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutboxBatch) ProtoMessage() {}
+
+func (x *OutboxBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutboxBatch.ProtoReflect.Descriptor instead.
+func (*OutboxBatch) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *OutboxBatch) GetMessages() []*OutboxMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+// OutboxMessage is a domain event of the product catalog waiting to be
+// published.
+type OutboxMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id numbers the messages of the outbox, starting at 1.
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// type is "created", "updated" or "deleted".
+	Type      string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	ProductId string                 `protobuf:"bytes,3,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Time      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	// product is the product after the change; unset for "deleted".
+	Product *product.Product `protobuf:"bytes,5,opt,name=product,proto3" json:"product,omitempty"`
+	// previous is the product before the change; unset for "created".
+	Previous *product.Product `protobuf:"bytes,6,opt,name=previous,proto3" json:"previous,omitempty"`
+	// attempts is the number of failed deliveries.
+	Attempts      uint32                 `protobuf:"varint,7,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	NextAttempt   *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=next_attempt,json=nextAttempt,proto3" json:"next_attempt,omitempty"`
+	LastError     string                 `protobuf:"bytes,9,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutboxMessage) Reset() {
+	*x = OutboxMessage{}
+	mi := &file_storage_storage_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutboxMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutboxMessage) ProtoMessage() {}
+
+func (x *OutboxMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutboxMessage.ProtoReflect.Descriptor instead.
+func (*OutboxMessage) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *OutboxMessage) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *OutboxMessage) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *OutboxMessage) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *OutboxMessage) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *OutboxMessage) GetProduct() *product.Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+func (x *OutboxMessage) GetPrevious() *product.Product {
+	if x != nil {
+		return x.Previous
+	}
+	return nil
+}
+
+func (x *OutboxMessage) GetAttempts() uint32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *OutboxMessage) GetNextAttempt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextAttempt
+	}
+	return nil
+}
+
+func (x *OutboxMessage) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+// OutboxAttempt records a failed delivery of a message.
+type OutboxAttempt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Attempts      uint32                 `protobuf:"varint,2,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	NextAttempt   *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=next_attempt,json=nextAttempt,proto3" json:"next_attempt,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutboxAttempt) Reset() {
+	*x = OutboxAttempt{}
+	mi := &file_storage_storage_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutboxAttempt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutboxAttempt) ProtoMessage() {}
+
+func (x *OutboxAttempt) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_storage_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutboxAttempt.ProtoReflect.Descriptor instead.
+func (*OutboxAttempt) Descriptor() ([]byte, []int) {
+	return file_storage_storage_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *OutboxAttempt) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *OutboxAttempt) GetAttempts() uint32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *OutboxAttempt) GetNextAttempt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextAttempt
+	}
+	return nil
+}
+
+func (x *OutboxAttempt) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_storage_storage_proto protoreflect.FileDescriptor
+
+const file_storage_storage_proto_rawDesc = "" +
+	"\n" +
+	"\x15storage/storage.proto\x12\x12product.storage.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\rproduct.proto\"c\n" +
+	"\tWALRecord\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12:\n" +
+	"\tmutations\x18\x02 \x03(\v2\x1c.product.storage.v1.MutationR\tmutations\"\xb0\x01\n" +
+	"\bMutation\x12'\n" +
+	"\x03put\x18\x01 \x01(\v2\x13.product.v1.ProductH\x00R\x03put\x12\x18\n" +
+	"\x06delete\x18\x02 \x01(\tH\x00R\x06delete\x12[\n" +
+	"\x18put_attribute_definition\x18\x03 \x01(\v2\x1f.product.v1.AttributeDefinitionH\x00R\x16putAttributeDefinitionB\x04\n" +
+	"\x02op\"\xad\x01\n" +
+	"\bSnapshot\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12/\n" +
+	"\bproducts\x18\x02 \x03(\v2\x13.product.v1.ProductR\bproducts\x12T\n" +
+	"\x15attribute_definitions\x18\x03 \x03(\v2\x1f.product.v1.AttributeDefinitionR\x14attributeDefinitions\"@\n" +
+	"\rCatalogBackup\x12/\n" +
+	"\bproducts\x18\x01 \x03(\v2\x13.product.v1.ProductR\bproducts\"\x9c\x01\n" +
+	"\n" +
+	"EventBatch\x128\n" +
+	"\x06events\x18\x01 \x03(\v2 .product.storage.v1.ProductEventR\x06events\x12T\n" +
+	"\x15attribute_definitions\x18\x02 \x03(\v2\x1f.product.v1.AttributeDefinitionR\x14attributeDefinitions\"\xd8\x03\n" +
+	"\fProductEvent\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x02 \x01(\tR\tproductId\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x04R\aversion\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12>\n" +
+	"\acreated\x18\x05 \x01(\v2\".product.storage.v1.ProductCreatedH\x00R\acreated\x12G\n" +
+	"\rprice_changed\x18\x06 \x01(\v2 .product.storage.v1.PriceChangedH\x00R\fpriceChanged\x127\n" +
+	"\arenamed\x18\a \x01(\v2\x1b.product.storage.v1.RenamedH\x00R\arenamed\x12>\n" +
+	"\aupdated\x18\b \x01(\v2\".product.storage.v1.ProductUpdatedH\x00R\aupdated\x127\n" +
+	"\adeleted\x18\t \x01(\v2\x1b.product.storage.v1.DeletedH\x00R\adeletedB\b\n" +
+	"\x06change\"?\n" +
+	"\x0eProductCreated\x12-\n" +
+	"\aproduct\x18\x01 \x01(\v2\x13.product.v1.ProductR\aproduct\"A\n" +
+	"\fPriceChanged\x12\x1b\n" +
+	"\told_price\x18\x01 \x01(\x01R\boldPrice\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\x01R\x05price\"8\n" +
+	"\aRenamed\x12\x19\n" +
+	"\bold_name\x18\x01 \x01(\tR\aoldName\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"?\n" +
+	"\x0eProductUpdated\x12-\n" +
+	"\aproduct\x18\x01 \x01(\v2\x13.product.v1.ProductR\aproduct\"\t\n" +
+	"\aDeleted\"\xcf\x02\n" +
+	"\fOutboxRecord\x127\n" +
+	"\x05start\x18\x01 \x01(\v2\x1f.product.storage.v1.OutboxStartH\x00R\x05start\x12=\n" +
+	"\bprepared\x18\x02 \x01(\v2\x1f.product.storage.v1.OutboxBatchH\x00R\bprepared\x12\x1e\n" +
+	"\tcommitted\x18\x03 \x01(\x04H\x00R\tcommitted\x12\x1a\n" +
+	"\aaborted\x18\x04 \x01(\x04H\x00R\aaborted\x12\x1e\n" +
+	"\tdelivered\x18\x05 \x01(\x04H\x00R\tdelivered\x12%\n" +
+	"\rdead_lettered\x18\x06 \x01(\x04H\x00R\fdeadLettered\x12;\n" +
+	"\x06failed\x18\a \x01(\v2!.product.storage.v1.OutboxAttemptH\x00R\x06failedB\a\n" +
+	"\x05entry\"=\n" +
+	"\vOutboxStart\x12\x15\n" +
+	"\x06log_id\x18\x01 \x01(\tR\x05logId\x12\x17\n" +
+	"\anext_id\x18\x02 \x01(\x04R\x06nextId\"L\n" +
+	"\vOutboxBatch\x12=\n" +
+	"\bmessages\x18\x01 \x03(\v2!.product.storage.v1.OutboxMessageR\bmessages\"\xdc\x02\n" +
+	"\rOutboxMessage\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x03 \x01(\tR\tproductId\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12-\n" +
+	"\aproduct\x18\x05 \x01(\v2\x13.product.v1.ProductR\aproduct\x12/\n" +
+	"\bprevious\x18\x06 \x01(\v2\x13.product.v1.ProductR\bprevious\x12\x1a\n" +
+	"\battempts\x18\a \x01(\rR\battempts\x12=\n" +
+	"\fnext_attempt\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\vnextAttempt\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\t \x01(\tR\tlastError\"\x90\x01\n" +
+	"\rOutboxAttempt\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1a\n" +
+	"\battempts\x18\x02 \x01(\rR\battempts\x12=\n" +
+	"\fnext_attempt\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\vnextAttempt\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05errorB9Z7grpc-go-fx/internal/generated/product/storage;storagepbb\x06proto3"
+
+var (
+	file_storage_storage_proto_rawDescOnce sync.Once
+	file_storage_storage_proto_rawDescData []byte
+)
+
+func file_storage_storage_proto_rawDescGZIP() []byte {
+	file_storage_storage_proto_rawDescOnce.Do(func() {
+		file_storage_storage_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_storage_storage_proto_rawDesc), len(file_storage_storage_proto_rawDesc)))
+	})
+	return file_storage_storage_proto_rawDescData
+}
+
+var file_storage_storage_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_storage_storage_proto_goTypes = []any{
+	(*WALRecord)(nil),                   // 0: product.storage.v1.WALRecord
+	(*Mutation)(nil),                    // 1: product.storage.v1.Mutation
+	(*Snapshot)(nil),                    // 2: product.storage.v1.Snapshot
+	(*CatalogBackup)(nil),               // 3: product.storage.v1.CatalogBackup
+	(*EventBatch)(nil),                  // 4: product.storage.v1.EventBatch
+	(*ProductEvent)(nil),                // 5: product.storage.v1.ProductEvent
+	(*ProductCreated)(nil),              // 6: product.storage.v1.ProductCreated
+	(*PriceChanged)(nil),                // 7: product.storage.v1.PriceChanged
+	(*Renamed)(nil),                     // 8: product.storage.v1.Renamed
+	(*ProductUpdated)(nil),              // 9: product.storage.v1.ProductUpdated
+	(*Deleted)(nil),                     // 10: product.storage.v1.Deleted
+	(*OutboxRecord)(nil),                // 11: product.storage.v1.OutboxRecord
+	(*OutboxStart)(nil),                 // 12: product.storage.v1.OutboxStart
+	(*OutboxBatch)(nil),                 // 13: product.storage.v1.OutboxBatch
+	(*OutboxMessage)(nil),               // 14: product.storage.v1.OutboxMessage
+	(*OutboxAttempt)(nil),               // 15: product.storage.v1.OutboxAttempt
+	(*product.Product)(nil),             // 16: product.v1.Product
+	(*product.AttributeDefinition)(nil), // 17: product.v1.AttributeDefinition
+	(*timestamppb.Timestamp)(nil),       // 18: google.protobuf.Timestamp
+}
+var file_storage_storage_proto_depIdxs = []int32{
+	1,  // 0: product.storage.v1.WALRecord.mutations:type_name -> product.storage.v1.Mutation
+	16, // 1: product.storage.v1.Mutation.put:type_name -> product.v1.Product
+	17, // 2: product.storage.v1.Mutation.put_attribute_definition:type_name -> product.v1.AttributeDefinition
+	16, // 3: product.storage.v1.Snapshot.products:type_name -> product.v1.Product
+	17, // 4: product.storage.v1.Snapshot.attribute_definitions:type_name -> product.v1.AttributeDefinition
+	16, // 5: product.storage.v1.CatalogBackup.products:type_name -> product.v1.Product
+	5,  // 6: product.storage.v1.EventBatch.events:type_name -> product.storage.v1.ProductEvent
+	17, // 7: product.storage.v1.EventBatch.attribute_definitions:type_name -> product.v1.AttributeDefinition
+	18, // 8: product.storage.v1.ProductEvent.time:type_name -> google.protobuf.Timestamp
+	6,  // 9: product.storage.v1.ProductEvent.created:type_name -> product.storage.v1.ProductCreated
+	7,  // 10: product.storage.v1.ProductEvent.price_changed:type_name -> product.storage.v1.PriceChanged
+	8,  // 11: product.storage.v1.ProductEvent.renamed:type_name -> product.storage.v1.Renamed
+	9,  // 12: product.storage.v1.ProductEvent.updated:type_name -> product.storage.v1.ProductUpdated
+	10, // 13: product.storage.v1.ProductEvent.deleted:type_name -> product.storage.v1.Deleted
+	16, // 14: product.storage.v1.ProductCreated.product:type_name -> product.v1.Product
+	16, // 15: product.storage.v1.ProductUpdated.product:type_name -> product.v1.Product
+	12, // 16: product.storage.v1.OutboxRecord.start:type_name -> product.storage.v1.OutboxStart
+	13, // 17: product.storage.v1.OutboxRecord.prepared:type_name -> product.storage.v1.OutboxBatch
+	15, // 18: product.storage.v1.OutboxRecord.failed:type_name -> product.storage.v1.OutboxAttempt
+	14, // 19: product.storage.v1.OutboxBatch.messages:type_name -> product.storage.v1.OutboxMessage
+	18, // 20: product.storage.v1.OutboxMessage.time:type_name -> google.protobuf.Timestamp
+	16, // 21: product.storage.v1.OutboxMessage.product:type_name -> product.v1.Product
+	16, // 22: product.storage.v1.OutboxMessage.previous:type_name -> product.v1.Product
+	18, // 23: product.storage.v1.OutboxMessage.next_attempt:type_name -> google.protobuf.Timestamp
+	18, // 24: product.storage.v1.OutboxAttempt.next_attempt:type_name -> google.protobuf.Timestamp
+	25, // [25:25] is the sub-list for method output_type
+	25, // [25:25] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
+}
+
+func init() { file_storage_storage_proto_init() }
+func file_storage_storage_proto_init() {
+	if File_storage_storage_proto != nil {
+		return
+	}
+	file_storage_storage_proto_msgTypes[1].OneofWrappers = []any{
+		(*Mutation_Put)(nil),
+		(*Mutation_Delete)(nil),
+		(*Mutation_PutAttributeDefinition)(nil),
+	}
+	file_storage_storage_proto_msgTypes[5].OneofWrappers = []any{
+		(*ProductEvent_Created)(nil),
+		(*ProductEvent_PriceChanged)(nil),
+		(*ProductEvent_Renamed)(nil),
+		(*ProductEvent_Updated)(nil),
+		(*ProductEvent_Deleted)(nil),
+	}
+	file_storage_storage_proto_msgTypes[11].OneofWrappers = []any{
+		(*OutboxRecord_Start)(nil),
+		(*OutboxRecord_Prepared)(nil),
+		(*OutboxRecord_Committed)(nil),
+		(*OutboxRecord_Aborted)(nil),
+		(*OutboxRecord_Delivered)(nil),
+		(*OutboxRecord_DeadLettered)(nil),
+		(*OutboxRecord_Failed)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_storage_storage_proto_rawDesc), len(file_storage_storage_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_storage_storage_proto_goTypes,
+		DependencyIndexes: file_storage_storage_proto_depIdxs,
+		MessageInfos:      file_storage_storage_proto_msgTypes,
+	}.Build()
+	File_storage_storage_proto = out.File
+	file_storage_storage_proto_goTypes = nil
+	file_storage_storage_proto_depIdxs = nil
+}
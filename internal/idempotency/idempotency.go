@@ -0,0 +1,273 @@
+// Package idempotency replays the outcome of requests retried with the same
+// Idempotency-Key, so that a client whose response was lost can safely retry.
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc-go-fx/internal/audit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// MetadataKey is the gRPC metadata key carrying the idempotency key. The
+	// gateway maps the Idempotency-Key HTTP header to it.
+	MetadataKey = "idempotency-key"
+	// ReplayedKey is set to "true" in the response header metadata when a stored
+	// outcome is replayed.
+	ReplayedKey = "idempotent-replayed"
+	// DefaultWindow is how long outcomes are kept when no window is configured.
+	DefaultWindow = 24 * time.Hour
+	// DefaultMaxEntries is how many outcomes are kept when no limit is
+	// configured.
+	DefaultMaxEntries = 100_000
+
+	maxKeyLength = 255
+)
+
+// Key identifies a keyed request. Keys sent by different actors, or to
+// different methods, never collide.
+type Key struct {
+	Actor  string
+	Method string
+	Key    string
+}
+
+// Store remembers the outcome of keyed requests for a fixed window. Once it
+// holds its maximum number of outcomes, the least recently used one is
+// forgotten to make room.
+type Store struct {
+	window     time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[Key]*list.Element
+	// lru holds the entries from the least to the most recently used.
+	lru *list.List
+}
+
+type entry struct {
+	key         Key
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	done        chan struct{}
+
+	// Set before done is closed.
+	resp      any
+	err       error
+	discarded bool
+}
+
+// NewStore creates a Store that keeps outcomes for window (DefaultWindow if
+// window is not positive) and at most maxEntries of them (DefaultMaxEntries
+// if maxEntries is not positive).
+func NewStore(window time.Duration, maxEntries int) *Store {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Store{window: window, maxEntries: maxEntries, now: time.Now, entries: make(map[Key]*list.Element), lru: list.New()}
+}
+
+// Len returns the number of outcomes kept, including those still running.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Do runs fn unless key was already used within the window. A duplicate with
+// the same fingerprint waits for the first call to finish and gets its outcome
+// with replayed set; a duplicate with another fingerprint fails with
+// InvalidArgument. Outcomes with transient error codes are not kept, so the
+// request can be retried under the same key.
+func (s *Store) Do(ctx context.Context, key Key, fingerprint [sha256.Size]byte, fn func() (any, error)) (resp any, replayed bool, err error) {
+	for {
+		s.mu.Lock()
+		now := s.now()
+		s.pruneLocked(now)
+		el, ok := s.entries[key]
+		if ok && !now.Before(el.Value.(*entry).expires) {
+			s.removeLocked(el)
+			ok = false
+		}
+		if !ok {
+			e := &entry{key: key, fingerprint: fingerprint, expires: now.Add(s.window), done: make(chan struct{})}
+			s.entries[key] = s.lru.PushBack(e)
+			for s.lru.Len() > s.maxEntries {
+				s.removeLocked(s.lru.Front())
+			}
+			s.mu.Unlock()
+			return s.run(e, fn)
+		}
+		s.lru.MoveToBack(el)
+		e := el.Value.(*entry)
+		s.mu.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, false, status.Errorf(codes.InvalidArgument, "idempotency key %q was already used with a different request", key.Key)
+		}
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, false, status.FromContextError(ctx.Err()).Err()
+		}
+		if !e.discarded {
+			return e.resp, true, e.err
+		}
+		// The first attempt failed transiently; try again as the owner.
+	}
+}
+
+// run calls fn as the owner of e and publishes its outcome. The entry is
+// discarded if fn fails transiently or panics, so waiting duplicates retry
+// instead of replaying the failure or blocking forever.
+func (s *Store) run(e *entry, fn func() (any, error)) (resp any, replayed bool, err error) {
+	e.discarded = true
+	defer func() {
+		if e.discarded {
+			s.mu.Lock()
+			if el, ok := s.entries[e.key]; ok && el.Value == e {
+				s.removeLocked(el)
+			}
+			s.mu.Unlock()
+		}
+		close(e.done)
+	}()
+	resp, err = fn()
+	if transient(err) {
+		return resp, false, err
+	}
+	// Handlers may return values that alias live state; keep a private copy.
+	if m, ok := resp.(proto.Message); ok {
+		e.resp = proto.Clone(m)
+	} else {
+		e.resp = resp
+	}
+	e.err = err
+	e.discarded = false
+	return resp, false, err
+}
+
+// pruneLocked drops the least recently used entries whose window has passed.
+// Expired entries that were used more recently are dropped when they are
+// looked up or evicted.
+func (s *Store) pruneLocked(now time.Time) {
+	for el := s.lru.Front(); el != nil && !now.Before(el.Value.(*entry).expires); el = s.lru.Front() {
+		s.removeLocked(el)
+	}
+}
+
+func (s *Store) removeLocked(el *list.Element) {
+	delete(s.entries, el.Value.(*entry).key)
+	s.lru.Remove(el)
+}
+
+// transient reports whether err should not be replayed because a retry may succeed.
+func transient(err error) bool {
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Unavailable, codes.ResourceExhausted,
+		codes.Aborted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+// UnaryServerInterceptor honours idempotency keys on methods with side
+// effects, i.e. methods whose idempotency_level is neither NO_SIDE_EFFECTS nor
+// IDEMPOTENT. Keys are scoped to the calling actor and the method. Requests
+// without a key are passed through.
+func UnaryServerInterceptor(store *Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := keyFromContext(ctx)
+		m, ok := req.(proto.Message)
+		if key == "" || !ok || !hasSideEffects(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		if len(key) > maxKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "idempotency key must be at most %d bytes", maxKeyLength)
+		}
+		fp, err := fingerprint(info.FullMethod, m)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		scoped := Key{Actor: audit.ActorFromContext(ctx), Method: info.FullMethod, Key: key}
+		resp, replayed, err := store.Do(ctx, scoped, fp, func() (any, error) { return handler(ctx, req) })
+		if replayed {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(ReplayedKey, "true"))
+		}
+		return resp, err
+	}
+}
+
+func keyFromContext(ctx context.Context) string {
+	if vals := metadata.ValueFromIncomingContext(ctx, MetadataKey); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// fingerprint identifies a request by method and deterministic wire encoding,
+// so JSON requests from the gateway and binary gRPC requests compare equal.
+func fingerprint(method string, m proto.Message) ([sha256.Size]byte, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write(b)
+	var out [sha256.Size]byte
+	h.Sum(out[:0])
+	return out, nil
+}
+
+var idempotencyLevels sync.Map // full method name -> descriptorpb.MethodOptions_IdempotencyLevel
+
+// hasSideEffects reports whether a method is declared neither NO_SIDE_EFFECTS
+// nor IDEMPOTENT.
+func hasSideEffects(fullMethod string) bool {
+	return idempotencyLevel(fullMethod) == descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN
+}
+
+// ReadOnly reports whether a "/package.Service/Method" is declared
+// NO_SIDE_EFFECTS. Unknown methods are not.
+func ReadOnly(fullMethod string) bool {
+	return idempotencyLevel(fullMethod) == descriptorpb.MethodOptions_NO_SIDE_EFFECTS
+}
+
+// idempotencyLevel looks up the method descriptor of a "/package.Service/Method"
+// name. Methods missing from the registry are IDEMPOTENCY_UNKNOWN.
+func idempotencyLevel(fullMethod string) descriptorpb.MethodOptions_IdempotencyLevel {
+	if v, ok := idempotencyLevels.Load(fullMethod); ok {
+		return v.(descriptorpb.MethodOptions_IdempotencyLevel)
+	}
+	level := descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN
+	if name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1)); name.IsValid() {
+		if d, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+			if md, ok := d.(protoreflect.MethodDescriptor); ok {
+				opts, _ := md.Options().(*descriptorpb.MethodOptions)
+				level = opts.GetIdempotencyLevel()
+			}
+		}
+	}
+	idempotencyLevels.Store(fullMethod, level)
+	return level
+}
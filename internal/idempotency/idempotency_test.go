@@ -0,0 +1,320 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/audit"
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const createMethod = "/product.v1.ProductService/CreateProduct"
+
+func withKey(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, key))
+}
+
+// countingHandler creates a product with a fresh ID on every call.
+func countingHandler(calls *atomic.Int32) grpc.UnaryHandler {
+	return func(ctx context.Context, req any) (any, error) {
+		n := calls.Add(1)
+		p := proto.Clone(req.(*product.CreateProductRequest).GetProduct()).(*product.Product)
+		p.Id = fmt.Sprintf("prod-%d", n)
+		return p, nil
+	}
+}
+
+func TestInterceptor_ReplaysDuplicate(t *testing.T) {
+	intercept := UnaryServerInterceptor(NewStore(0, 0))
+	info := &grpc.UnaryServerInfo{FullMethod: createMethod}
+	var calls atomic.Int32
+	req := &product.CreateProductRequest{Product: &product.Product{Name: "Widget", Price: 1}}
+
+	first, err := intercept(withKey("k1"), req, info, countingHandler(&calls))
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	again, err := intercept(withKey("k1"), proto.Clone(req), info, countingHandler(&calls))
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls.Load())
+	}
+	if !proto.Equal(first.(proto.Message), again.(proto.Message)) {
+		t.Fatalf("replayed %v, want %v", again, first)
+	}
+
+	// A different key runs the handler again.
+	if _, err := intercept(withKey("k2"), req, info, countingHandler(&calls)); err != nil || calls.Load() != 2 {
+		t.Fatalf("expected a new call for another key, err=%v calls=%d", err, calls.Load())
+	}
+	// Without a key every call runs.
+	if _, err := intercept(context.Background(), req, info, countingHandler(&calls)); err != nil || calls.Load() != 3 {
+		t.Fatalf("expected a new call without key, err=%v calls=%d", err, calls.Load())
+	}
+}
+
+func TestInterceptor_RejectsKeyReuseWithDifferentPayload(t *testing.T) {
+	intercept := UnaryServerInterceptor(NewStore(0, 0))
+	var calls atomic.Int32
+	info := &grpc.UnaryServerInfo{FullMethod: createMethod}
+
+	if _, err := intercept(withKey("k"), &product.CreateProductRequest{Product: &product.Product{Name: "A"}}, info, countingHandler(&calls)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := intercept(withKey("k"), &product.CreateProductRequest{Product: &product.Product{Name: "B"}}, info, countingHandler(&calls))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a different payload, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls.Load())
+	}
+}
+
+// proxyAddr is the peer address of a proxy that forwards the actors of its
+// requests.
+type proxyAddr struct{}
+
+func (proxyAddr) Network() string     { return "pipe" }
+func (proxyAddr) String() string      { return "proxy" }
+func (proxyAddr) ForwardsActor() bool { return true }
+
+func TestInterceptor_ScopesKeysToActorAndMethod(t *testing.T) {
+	intercept := UnaryServerInterceptor(NewStore(0, 0))
+	var calls atomic.Int32
+	req := &product.CreateProductRequest{Product: &product.Product{Name: "A"}}
+	as := func(actor string) context.Context {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "k", audit.ActorMetadataKey, actor))
+		return peer.NewContext(ctx, &peer.Peer{Addr: proxyAddr{}})
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: createMethod}
+
+	if _, err := intercept(as("alice"), req, info, countingHandler(&calls)); err != nil {
+		t.Fatal(err)
+	}
+	// Another actor reusing the key, even with another payload, runs its own request.
+	if _, err := intercept(as("bob"), &product.CreateProductRequest{Product: &product.Product{Name: "B"}}, info, countingHandler(&calls)); err != nil {
+		t.Fatalf("another actor: %v", err)
+	}
+	// So does the same actor calling another method.
+	if _, err := intercept(as("alice"), &product.DeleteProductRequest{Id: "prod-1"},
+		&grpc.UnaryServerInfo{FullMethod: "/product.v1.ProductService/DeleteProduct"}, func(ctx context.Context, req any) (any, error) {
+			calls.Add(1)
+			return &product.Product{}, nil
+		}); err != nil {
+		t.Fatalf("another method: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("handler ran %d times, want 3", calls.Load())
+	}
+	// The first actor's retry is still replayed.
+	if _, err := intercept(as("alice"), req, info, countingHandler(&calls)); err != nil || calls.Load() != 3 {
+		t.Fatalf("expected a replay, err=%v calls=%d", err, calls.Load())
+	}
+}
+
+func TestInterceptor_IgnoresKeysOnMethodsWithoutSideEffects(t *testing.T) {
+	intercept := UnaryServerInterceptor(NewStore(0, 0))
+	var calls atomic.Int32
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls.Add(1)
+		return &product.Product{}, nil
+	}
+	for _, method := range []string{"/product.v1.ProductService/GetProduct", "/product.v1.ProductService/PutAttributeDefinition"} {
+		for i := 0; i < 2; i++ {
+			if _, err := intercept(withKey("k"), &product.GetProductRequest{Id: "prod-1"}, &grpc.UnaryServerInfo{FullMethod: method}, handler); err != nil {
+				t.Fatalf("%s: %v", method, err)
+			}
+		}
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("handler ran %d times, want 4", calls.Load())
+	}
+}
+
+func TestInterceptor_ReplaysPermanentErrorsButNotTransientOnes(t *testing.T) {
+	intercept := UnaryServerInterceptor(NewStore(0, 0))
+	info := &grpc.UnaryServerInfo{FullMethod: createMethod}
+	req := &product.CreateProductRequest{Product: &product.Product{Name: "A"}}
+	var calls atomic.Int32
+	failWith := func(code codes.Code) grpc.UnaryHandler {
+		return func(ctx context.Context, req any) (any, error) {
+			calls.Add(1)
+			return nil, status.Error(code, "boom")
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := intercept(withKey("exists"), req, info, failWith(codes.AlreadyExists)); status.Code(err) != codes.AlreadyExists {
+			t.Fatalf("expected AlreadyExists, got %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("permanent error: handler ran %d times, want 1", calls.Load())
+	}
+
+	calls.Store(0)
+	for i := 0; i < 2; i++ {
+		if _, err := intercept(withKey("flaky"), req, info, failWith(codes.Unavailable)); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, got %v", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("transient error: handler ran %d times, want 2", calls.Load())
+	}
+}
+
+func TestStore_ConcurrentDuplicatesRunOnce(t *testing.T) {
+	s := NewStore(0, 0)
+	fp := sha256.Sum256([]byte("req"))
+	release := make(chan struct{})
+	var calls atomic.Int32
+
+	var wg sync.WaitGroup
+	results := make([]any, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _, err := s.Do(context.Background(), Key{Key: "k"}, fp, func() (any, error) {
+				calls.Add(1)
+				<-release
+				return &product.Product{Id: "prod-1"}, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = resp
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("fn ran %d times, want 1", calls.Load())
+	}
+	for _, r := range results {
+		if r.(*product.Product).GetId() != "prod-1" {
+			t.Fatalf("unexpected result %v", r)
+		}
+	}
+}
+
+func TestStore_ForgetsOutcomesAfterWindow(t *testing.T) {
+	s := NewStore(time.Minute, 0)
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+	fp := sha256.Sum256([]byte("req"))
+	var calls int
+	fn := func() (any, error) {
+		calls++
+		return &product.Product{}, nil
+	}
+
+	s.Do(context.Background(), Key{Key: "k"}, fp, fn)
+	now = now.Add(59 * time.Second)
+	if _, replayed, _ := s.Do(context.Background(), Key{Key: "k"}, fp, fn); !replayed {
+		t.Fatal("expected a replay inside the window")
+	}
+	now = now.Add(time.Second)
+	if _, replayed, _ := s.Do(context.Background(), Key{Key: "k"}, fp, fn); replayed || calls != 2 {
+		t.Fatalf("expected a fresh call after the window, replayed=%v calls=%d", replayed, calls)
+	}
+	// A different payload is accepted once the key has expired.
+	now = now.Add(time.Minute)
+	if _, _, err := s.Do(context.Background(), Key{Key: "k"}, sha256.Sum256([]byte("other")), fn); err != nil {
+		t.Fatalf("expected expired key to be reusable, got %v", err)
+	}
+}
+
+func TestStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewStore(0, 2)
+	fp := sha256.Sum256([]byte("req"))
+	var calls int
+	fn := func() (any, error) {
+		calls++
+		return &product.Product{}, nil
+	}
+	do := func(key string) bool {
+		_, replayed, err := s.Do(context.Background(), Key{Key: key}, fp, fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return replayed
+	}
+
+	do("a")
+	do("b")
+	if !do("a") {
+		t.Fatal("expected a replay of a")
+	}
+	// b is now the least recently used and makes room for c.
+	do("c")
+	if s.Len() != 2 {
+		t.Fatalf("store holds %d outcomes, want 2", s.Len())
+	}
+	if !do("a") || !do("c") {
+		t.Fatal("expected replays of a and c")
+	}
+	if do("b") {
+		t.Fatal("expected b to have been evicted")
+	}
+	if calls != 4 {
+		t.Fatalf("fn ran %d times, want 4", calls)
+	}
+}
+
+func TestStore_DiscardsKeyWhenFnPanics(t *testing.T) {
+	s := NewStore(0, 0)
+	fp := sha256.Sum256([]byte("req"))
+	started, release := make(chan struct{}), make(chan struct{})
+
+	waited := make(chan error, 1)
+	go func() {
+		defer func() { recover() }()
+		s.Do(context.Background(), Key{Key: "k"}, fp, func() (any, error) {
+			close(started)
+			<-release
+			panic("handler bug")
+		})
+	}()
+	<-started
+	go func() {
+		_, _, err := s.Do(context.Background(), Key{Key: "k"}, fp, func() (any, error) {
+			return &product.Product{Id: "prod-1"}, nil
+		})
+		waited <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Fatalf("duplicate waiting on a panicked call: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("duplicate still waiting on a panicked call")
+	}
+	resp, replayed, err := s.Do(context.Background(), Key{Key: "k"}, fp, func() (any, error) {
+		t.Fatal("fn ran although the retried call's outcome was kept")
+		return nil, nil
+	})
+	if err != nil || !replayed || resp.(*product.Product).GetId() != "prod-1" {
+		t.Fatalf("after the retry: %v, %v, %v; want the retried outcome replayed", resp, replayed, err)
+	}
+}
@@ -0,0 +1,130 @@
+// Package interceptor orders the unary and stream interceptors and the server
+// options that FX modules contribute to the gRPC server.
+//
+// A module contributes an interceptor by providing a Unary or Stream, or a
+// slice of them with the flatten option, into the value group
+// "unary_interceptors" or "stream_interceptors", and an option by providing an
+// Option into "server_options". FX does not order the values of a group, so
+// every contribution carries an explicit Order: the server chains them from
+// the lowest Order, which runs first and sees every later interceptor's
+// outcome, to the highest, which runs last, just before the handler.
+package interceptor
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"google.golang.org/grpc"
+)
+
+// The Orders of the built-in interceptors. They leave room between them for
+// contributions that need to run in between.
+const (
+	// OrderAccessLog logs every call with the outcome the client sees.
+	OrderAccessLog = 50
+	// OrderRecovery turns panics below it into Internal errors.
+	OrderRecovery = 100
+	// OrderDeadline gives requests without a deadline the default one.
+	OrderDeadline = 200
+	// OrderValidate rejects requests that break their field rules.
+	OrderValidate = 300
+	// OrderReplication rejects or forwards writes to a follower.
+	OrderReplication = 400
+	// OrderIdempotency replays the outcome of retried requests.
+	OrderIdempotency = 500
+)
+
+// Unary is a unary interceptor contributed to the gRPC server.
+type Unary struct {
+	// Order places the interceptor in the chain; lower runs first.
+	Order int
+	// Name identifies the interceptor in errors; it must be unique among
+	// the unary interceptors and breaks ties between equal Orders.
+	Name        string
+	Interceptor grpc.UnaryServerInterceptor
+}
+
+// Stream is a stream interceptor contributed to the gRPC server.
+type Stream struct {
+	// Order places the interceptor in the chain; lower runs first.
+	Order int
+	// Name identifies the interceptor in errors; it must be unique among
+	// the stream interceptors and breaks ties between equal Orders.
+	Name        string
+	Interceptor grpc.StreamServerInterceptor
+}
+
+// Option is a grpc.ServerOption contributed to the gRPC server. Options are
+// applied in Order, so a later option overrides an earlier one setting the
+// same thing.
+type Option struct {
+	Order int
+	// Name identifies the option in errors; it must be unique among the
+	// options and breaks ties between equal Orders.
+	Name   string
+	Option grpc.ServerOption
+}
+
+// ServerOptions returns the options that build a gRPC server with opts
+// applied and the unary and stream interceptors chained, each in Order. It
+// fails if two contributions of a kind share a name, or one has no
+// interceptor or option.
+func ServerOptions(unary []Unary, stream []Stream, opts []Option) ([]grpc.ServerOption, error) {
+	for _, dup := range []string{
+		duplicate(unary, func(u Unary) string { return u.Name }),
+		duplicate(stream, func(s Stream) string { return s.Name }),
+		duplicate(opts, func(o Option) string { return o.Name }),
+	} {
+		if dup != "" {
+			return nil, fmt.Errorf("%s contributed twice to the gRPC server", dup)
+		}
+	}
+	var unaryChain []grpc.UnaryServerInterceptor
+	for _, u := range sorted(unary, func(u Unary) (int, string) { return u.Order, u.Name }) {
+		if u.Interceptor == nil {
+			return nil, fmt.Errorf("unary interceptor %q is nil", u.Name)
+		}
+		unaryChain = append(unaryChain, u.Interceptor)
+	}
+	var streamChain []grpc.StreamServerInterceptor
+	for _, s := range sorted(stream, func(s Stream) (int, string) { return s.Order, s.Name }) {
+		if s.Interceptor == nil {
+			return nil, fmt.Errorf("stream interceptor %q is nil", s.Name)
+		}
+		streamChain = append(streamChain, s.Interceptor)
+	}
+	var serverOpts []grpc.ServerOption
+	for _, o := range sorted(opts, func(o Option) (int, string) { return o.Order, o.Name }) {
+		if o.Option == nil {
+			return nil, fmt.Errorf("server option %q is nil", o.Name)
+		}
+		serverOpts = append(serverOpts, o.Option)
+	}
+	return append(serverOpts,
+		grpc.ChainUnaryInterceptor(unaryChain...),
+		grpc.ChainStreamInterceptor(streamChain...),
+	), nil
+}
+
+// sorted returns a copy of s sorted by the order, then the name, key returns.
+func sorted[T any](s []T, key func(T) (int, string)) []T {
+	return slices.SortedStableFunc(slices.Values(s), func(a, b T) int {
+		ao, an := key(a)
+		bo, bn := key(b)
+		return cmp.Or(cmp.Compare(ao, bo), cmp.Compare(an, bn))
+	})
+}
+
+// duplicate returns the first name shared by two elements of s, or "".
+func duplicate[T any](s []T, name func(T) string) string {
+	seen := make(map[string]bool, len(s))
+	for _, v := range s {
+		n := name(v)
+		if seen[n] {
+			return fmt.Sprintf("%q", n)
+		}
+		seen[n] = true
+	}
+	return ""
+}
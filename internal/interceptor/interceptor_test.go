@@ -0,0 +1,75 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// recording returns a unary interceptor that appends name to calls.
+func recording(calls *[]string, name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		*calls = append(*calls, name)
+		return handler(ctx, req)
+	}
+}
+
+func TestServerOptions_ChainsInOrder(t *testing.T) {
+	var calls []string
+	opts, err := ServerOptions([]Unary{
+		{Order: OrderIdempotency, Name: "last", Interceptor: recording(&calls, "last")},
+		{Order: OrderRecovery, Name: "b", Interceptor: recording(&calls, "b")},
+		{Order: OrderValidate, Name: "middle", Interceptor: recording(&calls, "middle")},
+		{Order: OrderRecovery, Name: "a", Interceptor: recording(&calls, "a")},
+	}, nil, []Option{{Order: 1, Name: "size", Option: grpc.MaxRecvMsgSize(1 << 20)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(opts...)
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.NewClient("passthrough:///in-process",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(calls); got != "[a b middle last]" {
+		t.Fatalf("interceptors ran in the order %s", got)
+	}
+}
+
+func TestServerOptions_RejectsBadContributions(t *testing.T) {
+	noop := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(ctx, req)
+	}
+	for name, tc := range map[string]struct {
+		unary  []Unary
+		stream []Stream
+		opts   []Option
+		want   string
+	}{
+		"duplicate unary":  {unary: []Unary{{Name: "x", Interceptor: noop}, {Order: 1, Name: "x", Interceptor: noop}}, want: `"x" contributed twice`},
+		"duplicate option": {opts: []Option{{Name: "o", Option: grpc.MaxRecvMsgSize(1)}, {Name: "o", Option: grpc.MaxRecvMsgSize(2)}}, want: `"o" contributed twice`},
+		"nil stream":       {stream: []Stream{{Name: "s"}}, want: `stream interceptor "s" is nil`},
+	} {
+		if _, err := ServerOptions(tc.unary, tc.stream, tc.opts); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want %q", name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"grpc-go-fx/internal/config"
+	"grpc-go-fx/internal/interceptor"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key, and RequestIDHeader the HTTP header, of
+// the ID that correlates the access log entries of a request. A valid ID
+// sent by the client is kept; otherwise one is generated. Either way it is
+// returned in the response headers.
+const (
+	RequestIDKey    = "x-request-id"
+	RequestIDHeader = "X-Request-Id"
+)
+
+// maxRequestIDLen bounds the length of a request ID accepted from a client.
+const maxRequestIDLen = 128
+
+// forwardedForKey is the metadata key the gateway forwards the HTTP client's
+// address in.
+const forwardedForKey = "x-forwarded-for"
+
+// AccessLogOptions configure an AccessLog.
+type AccessLogOptions struct {
+	// SampleInitial and SampleThereafter sample the entries of each level:
+	// every second, the first SampleInitial are logged, then every
+	// SampleThereafter-th (none if it is zero). Zero SampleInitial logs every
+	// entry.
+	SampleInitial    int
+	SampleThereafter int
+}
+
+// AccessLog logs one entry per gRPC call and HTTP request, with its method,
+// status code, latency, peer and request ID. Successful calls, and calls
+// failing through the fault of the client, are logged at info level; calls
+// that may need attention, such as DeadlineExceeded or Unavailable, at warn;
+// server faults, such as Internal or HTTP 5xx, at error. A nil *AccessLog logs
+// nothing.
+type AccessLog struct {
+	log *zap.Logger
+}
+
+// NewAccessLog creates an access log writing to log under the name "access".
+func NewAccessLog(log *zap.Logger, opts AccessLogOptions) *AccessLog {
+	log = log.Named("access")
+	if opts.SampleInitial > 0 {
+		log = log.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(c, time.Second, opts.SampleInitial, opts.SampleThereafter)
+		}))
+	}
+	return &AccessLog{log: log}
+}
+
+// NewAccessLogFromConfig creates the access log configured by cfg, or nil
+// if cfg.AccessLog is off.
+func NewAccessLogFromConfig(log *zap.Logger, cfg *config.Config) *AccessLog {
+	if !cfg.AccessLog {
+		return nil
+	}
+	return NewAccessLog(log, AccessLogOptions{
+		SampleInitial:    cfg.AccessLogSampleInitial,
+		SampleThereafter: cfg.AccessLogSampleThereafter,
+	})
+}
+
+// UnaryInterceptors returns the unary interceptor of a, if it is not nil,
+// ordered before every built-in interceptor so that it logs the outcome
+// clients see, panics included.
+func UnaryInterceptors(a *AccessLog) []interceptor.Unary {
+	if a == nil {
+		return nil
+	}
+	return []interceptor.Unary{{Order: interceptor.OrderAccessLog, Name: "access-log", Interceptor: a.UnaryServerInterceptor()}}
+}
+
+// StreamInterceptors is the streaming counterpart of UnaryInterceptors.
+func StreamInterceptors(a *AccessLog) []interceptor.Stream {
+	if a == nil {
+		return nil
+	}
+	return []interceptor.Stream{{Order: interceptor.OrderAccessLog, Name: "access-log", Interceptor: a.StreamServerInterceptor()}}
+}
+
+// UnaryServerInterceptor logs every call once the handler returns.
+func (a *AccessLog) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx, id := incomingRequestID(ctx)
+		// SetHeader fails only outside a gRPC call, e.g. in tests.
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+		resp, err := handler(ctx, req)
+		a.logCall(ctx, info.FullMethod, id, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs every stream once the handler returns.
+func (a *AccessLog) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, id := incomingRequestID(ss.Context())
+		ss.SetHeader(metadata.Pairs(RequestIDKey, id))
+		err := handler(srv, &stream{ServerStream: ss, ctx: ctx})
+		a.logCall(ctx, info.FullMethod, id, start, err)
+		return err
+	}
+}
+
+func (a *AccessLog) logCall(ctx context.Context, method, id string, start time.Time, err error) {
+	code := status.Code(err)
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("code", code.String()),
+		zap.Duration("latency", time.Since(start)),
+		zap.String("request_id", id),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(forwardedForKey)) > 0 {
+		fields = append(fields, zap.Strings("forwarded_for", md.Get(forwardedForKey)))
+	}
+	if err != nil {
+		fields = append(fields, zap.String("error", status.Convert(err).Message()))
+	}
+	a.log.Log(codeLevel(code), "gRPC call", fields...)
+}
+
+// codeLevel is the level of the access log entry of a call ending with code.
+func codeLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return zapcore.InfoLevel
+	case codes.Unknown, codes.Unimplemented, codes.Internal, codes.DataLoss:
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.WarnLevel
+	}
+}
+
+// Handler returns a handler that serves requests with next and logs them.
+// It sets the request ID header on the request, for the gateway to forward
+// as metadata, and on the response.
+func (a *AccessLog) Handler(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		level := zapcore.InfoLevel
+		if rec.status >= http.StatusInternalServerError {
+			level = zapcore.ErrorLevel
+		}
+		a.log.Log(level, "HTTP request",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", rec.status),
+			zap.Int64("bytes", rec.bytes),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("peer", r.RemoteAddr),
+			zap.String("request_id", id),
+		)
+	})
+}
+
+// statusRecorder remembers the status code and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = code, true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets the gateway stream server-streaming responses.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+type requestIDKey struct{}
+
+// RequestID returns the request ID of the gRPC call ctx belongs to, or ""
+// if the access log is off.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// incomingRequestID returns ctx carrying the request ID the client sent, or
+// a new one, and the ID.
+func incomingRequestID(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id string
+	if ids := md.Get(RequestIDKey); len(ids) > 0 && validRequestID(ids[0]) {
+		id = ids[0]
+	} else {
+		id = newRequestID()
+	}
+	return context.WithValue(ctx, requestIDKey{}, id), id
+}
+
+// validRequestID reports whether id, sent by a client, is safe to log and
+// return: non-empty, not too long, and printable ASCII.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// stream is a grpc.ServerStream with the context of its handler replaced.
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context { return s.ctx }
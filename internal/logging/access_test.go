@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccessLog_UnaryServerInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	intercept := NewAccessLog(zap.New(core), AccessLogOptions{}).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/product.v1.ProductService/GetProduct"}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4321}})
+
+	var seen string
+	intercept(metadata.NewIncomingContext(ctx, metadata.Pairs(RequestIDKey, "req-1")), nil, info, func(ctx context.Context, req any) (any, error) {
+		seen = RequestID(ctx)
+		return nil, nil
+	})
+	intercept(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.Internal, "disk full")
+	})
+	intercept(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "no such product")
+	})
+
+	if seen != "req-1" {
+		t.Fatalf("handler saw request ID %q", seen)
+	}
+	entries := logs.AllUntimed()
+	if len(entries) != 3 {
+		t.Fatalf("logged %d entries, want 3", len(entries))
+	}
+	ok := entries[0].ContextMap()
+	if ok["method"] != info.FullMethod || ok["code"] != "OK" || ok["peer"] != "10.0.0.1:4321" || ok["request_id"] != "req-1" || entries[0].Level != zapcore.InfoLevel {
+		t.Fatalf("logged %v at %v", ok, entries[0].Level)
+	}
+	if _, has := ok["latency"]; !has {
+		t.Fatal("no latency logged")
+	}
+	failed := entries[1].ContextMap()
+	if failed["code"] != "Internal" || failed["error"] != "disk full" || entries[1].Level != zapcore.ErrorLevel || len(failed["request_id"].(string)) != 16 {
+		t.Fatalf("logged %v at %v", failed, entries[1].Level)
+	}
+	if entries[2].Level != zapcore.InfoLevel {
+		t.Fatalf("NotFound logged at %v", entries[2].Level)
+	}
+}
+
+func TestAccessLog_Handler(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	var forwarded string
+	h := NewAccessLog(zap.New(core), AccessLogOptions{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(RequestIDHeader)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("try later"))
+	}))
+
+	for _, id := range []string{"abc", "bad id\n"} {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/product.v1.ProductService/GetProduct", nil)
+		r.Header.Set(RequestIDHeader, id)
+		h.ServeHTTP(rec, r)
+		got := rec.Header().Get(RequestIDHeader)
+		if got != forwarded || (id == "abc") != (got == "abc") {
+			t.Fatalf("request ID %q: returned %q, forwarded %q", id, got, forwarded)
+		}
+	}
+	entry := logs.AllUntimed()[0]
+	fields := entry.ContextMap()
+	if fields["status"] != int64(503) || fields["bytes"] != int64(9) || fields["path"] != "/product.v1.ProductService/GetProduct" || fields["request_id"] != "abc" || entry.Level != zapcore.ErrorLevel {
+		t.Fatalf("logged %v at %v", fields, entry.Level)
+	}
+
+	var nilLog *AccessLog
+	if nilLog.Handler(http.NotFoundHandler()) == nil || UnaryInterceptors(nil) != nil || StreamInterceptors(nil) != nil {
+		t.Fatal("a nil access log should log nothing")
+	}
+}
+
+func TestAccessLog_Samples(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	intercept := NewAccessLog(zap.New(core), AccessLogOptions{SampleInitial: 2, SampleThereafter: 3}).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Get"}
+	for range 8 {
+		intercept(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) { return nil, nil })
+	}
+	intercept(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, errors.New("boom")
+	})
+	// The first two, then the 5th and 8th; the error is sampled separately.
+	if n := logs.FilterLevelExact(zapcore.InfoLevel).Len(); n != 4 {
+		t.Fatalf("logged %d of 8 calls, want 4", n)
+	}
+	if n := logs.FilterLevelExact(zapcore.ErrorLevel).Len(); n != 1 {
+		t.Fatalf("logged %d failed calls, want 1", n)
+	}
+}
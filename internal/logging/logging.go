@@ -0,0 +1,64 @@
+// Package logging provides the structured zap logger of the Product API, the
+// adapter that routes FX's own events through it, and access logs of gRPC
+// calls and HTTP requests.
+package logging
+
+import (
+	"fmt"
+	"os"
+
+	"grpc-go-fx/internal/config"
+
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Module provides the *zap.Logger configured by the config, and the access
+// log, which it contributes to the value groups "unary_interceptors" and
+// "stream_interceptors" of the gRPC server. Pass NewFxLogger to fx.WithLogger
+// to log FX's events with the same logger.
+var Module = fx.Module("logging",
+	fx.Provide(NewLogger),
+	fx.Provide(NewAccessLogFromConfig),
+	fx.Provide(fx.Annotate(UnaryInterceptors, fx.ResultTags(`group:"unary_interceptors,flatten"`))),
+	fx.Provide(fx.Annotate(StreamInterceptors, fx.ResultTags(`group:"stream_interceptors,flatten"`))),
+)
+
+// New creates a logger writing entries of level and above to w, encoded as
+// JSON objects, one per line, if format is "json" or empty, or as
+// tab-separated text for people if it is "console".
+func New(format string, level zapcore.Level, w zapcore.WriteSyncer) (*zap.Logger, error) {
+	var enc zapcore.Encoder
+	switch format {
+	case "", "json":
+		ec := zap.NewProductionEncoderConfig()
+		ec.EncodeTime = zapcore.ISO8601TimeEncoder
+		enc = zapcore.NewJSONEncoder(ec)
+	case "console":
+		enc = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want json or console)", format)
+	}
+	return zap.New(zapcore.NewCore(enc, w, level), zap.ErrorOutput(w)), nil
+}
+
+// NewLogger creates the logger of cfg.LogFormat and cfg.LogLevel. It writes
+// to standard error, leaving standard output to the stdout outbox
+// publisher, and is synced on OnStop.
+func NewLogger(lc fx.Lifecycle, cfg *config.Config) (*zap.Logger, error) {
+	log, err := New(cfg.LogFormat, cfg.LogLevel, zapcore.Lock(os.Stderr))
+	if err != nil {
+		return nil, err
+	}
+	// Syncing fails on terminals and pipes, which have nothing to flush.
+	lc.Append(fx.StopHook(func() { log.Sync() }))
+	return log, nil
+}
+
+// NewFxLogger logs the events of the FX application, such as provided
+// constructors and run hooks, to log under the name "fx".
+func NewFxLogger(log *zap.Logger) fxevent.Logger {
+	return &fxevent.ZapLogger{Logger: log.Named("fx")}
+}
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New("", zapcore.InfoLevel, zapcore.AddSync(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("hidden")
+	log.Info("started", zap.String("addr", ":50051"))
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("json log %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "started" || entry["level"] != "info" || entry["addr"] != ":50051" {
+		t.Fatalf("logged %v", entry)
+	}
+
+	buf.Reset()
+	log, err = New("console", zapcore.DebugLevel, zapcore.AddSync(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("shown", zap.Int("n", 1))
+	if line := buf.String(); !strings.Contains(line, "DEBUG\tshown\t{\"n\": 1}") {
+		t.Fatalf("console log %q", line)
+	}
+
+	if _, err := New("xml", zapcore.InfoLevel, zapcore.AddSync(&buf)); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,274 @@
+// Package operations keeps track of long-running operations and serves them
+// through the google.longrunning.Operations API.
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// DefaultRetention is how long finished operations are kept when no retention is configured.
+const DefaultRetention = 24 * time.Hour
+
+// Func is the body of an operation. It runs in its own goroutine with a
+// context that is cancelled by Cancel or Close, and should return ctx.Err()
+// promptly once that happens. progress replaces the operation metadata.
+type Func func(ctx context.Context, progress func(metadata proto.Message)) (proto.Message, error)
+
+// Registry runs operations in the background and records their state.
+type Registry struct {
+	retention time.Duration
+
+	mu     sync.Mutex
+	ops    map[string]*operation
+	seq    uint64
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type operation struct {
+	seq      uint64
+	state    *longrunningpb.Operation
+	finished time.Time
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewRegistry creates a Registry that keeps finished operations for retention
+// (DefaultRetention if retention is not positive).
+func NewRegistry(retention time.Duration) *Registry {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Registry{retention: retention, ops: make(map[string]*operation), ctx: ctx, cancel: cancel}
+}
+
+// Start registers a new operation with the given initial metadata and runs fn
+// in the background. The returned snapshot is not done.
+func (r *Registry) Start(metadata proto.Message, fn Func) (*longrunningpb.Operation, error) {
+	meta, err := anypb.New(metadata)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ctx.Err() != nil {
+		return nil, status.Error(codes.Unavailable, "operation registry is shut down")
+	}
+	r.pruneLocked(time.Now())
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.seq++
+	op := &operation{
+		seq:    r.seq,
+		state:  &longrunningpb.Operation{Name: "operations/" + newID(), Metadata: meta},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	r.ops[op.state.GetName()] = op
+
+	r.wg.Add(1)
+	go r.run(ctx, op, fn)
+	return proto.Clone(op.state).(*longrunningpb.Operation), nil
+}
+
+func (r *Registry) run(ctx context.Context, op *operation, fn Func) {
+	defer r.wg.Done()
+	defer op.cancel()
+
+	progress := func(m proto.Message) {
+		meta, err := anypb.New(m)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		next := proto.Clone(op.state).(*longrunningpb.Operation)
+		next.Metadata = meta
+		op.state = next
+	}
+	resp, err := fn(ctx, progress)
+
+	next := func() *longrunningpb.Operation {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return proto.Clone(op.state).(*longrunningpb.Operation)
+	}()
+	next.Done = true
+	switch {
+	case err != nil:
+		if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+			err = status.Error(codes.Canceled, "operation was cancelled")
+		}
+		next.Result = &longrunningpb.Operation_Error{Error: status.Convert(err).Proto()}
+	case resp != nil:
+		packed, perr := anypb.New(resp)
+		if perr != nil {
+			next.Result = &longrunningpb.Operation_Error{Error: status.Convert(perr).Proto()}
+			break
+		}
+		next.Result = &longrunningpb.Operation_Response{Response: packed}
+	}
+
+	r.mu.Lock()
+	op.state = next
+	op.finished = time.Now()
+	r.mu.Unlock()
+	close(op.done)
+}
+
+// Get returns the current state of the named operation.
+func (r *Registry) Get(name string) (*longrunningpb.Operation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	op, ok := r.ops[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "operation %q not found", name)
+	}
+	return proto.Clone(op.state).(*longrunningpb.Operation), nil
+}
+
+// List returns operations in creation order. filter may be empty, "done=true"
+// or "done=false". pageToken is the value returned by a previous call.
+func (r *Registry) List(filter string, pageSize int, pageToken string) ([]*longrunningpb.Operation, string, error) {
+	var wantDone *bool
+	switch strings.ReplaceAll(filter, " ", "") {
+	case "":
+	case "done=true":
+		wantDone = proto.Bool(true)
+	case "done=false":
+		wantDone = proto.Bool(false)
+	default:
+		return nil, "", status.Errorf(codes.InvalidArgument, "unsupported filter %q: use done=true or done=false", filter)
+	}
+	var after uint64
+	if pageToken != "" {
+		n, err := strconv.ParseUint(pageToken, 10, 64)
+		if err != nil {
+			return nil, "", status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		after = n
+	}
+	if pageSize <= 0 || pageSize > 1000 {
+		pageSize = 100
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneLocked(time.Now())
+	ops := make([]*operation, 0, len(r.ops))
+	for _, op := range r.ops {
+		if op.seq > after && (wantDone == nil || op.state.GetDone() == *wantDone) {
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].seq < ops[j].seq })
+
+	next := ""
+	if len(ops) > pageSize {
+		ops = ops[:pageSize]
+		next = strconv.FormatUint(ops[pageSize-1].seq, 10)
+	}
+	out := make([]*longrunningpb.Operation, len(ops))
+	for i, op := range ops {
+		out[i] = proto.Clone(op.state).(*longrunningpb.Operation)
+	}
+	return out, next, nil
+}
+
+// Cancel requests cancellation of a running operation. Cancelling a finished
+// operation is a no-op. The operation finishes with code Canceled once its
+// Func observes the cancelled context.
+func (r *Registry) Cancel(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	op, ok := r.ops[name]
+	if !ok {
+		return status.Errorf(codes.NotFound, "operation %q not found", name)
+	}
+	op.cancel()
+	return nil
+}
+
+// Delete forgets an operation. It does not cancel it.
+func (r *Registry) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ops[name]; !ok {
+		return status.Errorf(codes.NotFound, "operation %q not found", name)
+	}
+	delete(r.ops, name)
+	return nil
+}
+
+// Wait blocks until the operation is done, timeout elapses or ctx is done,
+// and returns the latest state. Reaching the timeout is not an error.
+func (r *Registry) Wait(ctx context.Context, name string, timeout time.Duration) (*longrunningpb.Operation, error) {
+	r.mu.Lock()
+	op, ok := r.ops[name]
+	r.mu.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "operation %q not found", name)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-op.done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return proto.Clone(op.state).(*longrunningpb.Operation), nil
+}
+
+// Close cancels every running operation and waits for them to finish or for
+// ctx to be done. Start fails after Close.
+func (r *Registry) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.cancel()
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pruneLocked drops operations that finished more than r.retention ago.
+func (r *Registry) pruneLocked(now time.Time) {
+	for name, op := range r.ops {
+		if !op.finished.IsZero() && now.Sub(op.finished) > r.retention {
+			delete(r.ops, name)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,208 @@
+package operations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func waitDone(t *testing.T, r *Registry, name string) *longrunningpb.Operation {
+	t.Helper()
+	op, err := r.Wait(context.Background(), name, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if !op.GetDone() {
+		t.Fatalf("operation %q did not finish in time", name)
+	}
+	return op
+}
+
+func TestRegistry_StartReportsProgressAndResponse(t *testing.T) {
+	r := NewRegistry(0)
+	release := make(chan struct{})
+	progressed := make(chan struct{})
+
+	op, err := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		progress(wrapperspb.Int64(1))
+		close(progressed)
+		<-release
+		return wrapperspb.String("ok"), nil
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	if op.GetDone() || op.GetName() == "" {
+		t.Fatalf("unexpected initial operation: %v", op)
+	}
+
+	<-progressed
+	running, err := r.Get(op.GetName())
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	var meta wrapperspb.Int64Value
+	if err := running.GetMetadata().UnmarshalTo(&meta); err != nil || meta.GetValue() != 1 {
+		t.Fatalf("unexpected metadata %v (err %v)", running.GetMetadata(), err)
+	}
+
+	close(release)
+	done := waitDone(t, r, op.GetName())
+	var resp wrapperspb.StringValue
+	if err := done.GetResponse().UnmarshalTo(&resp); err != nil || resp.GetValue() != "ok" {
+		t.Fatalf("unexpected response %v (err %v)", done.GetResponse(), err)
+	}
+}
+
+func TestRegistry_CancelPropagatesToWork(t *testing.T) {
+	r := NewRegistry(0)
+	started := make(chan struct{})
+	op, err := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	<-started
+	if err := r.Cancel(op.GetName()); err != nil {
+		t.Fatalf("Cancel returned error: %v", err)
+	}
+	done := waitDone(t, r, op.GetName())
+	if got := codes.Code(done.GetError().GetCode()); got != codes.Canceled {
+		t.Fatalf("expected Canceled result, got %v", got)
+	}
+}
+
+func TestRegistry_FailedOperationKeepsStatus(t *testing.T) {
+	r := NewRegistry(0)
+	op, _ := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		return nil, status.Error(codes.FailedPrecondition, "nope")
+	})
+	done := waitDone(t, r, op.GetName())
+	if got := codes.Code(done.GetError().GetCode()); got != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", got)
+	}
+}
+
+func TestRegistry_ListPagesAndFilters(t *testing.T) {
+	r := NewRegistry(0)
+	block := make(chan struct{})
+	defer close(block)
+	var names []string
+	for i := 0; i < 3; i++ {
+		op, _ := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+			return wrapperspb.Bool(true), nil
+		})
+		waitDone(t, r, op.GetName())
+		names = append(names, op.GetName())
+	}
+	running, _ := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		<-block
+		return nil, nil
+	})
+
+	page, next, err := r.List("done = true", 2, "")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(page) != 2 || page[0].GetName() != names[0] || page[1].GetName() != names[1] || next == "" {
+		t.Fatalf("unexpected first page %v, next %q", page, next)
+	}
+	page, next, err = r.List("done = true", 2, next)
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(page) != 1 || page[0].GetName() != names[2] || next != "" {
+		t.Fatalf("unexpected second page %v, next %q", page, next)
+	}
+	page, _, _ = r.List("done=false", 0, "")
+	if len(page) != 1 || page[0].GetName() != running.GetName() {
+		t.Fatalf("unexpected running operations: %v", page)
+	}
+	if _, _, err := r.List("name = x", 0, ""); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for unsupported filter, got %v", err)
+	}
+}
+
+func TestRegistry_DeleteAndNotFound(t *testing.T) {
+	r := NewRegistry(0)
+	op, _ := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		return nil, nil
+	})
+	waitDone(t, r, op.GetName())
+	if err := r.Delete(op.GetName()); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := r.Get(op.GetName()); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound after delete, got %v", err)
+	}
+	if err := r.Cancel("operations/missing"); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound cancelling unknown operation, got %v", err)
+	}
+}
+
+func TestRegistry_RetentionPrunesFinishedOperations(t *testing.T) {
+	r := NewRegistry(time.Millisecond)
+	op, _ := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		return nil, nil
+	})
+	waitDone(t, r, op.GetName())
+	time.Sleep(5 * time.Millisecond)
+	if ops, _, _ := r.List("", 0, ""); len(ops) != 0 {
+		t.Fatalf("expected finished operation to be pruned, got %v", ops)
+	}
+}
+
+func TestRegistry_CloseCancelsRunningWork(t *testing.T) {
+	r := NewRegistry(0)
+	started := make(chan struct{})
+	op, _ := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := r.Close(ctx); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	got, _ := r.Get(op.GetName())
+	if !got.GetDone() || codes.Code(got.GetError().GetCode()) != codes.Canceled {
+		t.Fatalf("expected cancelled operation after Close, got %v", got)
+	}
+	if _, err := r.Start(wrapperspb.Int64(0), nil); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable after Close, got %v", err)
+	}
+}
+
+func TestServer_WaitOperationHonoursTimeout(t *testing.T) {
+	r := NewRegistry(0)
+	block := make(chan struct{})
+	defer close(block)
+	op, _ := r.Start(wrapperspb.Int64(0), func(ctx context.Context, progress func(proto.Message)) (proto.Message, error) {
+		<-block
+		return nil, nil
+	})
+	srv := NewServer(r)
+	got, err := srv.WaitOperation(context.Background(), &longrunningpb.WaitOperationRequest{Name: op.GetName(), Timeout: durationpb.New(10 * time.Millisecond)})
+	if err != nil {
+		t.Fatalf("WaitOperation returned error: %v", err)
+	}
+	if got.GetDone() {
+		t.Fatal("expected operation to still be running")
+	}
+	_, err = srv.GetOperation(context.Background(), &longrunningpb.GetOperationRequest{Name: "operations/missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package operations
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// maxWait bounds WaitOperation when neither the request nor the RPC deadline sets a timeout.
+const maxWait = time.Minute
+
+// Server implements longrunningpb.OperationsServer on top of a Registry.
+type Server struct {
+	longrunningpb.UnimplementedOperationsServer
+	reg *Registry
+}
+
+// NewServer creates a Server for reg.
+func NewServer(reg *Registry) *Server {
+	return &Server{reg: reg}
+}
+
+// GetOperation returns the latest state of an operation.
+func (s *Server) GetOperation(ctx context.Context, req *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	return s.reg.Get(req.GetName())
+}
+
+// ListOperations lists operations in creation order. req.Name is ignored since
+// every operation lives in the same collection.
+func (s *Server) ListOperations(ctx context.Context, req *longrunningpb.ListOperationsRequest) (*longrunningpb.ListOperationsResponse, error) {
+	ops, next, err := s.reg.List(req.GetFilter(), int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	return &longrunningpb.ListOperationsResponse{Operations: ops, NextPageToken: next}, nil
+}
+
+// CancelOperation requests cancellation; clients observe the result through GetOperation.
+func (s *Server) CancelOperation(ctx context.Context, req *longrunningpb.CancelOperationRequest) (*emptypb.Empty, error) {
+	if err := s.reg.Cancel(req.GetName()); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteOperation forgets an operation without cancelling it.
+func (s *Server) DeleteOperation(ctx context.Context, req *longrunningpb.DeleteOperationRequest) (*emptypb.Empty, error) {
+	if err := s.reg.Delete(req.GetName()); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// WaitOperation waits until the operation is done or the timeout elapses,
+// whichever comes first, and returns its latest state.
+func (s *Server) WaitOperation(ctx context.Context, req *longrunningpb.WaitOperationRequest) (*longrunningpb.Operation, error) {
+	timeout := maxWait
+	if req.GetTimeout() != nil {
+		timeout = req.GetTimeout().AsDuration()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		// Return the latest state just before the RPC deadline instead of failing it.
+		if d := time.Until(deadline) - 50*time.Millisecond; d < timeout {
+			timeout = max(d, 0)
+		}
+	}
+	return s.reg.Wait(ctx, req.GetName(), timeout)
+}
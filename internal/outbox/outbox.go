@@ -0,0 +1,667 @@
+// Package outbox implements a transactional outbox for the product catalog.
+// Writes made through the repository returned by Outbox.Wrap record a
+// message per changed product ("created", "updated" or "deleted") in the
+// same step as the change: the messages are prepared in the outbox log
+// before the repository commits, and committed or aborted with it. A Relay
+// delivers committed messages to a Publisher at least once, in order,
+// retrying failures with backoff and dead-lettering messages that keep
+// failing.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/repository"
+	"grpc-go-fx/internal/wal"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Message types.
+const (
+	TypeCreated = "created"
+	TypeUpdated = "updated"
+	TypeDeleted = "deleted"
+)
+
+// ErrClosed is returned for writes to a file-backed outbox that is not open.
+var ErrClosed = errors.New("outbox is not open")
+
+// compactSize is the log size past which delivering or retrying a message
+// compacts the log.
+const compactSize = 1 << 20
+
+// Outbox holds the messages of committed writes until they are delivered.
+// With a path, every change to it is appended to a CRC-framed log that is
+// fsynced before the change takes effect. The log is truncated whenever
+// every message has been delivered, and rewritten with only the messages
+// still pending or prepared once it grows past compactSize. Without one,
+// messages are kept in memory only, like the catalog of the in-memory stores.
+//
+// The messages of a write are prepared (logged) inside the repository
+// transaction, so a write whose messages cannot be logged fails, and
+// committed once the transaction has. After a crash between the two, Open
+// commits the prepared messages if the repository holds the products they
+// describe and aborts them otherwise; writes are serialized, so only the
+// last write can be in doubt. If appending to the log fails, every later
+// write fails too.
+type Outbox struct {
+	path string
+	now  func() time.Time
+	// writeMu is held while a write runs, from its transaction to the commit
+	// or abort of its messages.
+	writeMu sync.Mutex
+	backend repository.ProductRepository
+
+	mu     sync.Mutex
+	file   *os.File // nil unless open
+	size   int64    // bytes of valid records in file
+	failed error
+	logID  string
+	nextID uint64
+	// compactAt is the size past which the log is compacted.
+	compactAt int64
+	// pending holds the committed messages not yet delivered, by ID.
+	pending []*storagepb.OutboxMessage
+	// prepared holds the messages of writes not yet committed or aborted, by
+	// the ID of their first message.
+	prepared map[uint64][]*storagepb.OutboxMessage
+	// changed is closed and replaced when messages are committed.
+	changed chan struct{}
+	stats   Stats
+}
+
+// Stats counts what happened to the messages of an outbox since it was
+// created.
+type Stats struct {
+	Pending      int   `json:"pending"`
+	Delivered    int64 `json:"delivered"`
+	Failures     int64 `json:"failures"`
+	DeadLettered int64 `json:"dead_lettered"`
+}
+
+// New creates an Outbox logged to the file at path, or kept in memory if
+// path is empty. A file-backed outbox must be opened before use.
+func New(path string) *Outbox {
+	return &Outbox{
+		path:      path,
+		now:       time.Now,
+		logID:     newLogID(),
+		nextID:    1,
+		compactAt: compactSize,
+		prepared:  make(map[uint64][]*storagepb.OutboxMessage),
+		changed:   make(chan struct{}),
+	}
+}
+
+func newLogID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// LogID identifies the outbox: message IDs are unique within it. A
+// file-backed outbox keeps its ID across restarts; one in memory gets a new
+// one each run.
+func (o *Outbox) LogID() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.logID
+}
+
+// Stats returns the outbox's counters.
+func (o *Outbox) Stats() Stats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s := o.stats
+	s.Pending = len(o.pending)
+	return s
+}
+
+// Wrap returns a repository that stores products in backend and records the
+// messages of its writes in o. It must be called once, before Open, and
+// every write to backend must go through the returned repository.
+func (o *Outbox) Wrap(backend repository.ProductRepository) repository.ProductRepository {
+	o.backend = backend
+	return &recorder{outbox: o, backend: backend}
+}
+
+// Open reads the log, creating it and its directory if needed, and resolves
+// the messages of a write interrupted by a crash against the repository
+// given to Wrap, which must be open. An outbox without a path needs no Open.
+func (o *Outbox) Open(ctx context.Context) error {
+	if o.path == "" {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file != nil {
+		return errors.New("outbox is already open")
+	}
+	if err := os.MkdirAll(filepath.Dir(o.path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	off, err := o.replay(f)
+	if err == nil && off == 0 {
+		// A new log, or one cut short before its start record.
+		err = f.Truncate(0)
+	}
+	if err == nil {
+		_, err = f.Seek(off, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	o.file, o.size, o.failed = f, off, nil
+	if off == 0 {
+		if err := o.append(o.start()); err != nil {
+			return err
+		}
+	}
+	for first, msgs := range o.prepared {
+		committed, err := o.resolve(ctx, msgs)
+		if err != nil {
+			return fmt.Errorf("resolving outbox messages %d-%d: %w", first, msgs[len(msgs)-1].GetId(), err)
+		}
+		if committed {
+			err = o.commitLocked(first)
+		} else {
+			err = o.abortLocked(first)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replay loads the records of f and returns the length of its valid part; a
+// torn or corrupt record ends the log, which is truncated there.
+func (o *Outbox) replay(f *os.File) (int64, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	o.pending, o.prepared = nil, make(map[uint64][]*storagepb.OutboxMessage)
+	off := 0
+	for off < len(data) {
+		rec := &storagepb.OutboxRecord{}
+		n, err := wal.Decode(data[off:], rec)
+		if err != nil {
+			break
+		}
+		if start := rec.GetStart(); start != nil {
+			o.logID, o.nextID = start.GetLogId(), start.GetNextId()
+		} else if off == 0 {
+			return 0, fmt.Errorf("%s: the log does not begin with a start record", o.path)
+		}
+		o.apply(rec)
+		off += n
+	}
+	o.stats = Stats{}
+	if off < len(data) {
+		if err := f.Truncate(int64(off)); err != nil {
+			return 0, err
+		}
+	}
+	return int64(off), nil
+}
+
+// apply updates the in-memory state with a record. Callers must hold o.mu.
+func (o *Outbox) apply(rec *storagepb.OutboxRecord) {
+	switch e := rec.GetEntry().(type) {
+	case *storagepb.OutboxRecord_Prepared:
+		msgs := e.Prepared.GetMessages()
+		if len(msgs) == 0 {
+			return
+		}
+		o.prepared[msgs[0].GetId()] = msgs
+		o.nextID = max(o.nextID, msgs[len(msgs)-1].GetId()+1)
+	case *storagepb.OutboxRecord_Committed:
+		o.pending = append(o.pending, o.prepared[e.Committed]...)
+		delete(o.prepared, e.Committed)
+		close(o.changed)
+		o.changed = make(chan struct{})
+	case *storagepb.OutboxRecord_Aborted:
+		delete(o.prepared, e.Aborted)
+	case *storagepb.OutboxRecord_Delivered:
+		if o.remove(e.Delivered) {
+			o.stats.Delivered++
+		}
+	case *storagepb.OutboxRecord_DeadLettered:
+		if o.remove(e.DeadLettered) {
+			o.stats.DeadLettered++
+		}
+	case *storagepb.OutboxRecord_Failed:
+		for _, m := range o.pending {
+			if m.GetId() == e.Failed.GetId() {
+				m.Attempts, m.NextAttempt, m.LastError = e.Failed.GetAttempts(), e.Failed.GetNextAttempt(), e.Failed.GetError()
+				o.stats.Failures++
+				break
+			}
+		}
+	}
+}
+
+// remove removes the pending message with the given ID and reports whether
+// there was one. Callers must hold o.mu.
+func (o *Outbox) remove(id uint64) bool {
+	for i, m := range o.pending {
+		if m.GetId() == id {
+			o.pending = append(o.pending[:i:i], o.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// resolve reports whether the backend holds the products msgs describe, that
+// is, whether their write committed.
+func (o *Outbox) resolve(ctx context.Context, msgs []*storagepb.OutboxMessage) (bool, error) {
+	if o.backend == nil {
+		return false, nil
+	}
+	for _, m := range msgs {
+		got, err := o.backend.Get(ctx, m.GetProductId())
+		if errors.Is(err, repository.ErrNotFound) {
+			got, err = nil, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !proto.Equal(got, m.GetProduct()) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Close closes the log.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+// append logs rec, if the outbox has a log, and applies it. Callers must
+// hold o.mu.
+func (o *Outbox) append(rec *storagepb.OutboxRecord) error {
+	if o.path != "" {
+		if o.failed != nil {
+			return o.failed
+		}
+		if o.file == nil {
+			return ErrClosed
+		}
+		frame, err := wal.Encode(rec)
+		if err == nil {
+			_, err = o.file.Write(frame)
+		}
+		if err == nil {
+			err = o.file.Sync()
+		}
+		if err != nil {
+			o.failed = fmt.Errorf("outbox: %w", err)
+			return o.failed
+		}
+		o.size += int64(len(frame))
+	}
+	o.apply(rec)
+	return nil
+}
+
+// prepare logs msgs as the messages of a write that has not committed yet,
+// numbering them, and returns the ID of the first.
+func (o *Outbox) prepare(msgs []*storagepb.OutboxMessage) (uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	first := o.nextID
+	for i, m := range msgs {
+		m.Id = first + uint64(i)
+	}
+	if err := o.append(&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Prepared{Prepared: &storagepb.OutboxBatch{Messages: msgs}}}); err != nil {
+		return 0, err
+	}
+	return first, nil
+}
+
+// commit makes the prepared messages starting at first pending. They become
+// pending even if that cannot be logged: their write has committed. On the
+// next Open they are resolved again.
+func (o *Outbox) commit(first uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.commitLocked(first) != nil {
+		o.apply(&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Committed{Committed: first}})
+	}
+}
+
+func (o *Outbox) commitLocked(first uint64) error {
+	return o.append(&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Committed{Committed: first}})
+}
+
+// abort drops the prepared messages starting at first.
+func (o *Outbox) abort(first uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.abortLocked(first) != nil {
+		o.apply(&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Aborted{Aborted: first}})
+	}
+}
+
+func (o *Outbox) abortLocked(first uint64) error {
+	return o.append(&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Aborted{Aborted: first}})
+}
+
+// next returns a copy of the oldest pending message, or nil if there is
+// none, and a channel closed when messages are next committed.
+func (o *Outbox) next() (*storagepb.OutboxMessage, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) == 0 {
+		return nil, o.changed
+	}
+	return proto.Clone(o.pending[0]).(*storagepb.OutboxMessage), o.changed
+}
+
+// delivered removes a delivered message.
+func (o *Outbox) delivered(id uint64) error {
+	return o.done(&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Delivered{Delivered: id}})
+}
+
+// deadLettered removes a message handed to the dead-letter publisher.
+func (o *Outbox) deadLettered(id uint64) error {
+	return o.done(&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_DeadLettered{DeadLettered: id}})
+}
+
+// done logs that a message is no longer pending and truncates the log if no
+// message is left in it, or compacts it if it has grown too large.
+func (o *Outbox) done(rec *storagepb.OutboxRecord) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.append(rec); err != nil {
+		return err
+	}
+	if o.file == nil {
+		return nil
+	}
+	if len(o.pending) > 0 || len(o.prepared) > 0 {
+		return o.compactIfLarge()
+	}
+	err := o.file.Truncate(0)
+	if err == nil {
+		_, err = o.file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		o.failed = fmt.Errorf("outbox: %w", err)
+		return o.failed
+	}
+	o.size = 0
+	return o.append(o.start())
+}
+
+// start returns the record a log begins with.
+func (o *Outbox) start() *storagepb.OutboxRecord {
+	return &storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Start{Start: &storagepb.OutboxStart{LogId: o.logID, NextId: o.nextID}}}
+}
+
+// compactIfLarge compacts the log if it has grown past o.compactAt. Callers
+// must hold o.mu.
+func (o *Outbox) compactIfLarge() error {
+	if o.file == nil || o.size < o.compactAt {
+		return nil
+	}
+	return o.compact()
+}
+
+// compact replaces the log with one that holds only a start record and the
+// live messages: the pending ones, as one committed batch that keeps their
+// attempts, and the prepared ones. The new log is renamed over the old, so a
+// crash leaves one or the other. Callers must hold o.mu.
+func (o *Outbox) compact() error {
+	recs := []*storagepb.OutboxRecord{o.start()}
+	if len(o.pending) > 0 {
+		recs = append(recs,
+			&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Prepared{Prepared: &storagepb.OutboxBatch{Messages: o.pending}}},
+			&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Committed{Committed: o.pending[0].GetId()}})
+	}
+	for _, first := range slices.Sorted(maps.Keys(o.prepared)) {
+		recs = append(recs, &storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Prepared{Prepared: &storagepb.OutboxBatch{Messages: o.prepared[first]}}})
+	}
+	var data []byte
+	for _, rec := range recs {
+		frame, err := wal.Encode(rec)
+		if err != nil {
+			return err
+		}
+		data = append(data, frame...)
+	}
+	tmp := o.path + ".tmp"
+	err := wal.WriteFile(tmp, data)
+	if err == nil {
+		err = os.Rename(tmp, o.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		o.failed = fmt.Errorf("outbox: compacting the log: %w", err)
+		return o.failed
+	}
+	f, err := os.OpenFile(o.path, os.O_RDWR, 0o600)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekEnd)
+	}
+	if err == nil {
+		err = wal.SyncDir(filepath.Dir(o.path))
+	}
+	if err != nil {
+		if f != nil {
+			f.Close()
+		}
+		o.failed = fmt.Errorf("outbox: compacting the log: %w", err)
+		return o.failed
+	}
+	o.file.Close()
+	o.file, o.size = f, int64(len(data))
+	o.compactAt = max(compactSize, 2*o.size)
+	return nil
+}
+
+// retry records a failed delivery of a message, to be attempted again at
+// next.
+func (o *Outbox) retry(id uint64, attempts uint32, next time.Time, cause error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	err := o.append(&storagepb.OutboxRecord{Entry: &storagepb.OutboxRecord_Failed{Failed: &storagepb.OutboxAttempt{
+		Id:          id,
+		Attempts:    attempts,
+		NextAttempt: timestamppb.New(next),
+		Error:       cause.Error(),
+	}}})
+	if err != nil {
+		return err
+	}
+	return o.compactIfLarge()
+}
+
+// recorder is the repository returned by Outbox.Wrap.
+type recorder struct {
+	outbox  *Outbox
+	backend repository.ProductRepository
+}
+
+func (r *recorder) Get(ctx context.Context, id string) (*product.Product, error) {
+	return r.backend.Get(ctx, id)
+}
+
+func (r *recorder) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	return r.backend.List(ctx, cursor, limit)
+}
+
+// Query runs q on the backend, using its indexes if it has any.
+func (r *recorder) Query(ctx context.Context, q repository.Query, fn func(*product.Product) bool) (repository.Plan, error) {
+	return repository.Select(ctx, r.backend, q, fn)
+}
+
+// Unwrap returns the backend, which reads can go to directly.
+func (r *recorder) Unwrap() repository.ProductRepository {
+	return r.backend
+}
+
+func (r *recorder) Put(ctx context.Context, p *product.Product) error {
+	return r.Batch(ctx, func(tx repository.Tx) error { return tx.Put(ctx, p) })
+}
+
+func (r *recorder) Delete(ctx context.Context, id string) error {
+	return r.Batch(ctx, func(tx repository.Tx) error { return tx.Delete(ctx, id) })
+}
+
+// Batch runs fn in a backend transaction and prepares a message for every
+// product it changes before the transaction commits; the messages are then
+// committed or aborted with it.
+func (r *recorder) Batch(ctx context.Context, fn func(tx repository.Tx) error) error {
+	o := r.outbox
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+	var first uint64
+	err := r.backend.Batch(ctx, func(tx repository.Tx) error {
+		// The backend may run fn more than once.
+		if first != 0 {
+			o.abort(first)
+			first = 0
+		}
+		rtx := &recordingTx{Tx: tx, before: make(map[string]*product.Product), after: make(map[string]*product.Product)}
+		if err := fn(rtx); err != nil {
+			return err
+		}
+		msgs := rtx.messages(o.now())
+		if len(msgs) == 0 {
+			return nil
+		}
+		var err error
+		first, err = o.prepare(msgs)
+		return err
+	})
+	if first != 0 {
+		if err != nil {
+			o.abort(first)
+		} else {
+			o.commit(first)
+		}
+	}
+	return err
+}
+
+func (r *recorder) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	return r.backend.AttributeDefinitions(ctx)
+}
+
+// PutAttributeDefinition puts def in the backend. Definitions are not domain
+// events of the catalog, so no message is published.
+func (r *recorder) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	return r.backend.PutAttributeDefinition(ctx, def)
+}
+
+// recordingTx collects the products a backend transaction changes, as they
+// were before it and as they are after.
+type recordingTx struct {
+	repository.Tx
+	order         []string
+	before, after map[string]*product.Product
+}
+
+// touch records the product with the given ID as it was before the
+// transaction, the first time the transaction writes it.
+func (tx *recordingTx) touch(ctx context.Context, id string) error {
+	if _, ok := tx.before[id]; ok {
+		return nil
+	}
+	p, err := tx.Tx.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		p, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	tx.order = append(tx.order, id)
+	tx.before[id] = p
+	return nil
+}
+
+func (tx *recordingTx) Put(ctx context.Context, p *product.Product) error {
+	if err := tx.touch(ctx, p.GetId()); err != nil {
+		return err
+	}
+	if err := tx.Tx.Put(ctx, p); err != nil {
+		return err
+	}
+	tx.after[p.GetId()] = proto.Clone(p).(*product.Product)
+	return nil
+}
+
+func (tx *recordingTx) Create(ctx context.Context, p *product.Product) error {
+	if err := tx.touch(ctx, p.GetId()); err != nil {
+		return err
+	}
+	if err := tx.Tx.Create(ctx, p); err != nil {
+		return err
+	}
+	tx.after[p.GetId()] = proto.Clone(p).(*product.Product)
+	return nil
+}
+
+func (tx *recordingTx) Delete(ctx context.Context, id string) error {
+	if err := tx.touch(ctx, id); err != nil {
+		return err
+	}
+	if err := tx.Tx.Delete(ctx, id); err != nil {
+		return err
+	}
+	tx.after[id] = nil
+	return nil
+}
+
+// messages returns a message for every product the transaction changed, in
+// the order they were first written.
+func (tx *recordingTx) messages(now time.Time) []*storagepb.OutboxMessage {
+	var msgs []*storagepb.OutboxMessage
+	for _, id := range tx.order {
+		before, after := tx.before[id], tx.after[id]
+		m := &storagepb.OutboxMessage{ProductId: id, Time: timestamppb.New(now), Product: after, Previous: before}
+		switch {
+		case proto.Equal(before, after):
+			continue
+		case before == nil:
+			m.Type = TypeCreated
+		case after == nil:
+			m.Type = TypeDeleted
+		default:
+			m.Type = TypeUpdated
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
@@ -0,0 +1,210 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/repository"
+)
+
+// drain removes every pending message of o as delivered and returns them as
+// "type id".
+func drain(t *testing.T, o *Outbox) []string {
+	t.Helper()
+	var got []string
+	for {
+		m, _ := o.next()
+		if m == nil {
+			return got
+		}
+		got = append(got, m.GetType()+" "+m.GetProductId())
+		if err := o.delivered(m.GetId()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOutbox_RecordsCommittedChanges(t *testing.T) {
+	ctx := context.Background()
+	o := New("")
+	repo := o.Wrap(repository.NewMemory())
+	repo.Put(ctx, &product.Product{Id: "a", Name: "A"})
+	repo.Put(ctx, &product.Product{Id: "a", Name: "A"})
+	repo.Put(ctx, &product.Product{Id: "a", Name: "A2"})
+	if err := repo.Batch(ctx, func(tx repository.Tx) error {
+		tx.Put(ctx, &product.Product{Id: "b", Name: "B"})
+		tx.Put(ctx, &product.Product{Id: "c", Name: "C"})
+		tx.Delete(ctx, "c")
+		return tx.Delete(ctx, "a")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	repo.Batch(ctx, func(tx repository.Tx) error {
+		tx.Put(ctx, &product.Product{Id: "d"})
+		return errors.New("boom")
+	})
+	if err := repo.Delete(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Delete of a missing product: %v", err)
+	}
+
+	m, _ := o.next()
+	if m.GetId() != 1 || m.GetProduct().GetName() != "A" || m.GetPrevious() != nil {
+		t.Fatalf("first message %v", m)
+	}
+	want := []string{"created a", "updated a", "created b", "deleted a"}
+	if got := drain(t, o); !slices.Equal(got, want) {
+		t.Fatalf("messages %v, want %v", got, want)
+	}
+	if s := o.Stats(); s.Delivered != 4 || s.Pending != 0 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestOutbox_SurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox", "outbox.log")
+	backend := repository.NewMemory()
+	open := func() (*Outbox, repository.ProductRepository) {
+		o := New(path)
+		repo := o.Wrap(backend)
+		if err := o.Open(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return o, repo
+	}
+	o, repo := open()
+	logID := o.LogID()
+	for i := range 3 {
+		repo.Put(ctx, &product.Product{Id: fmt.Sprint(i)})
+	}
+	m, _ := o.next()
+	o.delivered(m.GetId())
+	m, _ = o.next()
+	o.retry(m.GetId(), 1, o.now(), errors.New("unavailable"))
+	o.Close()
+
+	o, repo = open()
+	defer o.Close()
+	if o.LogID() != logID {
+		t.Fatalf("log ID %q after a restart, was %q", o.LogID(), logID)
+	}
+	if m, _ := o.next(); m.GetId() != 2 || m.GetAttempts() != 1 || m.GetLastError() != "unavailable" {
+		t.Fatalf("first pending message after a restart: %v", m)
+	}
+	if got := drain(t, o); !slices.Equal(got, []string{"created 1", "created 2"}) {
+		t.Fatalf("pending after a restart: %v", got)
+	}
+	// The log was truncated when it emptied; IDs go on where they were.
+	repo.Put(ctx, &product.Product{Id: "3"})
+	o.Close()
+	o, _ = open()
+	defer o.Close()
+	if m, _ := o.next(); m.GetId() != 4 || o.LogID() != logID {
+		t.Fatalf("message after truncation: %v in log %q", m, o.LogID())
+	}
+}
+
+func TestOutbox_CompactsItsLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	backend := repository.NewMemory()
+	o := New(path)
+	repo := o.Wrap(backend)
+	if err := o.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		repo.Put(ctx, &product.Product{Id: fmt.Sprint(i)})
+	}
+	m, _ := o.next()
+	o.delivered(m.GetId())
+	m, _ = o.next()
+	for attempt := range uint32(50) {
+		if err := o.retry(m.GetId(), attempt+1, o.now(), errors.New("unavailable")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := o.size
+	o.compactAt = before
+	if err := o.retry(m.GetId(), 51, o.now(), errors.New("still unavailable")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != o.size || o.size >= before/4 {
+		t.Fatalf("log is %d bytes after compaction (tracked %d), was %d", info.Size(), o.size, before)
+	}
+	// Writes go on to the compacted log.
+	repo.Put(ctx, &product.Product{Id: "3"})
+	o.Close()
+
+	o = New(path)
+	o.Wrap(backend)
+	if err := o.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if m, _ := o.next(); m.GetId() != 2 || m.GetAttempts() != 51 || m.GetLastError() != "still unavailable" {
+		t.Fatalf("first pending message after compaction: %v", m)
+	}
+	if got := drain(t, o); !slices.Equal(got, []string{"created 1", "created 2", "created 3"}) {
+		t.Fatalf("pending after compaction: %v", got)
+	}
+}
+
+func TestOutbox_ResolvesWritesInterruptedByACrash(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	backend := repository.NewMemory()
+	o := New(path)
+	o.Wrap(backend)
+	if err := o.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// A crash after the messages of a write were prepared; the first write
+	// reached the repository, the second did not.
+	p := &product.Product{Id: "a", Name: "A"}
+	o.prepare([]*storagepb.OutboxMessage{{Type: TypeCreated, ProductId: "a", Product: p}})
+	backend.Put(ctx, p)
+	o.Close()
+
+	o = New(path)
+	o.Wrap(backend)
+	if err := o.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	o.prepare([]*storagepb.OutboxMessage{{Type: TypeCreated, ProductId: "b", Product: &product.Product{Id: "b"}}})
+	o.Close()
+
+	o = New(path)
+	o.Wrap(backend)
+	if err := o.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if got := drain(t, o); !slices.Equal(got, []string{"created a"}) {
+		t.Fatalf("pending after recovery: %v", got)
+	}
+}
+
+func TestOutbox_WritesFailWithoutTheLog(t *testing.T) {
+	ctx := context.Background()
+	backend := repository.NewMemory()
+	o := New(filepath.Join(t.TempDir(), "outbox.log"))
+	repo := o.Wrap(backend)
+	if err := repo.Put(ctx, &product.Product{Id: "a"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Put before Open: %v, want ErrClosed", err)
+	}
+	if _, err := backend.Get(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("write without its message reached the repository: %v", err)
+	}
+}
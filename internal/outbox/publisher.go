@@ -0,0 +1,199 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// DefaultSource is the CloudEvents source of the events of the Product API.
+const DefaultSource = "/product-api"
+
+// TypePrefix is prepended to a message's type to form the CloudEvents type,
+// e.g. "product.v1.created".
+const TypePrefix = "product.v1."
+
+// Event is a message in the structured JSON format of CloudEvents 1.0. Data
+// holds the product after the change ("product") and before it ("previous"),
+// in the Product API's JSON form.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	// DeadLetterReason and DeliveryAttempts are extension attributes set on
+	// dead-lettered events.
+	DeadLetterReason string `json:"deadletterreason,omitempty"`
+	DeliveryAttempts uint32 `json:"deliveryattempts,omitempty"`
+}
+
+// NewEvent converts a message of the outbox with the given log ID into an
+// Event from source. Its ID is unique for the log ID and message.
+func NewEvent(source, logID string, m *storagepb.OutboxMessage) (*Event, error) {
+	data := make(map[string]json.RawMessage)
+	if p := m.GetProduct(); p != nil {
+		b, err := protojson.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		data["product"] = b
+	}
+	if p := m.GetPrevious(); p != nil {
+		b, err := protojson.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		data["previous"] = b
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		SpecVersion:     "1.0",
+		ID:              eventID(logID, m),
+		Source:          source,
+		Type:            TypePrefix + m.GetType(),
+		Subject:         m.GetProductId(),
+		Time:            m.GetTime().AsTime(),
+		DataContentType: "application/json",
+		Data:            b,
+	}, nil
+}
+
+func eventID(logID string, m *storagepb.OutboxMessage) string {
+	return fmt.Sprintf("%s-%d", logID, m.GetId())
+}
+
+// Publisher delivers events. The relay calls Publish for one event at a time
+// and retries it if it fails; an error wrapped with Permanent is not retried.
+type Publisher interface {
+	Publish(ctx context.Context, e *Event) error
+}
+
+// permanentError is an error that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one that retrying the delivery cannot fix, so that
+// the event is dead-lettered at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked by Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// WriterPublisher writes each event as a line of JSON, to standard output or
+// a file, say.
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+	f  *os.File // the file written to, if OpenFilePublisher opened it
+}
+
+// NewWriterPublisher creates a WriterPublisher that writes to w.
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// OpenFilePublisher opens (creating if needed) the file at path for
+// appending events to. Every event is fsynced before Publish returns.
+func OpenFilePublisher(path string) (*WriterPublisher, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterPublisher{w: f, f: f}, nil
+}
+
+// Publish implements Publisher.
+func (p *WriterPublisher) Publish(ctx context.Context, e *Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return Permanent(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if p.f != nil {
+		return p.f.Sync()
+	}
+	return nil
+}
+
+// Close closes the file opened by OpenFilePublisher, if any.
+func (p *WriterPublisher) Close() error {
+	if p.f == nil {
+		return nil
+	}
+	return p.f.Close()
+}
+
+// Webhook POSTs each event to a URL as application/cloudevents+json. A 2xx
+// response is a delivery; 4xx responses other than 408 and 429 are
+// permanent failures.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a Webhook posting to url with client, or
+// http.DefaultClient if client is nil.
+func NewWebhook(url string, client *http.Client) *Webhook {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Webhook{url: url, client: client}
+}
+
+// Publish implements Publisher.
+func (w *Webhook) Publish(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
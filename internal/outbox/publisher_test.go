@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func testEvent(t *testing.T) *Event {
+	t.Helper()
+	e, err := NewEvent(DefaultSource, "log1", &storagepb.OutboxMessage{
+		Id:        7,
+		Type:      TypeUpdated,
+		ProductId: "lamp",
+		Time:      timestamppb.New(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+		Product:   &product.Product{Id: "lamp", Name: "Desk lamp", Price: 12},
+		Previous:  &product.Product{Id: "lamp", Name: "Lamp", Price: 12},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestNewEvent_IsACloudEvent(t *testing.T) {
+	b, err := json.Marshal(testEvent(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	json.Unmarshal(b, &got)
+	for attr, want := range map[string]any{
+		"specversion":     "1.0",
+		"id":              "log1-7",
+		"source":          "/product-api",
+		"type":            "product.v1.updated",
+		"subject":         "lamp",
+		"time":            "2024-05-01T12:00:00Z",
+		"datacontenttype": "application/json",
+	} {
+		if got[attr] != want {
+			t.Errorf("%s = %v, want %v", attr, got[attr], want)
+		}
+	}
+	data, _ := got["data"].(map[string]any)
+	if p, _ := data["product"].(map[string]any); p["name"] != "Desk lamp" {
+		t.Errorf("data.product = %v", data["product"])
+	}
+	if p, _ := data["previous"].(map[string]any); p["name"] != "Lamp" {
+		t.Errorf("data.previous = %v", data["previous"])
+	}
+	if _, ok := got["deadletterreason"]; ok {
+		t.Error("deadletterreason set on an event that was not dead-lettered")
+	}
+}
+
+func TestFilePublisher_AppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	for range 2 {
+		p, err := OpenFilePublisher(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Publish(context.Background(), testEvent(t)); err != nil {
+			t.Fatal(err)
+		}
+		p.Close()
+	}
+	f, _ := os.Open(path)
+	defer f.Close()
+	lines := 0
+	for s := bufio.NewScanner(f); s.Scan(); lines++ {
+		var e Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil || e.ID != "log1-7" {
+			t.Fatalf("line %q: %v", s.Text(), err)
+		}
+	}
+	if lines != 2 {
+		t.Fatalf("%d lines, want 2", lines)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	status := http.StatusNoContent
+	var contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		contentType, body = r.Header.Get("Content-Type"), string(b)
+		w.WriteHeader(status)
+		w.Write([]byte("try later\n"))
+	}))
+	defer srv.Close()
+	w := NewWebhook(srv.URL, nil)
+	ctx := context.Background()
+
+	if err := w.Publish(ctx, testEvent(t)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(contentType, "application/cloudevents+json") || !strings.Contains(body, `"id":"log1-7"`) {
+		t.Fatalf("posted %q as %q", body, contentType)
+	}
+	for code, permanent := range map[int]bool{400: true, 410: true, 408: false, 429: false, 503: false} {
+		status = code
+		err := w.Publish(ctx, testEvent(t))
+		if err == nil || IsPermanent(err) != permanent {
+			t.Errorf("status %d: error %v, permanent %v", code, err, IsPermanent(err))
+		}
+	}
+	srv.Close()
+	if err := w.Publish(ctx, testEvent(t)); err == nil || IsPermanent(err) {
+		t.Errorf("unreachable webhook: %v", err)
+	}
+}
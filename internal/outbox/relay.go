@@ -0,0 +1,148 @@
+package outbox
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+)
+
+// Relay defaults.
+const (
+	DefaultMaxAttempts    = 10
+	DefaultMinBackoff     = time.Second
+	DefaultMaxBackoff     = 5 * time.Minute
+	DefaultPublishTimeout = 10 * time.Second
+)
+
+// RelayOptions configures a Relay. Zero values select the defaults.
+type RelayOptions struct {
+	// Source is the CloudEvents source of the events; DefaultSource if empty.
+	Source string
+	// MaxAttempts is the number of deliveries tried before an event is
+	// dead-lettered.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry; each later retry
+	// waits twice as long as the one before, up to MaxBackoff, less a random
+	// part of up to half of that.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// PublishTimeout bounds each call to Publish.
+	PublishTimeout time.Duration
+}
+
+// Relay delivers the messages of an Outbox as Events, one at a time in ID
+// order, so each consumer sees a product's changes in the order they were
+// made. A message is removed from the outbox only after its publisher
+// accepted it, so a message may be delivered again after a crash or a
+// failure to record its delivery: consumers should deduplicate by event ID.
+// A message that keeps failing holds back the ones after it until it is
+// dead-lettered: after MaxAttempts deliveries, or at once for a Permanent
+// error, it is handed to the dead-letter publisher instead.
+type Relay struct {
+	outbox      *Outbox
+	publisher   Publisher
+	deadLetters Publisher
+	opts        RelayOptions
+	now         func() time.Time
+}
+
+// NewRelay creates a Relay of o's messages to publisher that dead-letters
+// to deadLetters.
+func NewRelay(o *Outbox, publisher, deadLetters Publisher, opts RelayOptions) *Relay {
+	if opts.Source == "" {
+		opts.Source = DefaultSource
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.PublishTimeout <= 0 {
+		opts.PublishTimeout = DefaultPublishTimeout
+	}
+	return &Relay{outbox: o, publisher: publisher, deadLetters: deadLetters, opts: opts, now: time.Now}
+}
+
+// Run delivers messages until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		m, changed := r.outbox.next()
+		if m == nil {
+			select {
+			case <-ctx.Done():
+			case <-changed:
+			}
+			continue
+		}
+		if wait := m.GetNextAttempt().AsTime().Sub(r.now()); m.GetNextAttempt() != nil && wait > 0 {
+			r.sleep(ctx, wait)
+			continue
+		}
+		if err := r.deliver(ctx, m); err != nil {
+			// The outbox could not record the outcome, and every later
+			// write fails; try again later.
+			r.sleep(ctx, r.opts.MaxBackoff)
+		}
+	}
+}
+
+func (r *Relay) sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// deliver publishes m once and records the outcome in the outbox.
+func (r *Relay) deliver(ctx context.Context, m *storagepb.OutboxMessage) error {
+	e, err := NewEvent(r.opts.Source, r.outbox.LogID(), m)
+	if err == nil {
+		pctx, cancel := context.WithTimeout(ctx, r.opts.PublishTimeout)
+		err = r.publisher.Publish(pctx, e)
+		cancel()
+		if err == nil {
+			return r.outbox.delivered(m.GetId())
+		}
+	} else {
+		err = Permanent(err)
+	}
+	if ctx.Err() != nil {
+		// Stopping; the message is delivered after the next start.
+		return nil
+	}
+	attempts := m.GetAttempts() + 1
+	if !IsPermanent(err) && int(attempts) < r.opts.MaxAttempts {
+		return r.outbox.retry(m.GetId(), attempts, r.now().Add(r.backoff(attempts)), err)
+	}
+	if e == nil {
+		// The message could not be converted; dead-letter its attributes.
+		e = &Event{SpecVersion: "1.0", ID: eventID(r.outbox.LogID(), m), Source: r.opts.Source, Type: TypePrefix + m.GetType(), Subject: m.GetProductId(), Time: m.GetTime().AsTime()}
+	}
+	e.DeadLetterReason, e.DeliveryAttempts = err.Error(), attempts
+	pctx, cancel := context.WithTimeout(ctx, r.opts.PublishTimeout)
+	defer cancel()
+	if derr := r.deadLetters.Publish(pctx, e); derr != nil {
+		// Keep the message, and try it again later.
+		return r.outbox.retry(m.GetId(), attempts, r.now().Add(r.opts.MaxBackoff), derr)
+	}
+	return r.outbox.deadLettered(m.GetId())
+}
+
+// backoff returns the delay before the delivery after the given number of
+// failed ones.
+func (r *Relay) backoff(attempts uint32) time.Duration {
+	d := r.opts.MinBackoff
+	for i := uint32(1); i < attempts && d < r.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, r.opts.MaxBackoff)
+	return d - rand.N(d/2+1)
+}
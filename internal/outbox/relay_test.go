@@ -0,0 +1,138 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/repository"
+)
+
+// publisherFunc adapts a function to Publisher.
+type publisherFunc func(e *Event) error
+
+func (f publisherFunc) Publish(ctx context.Context, e *Event) error { return f(e) }
+
+// collector is a Publisher that keeps the subjects of the events it accepts.
+type collector struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (c *collector) Publish(ctx context.Context, e *Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, e)
+	return nil
+}
+
+func (c *collector) subjects() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var s []string
+	for _, e := range c.events {
+		s = append(s, e.Subject)
+	}
+	return s
+}
+
+// runRelay runs r until the outbox is empty and returns its stats.
+func runRelay(t *testing.T, r *Relay) Stats {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for r.outbox.Stats().Pending > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("messages still pending: %+v", r.outbox.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return r.outbox.Stats()
+}
+
+func TestRelay_RetriesAndDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	o := New("")
+	repo := o.Wrap(repository.NewMemory())
+	for _, id := range []string{"flaky", "rejected", "down", "ok"} {
+		repo.Put(ctx, &product.Product{Id: id})
+	}
+	var mu sync.Mutex
+	tries := make(map[string]int)
+	delivered := &collector{}
+	publisher := publisherFunc(func(e *Event) error {
+		mu.Lock()
+		tries[e.Subject]++
+		n := tries[e.Subject]
+		mu.Unlock()
+		switch {
+		case e.Subject == "flaky" && n < 3:
+			return errors.New("unavailable")
+		case e.Subject == "rejected":
+			return Permanent(errors.New("bad request"))
+		case e.Subject == "down":
+			return fmt.Errorf("attempt %d failed", n)
+		}
+		return delivered.Publish(ctx, e)
+	})
+	dead := &collector{}
+	r := NewRelay(o, publisher, dead, RelayOptions{MaxAttempts: 4, MinBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond})
+
+	stats := runRelay(t, r)
+	if got := fmt.Sprint(delivered.subjects()); got != "[flaky ok]" {
+		t.Fatalf("delivered %s", got)
+	}
+	if got := fmt.Sprint(dead.subjects()); got != "[rejected down]" {
+		t.Fatalf("dead-lettered %s", got)
+	}
+	if tries["flaky"] != 3 || tries["rejected"] != 1 || tries["down"] != 4 {
+		t.Fatalf("tries %v", tries)
+	}
+	if e := dead.events[1]; e.DeadLetterReason != "attempt 4 failed" || e.DeliveryAttempts != 4 {
+		t.Fatalf("dead letter %+v", e)
+	}
+	if stats.Delivered != 2 || stats.DeadLettered != 2 || stats.Failures != 5 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestRelay_KeepsMessagesTheDeadLetterPublisherRejects(t *testing.T) {
+	ctx := context.Background()
+	o := New("")
+	o.Wrap(repository.NewMemory()).Put(ctx, &product.Product{Id: "a"})
+	var deadTries int
+	r := NewRelay(o,
+		publisherFunc(func(e *Event) error { return Permanent(errors.New("bad request")) }),
+		publisherFunc(func(e *Event) error {
+			if deadTries++; deadTries < 2 {
+				return errors.New("disk full")
+			}
+			return nil
+		}),
+		RelayOptions{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	if stats := runRelay(t, r); stats.DeadLettered != 1 || deadTries != 2 {
+		t.Fatalf("stats %+v after %d dead-letter tries", stats, deadTries)
+	}
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	r := NewRelay(New(""), nil, nil, RelayOptions{MinBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	for attempts, want := range map[uint32]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 10 * time.Second, 100: 10 * time.Second} {
+		if d := r.backoff(attempts); d > want || d < want/2 {
+			t.Errorf("backoff after %d attempts = %v, want between %v and %v", attempts, d, want/2, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package recovery turns panics in gRPC handlers into Internal errors
+// instead of letting them crash the server.
+package recovery
+
+import (
+	"context"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor recovers from a panic in the handler, or in the
+// interceptors after it, logs the panic value and stack to log at error
+// level, and fails the call with Internal.
+func UnaryServerInterceptor(log *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				resp, err = nil, recovered(log, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor(log *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(log, info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs the panic p of method with the stack of the panicking
+// goroutine and returns the error the client gets, which does not reveal p.
+func recovered(log *zap.Logger, method string, p any) error {
+	log.Error("panic in gRPC handler",
+		zap.String("method", method),
+		zap.Any("panic", p),
+		zap.String("stack", string(debug.Stack())),
+	)
+	return status.Errorf(codes.Internal, "internal error in %s", method)
+}
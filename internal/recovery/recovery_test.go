@@ -0,0 +1,52 @@
+package recovery
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	intercept := UnaryServerInterceptor(zap.New(core))
+	info := &grpc.UnaryServerInfo{FullMethod: "/product.v1.ProductService/GetProduct"}
+
+	resp, err := intercept(context.Background(), "req", info, func(ctx context.Context, req any) (any, error) {
+		panic("nil map")
+	})
+	if resp != nil || status.Code(err) != codes.Internal || strings.Contains(err.Error(), "nil map") {
+		t.Fatalf("after a panic: %v, %v", resp, err)
+	}
+	if logs.Len() != 1 {
+		t.Fatalf("logged %d entries, want 1", logs.Len())
+	}
+	fields := logs.All()[0].ContextMap()
+	if fields["method"] != info.FullMethod || fields["panic"] != "nil map" || !strings.Contains(fields["stack"].(string), "recovery_test.go") {
+		t.Fatalf("logged %v, want the method, the panic and its stack", fields)
+	}
+
+	resp, err = intercept(context.Background(), "req", info, func(ctx context.Context, req any) (any, error) {
+		return "resp", nil
+	})
+	if resp != "resp" || err != nil {
+		t.Fatalf("without a panic: %v, %v", resp, err)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	err := StreamServerInterceptor(zap.New(core))(nil, nil, &grpc.StreamServerInfo{FullMethod: "/svc/Watch"}, func(srv any, ss grpc.ServerStream) error {
+		var m map[string]int
+		m["x"]++
+		return nil
+	})
+	if status.Code(err) != codes.Internal || logs.FilterField(zap.String("method", "/svc/Watch")).Len() != 1 {
+		t.Fatalf("error %v, logged %v", err, logs.All())
+	}
+}
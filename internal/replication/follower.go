@@ -0,0 +1,176 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	replicationpb "grpc-go-fx/internal/generated/product/replication"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// minRetryDelay and maxRetryDelay bound the delay before a follower
+	// reconnects to its leader; it doubles after each failed attempt.
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 10 * time.Second
+)
+
+// Applier applies the leader's writes to a follower's catalog. Each call must
+// be atomic.
+type Applier interface {
+	// ApplyMutations applies the writes of one leader record in order.
+	ApplyMutations(ctx context.Context, mutations []*storagepb.Mutation) error
+	// ReplaceCatalog puts the attribute definitions defs and makes the
+	// catalog equal to products.
+	ReplaceCatalog(ctx context.Context, defs []*product.AttributeDefinition, products []*product.Product) error
+}
+
+// Follower streams the log of a leader and applies it through an Applier,
+// reconnecting with backoff when the stream breaks.
+type Follower struct {
+	client replicationpb.ReplicationServiceClient
+	conn   grpc.ClientConnInterface
+	leader string
+	name   string
+	apply  Applier
+
+	mu sync.Mutex
+	// logID and applied are the position of the last write applied.
+	logID   string
+	applied uint64
+	// leaderLog and leaderSequence are the leader's position as last heard.
+	leaderLog      string
+	leaderSequence uint64
+	connected      bool
+	lastContact    time.Time
+	snapshots      int64
+	// lastError is why the last stream ended.
+	lastError error
+}
+
+// NewFollower creates a Follower of the leader at address leader, reached
+// over conn, that identifies itself to the leader as name.
+func NewFollower(conn grpc.ClientConnInterface, leader, name string, apply Applier) *Follower {
+	return &Follower{
+		client: replicationpb.NewReplicationServiceClient(conn),
+		conn:   conn,
+		leader: leader,
+		name:   name,
+		apply:  apply,
+	}
+}
+
+// Run follows the leader until ctx is done.
+func (f *Follower) Run(ctx context.Context) {
+	delay := minRetryDelay
+	for ctx.Err() == nil {
+		progressed, err := f.follow(ctx)
+		f.mu.Lock()
+		f.connected = false
+		f.lastError = err
+		f.mu.Unlock()
+		if progressed {
+			delay = minRetryDelay
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+// follow streams from the leader until the stream or an apply fails. It
+// reports whether any message was received.
+func (f *Follower) follow(ctx context.Context) (progressed bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	f.mu.Lock()
+	req := &replicationpb.StreamChangesRequest{LogId: f.logID, AfterSequence: f.applied, Follower: f.name}
+	f.mu.Unlock()
+	stream, err := f.client.StreamChanges(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	var (
+		pendingDefs []*product.AttributeDefinition
+		pending     []*product.Product
+	)
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return progressed, err
+		}
+		progressed = true
+		f.mu.Lock()
+		f.connected = true
+		f.lastContact = time.Now()
+		f.leaderLog = msg.GetLogId()
+		f.leaderSequence = msg.GetLeaderSequence()
+		logID, applied := f.logID, f.applied
+		f.mu.Unlock()
+
+		switch p := msg.GetPayload().(type) {
+		case *replicationpb.ReplicationMessage_Snapshot:
+			pendingDefs = append(pendingDefs, p.Snapshot.GetAttributeDefinitions()...)
+			pending = append(pending, p.Snapshot.GetProducts()...)
+			if !p.Snapshot.GetLast() {
+				continue
+			}
+			if err := f.apply.ReplaceCatalog(ctx, pendingDefs, pending); err != nil {
+				return progressed, fmt.Errorf("loading snapshot: %w", err)
+			}
+			pendingDefs, pending = nil, nil
+			f.mu.Lock()
+			f.logID, f.applied = msg.GetLogId(), p.Snapshot.GetSequence()
+			f.snapshots++
+			f.mu.Unlock()
+		case *replicationpb.ReplicationMessage_Record:
+			seq := p.Record.GetSequence()
+			if msg.GetLogId() != logID || seq != applied+1 {
+				return progressed, fmt.Errorf("leader sent record %s/%d after %s/%d", msg.GetLogId(), seq, logID, applied)
+			}
+			if err := f.apply.ApplyMutations(ctx, p.Record.GetMutations()); err != nil {
+				return progressed, fmt.Errorf("applying record %d: %w", seq, err)
+			}
+			f.mu.Lock()
+			f.applied = seq
+			f.mu.Unlock()
+		}
+	}
+}
+
+// Status reports the position of the follower and of its leader.
+func (f *Follower) Status() *replicationpb.ReplicationStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := &replicationpb.ReplicationStatus{
+		Role:            replicationpb.ReplicationStatus_ROLE_FOLLOWER,
+		LogId:           f.logID,
+		Sequence:        f.applied,
+		LeaderAddress:   f.leader,
+		Connected:       f.connected,
+		LeaderSequence:  f.leaderSequence,
+		SnapshotsLoaded: f.snapshots,
+	}
+	switch {
+	case f.leaderLog == "":
+	case f.leaderLog != f.logID:
+		st.Lag = f.leaderSequence
+	case f.leaderSequence > f.applied:
+		st.Lag = f.leaderSequence - f.applied
+	}
+	if f.lastError != nil {
+		st.LastError = f.lastError.Error()
+	}
+	if !f.lastContact.IsZero() {
+		st.LastContactTime = timestamppb.New(f.lastContact)
+	}
+	return st
+}
@@ -0,0 +1,245 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	replicationpb "grpc-go-fx/internal/generated/product/replication"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// serve serves srv on an in-memory listener and returns a connection to it.
+func serve(t *testing.T, srv *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	replicationpb.RegisterReplicationServiceServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.NewClient("passthrough:///leader",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// memoryApplier applies replicated writes to a repository.Memory.
+type memoryApplier struct {
+	repo *repository.Memory
+}
+
+func (a memoryApplier) ApplyMutations(ctx context.Context, mutations []*storagepb.Mutation) error {
+	for _, m := range mutations {
+		if def := m.GetPutAttributeDefinition(); def != nil {
+			a.repo.PutAttributeDefinition(ctx, def)
+		}
+	}
+	return a.repo.Batch(ctx, func(tx repository.Tx) error {
+		for _, m := range mutations {
+			switch op := m.GetOp().(type) {
+			case *storagepb.Mutation_Put:
+				tx.Put(ctx, op.Put)
+			case *storagepb.Mutation_Delete:
+				tx.Delete(ctx, op.Delete)
+			}
+		}
+		return nil
+	})
+}
+
+func (a memoryApplier) ReplaceCatalog(ctx context.Context, defs []*product.AttributeDefinition, products []*product.Product) error {
+	for _, def := range defs {
+		a.repo.PutAttributeDefinition(ctx, def)
+	}
+	old, _, _ := a.repo.List(ctx, "", 0)
+	return a.repo.Batch(ctx, func(tx repository.Tx) error {
+		for _, p := range old {
+			tx.Delete(ctx, p.GetId())
+		}
+		for _, p := range products {
+			tx.Put(ctx, p)
+		}
+		return nil
+	})
+}
+
+// start runs f until the returned function is called.
+func start(f *Follower) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// sameProducts reports whether a and b hold the same products.
+func sameProducts(t *testing.T, a, b repository.ProductRepository) bool {
+	t.Helper()
+	pa, _, err := a.List(context.Background(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, _, err := b.List(context.Background(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pa) != len(pb) {
+		return false
+	}
+	for i := range pa {
+		if !proto.Equal(pa[i], pb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFollower_StreamsWritesAndCatchesUpFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+	log := NewLog(4)
+	leader := log.Wrap(repository.NewMemory(&product.Product{Id: "a", Name: "A"}, &product.Product{Id: "b", Name: "B"}))
+	srv := NewLeaderServer(log, "", 10*time.Millisecond)
+	local := repository.NewMemory()
+	f := NewFollower(serve(t, srv), "leader:50051", "follower-1", memoryApplier{local})
+	caughtUp := func(snapshots int64) func() bool {
+		return func() bool {
+			st := f.Status()
+			return st.GetSequence() == log.Sequence() && st.GetSnapshotsLoaded() == snapshots && sameProducts(t, leader, local)
+		}
+	}
+
+	stop := start(f)
+	waitFor(t, "the initial snapshot", caughtUp(1))
+	leader.Put(ctx, &product.Product{Id: "c", Name: "C"})
+	leader.Delete(ctx, "a")
+	waitFor(t, "streamed writes", caughtUp(1))
+	waitFor(t, "a heartbeat", func() bool { return f.Status().GetConnected() })
+
+	st := f.Status()
+	if st.GetRole() != replicationpb.ReplicationStatus_ROLE_FOLLOWER || st.GetLogId() != log.ID() || st.GetLag() != 0 ||
+		st.GetLeaderAddress() != "leader:50051" || st.GetLastContactTime() == nil {
+		t.Fatalf("follower status %v", st)
+	}
+	waitFor(t, "the leader to list its follower", func() bool {
+		fs := srv.Status().GetFollowers()
+		return len(fs) == 1 && fs[0].GetFollower() == "follower-1" && fs[0].GetSequence() == 2 && fs[0].GetLag() == 0
+	})
+
+	// A follower that reconnects while the log still holds what it missed
+	// replays it.
+	stop()
+	leader.Put(ctx, &product.Product{Id: "d", Name: "D"})
+	if lag := f.Status().GetLag(); lag != 0 {
+		t.Fatalf("lag %d before the follower heard of the write", lag)
+	}
+	stop = start(f)
+	waitFor(t, "replayed writes", caughtUp(1))
+
+	// One that reconnects after the log dropped them loads a snapshot.
+	stop()
+	for i := range 10 {
+		leader.Put(ctx, &product.Product{Id: "e", Name: "E", Price: float64(i)})
+	}
+	stop = start(f)
+	defer stop()
+	waitFor(t, "a second snapshot", caughtUp(2))
+	if st := f.Status(); st.GetSequence() != 13 || st.GetLag() != 0 {
+		t.Fatalf("follower status %v", st)
+	}
+}
+
+func TestFollower_ReplicatesAttributeDefinitions(t *testing.T) {
+	ctx := context.Background()
+	log := NewLog(0)
+	leader := log.Wrap(repository.NewMemory())
+	leader.PutAttributeDefinition(ctx, &product.AttributeDefinition{Name: "color", Type: product.AttributeType_ATTRIBUTE_TYPE_STRING})
+	local := repository.NewMemory()
+	f := NewFollower(serve(t, NewLeaderServer(log, "", 0)), "leader:50051", "follower-1", memoryApplier{local})
+	names := func() string {
+		defs, _ := local.AttributeDefinitions(ctx)
+		var names []string
+		for _, def := range defs {
+			names = append(names, def.GetName())
+		}
+		return fmt.Sprint(names)
+	}
+
+	stop := start(f)
+	defer stop()
+	waitFor(t, "definitions from the snapshot", func() bool { return names() == "[color]" })
+	leader.PutAttributeDefinition(ctx, &product.AttributeDefinition{Name: "voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER})
+	waitFor(t, "a streamed definition", func() bool { return names() == "[color voltage]" })
+	if st := f.Status(); st.GetSequence() != 2 {
+		t.Fatalf("follower at sequence %d, want 2", st.GetSequence())
+	}
+}
+
+func TestServer_StreamChangesSendsSnapshotForAnotherLog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log := NewLog(0)
+	leader := log.Wrap(repository.NewMemory(&product.Product{Id: "a", Name: "A"}))
+	leader.Put(ctx, &product.Product{Id: "b", Name: "B"})
+	client := replicationpb.NewReplicationServiceClient(serve(t, NewLeaderServer(log, "", 0)))
+
+	stream, err := client.StreamChanges(ctx, &replicationpb.StreamChangesRequest{LogId: "earlier-run", AfterSequence: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := msg.GetSnapshot()
+	if msg.GetLogId() != log.ID() || msg.GetLeaderSequence() != 1 || chunk.GetSequence() != 1 || !chunk.GetLast() || len(chunk.GetProducts()) != 2 {
+		t.Fatalf("first message %v, want a snapshot of 2 products at sequence 1", msg)
+	}
+}
+
+func TestServer_StreamChangesFailsOnNonLeaders(t *testing.T) {
+	client := replicationpb.NewReplicationServiceClient(serve(t, NewStandaloneServer()))
+	stream, err := client.StreamChanges(context.Background(), &replicationpb.StreamChangesRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("streaming from a standalone instance: %v, want FailedPrecondition", err)
+	}
+	st, err := client.GetReplicationStatus(context.Background(), &replicationpb.GetReplicationStatusRequest{})
+	if err != nil || st.GetRole() != replicationpb.ReplicationStatus_ROLE_STANDALONE {
+		t.Fatalf("GetReplicationStatus = %v, %v", st, err)
+	}
+}
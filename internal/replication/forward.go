@@ -0,0 +1,130 @@
+package replication
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"grpc-go-fx/internal/audit"
+	replicationpb "grpc-go-fx/internal/generated/product/replication"
+	"grpc-go-fx/internal/idempotency"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// ForwardedMetadataKey marks a request a follower forwarded to its
+	// leader. A follower rejects forwarded requests instead of forwarding
+	// them again, so a misconfigured pair of followers cannot loop.
+	ForwardedMetadataKey = "replication-forwarded"
+	// ForwardedActorMetadataKey carries the actor of a forwarded request, as
+	// audit.ActorFromContext returned it on the follower. A leader trusts it
+	// only from followers that send its token (see TokenCredentials).
+	ForwardedActorMetadataKey = "replication-forwarded-actor"
+	// TokenMetadataKey carries the token a follower authenticates with.
+	TokenMetadataKey = "replication-token"
+)
+
+// TokenCredentials returns the credentials a follower's connection to its
+// leader sends token with. The token is sent in the clear unless the
+// connection is secure.
+func TokenCredentials(token string) credentials.PerRPCCredentials {
+	return tokenCredentials(token)
+}
+
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{TokenMetadataKey: string(t)}, nil
+}
+
+func (tokenCredentials) RequireTransportSecurity() bool { return false }
+
+// authenticated reports whether the request in ctx carries s's token. No
+// request does if s has none.
+func (s *Server) authenticated(ctx context.Context) bool {
+	if s.token == "" {
+		return false
+	}
+	vals := metadata.ValueFromIncomingContext(ctx, TokenMetadataKey)
+	return len(vals) == 1 && subtle.ConstantTimeCompare([]byte(vals[0]), []byte(s.token)) == 1
+}
+
+// UnaryServerInterceptor makes a follower read-only. Requests to methods not
+// declared NO_SIDE_EFFECTS fail with FAILED_PRECONDITION or, if the server
+// forwards writes, are sent to the leader with the caller's metadata and
+// actor and answered with the leader's response and headers. On other
+// instances, and for ReplicationService methods, requests pass through; a
+// leader attributes the requests its followers forward with its token to the
+// actor they were forwarded for, so they are audited and their idempotency
+// keys scoped as if the caller had sent them to the leader.
+func (s *Server) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if s.follower == nil {
+			if actor := metadata.ValueFromIncomingContext(ctx, ForwardedActorMetadataKey); len(actor) == 1 && s.authenticated(ctx) {
+				ctx = audit.WithActor(ctx, actor[0])
+			}
+			return handler(ctx, req)
+		}
+		if idempotency.ReadOnly(info.FullMethod) ||
+			strings.HasPrefix(info.FullMethod, "/"+replicationpb.ReplicationService_ServiceDesc.ServiceName+"/") {
+			return handler(ctx, req)
+		}
+		if !s.forward || len(metadata.ValueFromIncomingContext(ctx, ForwardedMetadataKey)) > 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "this instance is a read-only follower; send writes to the leader at %s", s.follower.leader)
+		}
+		return s.follower.forward(ctx, info.FullMethod, req)
+	}
+}
+
+// forward invokes method on the leader with req, the metadata of the
+// incoming request and the caller's actor.
+func (f *Follower) forward(ctx context.Context, method string, req any) (any, error) {
+	reply, err := newResponse(method)
+	if err != nil {
+		return nil, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	// The connection's credentials send the token, if any.
+	md.Delete(TokenMetadataKey)
+	md.Set(ForwardedMetadataKey, f.name)
+	md.Set(ForwardedActorMetadataKey, audit.ActorFromContext(ctx))
+	var header metadata.MD
+	err = f.conn.Invoke(metadata.NewOutgoingContext(ctx, md), method, req, reply, grpc.Header(&header))
+	if len(header) > 0 {
+		_ = grpc.SetHeader(ctx, header)
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Unavailable, "forwarding to the leader at %s: %v", f.leader, err)
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// newResponse returns an empty response message of a "/package.Service/Method".
+func newResponse(method string) (proto.Message, error) {
+	name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", 1))
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, status.Errorf(codes.Unimplemented, "cannot forward unknown method %s", method)
+	}
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "cannot forward unknown method %s", method)
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "response type of %s: %v", method, err)
+	}
+	return mt.New().Interface(), nil
+}
@@ -0,0 +1,244 @@
+package replication
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Log numbers the writes committed to a repository and keeps the most recent
+// ones for followers. Writes go through the repository returned by Wrap; each
+// repository call or Batch that changes something, and each
+// PutAttributeDefinition, becomes one record, with the sequence number
+// following the previous record's.
+type Log struct {
+	id   string
+	size int
+	// commit is held for writing while a write is committed and recorded, so
+	// records are in commit order, and for reading while a snapshot is taken.
+	commit  sync.RWMutex
+	backend repository.ProductRepository
+
+	mu sync.Mutex
+	// records holds at least the last size records, oldest first.
+	records []*storagepb.WALRecord
+	seq     uint64
+	// changed is closed and replaced when a record is appended.
+	changed   chan struct{}
+	followers map[*followerState]struct{}
+}
+
+// followerState is a follower streaming from the leader.
+type followerState struct {
+	name, peer  string
+	connectTime time.Time
+	sent        uint64
+}
+
+// NewLog creates a Log that keeps the last size records; zero or less means
+// DefaultLogSize. Its ID is random, so followers of an earlier run of the
+// leader notice that sequence numbers restarted.
+func NewLog(size int) *Log {
+	if size <= 0 {
+		size = DefaultLogSize
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return &Log{
+		id:        hex.EncodeToString(id),
+		size:      size,
+		changed:   make(chan struct{}),
+		followers: make(map[*followerState]struct{}),
+	}
+}
+
+// ID identifies the log.
+func (l *Log) ID() string { return l.id }
+
+// Sequence returns the sequence number of the latest record, zero before the
+// first.
+func (l *Log) Sequence() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+// Wrap returns a repository that stores products in backend and records its
+// writes in l. It must be called once, and every write to backend must go
+// through the returned repository.
+func (l *Log) Wrap(backend repository.ProductRepository) repository.ProductRepository {
+	l.backend = backend
+	return &recorder{log: l, backend: backend}
+}
+
+// append records mutations as the next record.
+func (l *Log) append(mutations []*storagepb.Mutation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	l.records = append(l.records, &storagepb.WALRecord{Sequence: l.seq, Mutations: mutations})
+	if len(l.records) >= 2*l.size {
+		l.records = append([]*storagepb.WALRecord(nil), l.records[len(l.records)-l.size:]...)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the records after sequence after, the latest sequence and a
+// channel closed by the next append. ok is false if the log no longer holds
+// every record after after, or never did.
+func (l *Log) since(after uint64) (records []*storagepb.WALRecord, latest uint64, changed <-chan struct{}, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if after > l.seq {
+		return nil, l.seq, l.changed, false
+	}
+	first := l.seq + 1 - uint64(len(l.records))
+	if after+1 < first {
+		return nil, l.seq, l.changed, false
+	}
+	records = append(records, l.records[after+1-first:]...)
+	return records, l.seq, l.changed, true
+}
+
+// snapshot returns every stored product and attribute definition and the
+// sequence of the last record reflected in them. Writes wait while they are
+// read.
+func (l *Log) snapshot(ctx context.Context) (*storagepb.Snapshot, error) {
+	l.commit.RLock()
+	defer l.commit.RUnlock()
+	defs, err := l.backend.AttributeDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	products, _, err := l.backend.List(ctx, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.Snapshot{Sequence: l.Sequence(), Products: products, AttributeDefinitions: defs}, nil
+}
+
+// addFollower registers a follower for the leader's status until the
+// returned function is called.
+func (l *Log) addFollower(f *followerState) (remove func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.followers[f] = struct{}{}
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.followers, f)
+	}
+}
+
+// sent records that the records up to seq were sent to f.
+func (l *Log) sent(f *followerState, seq uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f.sent = seq
+}
+
+// recorder is the repository returned by Log.Wrap.
+type recorder struct {
+	log     *Log
+	backend repository.ProductRepository
+}
+
+func (r *recorder) Get(ctx context.Context, id string) (*product.Product, error) {
+	return r.backend.Get(ctx, id)
+}
+
+func (r *recorder) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	return r.backend.List(ctx, cursor, limit)
+}
+
+// Query runs q on the backend, using its indexes if it has any.
+func (r *recorder) Query(ctx context.Context, q repository.Query, fn func(*product.Product) bool) (repository.Plan, error) {
+	return repository.Select(ctx, r.backend, q, fn)
+}
+
+// Unwrap returns the backend, whose versions can be read directly: a read
+// needs no recording.
+func (r *recorder) Unwrap() repository.ProductRepository {
+	return r.backend
+}
+
+func (r *recorder) Put(ctx context.Context, p *product.Product) error {
+	return r.Batch(ctx, func(tx repository.Tx) error { return tx.Put(ctx, p) })
+}
+
+func (r *recorder) Delete(ctx context.Context, id string) error {
+	return r.Batch(ctx, func(tx repository.Tx) error { return tx.Delete(ctx, id) })
+}
+
+// Batch runs fn in a backend transaction and, if it commits with any writes,
+// records them as one record.
+func (r *recorder) Batch(ctx context.Context, fn func(tx repository.Tx) error) error {
+	r.log.commit.Lock()
+	defer r.log.commit.Unlock()
+	var mutations []*storagepb.Mutation
+	err := r.backend.Batch(ctx, func(tx repository.Tx) error {
+		// The backend may run fn more than once.
+		mutations = nil
+		return fn(&recordingTx{Tx: tx, mutations: &mutations})
+	})
+	if err == nil && len(mutations) > 0 {
+		r.log.append(mutations)
+	}
+	return err
+}
+
+func (r *recorder) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	return r.backend.AttributeDefinitions(ctx)
+}
+
+// PutAttributeDefinition puts def in the backend and records it as one
+// record.
+func (r *recorder) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	r.log.commit.Lock()
+	defer r.log.commit.Unlock()
+	if err := r.backend.PutAttributeDefinition(ctx, def); err != nil {
+		return err
+	}
+	def = proto.Clone(def).(*product.AttributeDefinition)
+	r.log.append([]*storagepb.Mutation{{Op: &storagepb.Mutation_PutAttributeDefinition{PutAttributeDefinition: def}}})
+	return nil
+}
+
+// recordingTx collects the writes made through a backend transaction.
+type recordingTx struct {
+	repository.Tx
+	mutations *[]*storagepb.Mutation
+}
+
+func (tx *recordingTx) Put(ctx context.Context, p *product.Product) error {
+	if err := tx.Tx.Put(ctx, p); err != nil {
+		return err
+	}
+	*tx.mutations = append(*tx.mutations, &storagepb.Mutation{Op: &storagepb.Mutation_Put{Put: proto.Clone(p).(*product.Product)}})
+	return nil
+}
+
+func (tx *recordingTx) Create(ctx context.Context, p *product.Product) error {
+	if err := tx.Tx.Create(ctx, p); err != nil {
+		return err
+	}
+	*tx.mutations = append(*tx.mutations, &storagepb.Mutation{Op: &storagepb.Mutation_Put{Put: proto.Clone(p).(*product.Product)}})
+	return nil
+}
+
+func (tx *recordingTx) Delete(ctx context.Context, id string) error {
+	if err := tx.Tx.Delete(ctx, id); err != nil {
+		return err
+	}
+	*tx.mutations = append(*tx.mutations, &storagepb.Mutation{Op: &storagepb.Mutation_Delete{Delete: id}})
+	return nil
+}
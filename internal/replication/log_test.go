@@ -0,0 +1,113 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/repository"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func put(p *product.Product) *storagepb.Mutation {
+	return &storagepb.Mutation{Op: &storagepb.Mutation_Put{Put: p}}
+}
+
+func del(id string) *storagepb.Mutation {
+	return &storagepb.Mutation{Op: &storagepb.Mutation_Delete{Delete: id}}
+}
+
+func TestLog_RecordsCommittedWritesInOrder(t *testing.T) {
+	ctx := context.Background()
+	log := NewLog(0)
+	repo := log.Wrap(repository.NewMemory(&product.Product{Id: "a", Name: "A"}))
+	b := &product.Product{Id: "b", Name: "B"}
+	c := &product.Product{Id: "c", Name: "C"}
+
+	if err := repo.Put(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	b.Name = "changed after Put"
+	if err := repo.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("deleting a missing product: %v", err)
+	}
+	failed := errors.New("failed")
+	if err := repo.Batch(ctx, func(tx repository.Tx) error {
+		tx.Put(ctx, c)
+		return failed
+	}); !errors.Is(err, failed) {
+		t.Fatal(err)
+	}
+	if err := repo.Batch(ctx, func(tx repository.Tx) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Batch(ctx, func(tx repository.Tx) error {
+		if err := tx.Put(ctx, c); err != nil {
+			return err
+		}
+		return tx.Delete(ctx, "b")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*storagepb.WALRecord{
+		{Sequence: 1, Mutations: []*storagepb.Mutation{put(&product.Product{Id: "b", Name: "B"})}},
+		{Sequence: 2, Mutations: []*storagepb.Mutation{del("a")}},
+		{Sequence: 3, Mutations: []*storagepb.Mutation{put(c), del("b")}},
+	}
+	records, latest, _, ok := log.since(0)
+	if !ok || latest != 3 || log.Sequence() != 3 || len(records) != len(want) {
+		t.Fatalf("since(0) = %v, %d, %v", records, latest, ok)
+	}
+	for i := range want {
+		if !proto.Equal(records[i], want[i]) {
+			t.Errorf("record %d = %v, want %v", i, records[i], want[i])
+		}
+	}
+
+	snap, err := log.snapshot(ctx)
+	if err != nil || snap.GetSequence() != 3 || len(snap.GetProducts()) != 1 || snap.GetProducts()[0].GetId() != "c" {
+		t.Fatalf("snapshot() = %v, %v", snap, err)
+	}
+}
+
+func TestLog_KeepsTheLastSizeRecords(t *testing.T) {
+	ctx := context.Background()
+	log := NewLog(4)
+	repo := log.Wrap(repository.NewMemory())
+	for i := range 11 {
+		if err := repo.Put(ctx, &product.Product{Id: "p", Name: "P", Price: float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, _, ok := log.since(0); ok {
+		t.Fatal("since(0) succeeded after the first records were dropped")
+	}
+	if _, _, _, ok := log.since(12); ok {
+		t.Fatal("since(12) succeeded for a sequence the log never had")
+	}
+	records, latest, _, ok := log.since(7)
+	if !ok || latest != 11 || len(records) != 4 || records[0].GetSequence() != 8 {
+		t.Fatalf("since(7) = %v, %d, %v", records, latest, ok)
+	}
+	if records, _, _, ok := log.since(11); !ok || len(records) != 0 {
+		t.Fatalf("since(11) = %v, %v", records, ok)
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, r := range []Role{RoleStandalone, RoleLeader, RoleFollower} {
+		if got, err := ParseRole(r.String()); err != nil || got != r {
+			t.Errorf("ParseRole(%q) = %v, %v", r.String(), got, err)
+		}
+	}
+	if _, err := ParseRole("primary"); err == nil {
+		t.Error("ParseRole(\"primary\") succeeded")
+	}
+}
@@ -0,0 +1,55 @@
+// Package replication copies the catalog of one API instance, the leader, to
+// others, the followers. The leader numbers its committed writes in a Log;
+// followers stream the log over gRPC (ReplicationService) and apply it, and
+// load a snapshot of the whole catalog when they are too far behind for the
+// writes they miss to still be in the log.
+package replication
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Role is the part an instance plays in replication.
+type Role int
+
+const (
+	// RoleStandalone instances neither serve nor follow a log.
+	RoleStandalone Role = iota
+	// RoleLeader instances record their writes and serve them to followers.
+	RoleLeader
+	// RoleFollower instances apply the writes of a leader and do not accept
+	// writes of their own.
+	RoleFollower
+)
+
+var roleNames = []string{"standalone", "leader", "follower"}
+
+func (r Role) String() string {
+	if r < 0 || int(r) >= len(roleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// ParseRole parses "standalone", "leader" or "follower".
+func ParseRole(s string) (Role, error) {
+	for i, name := range roleNames {
+		if s == name {
+			return Role(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown replication role %q: want %s", s, strings.Join(roleNames, ", "))
+}
+
+const (
+	// DefaultLogSize is the number of recent writes a leader keeps for
+	// followers that reconnect; a follower further behind loads a snapshot.
+	DefaultLogSize = 10000
+	// DefaultHeartbeatInterval is how often an idle leader tells its
+	// followers its latest sequence.
+	DefaultHeartbeatInterval = time.Second
+	// snapshotChunkSize is the number of products per SnapshotChunk.
+	snapshotChunkSize = 500
+)
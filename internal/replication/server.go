@@ -0,0 +1,216 @@
+package replication
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	replicationpb "grpc-go-fx/internal/generated/product/replication"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements replicationpb.ReplicationServiceServer for an instance in
+// any role: a leader serves its Log, a follower reports the status of its
+// Follower, and a standalone instance only reports its role.
+type Server struct {
+	replicationpb.UnimplementedReplicationServiceServer
+	log      *Log
+	follower *Follower
+	// forward sends the writes a follower receives to the leader instead of
+	// rejecting them.
+	forward bool
+	// token, if set, is the token a leader's followers authenticate with.
+	token string
+	// heartbeat is how often an idle stream is sent the leader's sequence.
+	heartbeat time.Duration
+	// closed is closed by Close to end the streams of a leader.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewStandaloneServer creates the Server of an instance that does not
+// replicate.
+func NewStandaloneServer() *Server {
+	return &Server{}
+}
+
+// NewLeaderServer creates the Server of a leader that streams log to its
+// followers, sending a heartbeat every interval while idle (zero means
+// DefaultHeartbeatInterval). With a token, only followers that send it (see
+// TokenCredentials) may stream changes, and the actors of the writes they
+// forward are trusted; without one, anyone may stream changes and forwarded
+// writes are attributed to the follower's connection.
+func NewLeaderServer(log *Log, token string, heartbeat time.Duration) *Server {
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeatInterval
+	}
+	return &Server{log: log, token: token, heartbeat: heartbeat, closed: make(chan struct{})}
+}
+
+// NewFollowerServer creates the Server of a follower. With forward, writes
+// sent to the follower are forwarded to its leader; otherwise they fail with
+// FAILED_PRECONDITION (see UnaryServerInterceptor).
+func NewFollowerServer(f *Follower, forward bool) *Server {
+	return &Server{follower: f, forward: forward}
+}
+
+// Close ends the streams of a leader with UNAVAILABLE, so that followers
+// reconnect and a graceful stop of the gRPC server does not wait for them.
+// Later streams fail the same way.
+func (s *Server) Close() {
+	if s.closed != nil {
+		s.closeOnce.Do(func() { close(s.closed) })
+	}
+}
+
+// Role returns the role of the instance.
+func (s *Server) Role() Role {
+	switch {
+	case s.log != nil:
+		return RoleLeader
+	case s.follower != nil:
+		return RoleFollower
+	}
+	return RoleStandalone
+}
+
+// StreamChanges sends the log to a follower, starting with a snapshot if the
+// records the follower needs are gone or belong to another log.
+func (s *Server) StreamChanges(req *replicationpb.StreamChangesRequest, stream grpc.ServerStreamingServer[replicationpb.ReplicationMessage]) error {
+	if s.log == nil {
+		return status.Errorf(codes.FailedPrecondition, "this instance is not the replication leader (role %s)", s.Role())
+	}
+	if s.token != "" && !s.authenticated(stream.Context()) {
+		return status.Error(codes.Unauthenticated, "a follower must send the leader's replication token")
+	}
+	select {
+	case <-s.closed:
+		return status.Error(codes.Unavailable, "the leader is shutting down")
+	default:
+	}
+	ctx := stream.Context()
+	f := &followerState{name: req.GetFollower(), connectTime: time.Now()}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		f.peer = p.Addr.String()
+	}
+	defer s.log.addFollower(f)()
+
+	after := req.GetAfterSequence()
+	fresh := req.GetLogId() == s.log.ID()
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		records, latest, changed, ok := s.log.since(after)
+		if !fresh || !ok {
+			seq, err := s.sendSnapshot(ctx, stream)
+			if err != nil {
+				return err
+			}
+			after, fresh = seq, true
+			s.log.sent(f, after)
+			continue
+		}
+		for _, r := range records {
+			msg := &replicationpb.ReplicationMessage{
+				LogId:          s.log.ID(),
+				LeaderSequence: latest,
+				Payload:        &replicationpb.ReplicationMessage_Record{Record: r},
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+			after = r.GetSequence()
+			s.log.sent(f, after)
+		}
+		if len(records) > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.closed:
+			return status.Error(codes.Unavailable, "the leader is shutting down")
+		case <-changed:
+		case <-heartbeat.C:
+			if err := stream.Send(&replicationpb.ReplicationMessage{LogId: s.log.ID(), LeaderSequence: latest}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendSnapshot sends the whole catalog in chunks, the attribute definitions
+// in the first, and returns the sequence it reflects.
+func (s *Server) sendSnapshot(ctx context.Context, stream grpc.ServerStreamingServer[replicationpb.ReplicationMessage]) (uint64, error) {
+	snap, err := s.log.snapshot(ctx)
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "snapshot: %v", err)
+	}
+	seq, products := snap.GetSequence(), snap.GetProducts()
+	for start := 0; ; start += snapshotChunkSize {
+		end := min(start+snapshotChunkSize, len(products))
+		chunk := &replicationpb.SnapshotChunk{Sequence: seq, Products: products[start:end], Last: end == len(products)}
+		if start == 0 {
+			chunk.AttributeDefinitions = snap.GetAttributeDefinitions()
+		}
+		msg := &replicationpb.ReplicationMessage{
+			LogId:          s.log.ID(),
+			LeaderSequence: s.log.Sequence(),
+			Payload:        &replicationpb.ReplicationMessage_Snapshot{Snapshot: chunk},
+		}
+		if err := stream.Send(msg); err != nil {
+			return 0, err
+		}
+		if chunk.Last {
+			return seq, nil
+		}
+	}
+}
+
+// GetReplicationStatus reports the role of the instance and, depending on
+// it, the followers streaming from it or how far it lags behind its leader.
+func (s *Server) GetReplicationStatus(ctx context.Context, req *replicationpb.GetReplicationStatusRequest) (*replicationpb.ReplicationStatus, error) {
+	return s.Status(), nil
+}
+
+// Status returns the replication status of the instance.
+func (s *Server) Status() *replicationpb.ReplicationStatus {
+	switch {
+	case s.log != nil:
+		return s.log.status()
+	case s.follower != nil:
+		return s.follower.Status()
+	}
+	return &replicationpb.ReplicationStatus{Role: replicationpb.ReplicationStatus_ROLE_STANDALONE}
+}
+
+// status reports the leader's log and followers, in the order they connected.
+func (l *Log) status() *replicationpb.ReplicationStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := &replicationpb.ReplicationStatus{
+		Role:     replicationpb.ReplicationStatus_ROLE_LEADER,
+		LogId:    l.id,
+		Sequence: l.seq,
+	}
+	for f := range l.followers {
+		st.Followers = append(st.Followers, &replicationpb.FollowerStatus{
+			Follower:    f.name,
+			Peer:        f.peer,
+			Sequence:    f.sent,
+			Lag:         l.seq - f.sent,
+			ConnectTime: timestamppb.New(f.connectTime),
+		})
+	}
+	slices.SortFunc(st.Followers, func(a, b *replicationpb.FollowerStatus) int {
+		return cmp.Or(a.GetConnectTime().AsTime().Compare(b.GetConnectTime().AsTime()), cmp.Compare(a.GetFollower(), b.GetFollower()))
+	})
+	return st
+}
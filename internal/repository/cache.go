@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultCacheSize is the number of entries a Cache holds when
+	// CacheOptions.Size is zero.
+	DefaultCacheSize = 10000
+	// DefaultCacheTTL is how long a product stays cached when
+	// CacheOptions.TTL is zero.
+	DefaultCacheTTL = time.Minute
+	// DefaultCacheNegativeTTL is how long a missing ID stays cached when
+	// CacheOptions.NegativeTTL is zero.
+	DefaultCacheNegativeTTL = 5 * time.Second
+)
+
+// CacheOptions configures a Cache.
+type CacheOptions struct {
+	// Size is the maximum number of cached products and misses; the least
+	// recently used entry is evicted to make room. Zero means DefaultCacheSize.
+	Size int
+	// TTL is how long a product is served from the cache; zero means
+	// DefaultCacheTTL.
+	TTL time.Duration
+	// NegativeTTL is how long a Get of a missing ID is answered with
+	// ErrNotFound without asking the backend; zero means
+	// DefaultCacheNegativeTTL and negative disables negative caching.
+	NegativeTTL time.Duration
+}
+
+// CacheStats counts the lookups of a Cache.
+type CacheStats struct {
+	// Hits is the number of Gets answered from the cache, including
+	// NegativeHits.
+	Hits uint64 `json:"hits"`
+	// NegativeHits is the number of Gets answered with a cached miss.
+	NegativeHits uint64 `json:"negative_hits"`
+	// Misses is the number of Gets that were not answered from the cache.
+	Misses uint64 `json:"misses"`
+	// Coalesced is the number of Misses that waited for another Get's read
+	// of the same ID instead of reading the backend themselves.
+	Coalesced uint64 `json:"coalesced"`
+	// Evictions is the number of entries dropped to stay within the size.
+	Evictions uint64 `json:"evictions"`
+	// Entries is the number of entries currently cached.
+	Entries int `json:"entries"`
+}
+
+// Cache is a ProductRepository that caches the Gets of another repository.
+// Concurrent Gets of an ID that is not cached share a single backend read.
+// Writes through the Cache invalidate the IDs they touch, so the Cache must
+// be the only writer of its backend; List, Query and reads inside Batch always
+// go to the backend. While a write is in progress, Gets of the IDs it touches
+// bypass the cache, so a reader never combines products cached before a
+// Batch with products read after it.
+type Cache struct {
+	backend     ProductRepository
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+	loads       singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // of *cacheEntry, most recently used first
+	// gen is incremented by every invalidation. A backend read only fills the
+	// cache if no invalidation happened while it ran, as it may have read
+	// the product before the write.
+	gen uint64
+	// writing counts the writes in progress per ID.
+	writing map[string]int
+
+	hits, negativeHits, misses, coalesced, evictions atomic.Uint64
+}
+
+// cacheEntry is a cached product, or a cached miss if p is nil.
+type cacheEntry struct {
+	id      string
+	p       *product.Product
+	expires time.Time
+}
+
+// NewCache creates a Cache in front of backend.
+func NewCache(backend ProductRepository, opts CacheOptions) *Cache {
+	c := &Cache{
+		backend:     backend,
+		size:        opts.Size,
+		ttl:         opts.TTL,
+		negativeTTL: opts.NegativeTTL,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		writing:     make(map[string]int),
+	}
+	if c.size <= 0 {
+		c.size = DefaultCacheSize
+	}
+	if c.ttl <= 0 {
+		c.ttl = DefaultCacheTTL
+	}
+	if c.negativeTTL == 0 {
+		c.negativeTTL = DefaultCacheNegativeTTL
+	}
+	return c
+}
+
+// Stats returns the cache's counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:         c.hits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Misses:       c.misses.Load(),
+		Coalesced:    c.coalesced.Load(),
+		Evictions:    c.evictions.Load(),
+		Entries:      entries,
+	}
+}
+
+// Get implements ProductRepository.
+func (c *Cache) Get(ctx context.Context, id string) (*product.Product, error) {
+	if e, ok := c.lookup(id); ok {
+		c.hits.Add(1)
+		if e.p == nil {
+			c.negativeHits.Add(1)
+			return nil, ErrNotFound
+		}
+		return clone(e.p), nil
+	}
+	c.misses.Add(1)
+
+	// The read is shared, so it must not be canceled with the Get that
+	// happened to start it.
+	led := false
+	ch := c.loads.DoChan(id, func() (any, error) {
+		led = true
+		return c.load(context.WithoutCancel(ctx), id)
+	})
+	select {
+	case r := <-ch:
+		if !led {
+			c.coalesced.Add(1)
+		}
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return clone(r.Val.(*product.Product)), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// List implements ProductRepository by listing the backend.
+func (c *Cache) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	return c.backend.List(ctx, cursor, limit)
+}
+
+// Query implements Querier by selecting from the backend.
+func (c *Cache) Query(ctx context.Context, q Query, fn func(p *product.Product) bool) (Plan, error) {
+	return Select(ctx, c.backend, q, fn)
+}
+
+// Unwrap implements Unwrapper.
+func (c *Cache) Unwrap() ProductRepository {
+	return c.backend
+}
+
+// Put implements ProductRepository.
+func (c *Cache) Put(ctx context.Context, p *product.Product) error {
+	c.beginWrite(p.GetId())
+	defer c.endWrite(p.GetId())
+	return c.backend.Put(ctx, p)
+}
+
+// Delete implements ProductRepository.
+func (c *Cache) Delete(ctx context.Context, id string) error {
+	c.beginWrite(id)
+	defer c.endWrite(id)
+	return c.backend.Delete(ctx, id)
+}
+
+// Batch implements ProductRepository. Every ID written by fn bypasses the
+// cache from the write until the backend's Batch returns.
+func (c *Cache) Batch(ctx context.Context, fn func(tx Tx) error) error {
+	var written []string
+	defer func() { c.endWrite(written...) }()
+	return c.backend.Batch(ctx, func(tx Tx) error {
+		return fn(&cacheTx{cache: c, Tx: tx, written: &written})
+	})
+}
+
+// AttributeDefinitions implements ProductRepository. Definitions are not
+// cached.
+func (c *Cache) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	return c.backend.AttributeDefinitions(ctx)
+}
+
+// PutAttributeDefinition implements ProductRepository.
+func (c *Cache) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	return c.backend.PutAttributeDefinition(ctx, def)
+}
+
+// lookup returns the unexpired entry for id and marks it used.
+func (c *Cache) lookup(id string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[id]
+	if !ok || c.writing[id] > 0 {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e, true
+}
+
+// load reads id from the backend and caches the product or miss.
+func (c *Cache) load(ctx context.Context, id string) (*product.Product, error) {
+	c.mu.Lock()
+	gen := c.gen
+	c.mu.Unlock()
+	p, err := c.backend.Get(ctx, id)
+	switch {
+	case err == nil:
+		c.fill(id, p, c.ttl, gen)
+	case errors.Is(err, ErrNotFound) && c.negativeTTL > 0:
+		c.fill(id, nil, c.negativeTTL, gen)
+	}
+	return p, err
+}
+
+// fill caches p, or a miss if p is nil, unless the cache was invalidated
+// after gen was read.
+func (c *Cache) fill(id string, p *product.Product, ttl time.Duration, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen || c.writing[id] > 0 {
+		return
+	}
+	e := &cacheEntry{id: id, p: p, expires: c.now().Add(ttl)}
+	if el, ok := c.entries[id]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[id] = c.lru.PushFront(e)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// beginWrite makes Gets of id bypass the cache until the matching endWrite.
+func (c *Cache) beginWrite(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writing[id]++
+	c.invalidate(id)
+}
+
+// endWrite ends a write of each of ids begun by beginWrite.
+func (c *Cache) endWrite(ids ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		if c.writing[id]--; c.writing[id] == 0 {
+			delete(c.writing, id)
+		}
+		c.invalidate(id)
+	}
+}
+
+// invalidate drops id from the cache and detaches later Gets from a read of
+// it that is still running. Callers must hold c.mu.
+func (c *Cache) invalidate(id string) {
+	c.gen++
+	if el, ok := c.entries[id]; ok {
+		c.remove(el)
+	}
+	c.loads.Forget(id)
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).id)
+}
+
+// cacheTx begins a write of every ID written through a backend Tx and
+// records it, so that Batch can end the writes.
+type cacheTx struct {
+	cache *Cache
+	Tx
+	written *[]string
+}
+
+func (tx *cacheTx) Put(ctx context.Context, p *product.Product) error {
+	tx.write(p.GetId())
+	return tx.Tx.Put(ctx, p)
+}
+
+func (tx *cacheTx) Create(ctx context.Context, p *product.Product) error {
+	tx.write(p.GetId())
+	return tx.Tx.Create(ctx, p)
+}
+
+func (tx *cacheTx) Delete(ctx context.Context, id string) error {
+	tx.write(id)
+	return tx.Tx.Delete(ctx, id)
+}
+
+func (tx *cacheTx) write(id string) {
+	tx.cache.beginWrite(id)
+	*tx.written = append(*tx.written, id)
+}
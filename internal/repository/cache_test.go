@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+// countingRepository counts the Gets that reach a repository and, if release
+// is set, holds their results until it is closed.
+type countingRepository struct {
+	ProductRepository
+	gets    atomic.Int64
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *countingRepository) Get(ctx context.Context, id string) (*product.Product, error) {
+	r.gets.Add(1)
+	p, err := r.ProductRepository.Get(ctx, id)
+	if release := r.release; release != nil {
+		r.started <- struct{}{}
+		<-release
+	}
+	return p, err
+}
+
+// fakeClock is a settable time source.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestCache(opts CacheOptions, seed ...*product.Product) (*Cache, *countingRepository, *fakeClock) {
+	backend := &countingRepository{ProductRepository: NewMemory(seed...)}
+	c := NewCache(backend, opts)
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	c.now = clock.now
+	return c, backend, clock
+}
+
+func TestCache(t *testing.T) {
+	testRepository(t, func(t *testing.T) ProductRepository { return NewCache(NewMemory(), CacheOptions{}) })
+}
+
+func TestCache_ServesHitsAndExpires(t *testing.T) {
+	ctx := context.Background()
+	c, backend, clock := newTestCache(CacheOptions{TTL: time.Minute}, &product.Product{Id: "a", Name: "A"})
+	for range 3 {
+		p, err := c.Get(ctx, "a")
+		if err != nil || p.GetName() != "A" {
+			t.Fatalf("Get = %v, %v", p, err)
+		}
+		p.Name = "modified"
+	}
+	if n := backend.gets.Load(); n != 1 {
+		t.Fatalf("backend read %d times, want 1", n)
+	}
+	clock.advance(time.Minute)
+	if _, err := c.Get(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if n := backend.gets.Load(); n != 2 {
+		t.Fatalf("expired entry not reloaded: %d backend reads", n)
+	}
+	if s := c.Stats(); s.Hits != 2 || s.Misses != 2 || s.Entries != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestCache_CachesMisses(t *testing.T) {
+	ctx := context.Background()
+	c, backend, clock := newTestCache(CacheOptions{NegativeTTL: time.Second})
+	for range 2 {
+		if _, err := c.Get(ctx, "x"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("got %v, want ErrNotFound", err)
+		}
+	}
+	if n := backend.gets.Load(); n != 1 {
+		t.Fatalf("backend read %d times, want 1", n)
+	}
+	clock.advance(time.Second)
+	c.Get(ctx, "x")
+	if s := c.Stats(); s.NegativeHits != 1 || s.Misses != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	// A write replaces the cached miss.
+	if err := c.Put(ctx, &product.Product{Id: "x", Name: "X"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := c.Get(ctx, "x"); err != nil || p.GetName() != "X" {
+		t.Fatalf("Get after Put = %v, %v", p, err)
+	}
+
+	c, backend, _ = newTestCache(CacheOptions{NegativeTTL: -1})
+	c.Get(ctx, "x")
+	c.Get(ctx, "x")
+	if n := backend.gets.Load(); n != 2 {
+		t.Fatalf("misses cached with negative caching disabled: %d backend reads", n)
+	}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c, backend, _ := newTestCache(CacheOptions{Size: 2},
+		&product.Product{Id: "a"}, &product.Product{Id: "b"}, &product.Product{Id: "c"})
+	for _, id := range []string{"a", "b", "a", "c"} {
+		c.Get(ctx, id)
+	}
+	// b was the least recently used when c was added.
+	backend.gets.Store(0)
+	c.Get(ctx, "a")
+	c.Get(ctx, "c")
+	if n := backend.gets.Load(); n != 0 {
+		t.Fatalf("recently used entries evicted: %d backend reads", n)
+	}
+	c.Get(ctx, "b")
+	if n := backend.gets.Load(); n != 1 {
+		t.Fatal("least recently used entry not evicted")
+	}
+	if s := c.Stats(); s.Evictions != 2 || s.Entries != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestCache_WritesInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c, _, _ := newTestCache(CacheOptions{}, &product.Product{Id: "a", Name: "A"}, &product.Product{Id: "b", Name: "B"})
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+
+	if err := c.Put(ctx, &product.Product{Id: "a", Name: "A2"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := c.Get(ctx, "a"); p.GetName() != "A2" {
+		t.Fatalf("Get after Put = %v", p)
+	}
+	err := c.Batch(ctx, func(tx Tx) error {
+		if err := tx.Put(ctx, &product.Product{Id: "a", Name: "A3"}); err != nil {
+			return err
+		}
+		return tx.Delete(ctx, "b")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := c.Get(ctx, "a"); p.GetName() != "A3" {
+		t.Fatalf("Get after Batch = %v", p)
+	}
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted product still cached: %v", err)
+	}
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted product still cached: %v", err)
+	}
+}
+
+func TestCache_CoalescesConcurrentMisses(t *testing.T) {
+	c, backend, _ := newTestCache(CacheOptions{}, &product.Product{Id: "hot", Name: "Hot"})
+	backend.started = make(chan struct{}, 1)
+	backend.release = make(chan struct{})
+
+	const callers = 50
+	var wg sync.WaitGroup
+	var got [callers]*product.Product
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], _ = c.Get(context.Background(), "hot")
+		}()
+	}
+	<-backend.started
+	// Wait for every caller to be counted before letting the read finish.
+	for c.Stats().Misses != callers {
+		time.Sleep(time.Millisecond)
+	}
+	close(backend.release)
+	wg.Wait()
+
+	if n := backend.gets.Load(); n != 1 {
+		t.Fatalf("%d backend reads, want 1", n)
+	}
+	for i, p := range got {
+		if p.GetName() != "Hot" {
+			t.Fatalf("caller %d got %v", i, p)
+		}
+		if i > 0 && p == got[0] {
+			t.Fatal("callers share a product")
+		}
+	}
+	if s := c.Stats(); s.Coalesced != callers-1 {
+		t.Fatalf("coalesced %d misses, want %d", s.Coalesced, callers-1)
+	}
+}
+
+func TestCache_DropsReadsRacingAWrite(t *testing.T) {
+	ctx := context.Background()
+	c, backend, _ := newTestCache(CacheOptions{}, &product.Product{Id: "a", Name: "old"})
+	backend.started = make(chan struct{}, 1)
+	backend.release = make(chan struct{})
+
+	done := make(chan *product.Product)
+	go func() {
+		p, _ := c.Get(ctx, "a")
+		done <- p
+	}()
+	<-backend.started
+	// The read above saw the old product but has not returned yet; it must
+	// not be cached, and a Get that starts after this write must not join it.
+	if err := c.Put(ctx, &product.Product{Id: "a", Name: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	release := backend.release
+	backend.release = nil
+	close(release)
+	if p := <-done; p.GetName() != "old" {
+		t.Fatalf("racing Get = %v", p)
+	}
+
+	if p, _ := c.Get(ctx, "a"); p.GetName() != "new" {
+		t.Fatalf("Get after Put = %v", p)
+	}
+}
+
+func TestCache_CanceledGetLeavesSharedReadRunning(t *testing.T) {
+	c, backend, _ := newTestCache(CacheOptions{}, &product.Product{Id: "a", Name: "A"})
+	backend.started = make(chan struct{}, 1)
+	backend.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, "a")
+		errc <- err
+	}()
+	<-backend.started
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled Get returned %v", err)
+	}
+	close(backend.release)
+
+	for c.Stats().Entries == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if p, err := c.Get(context.Background(), "a"); err != nil || p.GetName() != "A" {
+		t.Fatalf("Get = %v, %v", p, err)
+	}
+}
+
+func TestCache_BypassedForIDsWrittenByARunningBatch(t *testing.T) {
+	ctx := context.Background()
+	c, _, _ := newTestCache(CacheOptions{}, &product.Product{Id: "a"}, &product.Product{Id: "b"})
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+
+	written, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- c.Batch(ctx, func(tx Tx) error {
+			if err := tx.Put(ctx, &product.Product{Id: "a", Name: "A"}); err != nil {
+				return err
+			}
+			close(written)
+			<-release
+			return nil
+		})
+	}()
+	<-written
+	// The backend holds its lock until the batch commits, so only the cache
+	// itself is consulted here.
+	if _, ok := c.lookup("a"); ok {
+		t.Fatal("product written by a running batch served from the cache")
+	}
+	c.fill("a", &product.Product{Id: "a"}, time.Minute, c.gen)
+	if _, ok := c.lookup("a"); ok {
+		t.Fatal("product written by a running batch cached")
+	}
+	if _, ok := c.lookup("b"); !ok {
+		t.Fatal("product untouched by the batch evicted")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := c.Get(ctx, "a"); p.GetName() != "A" {
+		t.Fatalf("Get after Batch = %v", p)
+	}
+}
@@ -0,0 +1,550 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	storagepb "grpc-go-fx/internal/generated/product/storage"
+	"grpc-go-fx/internal/wal"
+)
+
+// SyncPolicy says when the file store fsyncs its write-ahead log.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs every record before the write returns.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval fsyncs at most once per FileOptions.SyncInterval, so a
+	// machine crash can lose the writes of the last interval.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system; Close still syncs.
+	SyncNever
+)
+
+var syncPolicyNames = []string{"always", "interval", "never"}
+
+func (p SyncPolicy) String() string {
+	if p < 0 || int(p) >= len(syncPolicyNames) {
+		return fmt.Sprintf("SyncPolicy(%d)", int(p))
+	}
+	return syncPolicyNames[p]
+}
+
+// ParseSyncPolicy parses "always", "interval" or "never".
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	for i, name := range syncPolicyNames {
+		if s == name {
+			return SyncPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown fsync policy %q: want %s", s, strings.Join(syncPolicyNames, ", "))
+}
+
+const (
+	// DefaultSyncInterval is used with SyncInterval when FileOptions.SyncInterval is zero.
+	DefaultSyncInterval = time.Second
+	// DefaultSnapshotThreshold is used when FileOptions.SnapshotThreshold is zero.
+	DefaultSnapshotThreshold = 1000
+)
+
+// FileOptions configures a File store.
+type FileOptions struct {
+	Sync SyncPolicy
+	// SyncInterval is how often the log is synced under SyncInterval.
+	SyncInterval time.Duration
+	// SnapshotThreshold is the number of log records after which the catalog
+	// is snapshotted in the background and the log compacted. Negative
+	// disables compaction except on Close and Compact.
+	SnapshotThreshold int
+}
+
+// File names inside the store directory.
+const (
+	walFile      = "wal.log"
+	snapshotFile = "snapshot.pb"
+)
+
+// ErrClosed is returned by a File store that is not open.
+var ErrClosed = errors.New("file store is not open")
+
+// File is a ProductRepository that keeps the catalog in memory and makes it
+// durable in a directory holding a write-ahead log of WALRecord messages and
+// a Snapshot of the catalog as of some log record. Every Put, Delete, Batch
+// and PutAttributeDefinition appends one record before it is applied. Once
+// the log holds SnapshotThreshold records the catalog is snapshotted in the
+// background and the records the snapshot covers are dropped from the log.
+// Writes continue while the snapshot is written.
+//
+// Records and snapshots are framed by their length and CRC-32C. On Open the
+// snapshot is loaded and later records replayed; a torn or corrupt record
+// ends the log, which is truncated there.
+type File struct {
+	dir  string
+	opts FileOptions
+
+	mu       sync.RWMutex
+	products *catalog
+	defs     definitions
+	wal      *os.File // nil unless open
+	size     int64    // bytes of valid records in wal
+	seq      uint64   // sequence number of the last record
+	records  int      // records in wal
+	unsynced bool
+	// failed is set when the log may no longer match memory (a write or
+	// fsync failed); every later write returns it.
+	failed error
+	// snapshotting is set while a background snapshot is pending.
+	snapshotting bool
+
+	// snapMu serializes snapshots. It is taken before mu.
+	snapMu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFile creates a File store in dir. It must be opened before use.
+func NewFile(dir string, opts FileOptions) *File {
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = DefaultSyncInterval
+	}
+	if opts.SnapshotThreshold == 0 {
+		opts.SnapshotThreshold = DefaultSnapshotThreshold
+	}
+	return &File{dir: dir, opts: opts}
+}
+
+// Open creates the directory if needed, recovers the catalog from the
+// snapshot and log, and starts the background sync for SyncInterval.
+func (f *File) Open() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.wal != nil {
+		return errors.New("file store is already open")
+	}
+	if err := os.MkdirAll(f.dir, 0o700); err != nil {
+		return err
+	}
+	f.products, f.defs, f.seq, f.records, f.failed, f.snapshotting = newCatalog(), definitions{}, 0, 0, nil, false
+	if err := f.loadSnapshot(); err != nil {
+		return err
+	}
+	log, err := os.OpenFile(filepath.Join(f.dir, walFile), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := f.replay(log); err != nil {
+		log.Close()
+		return err
+	}
+	f.wal = log
+	if f.opts.Sync == SyncInterval {
+		f.stop, f.done = make(chan struct{}), make(chan struct{})
+		go f.syncLoop(f.stop, f.done)
+	}
+	return nil
+}
+
+// Close snapshots the catalog, truncates the log and closes it.
+func (f *File) Close() error {
+	f.mu.Lock()
+	stop, done := f.stop, f.done
+	f.stop, f.done = nil, nil
+	f.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	f.snapMu.Lock()
+	defer f.snapMu.Unlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.wal == nil {
+		return nil
+	}
+	var err error
+	if f.records > 0 && f.failed == nil {
+		s := f.takeSnapshot()
+		if err = s.write(f.dir); err == nil {
+			err = f.dropSnapshotted(s)
+		}
+	}
+	if serr := f.wal.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := f.wal.Close(); err == nil {
+		err = cerr
+	}
+	f.wal, f.products, f.defs = nil, nil, nil
+	return err
+}
+
+// Compact snapshots the catalog and drops the records the snapshot covers
+// from the log.
+func (f *File) Compact() error {
+	return f.snapshot()
+}
+
+// Get implements ProductRepository.
+func (f *File) Get(ctx context.Context, id string) (*product.Product, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.wal == nil {
+		return nil, ErrClosed
+	}
+	return f.products.get(id)
+}
+
+// List implements ProductRepository. The cursor is the ID of the last product
+// on the previous page.
+func (f *File) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.wal == nil {
+		return nil, "", ErrClosed
+	}
+	page, next := f.products.list(cursor, limit)
+	return page, next, nil
+}
+
+// Query implements Querier.
+func (f *File) Query(ctx context.Context, q Query, fn func(p *product.Product) bool) (Plan, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.wal == nil {
+		return Plan{}, ErrClosed
+	}
+	return f.products.query(q, fn)
+}
+
+// Put implements ProductRepository.
+func (f *File) Put(ctx context.Context, p *product.Product) error {
+	return f.Batch(ctx, func(tx Tx) error { return tx.Put(ctx, p) })
+}
+
+// Delete implements ProductRepository.
+func (f *File) Delete(ctx context.Context, id string) error {
+	return f.Batch(ctx, func(tx Tx) error { return tx.Delete(ctx, id) })
+}
+
+// Batch implements ProductRepository. The writes are logged as one record. It
+// holds the write lock while fn runs, so fn must not call f's other methods.
+func (f *File) Batch(ctx context.Context, fn func(tx Tx) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.wal == nil {
+		return ErrClosed
+	}
+	if f.failed != nil {
+		return f.failed
+	}
+	tx := newMemoryTx(f.products)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+	return f.commitWrites(tx.writes)
+}
+
+// AttributeDefinitions implements ProductRepository.
+func (f *File) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.wal == nil {
+		return nil, ErrClosed
+	}
+	return f.defs.list(), nil
+}
+
+// PutAttributeDefinition implements ProductRepository. The definition is
+// logged as a record of its own.
+func (f *File) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.wal == nil {
+		return ErrClosed
+	}
+	if f.failed != nil {
+		return f.failed
+	}
+	m := &storagepb.Mutation{Op: &storagepb.Mutation_PutAttributeDefinition{PutAttributeDefinition: def}}
+	return f.commit([]*storagepb.Mutation{m}, func() { f.defs.put(def) })
+}
+
+// commitWrites logs writes as the next record and applies them.
+func (f *File) commitWrites(writes map[string]*product.Product) error {
+	ids := make([]string, 0, len(writes))
+	for id := range writes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var muts []*storagepb.Mutation
+	for _, id := range ids {
+		m := &storagepb.Mutation{Op: &storagepb.Mutation_Delete{Delete: id}}
+		if p := writes[id]; p != nil {
+			m.Op = &storagepb.Mutation_Put{Put: p}
+		}
+		muts = append(muts, m)
+	}
+	return f.commit(muts, func() { f.products.apply(writes) })
+}
+
+// commit logs muts as the next record and then calls apply to apply them in
+// memory. It starts a background snapshot once the log reaches the threshold;
+// a failed snapshot does not fail the write and is retried after the next one.
+func (f *File) commit(muts []*storagepb.Mutation, apply func()) error {
+	rec := &storagepb.WALRecord{Sequence: f.seq + 1, Mutations: muts}
+	frame, err := wal.Encode(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := f.wal.Write(frame); err != nil {
+		// Drop whatever part of the record reached the file so that later
+		// records are not hidden behind it on recovery.
+		if terr := f.wal.Truncate(f.size); terr != nil {
+			f.failed = fmt.Errorf("file store: write failed (%v) and the log could not be repaired: %w", err, terr)
+		}
+		return err
+	}
+	if f.opts.Sync == SyncAlways {
+		if err := f.wal.Sync(); err != nil {
+			// After a failed fsync the kernel may have dropped the data, so
+			// nothing written since the last successful sync can be trusted.
+			f.failed = fmt.Errorf("file store: fsync failed: %w", err)
+			return f.failed
+		}
+	} else {
+		f.unsynced = true
+	}
+	apply()
+	f.seq++
+	f.records++
+	f.size += int64(len(frame))
+	if f.opts.SnapshotThreshold > 0 && f.records >= f.opts.SnapshotThreshold && !f.snapshotting {
+		f.snapshotting = true
+		go func() {
+			_ = f.snapshot()
+			f.mu.Lock()
+			f.snapshotting = false
+			f.mu.Unlock()
+		}()
+	}
+	return nil
+}
+
+// snapshot snapshots the catalog and drops the records it covers from the
+// log. The snapshot is written without holding f.mu, so writes continue
+// meanwhile.
+func (f *File) snapshot() error {
+	f.snapMu.Lock()
+	defer f.snapMu.Unlock()
+	f.mu.Lock()
+	if err := f.writable(); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	s := f.takeSnapshot()
+	f.mu.Unlock()
+
+	if err := s.write(f.dir); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.writable(); err != nil {
+		return err
+	}
+	return f.dropSnapshotted(s)
+}
+
+// writable returns ErrClosed or the error that failed f, if any. Callers
+// must hold f.mu.
+func (f *File) writable() error {
+	if f.wal == nil {
+		return ErrClosed
+	}
+	return f.failed
+}
+
+// pendingSnapshot is the catalog as of a log record, taken under f.mu to be
+// written without it.
+type pendingSnapshot struct {
+	products *catalog // no longer written to
+	defs     []*product.AttributeDefinition
+	seq      uint64
+	size     int64 // bytes of the log up to and including record seq
+	records  int
+}
+
+// takeSnapshot takes the catalog for a snapshot. f keeps writing to a clone
+// of it, which is cheap, so the snapshot can read it without f.mu. Callers
+// must hold f.mu for writing.
+func (f *File) takeSnapshot() *pendingSnapshot {
+	s := &pendingSnapshot{products: f.products, defs: f.defs.list(), seq: f.seq, size: f.size, records: f.records}
+	f.products = f.products.clone()
+	return s
+}
+
+// write writes s to a new snapshot file in dir and atomically replaces the
+// old one. Records at or below s's sequence number are skipped on recovery,
+// so the log may keep them until dropSnapshotted.
+func (s *pendingSnapshot) write(dir string) error {
+	snap := &storagepb.Snapshot{Sequence: s.seq, AttributeDefinitions: s.defs}
+	snap.Products, _ = s.products.list("", 0)
+	frame, err := wal.Encode(snap)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, snapshotFile)
+	if err := wal.WriteFile(path+".tmp", frame); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	return wal.SyncDir(dir)
+}
+
+// dropSnapshotted removes the records covered by the written snapshot s from
+// the log. Records appended since s was taken are copied to a new log that
+// replaces the old one. Callers must hold f.mu for writing.
+func (f *File) dropSnapshotted(s *pendingSnapshot) error {
+	if f.size == s.size {
+		if err := f.wal.Truncate(0); err != nil {
+			f.failed = fmt.Errorf("file store: truncating the log: %w", err)
+			return f.failed
+		}
+		f.size, f.records, f.unsynced = 0, 0, false
+		return f.wal.Sync()
+	}
+	tail := make([]byte, f.size-s.size)
+	if _, err := f.wal.ReadAt(tail, s.size); err != nil {
+		return err
+	}
+	path := filepath.Join(f.dir, walFile)
+	if err := wal.WriteFile(path+".tmp", tail); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	log, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		// Writes would go to the replaced log.
+		f.failed = fmt.Errorf("file store: reopening the log: %w", err)
+		return f.failed
+	}
+	f.wal.Close()
+	f.wal = log
+	f.size -= s.size
+	f.records -= s.records
+	f.unsynced = false
+	// Until the rename is durable, a crash recovers the old log, whose
+	// snapshotted records are skipped.
+	return wal.SyncDir(f.dir)
+}
+
+// loadSnapshot reads the snapshot into f, if there is one.
+func (f *File) loadSnapshot() error {
+	path := filepath.Join(f.dir, snapshotFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	snap := &storagepb.Snapshot{}
+	if n, err := wal.Decode(data, snap); err != nil || n != len(data) {
+		return fmt.Errorf("%s is corrupt: %v", path, err)
+	}
+	for _, p := range snap.GetProducts() {
+		f.products.put(p)
+	}
+	for _, def := range snap.GetAttributeDefinitions() {
+		f.defs.put(def)
+	}
+	f.seq = snap.GetSequence()
+	return nil
+}
+
+// replay applies the records in log that follow the snapshot and truncates
+// the log after the last valid one.
+func (f *File) replay(log *os.File) error {
+	data, err := io.ReadAll(log)
+	if err != nil {
+		return err
+	}
+	var off int
+	for off < len(data) {
+		rec := &storagepb.WALRecord{}
+		n, err := wal.Decode(data[off:], rec)
+		if err != nil {
+			break
+		}
+		switch seq := rec.GetSequence(); {
+		case seq <= f.seq:
+			// Already in the snapshot.
+		case seq == f.seq+1:
+			for _, m := range rec.GetMutations() {
+				switch op := m.GetOp().(type) {
+				case *storagepb.Mutation_Put:
+					f.products.put(op.Put)
+				case *storagepb.Mutation_Delete:
+					f.products.delete(op.Delete)
+				case *storagepb.Mutation_PutAttributeDefinition:
+					f.defs.put(op.PutAttributeDefinition)
+				}
+			}
+			f.seq = seq
+		default:
+			return fmt.Errorf("%s: record %d follows %d", log.Name(), seq, f.seq)
+		}
+		off += n
+		f.records++
+	}
+	if off < len(data) {
+		if err := log.Truncate(int64(off)); err != nil {
+			return err
+		}
+		if err := log.Sync(); err != nil {
+			return err
+		}
+	}
+	f.size = int64(off)
+	return nil
+}
+
+// syncLoop fsyncs the log every SyncInterval while there are unsynced writes.
+func (f *File) syncLoop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	t := time.NewTicker(f.opts.SyncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		f.mu.Lock()
+		if f.unsynced && f.wal != nil && f.failed == nil {
+			if err := f.wal.Sync(); err != nil {
+				f.failed = fmt.Errorf("file store: fsync failed: %w", err)
+			}
+			f.unsynced = false
+		}
+		f.mu.Unlock()
+	}
+}
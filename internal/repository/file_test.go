@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+	"grpc-go-fx/internal/wal"
+)
+
+func openFile(t *testing.T, dir string, opts FileOptions) *File {
+	t.Helper()
+	f := NewFile(dir, opts)
+	if err := f.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// contents returns the names of every product in r keyed by ID.
+func contents(t *testing.T, r ProductRepository) map[string]string {
+	t.Helper()
+	all, _, err := r.List(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	names := make(map[string]string, len(all))
+	for _, p := range all {
+		names[p.GetId()] = p.GetName()
+	}
+	return names
+}
+
+// writeSome puts a, b and c, renames b and deletes a, one record each.
+func writeSome(t *testing.T, r ProductRepository) {
+	t.Helper()
+	ctx := context.Background()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := r.Put(ctx, &product.Product{Id: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := r.Batch(ctx, func(tx Tx) error {
+		if err := tx.Put(ctx, &product.Product{Id: "b", Name: "b2"}); err != nil {
+			return err
+		}
+		return tx.Delete(ctx, "a")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	testRepository(t, func(t *testing.T) ProductRepository { return openFile(t, t.TempDir(), FileOptions{}) })
+}
+
+func TestFile_RecoversFromLogAfterCrash(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		t.Run(policy.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			// The first store is never closed, as if the process had died.
+			writeSome(t, openFile(t, dir, FileOptions{Sync: policy}))
+
+			got := contents(t, openFile(t, dir, FileOptions{Sync: policy}))
+			if fmt.Sprint(got) != "map[b:b2 c:c]" {
+				t.Fatalf("recovered %v", got)
+			}
+		})
+	}
+}
+
+func TestFile_RecoversAttributeDefinitions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f := openFile(t, dir, FileOptions{})
+	f.PutAttributeDefinition(ctx, &product.AttributeDefinition{Name: "color", Type: product.AttributeType_ATTRIBUTE_TYPE_STRING})
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// The snapshot written by Close holds color; the log holds voltage.
+	f = openFile(t, dir, FileOptions{})
+	f.PutAttributeDefinition(ctx, &product.AttributeDefinition{Name: "voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER})
+
+	defs, err := openFile(t, dir, FileOptions{}).AttributeDefinitions(ctx)
+	if err != nil || len(defs) != 2 || defs[0].GetName() != "color" || defs[1].GetName() != "voltage" {
+		t.Fatalf("recovered definitions %v, %v; want color and voltage", defs, err)
+	}
+}
+
+func TestFile_TruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	writeSome(t, openFile(t, dir, FileOptions{}))
+	path := filepath.Join(dir, walFile)
+	good, _ := os.Stat(path)
+
+	// Simulate a crash in the middle of appending a record.
+	frame, _ := wal.Encode(&product.Product{Id: "torn"})
+	w, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(frame[:len(frame)-3])
+	w.Close()
+
+	f := openFile(t, dir, FileOptions{})
+	if after, _ := os.Stat(path); after.Size() != good.Size() {
+		t.Fatalf("log is %d bytes after recovery, want %d", after.Size(), good.Size())
+	}
+	if err := f.Put(context.Background(), &product.Product{Id: "d", Name: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(t, openFile(t, dir, FileOptions{})); fmt.Sprint(got) != "map[b:b2 c:c d:d]" {
+		t.Fatalf("recovered %v", got)
+	}
+}
+
+func TestFile_CompactsAfterThreshold(t *testing.T) {
+	dir := t.TempDir()
+	f := openFile(t, dir, FileOptions{SnapshotThreshold: 3})
+	writeSome(t, f)
+	// The snapshot is written in the background.
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.mu.RLock()
+		snapshotting, records := f.snapshotting, f.records
+		f.mu.RUnlock()
+		if !snapshotting {
+			// The last write may land before or after the snapshot is taken.
+			if records > 1 {
+				t.Fatalf("log holds %d records after compaction, want at most 1", records)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Fatalf("no snapshot after the threshold: %v", err)
+	}
+	if got := contents(t, openFile(t, dir, FileOptions{})); fmt.Sprint(got) != "map[b:b2 c:c]" {
+		t.Fatalf("recovered %v", got)
+	}
+}
+
+func TestFile_KeepsRecordsWrittenDuringSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	f := openFile(t, dir, FileOptions{SnapshotThreshold: -1})
+	writeSome(t, f)
+	f.mu.Lock()
+	s := f.takeSnapshot()
+	f.mu.Unlock()
+	ctx := context.Background()
+	if err := f.Put(ctx, &product.Product{Id: "d", Name: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write(dir); err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	err := f.dropSnapshotted(s)
+	records := f.records
+	f.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records != 1 {
+		t.Fatalf("log holds %d records after compaction, want 1", records)
+	}
+	if err := f.Put(ctx, &product.Product{Id: "e", Name: "e"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(t, f); fmt.Sprint(got) != "map[b:b2 c:c d:d e:e]" {
+		t.Fatalf("contents %v", got)
+	}
+	if got := contents(t, openFile(t, dir, FileOptions{})); fmt.Sprint(got) != "map[b:b2 c:c d:d e:e]" {
+		t.Fatalf("recovered %v", got)
+	}
+}
+
+func TestFile_CompactsWhileWriting(t *testing.T) {
+	dir := t.TempDir()
+	f := openFile(t, dir, FileOptions{SnapshotThreshold: 5})
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				id := fmt.Sprintf("%d-%d", w, i)
+				if err := f.Put(ctx, &product.Product{Id: id, Name: id}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := f.Compact(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if got := len(contents(t, openFile(t, dir, FileOptions{}))); got != 200 {
+		t.Fatalf("recovered %d products, want 200", got)
+	}
+}
+
+func TestFile_SkipsLoggedRecordsCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	f := openFile(t, dir, FileOptions{})
+	writeSome(t, f)
+	wal := filepath.Join(dir, walFile)
+	log, _ := os.ReadFile(wal)
+	if err := f.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash after the snapshot was renamed into place but before
+	// the log was truncated.
+	if err := os.WriteFile(wal, log, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := openFile(t, dir, FileOptions{})
+	if got := contents(t, r); fmt.Sprint(got) != "map[b:b2 c:c]" {
+		t.Fatalf("recovered %v", got)
+	}
+	if err := r.Put(context.Background(), &product.Product{Id: "e", Name: "e"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(t, openFile(t, dir, FileOptions{})); fmt.Sprint(got) != "map[b:b2 c:c e:e]" {
+		t.Fatalf("recovered %v", got)
+	}
+}
+
+func TestFile_RejectsCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	f := openFile(t, dir, FileOptions{})
+	writeSome(t, f)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, snapshotFile)
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(path, data, 0o600)
+	if err := NewFile(dir, FileOptions{}).Open(); err == nil {
+		t.Fatal("expected an error for a corrupt snapshot")
+	}
+}
+
+func TestFile_SyncIntervalFlushesInBackground(t *testing.T) {
+	f := openFile(t, t.TempDir(), FileOptions{Sync: SyncInterval, SyncInterval: 5 * time.Millisecond})
+	if err := f.Put(context.Background(), &product.Product{Id: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.mu.RLock()
+		unsynced := f.unsynced
+		f.mu.RUnlock()
+		if !unsynced {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("log not synced within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFile_NotOpen(t *testing.T) {
+	f := NewFile(t.TempDir(), FileOptions{})
+	if _, err := f.Get(context.Background(), "a"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get before Open: got %v, want ErrClosed", err)
+	}
+	if err := f.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Put(context.Background(), &product.Product{Id: "a"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Put after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	for _, p := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		if got, err := ParseSyncPolicy(p.String()); err != nil || got != p {
+			t.Errorf("ParseSyncPolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+	if _, err := ParseSyncPolicy("sometimes"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"cmp"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"github.com/google/btree"
+)
+
+// btreeDegree is the degree of the catalog's B-trees.
+const btreeDegree = 32
+
+// catalog holds the products of an in-memory repository keyed by ID, together
+// with the secondary indexes Query plans over: the IDs, names and prices in
+// order, and the IDs per tag and per category. Every write updates the
+// indexes with the product, so they never disagree with it. Its methods do
+// no locking.
+type catalog struct {
+	products   pmap[*product.Product]
+	ids        *btree.BTreeG[indexKey[string]]
+	names      *btree.BTreeG[indexKey[string]]
+	prices     *btree.BTreeG[indexKey[float64]]
+	tags       labelIndex
+	categories labelIndex
+}
+
+// indexKey is an entry of an ordered index: the indexed value of the product
+// with ID id. Entries are ordered by value, then by ID. A key with end set
+// sorts after every entry with the same value; it is only used to start
+// descending scans.
+type indexKey[K cmp.Ordered] struct {
+	value K
+	id    string
+	end   bool
+}
+
+func lessKey[K cmp.Ordered](a, b indexKey[K]) bool {
+	if c := cmp.Compare(a.value, b.value); c != 0 {
+		return c < 0
+	}
+	if a.end != b.end {
+		return b.end
+	}
+	return a.id < b.id
+}
+
+// labelIndex maps a tag or category to the IDs of the products carrying it:
+// it orders an entry per label and ID by label, and counts the IDs per label.
+type labelIndex struct {
+	ids    *btree.BTreeG[indexKey[string]]
+	counts pmap[int]
+}
+
+func newLabelIndex() labelIndex {
+	return labelIndex{ids: btree.NewG(btreeDegree, lessKey[string])}
+}
+
+func (li *labelIndex) add(labels []string, id string) {
+	for _, l := range labels {
+		if _, found := li.ids.ReplaceOrInsert(indexKey[string]{value: l, id: id}); !found {
+			n, _ := li.counts.get(l)
+			li.counts.set(l, n+1)
+		}
+	}
+}
+
+func (li *labelIndex) remove(labels []string, id string) {
+	for _, l := range labels {
+		if _, found := li.ids.Delete(indexKey[string]{value: l, id: id}); found {
+			if n, _ := li.counts.get(l); n > 1 {
+				li.counts.set(l, n-1)
+			} else {
+				li.counts.delete(l)
+			}
+		}
+	}
+}
+
+// count returns the number of products carrying label.
+func (li *labelIndex) count(label string) int {
+	n, _ := li.counts.get(label)
+	return n
+}
+
+// scan calls yield with the IDs of the products carrying label, in ascending
+// order or its reverse, until yield returns false.
+func (li *labelIndex) scan(label string, desc bool, yield func(id string) bool) {
+	scanRange(li.ids, bounds[string]{lo: label, hi: label, hasLo: true, hasHi: true}, desc, yield)
+}
+
+func (li *labelIndex) clone() labelIndex {
+	return labelIndex{ids: li.ids.Clone(), counts: li.counts.clone()}
+}
+
+func newCatalog() *catalog {
+	return &catalog{
+		ids:        btree.NewG(btreeDegree, lessKey[string]),
+		names:      btree.NewG(btreeDegree, lessKey[string]),
+		prices:     btree.NewG(btreeDegree, lessKey[float64]),
+		tags:       newLabelIndex(),
+		categories: newLabelIndex(),
+	}
+}
+
+// clone returns a copy of c. The copy and c share the products and the nodes
+// of their indexes until either changes them, so cloning is cheap and a write
+// to either copies only what it changes. Reads of c may run concurrently with
+// writes to the copy.
+func (c *catalog) clone() *catalog {
+	return &catalog{
+		products:   c.products.clone(),
+		ids:        c.ids.Clone(),
+		names:      c.names.Clone(),
+		prices:     c.prices.Clone(),
+		tags:       c.tags.clone(),
+		categories: c.categories.clone(),
+	}
+}
+
+func (c *catalog) len() int { return c.products.len }
+
+func (c *catalog) get(id string) (*product.Product, error) {
+	p, ok := c.products.get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(p), nil
+}
+
+// product returns the stored product with the given ID, or nil.
+func (c *catalog) product(id string) *product.Product {
+	p, _ := c.products.get(id)
+	return p
+}
+
+// list returns copies of up to limit products with IDs greater than cursor,
+// in ascending ID order, and the cursor of the next page.
+func (c *catalog) list(cursor string, limit int) ([]*product.Product, string) {
+	n := 0
+	if limit > 0 {
+		n = limit + 1
+	}
+	ids := c.listIDs(cursor, n)
+	next := ""
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+		next = ids[limit-1]
+	}
+	page := make([]*product.Product, len(ids))
+	for i, id := range ids {
+		page[i] = clone(c.product(id))
+	}
+	return page, next
+}
+
+// listIDs returns up to n IDs greater than cursor (all of them if n is zero
+// or less) in ascending order.
+func (c *catalog) listIDs(cursor string, n int) []string {
+	var ids []string
+	c.ids.AscendGreaterOrEqual(indexKey[string]{value: cursor}, func(k indexKey[string]) bool {
+		if k.value == cursor {
+			return true
+		}
+		if n > 0 && len(ids) == n {
+			return false
+		}
+		ids = append(ids, k.value)
+		return true
+	})
+	return ids
+}
+
+// put stores p, which the catalog takes ownership of, replacing the product
+// with its ID.
+func (c *catalog) put(p *product.Product) {
+	id := p.GetId()
+	c.delete(id)
+	c.products.set(id, p)
+	c.ids.ReplaceOrInsert(indexKey[string]{value: id, id: id})
+	c.names.ReplaceOrInsert(indexKey[string]{value: p.GetName(), id: id})
+	c.prices.ReplaceOrInsert(indexKey[float64]{value: p.GetPrice(), id: id})
+	c.tags.add(p.GetTags(), id)
+	c.categories.add(p.GetCategories(), id)
+}
+
+// delete removes the product with the given ID and reports whether there was
+// one.
+func (c *catalog) delete(id string) bool {
+	p, ok := c.products.get(id)
+	if !ok {
+		return false
+	}
+	c.products.delete(id)
+	c.ids.Delete(indexKey[string]{value: id, id: id})
+	c.names.Delete(indexKey[string]{value: p.GetName(), id: id})
+	c.prices.Delete(indexKey[float64]{value: p.GetPrice(), id: id})
+	c.tags.remove(p.GetTags(), id)
+	c.categories.remove(p.GetCategories(), id)
+	return true
+}
+
+// apply stores the writes staged by a memoryTx; a nil product is a delete.
+func (c *catalog) apply(writes map[string]*product.Product) {
+	for id, p := range writes {
+		if p == nil {
+			c.delete(id)
+		} else {
+			c.put(p)
+		}
+	}
+}
+
+// bounds is an inclusive range of index values; a missing bound is open.
+type bounds[K cmp.Ordered] struct {
+	lo, hi       K
+	hasLo, hasHi bool
+}
+
+// atLeast narrows b to values of at least v.
+func (b *bounds[K]) atLeast(v K) {
+	if !b.hasLo || v > b.lo {
+		b.lo, b.hasLo = v, true
+	}
+}
+
+// atMost narrows b to values of at most v.
+func (b *bounds[K]) atMost(v K) {
+	if !b.hasHi || v < b.hi {
+		b.hi, b.hasHi = v, true
+	}
+}
+
+// scanRange calls yield with the ID of every entry of t whose value lies in
+// b, in index order or its reverse, until yield returns false.
+func scanRange[K cmp.Ordered](t *btree.BTreeG[indexKey[K]], b bounds[K], desc bool, yield func(id string) bool) {
+	if !desc {
+		visit := func(k indexKey[K]) bool {
+			return !(b.hasHi && k.value > b.hi) && yield(k.id)
+		}
+		if b.hasLo {
+			t.AscendGreaterOrEqual(indexKey[K]{value: b.lo}, visit)
+		} else {
+			t.Ascend(visit)
+		}
+		return
+	}
+	visit := func(k indexKey[K]) bool {
+		return !(b.hasLo && k.value < b.lo) && yield(k.id)
+	}
+	if b.hasHi {
+		t.DescendLessOrEqual(indexKey[K]{value: b.hi, end: true}, visit)
+	} else {
+		t.Descend(visit)
+	}
+}
+
+// countRange returns the number of entries of t in b, or max if there are
+// more.
+func countRange[K cmp.Ordered](t *btree.BTreeG[indexKey[K]], b bounds[K], max int) int {
+	n := 0
+	if max <= 0 {
+		return 0
+	}
+	scanRange(t, b, false, func(string) bool {
+		n++
+		return n < max
+	})
+	return n
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Memory is a ProductRepository backed by a map with secondary indexes. Its
+// contents are lost when the process exits.
+type Memory struct {
+	mu       sync.RWMutex
+	products *catalog
+	defs     definitions
+}
+
+// NewMemory creates a Memory repository holding copies of seed.
+func NewMemory(seed ...*product.Product) *Memory {
+	m := &Memory{products: newCatalog(), defs: definitions{}}
+	for _, p := range seed {
+		m.products.put(clone(p))
+	}
+	return m
+}
+
+// Get implements ProductRepository.
+func (m *Memory) Get(ctx context.Context, id string) (*product.Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.products.get(id)
+}
+
+// List implements ProductRepository. The cursor is the ID of the last product
+// on the previous page.
+func (m *Memory) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	page, next := m.products.list(cursor, limit)
+	return page, next, nil
+}
+
+// Put implements ProductRepository.
+func (m *Memory) Put(ctx context.Context, p *product.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.products.put(clone(p))
+	return nil
+}
+
+// Delete implements ProductRepository.
+func (m *Memory) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.products.delete(id) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Query implements Querier.
+func (m *Memory) Query(ctx context.Context, q Query, fn func(p *product.Product) bool) (Plan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.products.query(q, fn)
+}
+
+// Batch implements ProductRepository. It holds the write lock while fn runs,
+// so fn must not call m's other methods.
+func (m *Memory) Batch(ctx context.Context, fn func(tx Tx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := newMemoryTx(m.products)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.products.apply(tx.writes)
+	return nil
+}
+
+// AttributeDefinitions implements ProductRepository.
+func (m *Memory) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.defs.list(), nil
+}
+
+// PutAttributeDefinition implements ProductRepository.
+func (m *Memory) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defs.put(def)
+	return nil
+}
+
+// memoryTx stages writes over a catalog until they are applied; a nil entry
+// is a delete.
+type memoryTx struct {
+	base   *catalog
+	writes map[string]*product.Product
+}
+
+func newMemoryTx(base *catalog) *memoryTx {
+	return &memoryTx{base: base, writes: make(map[string]*product.Product)}
+}
+
+func (tx *memoryTx) Get(ctx context.Context, id string) (*product.Product, error) {
+	p, staged := tx.writes[id]
+	if !staged {
+		p = tx.base.product(id)
+	}
+	if p == nil {
+		return nil, ErrNotFound
+	}
+	return clone(p), nil
+}
+
+func (tx *memoryTx) Put(ctx context.Context, p *product.Product) error {
+	tx.writes[p.GetId()] = clone(p)
+	return nil
+}
+
+func (tx *memoryTx) Create(ctx context.Context, p *product.Product) error {
+	return create(ctx, tx, p)
+}
+
+func (tx *memoryTx) Delete(ctx context.Context, id string) error {
+	if _, err := tx.Get(ctx, id); err != nil {
+		return err
+	}
+	tx.writes[id] = nil
+	return nil
+}
+
+// create implements Tx.Create with tx's Get and Put, for transactions that
+// run while other writers wait.
+func create(ctx context.Context, tx Tx, p *product.Product) error {
+	_, err := tx.Get(ctx, p.GetId())
+	switch {
+	case err == nil:
+		return ErrAlreadyExists
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+	return tx.Put(ctx, p)
+}
+
+func clone(p *product.Product) *product.Product {
+	return proto.Clone(p).(*product.Product)
+}
+
+// definitions holds copies of attribute definitions keyed by name.
+type definitions map[string]*product.AttributeDefinition
+
+func (d definitions) put(def *product.AttributeDefinition) {
+	d[def.GetName()] = proto.Clone(def).(*product.AttributeDefinition)
+}
+
+// list returns copies of the definitions in name order.
+func (d definitions) list() []*product.AttributeDefinition {
+	defs := make([]*product.AttributeDefinition, 0, len(d))
+	for _, def := range d {
+		defs = append(defs, proto.Clone(def).(*product.AttributeDefinition))
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].GetName() < defs[j].GetName() })
+	return defs
+}
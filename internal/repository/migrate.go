@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFiles holds the schema of the SQL store as numbered scripts named
+// NNNN_description.sql, or NNNN_description.DIALECT.sql for a change only
+// some dialects need; dialects without a script for such a version record it
+// without running anything. Scripts are never edited once released; schema
+// changes are new scripts.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+	// Statements are executed in order, in one transaction where the database
+	// supports transactional DDL.
+	Statements []string
+}
+
+// Migrations returns the embedded migrations of dialect d in version order.
+func Migrations(d Dialect) ([]Migration, error) {
+	paths, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	// scripts holds the scripts of each version, keyed by dialect; the
+	// generic one has the empty key.
+	scripts := make(map[int]map[Dialect]string)
+	names := make(map[int]string)
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "migrations/"), ".sql")
+		var dialect Dialect
+		if base, suffix, ok := strings.Cut(name, "."); ok {
+			dialect = Dialect(suffix)
+			switch dialect {
+			case DialectSQLite, DialectPostgres, DialectMySQL:
+			default:
+				return nil, fmt.Errorf("migration %s: unknown dialect %q", path, suffix)
+			}
+			name = base
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version number", path)
+		}
+		if other, ok := names[version]; ok && other != name {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		names[version] = name
+		if scripts[version] == nil {
+			scripts[version] = make(map[Dialect]string)
+		}
+		scripts[version][dialect] = path
+	}
+	var ms []Migration
+	for version, byDialect := range scripts {
+		if _, ok := byDialect[""]; ok && len(byDialect) > 1 {
+			return nil, fmt.Errorf("migration %s has both a generic script and dialect scripts", names[version])
+		}
+		m := Migration{Version: version, Name: names[version]}
+		path, ok := byDialect[d]
+		if !ok {
+			path, ok = byDialect[""]
+		}
+		if ok {
+			script, err := migrationFiles.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			m.Statements = splitStatements(string(script))
+		}
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
+
+// splitStatements splits a script on semicolons at the end of a line and
+// drops comment lines. Scripts must not put semicolons at the end of a line
+// inside string literals.
+func splitStatements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteByte('\n')
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(cur.String()), ";"))
+			cur.Reset()
+		}
+	}
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
+
+// createMigrationsTable records which migrations have been applied.
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+)`
+
+// Migrate applies the embedded migrations that the database has not seen yet,
+// each in its own transaction, and returns them. Concurrent runs, from this
+// process or another, apply each migration once: on PostgreSQL and MySQL a run
+// holds a lock on the database throughout, and on SQLite each migration
+// checks the version again once its transaction holds the write lock.
+func (s *SQL) Migrate(ctx context.Context) ([]Migration, error) {
+	ms, err := Migrations(s.dialect)
+	if err != nil {
+		return nil, err
+	}
+	// The lock belongs to a session, so everything runs on one connection.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	unlock, err := s.lockMigrations(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("locking the schema for migration: %w", err)
+	}
+	defer unlock()
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, m := range ms {
+		if m.Version <= current {
+			continue
+		}
+		ok, err := s.apply(ctx, conn, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// migrationLock names the lock Migrate holds on PostgreSQL (as an advisory
+// lock key) and MySQL (as a named lock).
+const (
+	migrationLockKey  = 0x70726f6475637473 // "products"
+	migrationLockName = "products.schema_migrations"
+)
+
+// lockMigrations takes the migration lock of s's dialect on conn and returns
+// the function that releases it. SQLite has no such lock; apply takes its
+// write lock instead.
+func (s *SQL) lockMigrations(ctx context.Context, conn *sql.Conn) (unlock func(), err error) {
+	// The lock outlives a cancelled ctx, so it is released regardless.
+	release := context.WithoutCancel(ctx)
+	switch s.dialect {
+	case DialectPostgres:
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", int64(migrationLockKey)); err != nil {
+			return nil, err
+		}
+		return func() { conn.ExecContext(release, "SELECT pg_advisory_unlock($1)", int64(migrationLockKey)) }, nil
+	case DialectMySQL:
+		// GET_LOCK reports failure as 0 or NULL rather than as an error.
+		var ok sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", migrationLockName).Scan(&ok); err != nil {
+			return nil, err
+		}
+		if ok.Int64 != 1 {
+			return nil, errors.New("GET_LOCK failed")
+		}
+		return func() { conn.ExecContext(release, "SELECT RELEASE_LOCK(?)", migrationLockName) }, nil
+	}
+	return func() {}, nil
+}
+
+// apply applies m unless the database already has, and reports whether it
+// did.
+func (s *SQL) apply(ctx context.Context, conn *sql.Conn, m Migration) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if s.dialect == DialectSQLite {
+		// A write takes the database's write lock, which holds until the
+		// transaction ends, so the version read below stays current.
+		if _, err := tx.ExecContext(ctx, "UPDATE schema_migrations SET name = name WHERE version < 0"); err != nil {
+			return false, err
+		}
+	}
+	current, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+	if m.Version <= current {
+		return false, nil
+	}
+	for _, stmt := range m.Statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"), m.Version, m.Name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// SchemaVersion returns the version of the last applied migration, or 0 if
+// none has been applied.
+func (s *SQL) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, s.db)
+}
+
+func schemaVersion(ctx context.Context, q queryer) (int, error) {
+	var version sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// CheckSchema returns an error unless every embedded migration has been applied.
+func (s *SQL) CheckSchema(ctx context.Context) error {
+	ms, err := Migrations(s.dialect)
+	if err != nil {
+		return err
+	}
+	want := ms[len(ms)-1].Version
+	got, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("reading the schema version (has the database been migrated?): %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("database schema is at version %d, want %d: run the migrate command", got, want)
+	}
+	return nil
+}
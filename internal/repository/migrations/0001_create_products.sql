@@ -0,0 +1,8 @@
+-- Products are stored as protojson in body; id, name and price are copied
+-- into columns so that they can be indexed and queried.
+CREATE TABLE products (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    body TEXT NOT NULL
+);
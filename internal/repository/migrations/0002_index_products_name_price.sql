@@ -0,0 +1,2 @@
+CREATE INDEX products_name ON products (name);
+CREATE INDEX products_price ON products (price);
@@ -0,0 +1,6 @@
+-- Custom attribute definitions are stored as protojson in body, keyed by
+-- their name.
+CREATE TABLE attribute_definitions (
+    name VARCHAR(255) NOT NULL PRIMARY KEY,
+    body TEXT NOT NULL
+);
@@ -0,0 +1,4 @@
+-- IDs and attribute names are compared byte by byte, as by the other
+-- repositories, rather than by MySQL's case-insensitive default collation.
+ALTER TABLE products MODIFY id VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL;
+ALTER TABLE attribute_definitions MODIFY name VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL;
@@ -0,0 +1,4 @@
+-- IDs and attribute names are ordered byte by byte, as by the other
+-- repositories, rather than by the database's locale.
+ALTER TABLE products ALTER COLUMN id TYPE VARCHAR(255) COLLATE "C";
+ALTER TABLE attribute_definitions ALTER COLUMN name TYPE VARCHAR(255) COLLATE "C";
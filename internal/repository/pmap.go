@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// pmap is a persistent map from strings to V: a hash array mapped trie whose
+// copies made by clone share every node until one of them changes it. Like
+// the B-trees of the catalog, a pmap changes the nodes it owns in place and
+// copies the others, so a map that is never cloned costs no extra copying.
+// Reads of a map may run concurrently with writes to its clones, but not with
+// writes to the map itself. The zero value is an empty map.
+type pmap[V any] struct {
+	root *pnode[V]
+	len  int
+	// owner marks the nodes this map may change in place.
+	owner *pmapOwner
+}
+
+// pmapOwner identifies the nodes of one pmap. It is not zero-sized, so every
+// new owner has a distinct address.
+type pmapOwner struct{ _ byte }
+
+// pmapBits is the number of hash bits consumed by each level of the trie.
+const pmapBits = 5
+
+// pnode is a trie node. Below the depth where the 64 hash bits run out, a
+// node is a bucket of colliding keys, searched in turn.
+type pnode[V any] struct {
+	owner *pmapOwner
+	// bitmap has a bit set for every hash chunk at this level that has an
+	// entry; entries holds them in the order of their bits.
+	bitmap  uint32
+	entries []pentry[V]
+}
+
+// pentry is a key and its value, or the subtrie of the keys sharing a hash
+// chunk if child is set.
+type pentry[V any] struct {
+	child *pnode[V]
+	key   string
+	value V
+}
+
+// hashKey hashes key with 64-bit FNV-1a.
+func hashKey(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// chunk returns the bit of the hash chunk of h at shift and the index of its
+// entry in n.
+func (n *pnode[V]) chunk(h uint64, shift uint) (bit uint32, i int) {
+	bit = 1 << (h >> shift & (1<<pmapBits - 1))
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+// clone returns a copy of m. Neither m nor the copy changes the nodes they
+// share; each copies a node the first time it changes it.
+func (m *pmap[V]) clone() pmap[V] {
+	m.owner = new(pmapOwner)
+	return pmap[V]{root: m.root, len: m.len, owner: new(pmapOwner)}
+}
+
+func (m *pmap[V]) get(key string) (V, bool) {
+	h := hashKey(key)
+	for n, shift := m.root, uint(0); n != nil; shift += pmapBits {
+		if shift >= 64 {
+			for _, e := range n.entries {
+				if e.key == key {
+					return e.value, true
+				}
+			}
+			break
+		}
+		bit, i := n.chunk(h, shift)
+		if n.bitmap&bit == 0 {
+			break
+		}
+		e := &n.entries[i]
+		if e.child == nil {
+			if e.key == key {
+				return e.value, true
+			}
+			break
+		}
+		n = e.child
+	}
+	var zero V
+	return zero, false
+}
+
+// set maps key to value.
+func (m *pmap[V]) set(key string, value V) {
+	root := m.root
+	if root == nil {
+		root = &pnode[V]{owner: m.owner}
+	}
+	var added bool
+	m.root, added = m.insert(root, 0, hashKey(key), key, value)
+	if added {
+		m.len++
+	}
+}
+
+// delete removes key and reports whether m had it.
+func (m *pmap[V]) delete(key string) bool {
+	if m.root == nil {
+		return false
+	}
+	root, removed := m.remove(m.root, 0, hashKey(key), key)
+	if !removed {
+		return false
+	}
+	m.root = root
+	m.len--
+	return true
+}
+
+// all calls fn with every key and value, in no particular order, until fn
+// returns false.
+func (m *pmap[V]) all(fn func(key string, value V) bool) {
+	var walk func(n *pnode[V]) bool
+	walk = func(n *pnode[V]) bool {
+		for _, e := range n.entries {
+			if e.child != nil && !walk(e.child) || e.child == nil && !fn(e.key, e.value) {
+				return false
+			}
+		}
+		return true
+	}
+	if m.root != nil {
+		walk(m.root)
+	}
+}
+
+// editable returns n if m owns it and a copy owned by m otherwise.
+func (m *pmap[V]) editable(n *pnode[V]) *pnode[V] {
+	if n.owner == m.owner {
+		return n
+	}
+	return &pnode[V]{owner: m.owner, bitmap: n.bitmap, entries: slices.Clone(n.entries)}
+}
+
+func (m *pmap[V]) insert(n *pnode[V], shift uint, h uint64, key string, value V) (*pnode[V], bool) {
+	n = m.editable(n)
+	if shift >= 64 {
+		for i := range n.entries {
+			if n.entries[i].key == key {
+				n.entries[i].value = value
+				return n, false
+			}
+		}
+		n.entries = append(n.entries, pentry[V]{key: key, value: value})
+		return n, true
+	}
+	bit, i := n.chunk(h, shift)
+	if n.bitmap&bit == 0 {
+		n.bitmap |= bit
+		n.entries = slices.Insert(n.entries, i, pentry[V]{key: key, value: value})
+		return n, true
+	}
+	e := &n.entries[i]
+	switch {
+	case e.child != nil:
+		var added bool
+		e.child, added = m.insert(e.child, shift+pmapBits, h, key, value)
+		return n, added
+	case e.key == key:
+		e.value = value
+		return n, false
+	}
+	// Two keys share this chunk: move both a level down.
+	child := &pnode[V]{owner: m.owner}
+	child, _ = m.insert(child, shift+pmapBits, hashKey(e.key), e.key, e.value)
+	child, _ = m.insert(child, shift+pmapBits, h, key, value)
+	*e = pentry[V]{child: child}
+	return n, true
+}
+
+// remove returns n without key, nil if that leaves it empty, and whether key
+// was there. A subtrie left with a single key is replaced by that key.
+func (m *pmap[V]) remove(n *pnode[V], shift uint, h uint64, key string) (*pnode[V], bool) {
+	if shift >= 64 {
+		i := slices.IndexFunc(n.entries, func(e pentry[V]) bool { return e.key == key })
+		if i < 0 {
+			return n, false
+		}
+		n = m.editable(n)
+		n.entries = slices.Delete(n.entries, i, i+1)
+		return collapse(n), true
+	}
+	bit, i := n.chunk(h, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	e := n.entries[i]
+	if e.child == nil {
+		if e.key != key {
+			return n, false
+		}
+		n = m.editable(n)
+		n.bitmap &^= bit
+		n.entries = slices.Delete(n.entries, i, i+1)
+		return collapse(n), true
+	}
+	child, removed := m.remove(e.child, shift+pmapBits, h, key)
+	if !removed {
+		return n, false
+	}
+	n = m.editable(n)
+	switch {
+	case child == nil:
+		n.bitmap &^= bit
+		n.entries = slices.Delete(n.entries, i, i+1)
+		return collapse(n), true
+	case len(child.entries) == 1 && child.entries[0].child == nil:
+		n.entries[i] = child.entries[0]
+	default:
+		n.entries[i].child = child
+	}
+	return n, true
+}
+
+// collapse returns nil for an empty node and n otherwise.
+func collapse[V any](n *pnode[V]) *pnode[V] {
+	if len(n.entries) == 0 {
+		return nil
+	}
+	return n
+}
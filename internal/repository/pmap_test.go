@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"testing"
+)
+
+// checkPmap fails unless m holds exactly want.
+func checkPmap(t *testing.T, m *pmap[int], want map[string]int) {
+	t.Helper()
+	if m.len != len(want) {
+		t.Fatalf("len %d, want %d", m.len, len(want))
+	}
+	for k, v := range want {
+		if got, ok := m.get(k); !ok || got != v {
+			t.Fatalf("get(%q) = %d, %v; want %d", k, got, ok, v)
+		}
+	}
+	n := 0
+	m.all(func(k string, v int) bool {
+		if want[k] != v {
+			t.Fatalf("all yielded %q: %d, want %d", k, v, want[k])
+		}
+		n++
+		return true
+	})
+	if n != len(want) {
+		t.Fatalf("all yielded %d entries, want %d", n, len(want))
+	}
+}
+
+func TestPmap_MatchesMapAcrossClones(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var m pmap[int]
+	want := map[string]int{}
+	type version struct {
+		m    pmap[int]
+		want map[string]int
+	}
+	var versions []version
+	for i := range 20000 {
+		k := fmt.Sprintf("k%d", rng.IntN(3000))
+		if rng.IntN(3) == 0 {
+			_, had := want[k]
+			if m.delete(k) != had {
+				t.Fatalf("delete(%q) reported %v, want %v", k, !had, had)
+			}
+			delete(want, k)
+		} else {
+			m.set(k, i)
+			want[k] = i
+		}
+		if i%1000 == 0 {
+			versions = append(versions, version{m.clone(), maps.Clone(want)})
+		}
+	}
+	checkPmap(t, &m, want)
+	if _, ok := m.get("missing"); ok {
+		t.Fatal("get of a missing key succeeded")
+	}
+	// Writes after a clone leave the clone as it was.
+	for _, v := range versions {
+		checkPmap(t, &v.m, v.want)
+	}
+	for k := range want {
+		m.delete(k)
+	}
+	if m.len != 0 || m.root != nil {
+		t.Fatalf("emptied map has len %d, root %v", m.len, m.root)
+	}
+}
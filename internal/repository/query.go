@@ -0,0 +1,389 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/generated/product"
+)
+
+// Query selects and orders products.
+type Query struct {
+	// Filter selects the products; nil selects every product.
+	Filter *filter.Filter
+	// Order is the order the products are returned in.
+	Order Order
+	// Limit is a hint that the caller stops after this many products, which
+	// the planner uses to prefer reading an index in order. Zero means the
+	// caller reads every product.
+	Limit int
+}
+
+// Order is the order of a Query's results. Products that compare equal on
+// Field are ordered by ID, in the same direction.
+type Order struct {
+	// Field is "id", "name" or "price"; empty means "id".
+	Field string
+	// Desc reverses the order.
+	Desc bool
+}
+
+// ParseOrder parses an order such as "price desc": a field accepted by
+// Order.Field, optionally followed by "asc" or "desc". An empty string is
+// ascending ID order.
+func ParseOrder(s string) (Order, error) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return Order{Field: "id"}, nil
+	}
+	o, err := Order{Field: words[0]}.normalize()
+	if err != nil {
+		return Order{}, err
+	}
+	if len(words) > 1 {
+		switch words[1] {
+		case "asc":
+		case "desc":
+			o.Desc = true
+		default:
+			return Order{}, fmt.Errorf("unknown direction %q; use asc or desc", words[1])
+		}
+	}
+	if len(words) > 2 {
+		return Order{}, fmt.Errorf("unexpected %q after the direction", words[2])
+	}
+	return o, nil
+}
+
+// normalize returns o with an empty Field replaced by "id", or an error if
+// Field is unknown.
+func (o Order) normalize() (Order, error) {
+	switch o.Field {
+	case "":
+		o.Field = "id"
+	case "id", "name", "price":
+	default:
+		return Order{}, fmt.Errorf("cannot order by %q; use id, name or price", o.Field)
+	}
+	return o, nil
+}
+
+// String returns the order in the syntax accepted by ParseOrder.
+func (o Order) String() string {
+	s := cmp.Or(o.Field, "id")
+	if o.Desc {
+		s += " desc"
+	}
+	return s
+}
+
+// compare orders a and b by o.
+func (o Order) compare(a, b *product.Product) int {
+	c := 0
+	switch o.Field {
+	case "name":
+		c = cmp.Compare(a.GetName(), b.GetName())
+	case "price":
+		c = cmp.Compare(a.GetPrice(), b.GetPrice())
+	}
+	if c == 0 {
+		c = cmp.Compare(a.GetId(), b.GetId())
+	}
+	if o.Desc {
+		c = -c
+	}
+	return c
+}
+
+// Plan describes how a Query was answered.
+type Plan struct {
+	// Index is the index the candidate products were read from: "id",
+	// "name" or "price" for a range of an ordered index, "tags" or
+	// "categories" for the products carrying one label, or "" if the
+	// repository has no indexes and was listed in full.
+	Index string
+	// Estimate is the planner's estimate of the number of candidates read
+	// before the filter is applied to them.
+	Estimate int
+	// Sorted reports that the candidates were sorted because Index does not
+	// yield them in the requested order.
+	Sorted bool
+}
+
+// Querier is implemented by repositories that answer queries from secondary
+// indexes rather than by listing every product.
+type Querier interface {
+	// Query calls fn with a copy of every product matching q, in q's order,
+	// until fn returns false, and returns the plan it used.
+	Query(ctx context.Context, q Query, fn func(p *product.Product) bool) (Plan, error)
+}
+
+// Select calls fn with every product of repo matching q, in q's order, until
+// fn returns false. It uses repo's indexes if repo is a Querier and lists
+// every product otherwise.
+func Select(ctx context.Context, repo ProductRepository, q Query, fn func(p *product.Product) bool) (Plan, error) {
+	if qr, ok := repo.(Querier); ok {
+		return qr.Query(ctx, q, fn)
+	}
+	order, err := q.Order.normalize()
+	if err != nil {
+		return Plan{}, err
+	}
+	inOrder := order == Order{Field: "id"}
+	var matches []*product.Product
+	cursor := ""
+	for {
+		page, next, err := repo.List(ctx, cursor, listPageSize)
+		if err != nil {
+			return Plan{}, err
+		}
+		for _, p := range page {
+			switch {
+			case !q.Filter.Match(p):
+			case inOrder:
+				if !fn(p) {
+					return Plan{}, nil
+				}
+			default:
+				matches = append(matches, p)
+			}
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	slices.SortFunc(matches, order.compare)
+	for _, p := range matches {
+		if !fn(p) {
+			break
+		}
+	}
+	return Plan{Sorted: !inOrder}, nil
+}
+
+// sortCost is the planner's cost of sorting n products, in units of reading
+// and filtering one, taking a comparison to cost a quarter of that.
+func sortCost(n int) float64 {
+	return float64(n) * math.Log2(float64(n)+1) / 4
+}
+
+// listPageSize is the page size Select lists repositories without indexes
+// with.
+const listPageSize = 256
+
+// source is a way of reading candidate products from one of a catalog's
+// indexes.
+type source struct {
+	index string
+	// order is the field whose order scan yields products in.
+	order string
+	// size is the number of products scan yields, or an estimate of it.
+	size int
+	scan func(desc bool, yield func(id string) bool)
+}
+
+// query answers q from the catalog's indexes. The planner collects a source
+// per indexable term of the filter's top-level conjunction: a range of the
+// id, name or price index, or the IDs carrying a tag or category. Every
+// source yields a superset of the matches, so the smallest bounds the number
+// of matches. It then picks the source that reads the fewest products,
+// counting the cost of sorting for sources that do not yield the requested
+// order and stopping early after Limit matches for those that do, and
+// applies the whole filter to what it reads.
+func (c *catalog) query(q Query, fn func(p *product.Product) bool) (Plan, error) {
+	order, err := q.Order.normalize()
+	if err != nil {
+		return Plan{}, err
+	}
+	sources := c.sources(q.Filter, order.Field)
+	matches := c.len()
+	for _, s := range sources {
+		matches = min(matches, s.size)
+	}
+	best, bestCost := sources[0], math.Inf(1)
+	for _, s := range sources {
+		var cost float64
+		if s.order == order.Field {
+			cost = float64(s.size)
+			if q.Limit > 0 && matches > 0 {
+				// Assume the matches are spread evenly over the source.
+				cost = min(cost, math.Ceil(float64(q.Limit)*float64(s.size)/float64(matches)))
+			}
+		} else {
+			cost = float64(s.size) + sortCost(matches)
+		}
+		if cost < bestCost {
+			best, bestCost = s, cost
+		}
+	}
+
+	plan := Plan{Index: best.index, Estimate: best.size, Sorted: best.order != order.Field}
+	if !plan.Sorted {
+		best.scan(order.Desc, func(id string) bool {
+			p := c.product(id)
+			return !q.Filter.Match(p) || fn(clone(p))
+		})
+		return plan, nil
+	}
+	var found []*product.Product
+	best.scan(false, func(id string) bool {
+		if p := c.product(id); q.Filter.Match(p) {
+			found = append(found, p)
+		}
+		return true
+	})
+	slices.SortFunc(found, order.compare)
+	for _, p := range found {
+		if !fn(clone(p)) {
+			break
+		}
+	}
+	return plan, nil
+}
+
+// sources returns the ways of reading candidates for f, ending with a scan
+// of the whole index of the order field unless f restricts that field.
+// Ranges are sized by counting their entries; a size at the counting budget
+// is a lower bound.
+func (c *catalog) sources(f *filter.Filter, orderField string) []source {
+	var (
+		ids, names     bounds[string]
+		prices         bounds[float64]
+		hasID, hasName bool
+		hasPrice       bool
+		sources        []source
+	)
+	for _, term := range conjuncts(f) {
+		comp, ok := term.(*filter.Comparison)
+		if !ok || (comp.Op == filter.OpHas && comp.Value.Text == "*" && !comp.Value.Quoted) {
+			continue
+		}
+		switch comp.Field {
+		case "id":
+			hasID = narrowString(&ids, comp) || hasID
+		case "name":
+			hasName = narrowString(&names, comp) || hasName
+		case "price":
+			hasPrice = narrowNumber(&prices, comp) || hasPrice
+		case "tags", "categories":
+			if comp.Op != filter.OpEq && comp.Op != filter.OpHas || strings.HasSuffix(comp.Value.Text, "*") {
+				continue
+			}
+			labels := &c.tags
+			if comp.Field == "categories" {
+				labels = &c.categories
+			}
+			label := comp.Value.Text
+			sources = append(sources, source{index: comp.Field, order: "id", size: labels.count(label), scan: func(desc bool, yield func(id string) bool) {
+				labels.scan(label, desc, yield)
+			}})
+		}
+	}
+
+	smallest := c.len()
+	for _, s := range sources {
+		smallest = min(smallest, s.size)
+	}
+	// A range is only worth counting up to the cost of reading and sorting
+	// the smallest source; beyond that its size does not change the plan.
+	budget := func() int {
+		return min(c.len(), smallest+int(sortCost(smallest))+1)
+	}
+	addRange := func(index string, size int, scan func(desc bool, yield func(id string) bool)) {
+		sources = append(sources, source{index: index, order: index, size: size, scan: scan})
+		smallest = min(smallest, size)
+	}
+	if hasID || orderField == "id" {
+		size := c.len()
+		if hasID {
+			size = countRange(c.ids, ids, budget())
+		}
+		addRange("id", size, func(desc bool, yield func(id string) bool) { scanRange(c.ids, ids, desc, yield) })
+	}
+	if hasName || orderField == "name" {
+		size := c.len()
+		if hasName {
+			size = countRange(c.names, names, budget())
+		}
+		addRange("name", size, func(desc bool, yield func(id string) bool) { scanRange(c.names, names, desc, yield) })
+	}
+	if hasPrice || orderField == "price" {
+		size := c.len()
+		if hasPrice {
+			size = countRange(c.prices, prices, budget())
+		}
+		addRange("price", size, func(desc bool, yield func(id string) bool) { scanRange(c.prices, prices, desc, yield) })
+	}
+	return sources
+}
+
+// conjuncts returns the terms of f's top-level conjunction.
+func conjuncts(f *filter.Filter) []filter.Expr {
+	if f == nil || f.Expr == nil {
+		return nil
+	}
+	var terms []filter.Expr
+	var walk func(e filter.Expr)
+	walk = func(e filter.Expr) {
+		if and, ok := e.(*filter.And); ok {
+			for _, t := range and.Terms {
+				walk(t)
+			}
+			return
+		}
+		terms = append(terms, e)
+	}
+	walk(f.Expr)
+	return terms
+}
+
+// narrowString narrows b to the values a string comparison can match and
+// reports whether it could. A prefix match covers the values from the prefix
+// up to the prefix followed by 0xff, which never occurs in UTF-8.
+func narrowString(b *bounds[string], c *filter.Comparison) bool {
+	text := c.Value.Text
+	switch c.Op {
+	case filter.OpEq, filter.OpHas:
+		if prefix, ok := strings.CutSuffix(text, "*"); ok {
+			b.atLeast(prefix)
+			b.atMost(prefix + "\xff")
+		} else {
+			b.atLeast(text)
+			b.atMost(text)
+		}
+	case filter.OpLt, filter.OpLe:
+		b.atMost(text)
+	case filter.OpGt, filter.OpGe:
+		b.atLeast(text)
+	default:
+		return false
+	}
+	return true
+}
+
+// narrowNumber narrows b to the values a numeric comparison can match and
+// reports whether it could.
+func narrowNumber(b *bounds[float64], c *filter.Comparison) bool {
+	if !c.Value.IsNumber {
+		return false
+	}
+	n := c.Value.Number
+	switch c.Op {
+	case filter.OpEq, filter.OpHas:
+		b.atLeast(n)
+		b.atMost(n)
+	case filter.OpLt, filter.OpLe:
+		b.atMost(n)
+	case filter.OpGt, filter.OpGe:
+		b.atLeast(n)
+	default:
+		return false
+	}
+	return true
+}
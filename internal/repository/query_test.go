@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/generated/product"
+)
+
+// unindexed hides the Querier of a repository, so Select lists it in full.
+type unindexed struct{ ProductRepository }
+
+var testNames = []string{"Lamp", "Desk", "Chair", "Lampshade", "Shelf", "Rug", "Mirror"}
+
+// testCatalog returns n products with spread out names, prices, tags and
+// categories.
+func testCatalog(n int) []*product.Product {
+	ps := make([]*product.Product, n)
+	for i := range ps {
+		ps[i] = &product.Product{
+			Id:         fmt.Sprintf("p%07d", i),
+			Name:       fmt.Sprintf("%s %d", testNames[i%len(testNames)], i*7919%n),
+			Price:      float64(i*104729%100000) / 100,
+			Tags:       []string{fmt.Sprintf("t%d", i%50), fmt.Sprintf("u%d", i%7)},
+			Categories: []string{fmt.Sprintf("c%d", i%20)},
+		}
+	}
+	return ps
+}
+
+func selectAll(t testing.TB, repo ProductRepository, q Query) ([]string, Plan) {
+	t.Helper()
+	var ids []string
+	plan, err := Select(context.Background(), repo, q, func(p *product.Product) bool {
+		ids = append(ids, p.GetId())
+		return q.Limit == 0 || len(ids) < q.Limit
+	})
+	if err != nil {
+		t.Fatalf("Select(%v): %v", q, err)
+	}
+	return ids, plan
+}
+
+var testQueries = []struct {
+	filter, order string
+}{
+	{"", ""},
+	{"", "name desc"},
+	{"", "price"},
+	{`name = "Lamp*"`, ""},
+	{`name = "Lamp*" AND price < 100`, "price desc"},
+	{`name >= "M" AND name < "S"`, "name"},
+	{`name = "Rug 3"`, ""},
+	{`price >= 10 AND price <= 12.5`, "price"},
+	{`price > 900`, "name desc"},
+	{`price = 0`, ""},
+	{`price < 5 AND price > 10`, ""},
+	{`tags = "t7"`, ""},
+	{`tags : "t7" AND categories = "c3"`, "id desc"},
+	{`tags = "t7" AND price < 300`, "price"},
+	{`categories = "c1" AND NOT tags = "u2"`, "name"},
+	{`tags = "nope"`, ""},
+	{`id >= "p0000100" AND id < "p0000200"`, "id desc"},
+	{`tags = "t1" OR tags = "t2"`, "price"},
+	{`name:* AND price != 10`, ""},
+}
+
+func TestQuery_MatchesFullScan(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(testCatalog(2000)...)
+	check := func(t *testing.T) {
+		t.Helper()
+		for _, tc := range testQueries {
+			f, err := filter.Parse(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			order, err := ParseOrder(tc.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, limit := range []int{0, 10} {
+				q := Query{Filter: f, Order: order, Limit: limit}
+				got, plan := selectAll(t, m, q)
+				want, _ := selectAll(t, unindexed{m}, q)
+				if !slices.Equal(got, want) {
+					t.Errorf("filter %q order %q limit %d with plan %+v: got %d products, want %d", tc.filter, tc.order, limit, plan, len(got), len(want))
+				}
+			}
+		}
+	}
+	check(t)
+
+	// Every kind of write keeps the indexes consistent.
+	for i := 0; i < 2000; i += 3 {
+		p, _ := m.Get(ctx, fmt.Sprintf("p%07d", i))
+		p.Name, p.Price, p.Tags = "Lamp "+p.GetName(), p.GetPrice()+1, []string{"t7"}
+		m.Put(ctx, p)
+	}
+	for i := 1; i < 2000; i += 5 {
+		m.Delete(ctx, fmt.Sprintf("p%07d", i))
+	}
+	err := m.Batch(ctx, func(tx Tx) error {
+		for i := 2; i < 2000; i += 7 {
+			id := fmt.Sprintf("p%07d", i)
+			p, err := tx.Get(ctx, id)
+			if err != nil {
+				tx.Put(ctx, &product.Product{Id: id, Name: "Mirror new", Price: 11, Categories: []string{"c3"}})
+				continue
+			}
+			p.Categories = nil
+			if err := tx.Put(ctx, p); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(ctx, "p0000004")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t)
+}
+
+func TestQuery_PlansWithTheMostSelectiveIndex(t *testing.T) {
+	m := NewMemory(testCatalog(10000)...)
+	tests := []struct {
+		filter, order string
+		limit         int
+		want          Plan
+	}{
+		{"", "", 10, Plan{Index: "id", Estimate: 10000}},
+		{"", "price desc", 10, Plan{Index: "price", Estimate: 10000}},
+		{`name = "Rug 3*"`, "", 0, Plan{Index: "name", Estimate: 158, Sorted: true}},
+		{`tags = "t7"`, "", 10, Plan{Index: "tags", Estimate: 200}},
+		{`tags = "t7" AND categories = "c3"`, "", 0, Plan{Index: "tags", Estimate: 200}},
+		{`tags = "u1" AND categories = "c3"`, "", 0, Plan{Index: "categories", Estimate: 500}},
+		{`tags = "t7" AND price >= 10 AND price < 11`, "price", 0, Plan{Index: "price", Estimate: 10}},
+		{`tags = "t7" AND price < 500`, "price", 0, Plan{Index: "tags", Estimate: 200, Sorted: true}},
+		// Walking the price index in order beats sorting every product
+		// tagged u1 when only the first page is wanted.
+		{`tags = "u1"`, "price", 10, Plan{Index: "price", Estimate: 10000}},
+		{`tags = "u1"`, "price", 0, Plan{Index: "tags", Estimate: 1429, Sorted: true}},
+		{`tags = "t1" OR tags = "t2"`, "", 10, Plan{Index: "id", Estimate: 10000}},
+	}
+	for _, tc := range tests {
+		f, _ := filter.Parse(tc.filter)
+		order, _ := ParseOrder(tc.order)
+		_, plan := selectAll(t, m, Query{Filter: f, Order: order, Limit: tc.limit})
+		if plan != tc.want {
+			t.Errorf("filter %q order %q limit %d: plan %+v, want %+v", tc.filter, tc.order, tc.limit, plan, tc.want)
+		}
+	}
+}
+
+func TestQuery_StopsWhenFnReturnsFalse(t *testing.T) {
+	m := NewMemory(testCatalog(100)...)
+	for _, order := range []string{"", "name"} {
+		o, _ := ParseOrder(order)
+		n := 0
+		Select(context.Background(), m, Query{Order: o}, func(p *product.Product) bool {
+			n++
+			return n < 3
+		})
+		if n != 3 {
+			t.Errorf("order %q: fn called %d times after returning false", order, n)
+		}
+	}
+}
+
+func TestFile_QueryUsesIndexesRebuiltOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	f := openFile(t, dir, FileOptions{})
+	for _, p := range testCatalog(100) {
+		if err := f.Put(context.Background(), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+	f = openFile(t, dir, FileOptions{})
+	flt, _ := filter.Parse(`tags = "t7"`)
+	ids, plan := selectAll(t, f, Query{Filter: flt})
+	if want := []string{"p0000007", "p0000057"}; !slices.Equal(ids, want) || plan.Index != "tags" {
+		t.Fatalf("got %v with plan %+v, want %v from the tags index", ids, plan, want)
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	for in, want := range map[string]Order{
+		"":            {Field: "id"},
+		"name":        {Field: "name"},
+		" price desc": {Field: "price", Desc: true},
+		"id asc":      {Field: "id"},
+	} {
+		if got, err := ParseOrder(in); err != nil || got != want {
+			t.Errorf("ParseOrder(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"tags", "price down", "name asc extra"} {
+		if _, err := ParseOrder(in); err == nil {
+			t.Errorf("ParseOrder(%q) succeeded", in)
+		}
+	}
+}
+
+var (
+	benchOnce    sync.Once
+	benchCatalog *Memory
+)
+
+// BenchmarkQuery compares answering typical first-page requests from the
+// indexes of a 1M-product catalog with listing and filtering all of it.
+func BenchmarkQuery(b *testing.B) {
+	benchOnce.Do(func() { benchCatalog = NewMemory(testCatalog(1_000_000)...) })
+	for _, bc := range []struct {
+		name, filter, order string
+	}{
+		{"NamePrefix", `name = "Rug 12345*"`, ""},
+		{"PriceRange", `price >= 10 AND price < 10.5`, "price"},
+		{"Tag", `tags = "t7" AND categories = "c7"`, ""},
+		{"TagByPrice", `tags = "t7"`, "price desc"},
+		{"ByName", "", "name"},
+	} {
+		f, err := filter.Parse(bc.filter)
+		if err != nil {
+			b.Fatal(err)
+		}
+		order, _ := ParseOrder(bc.order)
+		q := Query{Filter: f, Order: order, Limit: 10}
+		for _, repo := range []struct {
+			name string
+			r    ProductRepository
+		}{{"Indexed", benchCatalog}, {"Scan", unindexed{benchCatalog}}} {
+			b.Run(bc.name+"/"+repo.name, func(b *testing.B) {
+				for b.Loop() {
+					selectAll(b, repo.r, q)
+				}
+			})
+		}
+	}
+}
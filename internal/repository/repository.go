@@ -0,0 +1,55 @@
+// Package repository defines how the Product API stores products and
+// provides the implementations selected by config.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+// ErrNotFound is returned when no product has the requested ID.
+var ErrNotFound = errors.New("product not found")
+
+// ErrAlreadyExists is returned by Tx.Create when a product with the ID exists.
+var ErrAlreadyExists = errors.New("product already exists")
+
+// ProductRepository stores products keyed by ID, and the custom attribute
+// definitions their attributes are validated against keyed by name.
+//
+// Implementations copy products and definitions on the way in and out: a
+// value passed to Put, or returned by Get or List, never shares memory with
+// stored state. They must be safe for concurrent use.
+type ProductRepository interface {
+	// Get returns the product with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*product.Product, error)
+	// List returns up to limit products in ascending ID order, starting after
+	// cursor (empty for the first page), and the cursor of the next page,
+	// which is empty after the last one. A limit of zero or less returns
+	// every remaining product.
+	List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error)
+	// Put creates or replaces the product with p's ID.
+	Put(ctx context.Context, p *product.Product) error
+	// Delete removes the product with the given ID, or returns ErrNotFound.
+	Delete(ctx context.Context, id string) error
+	// Batch runs fn in a transaction. The writes fn makes through tx are
+	// applied together if fn returns nil and discarded otherwise; reads
+	// through tx see them.
+	Batch(ctx context.Context, fn func(tx Tx) error) error
+	// AttributeDefinitions returns every attribute definition in name order.
+	AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error)
+	// PutAttributeDefinition creates or replaces the definition with def's
+	// name. It does not validate def; that is up to the caller.
+	PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error
+}
+
+// Tx is the view of a repository inside Batch.
+type Tx interface {
+	Get(ctx context.Context, id string) (*product.Product, error)
+	Put(ctx context.Context, p *product.Product) error
+	// Create stores p, or returns ErrAlreadyExists if a product with its ID
+	// exists, checking and writing atomically with respect to other writers.
+	Create(ctx context.Context, p *product.Product) error
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// testRepository checks the ProductRepository contract against the empty
+// repositories returned by open.
+func testRepository(t *testing.T, open func(t *testing.T) ProductRepository) {
+	ctx := context.Background()
+
+	t.Run("GetPutDelete", func(t *testing.T) {
+		r := open(t)
+		if _, err := r.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get of a missing product: got %v, want ErrNotFound", err)
+		}
+		want := &product.Product{Id: "a", Name: "Widget", Price: 1.5, Tags: []string{"x"},
+			Attributes: map[string]*product.AttributeValue{"color": {Kind: &product.AttributeValue_StringValue{StringValue: "red"}}}}
+		if err := r.Put(ctx, want); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		got, err := r.Get(ctx, "a")
+		if err != nil || !proto.Equal(got, want) {
+			t.Fatalf("Get = %v, %v; want %v", got, err, want)
+		}
+		if err := r.Put(ctx, &product.Product{Id: "a", Name: "Widget v2"}); err != nil {
+			t.Fatalf("Put (replace): %v", err)
+		}
+		if got, _ := r.Get(ctx, "a"); got.GetName() != "Widget v2" || got.GetPrice() != 0 {
+			t.Fatalf("Put did not replace the product: %v", got)
+		}
+		if err := r.Delete(ctx, "a"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := r.Delete(ctx, "a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("second Delete: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Copies", func(t *testing.T) {
+		r := open(t)
+		p := &product.Product{Id: "a", Name: "Widget", Tags: []string{"x"}}
+		r.Put(ctx, p)
+		p.Name = "changed by caller"
+		p.Tags[0] = "changed by caller"
+
+		got, _ := r.Get(ctx, "a")
+		if got.GetName() != "Widget" || got.GetTags()[0] != "x" {
+			t.Fatalf("stored product changed through the Put argument: %v", got)
+		}
+		got.Name = "changed by caller"
+		page, _, _ := r.List(ctx, "", 0)
+		page[0].Tags[0] = "changed by caller"
+		if again, _ := r.Get(ctx, "a"); again.GetName() != "Widget" || again.GetTags()[0] != "x" {
+			t.Fatalf("stored product changed through a returned pointer: %v", again)
+		}
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		r := open(t)
+		for i := 5; i >= 1; i-- {
+			r.Put(ctx, &product.Product{Id: fmt.Sprintf("p%d", i), Name: "x"})
+		}
+		var ids []string
+		cursor, pages := "", 0
+		for {
+			page, next, err := r.List(ctx, cursor, 2)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			pages++
+			for _, p := range page {
+				ids = append(ids, p.GetId())
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if fmt.Sprint(ids) != "[p1 p2 p3 p4 p5]" || pages != 3 {
+			t.Fatalf("paged ids %v over %d pages", ids, pages)
+		}
+		all, next, _ := r.List(ctx, "", 0)
+		if len(all) != 5 || next != "" {
+			t.Fatalf("unlimited List returned %d products, next %q", len(all), next)
+		}
+	})
+
+	t.Run("BatchCommits", func(t *testing.T) {
+		r := open(t)
+		r.Put(ctx, &product.Product{Id: "a", Name: "A"})
+		err := r.Batch(ctx, func(tx Tx) error {
+			if err := tx.Put(ctx, &product.Product{Id: "b", Name: "B"}); err != nil {
+				return err
+			}
+			if got, err := tx.Get(ctx, "b"); err != nil || got.GetName() != "B" {
+				return fmt.Errorf("read inside batch: %v, %v", got, err)
+			}
+			return tx.Delete(ctx, "a")
+		})
+		if err != nil {
+			t.Fatalf("Batch: %v", err)
+		}
+		if _, err := r.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("delete in batch not applied: %v", err)
+		}
+		if _, err := r.Get(ctx, "b"); err != nil {
+			t.Fatalf("put in batch not applied: %v", err)
+		}
+	})
+
+	t.Run("BatchRollsBack", func(t *testing.T) {
+		r := open(t)
+		r.Put(ctx, &product.Product{Id: "a", Name: "A"})
+		boom := errors.New("boom")
+		err := r.Batch(ctx, func(tx Tx) error {
+			tx.Put(ctx, &product.Product{Id: "b", Name: "B"})
+			tx.Delete(ctx, "a")
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			t.Fatalf("Batch returned %v, want the callback error", err)
+		}
+		if _, err := r.Get(ctx, "a"); err != nil {
+			t.Fatalf("rolled-back delete applied: %v", err)
+		}
+		if _, err := r.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("rolled-back put applied: %v", err)
+		}
+		if err := r.Batch(ctx, func(tx Tx) error { return tx.Delete(ctx, "missing") }); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Delete of a missing product in batch: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("BatchCreates", func(t *testing.T) {
+		r := open(t)
+		r.Put(ctx, &product.Product{Id: "a", Name: "A"})
+		err := r.Batch(ctx, func(tx Tx) error { return tx.Create(ctx, &product.Product{Id: "a", Name: "A2"}) })
+		if !errors.Is(err, ErrAlreadyExists) {
+			t.Fatalf("Create of an existing product: got %v, want ErrAlreadyExists", err)
+		}
+		if got, _ := r.Get(ctx, "a"); got.GetName() != "A" {
+			t.Fatalf("failed Create replaced the product: %v", got)
+		}
+		err = r.Batch(ctx, func(tx Tx) error {
+			// IDs differing only in case are different products.
+			if err := tx.Create(ctx, &product.Product{Id: "A", Name: "upper"}); err != nil {
+				return err
+			}
+			if err := tx.Delete(ctx, "a"); err != nil {
+				return err
+			}
+			return tx.Create(ctx, &product.Product{Id: "a", Name: "A3"})
+		})
+		if err != nil {
+			t.Fatalf("Batch: %v", err)
+		}
+		page, _, _ := r.List(ctx, "", 0)
+		if len(page) != 2 || page[0].GetName() != "upper" || page[1].GetName() != "A3" {
+			t.Fatalf("products after creating A and recreating a: %v", page)
+		}
+	})
+
+	t.Run("AttributeDefinitions", func(t *testing.T) {
+		r := open(t)
+		if defs, err := r.AttributeDefinitions(ctx); err != nil || len(defs) != 0 {
+			t.Fatalf("AttributeDefinitions of an empty repository = %v, %v", defs, err)
+		}
+		voltage := &product.AttributeDefinition{Name: "voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER, Required: true}
+		for _, def := range []*product.AttributeDefinition{
+			voltage,
+			{Name: "color", Type: product.AttributeType_ATTRIBUTE_TYPE_STRING},
+			{Name: "voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER},
+		} {
+			if err := r.PutAttributeDefinition(ctx, def); err != nil {
+				t.Fatalf("PutAttributeDefinition(%s): %v", def.GetName(), err)
+			}
+		}
+		voltage.Required = false
+		voltage.Name = "changed by caller"
+
+		defs, err := r.AttributeDefinitions(ctx)
+		if err != nil {
+			t.Fatalf("AttributeDefinitions: %v", err)
+		}
+		want := []*product.AttributeDefinition{
+			{Name: "color", Type: product.AttributeType_ATTRIBUTE_TYPE_STRING},
+			{Name: "voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER},
+		}
+		if len(defs) != len(want) || !proto.Equal(defs[0], want[0]) || !proto.Equal(defs[1], want[1]) {
+			t.Fatalf("AttributeDefinitions = %v, want %v", defs, want)
+		}
+		defs[0].Name = "changed by caller"
+		if again, _ := r.AttributeDefinitions(ctx); again[0].GetName() != "color" {
+			t.Fatalf("stored definition changed through a returned pointer: %v", again[0])
+		}
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testRepository(t, func(t *testing.T) ProductRepository { return NewMemory() })
+}
+
+func TestNewMemory_CopiesSeed(t *testing.T) {
+	seed := &product.Product{Id: "a", Name: "A"}
+	r := NewMemory(seed)
+	seed.Name = "changed"
+	if got, _ := r.Get(context.Background(), "a"); got.GetName() != "A" {
+		t.Fatalf("seed shared with stored state: %v", got)
+	}
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"container/heap"
+	"context"
+	"iter"
+	"strings"
+	"sync"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+// DefaultShards is the number of shards of a Sharded repository created with
+// zero shards.
+const DefaultShards = 32
+
+// Sharded is an in-memory ProductRepository that partitions products over
+// shards by a hash of their ID, each with its own lock and secondary
+// indexes. A Get locks one shard, so it waits only for writes to that shard.
+//
+// Writes are serialized with each other, and each locks only the shards it
+// changes while applying its writes. List and Query read-lock every shard, in
+// shard order like writes, so they see a batch in full or not at all, and
+// merge the shards' results by ID or by the query's order, which makes pages
+// deterministic. Query collects its results before it unlocks the shards and
+// calls fn after, so a slow caller does not hold up writes.
+type Sharded struct {
+	shards []shard
+	// writeMu serializes Put, Delete and Batch, so that the reads of a Batch
+	// stay valid until it commits.
+	writeMu sync.Mutex
+
+	defsMu sync.RWMutex
+	defs   definitions
+}
+
+type shard struct {
+	mu       sync.RWMutex
+	products *catalog
+}
+
+// NewSharded creates a Sharded repository with n shards (DefaultShards if n is
+// zero or less) holding copies of seed.
+func NewSharded(n int, seed ...*product.Product) *Sharded {
+	if n <= 0 {
+		n = DefaultShards
+	}
+	s := &Sharded{shards: make([]shard, n), defs: definitions{}}
+	for i := range s.shards {
+		s.shards[i].products = newCatalog()
+	}
+	for _, p := range seed {
+		s.shardOf(p.GetId()).products.put(clone(p))
+	}
+	return s
+}
+
+// shardIndex returns the shard of the product with the given ID.
+func (s *Sharded) shardIndex(id string) int {
+	return int(hashKey(id) % uint64(len(s.shards)))
+}
+
+func (s *Sharded) shardOf(id string) *shard {
+	return &s.shards[s.shardIndex(id)]
+}
+
+// rlockAll read-locks every shard in order and returns the function that
+// unlocks them.
+func (s *Sharded) rlockAll() (unlock func()) {
+	for i := range s.shards {
+		s.shards[i].mu.RLock()
+	}
+	return func() {
+		for i := range s.shards {
+			s.shards[i].mu.RUnlock()
+		}
+	}
+}
+
+// Get implements ProductRepository.
+func (s *Sharded) Get(ctx context.Context, id string) (*product.Product, error) {
+	sh := s.shardOf(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.products.get(id)
+}
+
+// List implements ProductRepository. The cursor is the ID of the last product
+// on the previous page; the page holds the smallest limit IDs after it among
+// those of all shards.
+func (s *Sharded) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	defer s.rlockAll()()
+	n := 0
+	if limit > 0 {
+		// One more than a page tells whether there is a next one.
+		n = limit + 1
+	}
+	lists := make([]func() (string, bool), len(s.shards))
+	for i := range s.shards {
+		lists[i] = sliceNext(s.shards[i].products.listIDs(cursor, n))
+	}
+	page := []*product.Product{}
+	next := ""
+	for id := range merge(lists, strings.Compare) {
+		if limit > 0 && len(page) == limit {
+			next = page[limit-1].GetId()
+			break
+		}
+		page = append(page, clone(s.shardOf(id).products.product(id)))
+	}
+	return page, next, nil
+}
+
+// Put implements ProductRepository.
+func (s *Sharded) Put(ctx context.Context, p *product.Product) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	sh := s.shardOf(p.GetId())
+	p = clone(p)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.products.put(p)
+	return nil
+}
+
+// Delete implements ProductRepository.
+func (s *Sharded) Delete(ctx context.Context, id string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	sh := s.shardOf(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if !sh.products.delete(id) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Query implements Querier. Every shard plans the query over its own
+// indexes; the plan returned is the first shard's, with the estimates of all
+// shards added up. The first q.Limit matches (all of them if q.Limit is zero)
+// are collected with the shards locked and passed to fn once they are
+// unlocked.
+func (s *Sharded) Query(ctx context.Context, q Query, fn func(p *product.Product) bool) (Plan, error) {
+	matches, plan, err := s.query(q)
+	if err != nil {
+		return Plan{}, err
+	}
+	for _, p := range matches {
+		if !fn(p) {
+			break
+		}
+	}
+	return plan, nil
+}
+
+// query returns the first q.Limit matches of q, or all of them if q.Limit is
+// zero, read with every shard locked.
+func (s *Sharded) query(q Query) ([]*product.Product, Plan, error) {
+	order, err := q.Order.normalize()
+	if err != nil {
+		return nil, Plan{}, err
+	}
+	defer s.rlockAll()()
+	plans := make([]Plan, len(s.shards))
+	errs := make([]error, len(s.shards))
+	streams := make([]func() (*product.Product, bool), len(s.shards))
+	stops := make([]func(), len(s.shards))
+	for i := range s.shards {
+		streams[i], stops[i] = iter.Pull(func(yield func(*product.Product) bool) {
+			plans[i], errs[i] = s.shards[i].products.query(q, yield)
+		})
+		defer stops[i]()
+	}
+	var matches []*product.Product
+	for p := range merge(streams, order.compare) {
+		matches = append(matches, p)
+		if len(matches) == q.Limit {
+			break
+		}
+	}
+	// Stopping a stream lets its query return and set its plan.
+	for _, stop := range stops {
+		stop()
+	}
+	plan := plans[0]
+	plan.Estimate = 0
+	for i := range plans {
+		if errs[i] != nil {
+			return nil, Plan{}, errs[i]
+		}
+		plan.Estimate += plans[i].Estimate
+	}
+	return matches, plan, nil
+}
+
+// Batch implements ProductRepository. fn runs while other writes wait but
+// reads continue, so like with Memory it must not call s's write methods; its
+// writes are then applied with only the shards they change locked.
+func (s *Sharded) Batch(ctx context.Context, fn func(tx Tx) error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx := &shardedTx{s: s, writes: make(map[string]*product.Product)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	byShard := make(map[int]map[string]*product.Product)
+	for id, p := range tx.writes {
+		i := s.shardIndex(id)
+		if byShard[i] == nil {
+			byShard[i] = make(map[string]*product.Product)
+		}
+		byShard[i][id] = p
+	}
+	for i := range s.shards {
+		if byShard[i] != nil {
+			s.shards[i].mu.Lock()
+		}
+	}
+	for i := range s.shards {
+		if writes := byShard[i]; writes != nil {
+			s.shards[i].products.apply(writes)
+			s.shards[i].mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// AttributeDefinitions implements ProductRepository.
+func (s *Sharded) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	s.defsMu.RLock()
+	defer s.defsMu.RUnlock()
+	return s.defs.list(), nil
+}
+
+// PutAttributeDefinition implements ProductRepository.
+func (s *Sharded) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	s.defsMu.Lock()
+	defer s.defsMu.Unlock()
+	s.defs.put(def)
+	return nil
+}
+
+// shardedTx stages writes over a Sharded repository until they are applied;
+// a nil entry is a delete.
+type shardedTx struct {
+	s      *Sharded
+	writes map[string]*product.Product
+}
+
+func (tx *shardedTx) Get(ctx context.Context, id string) (*product.Product, error) {
+	if p, staged := tx.writes[id]; staged {
+		if p == nil {
+			return nil, ErrNotFound
+		}
+		return clone(p), nil
+	}
+	return tx.s.Get(ctx, id)
+}
+
+func (tx *shardedTx) Put(ctx context.Context, p *product.Product) error {
+	tx.writes[p.GetId()] = clone(p)
+	return nil
+}
+
+func (tx *shardedTx) Create(ctx context.Context, p *product.Product) error {
+	return create(ctx, tx, p)
+}
+
+func (tx *shardedTx) Delete(ctx context.Context, id string) error {
+	if _, err := tx.Get(ctx, id); err != nil {
+		return err
+	}
+	tx.writes[id] = nil
+	return nil
+}
+
+// sliceNext returns a function that yields the elements of s in turn.
+func sliceNext[T any](s []T) func() (T, bool) {
+	return func() (T, bool) {
+		var zero T
+		if len(s) == 0 {
+			return zero, false
+		}
+		v := s[0]
+		s = s[1:]
+		return v, true
+	}
+}
+
+// merge merges streams each sorted by cmp, reading each only as far as
+// needed.
+func merge[T any](streams []func() (T, bool), cmp func(a, b T) int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		h := &mergeHeap[T]{cmp: cmp}
+		for _, next := range streams {
+			if v, ok := next(); ok {
+				h.heads = append(h.heads, mergeHead[T]{v: v, next: next})
+			}
+		}
+		heap.Init(h)
+		for h.Len() > 0 {
+			head := &h.heads[0]
+			if !yield(head.v) {
+				return
+			}
+			if v, ok := head.next(); ok {
+				head.v = v
+				heap.Fix(h, 0)
+			} else {
+				heap.Pop(h)
+			}
+		}
+	}
+}
+
+// mergeHead is the next element of a stream being merged.
+type mergeHead[T any] struct {
+	v    T
+	next func() (T, bool)
+}
+
+// mergeHeap orders stream heads by cmp.
+type mergeHeap[T any] struct {
+	heads []mergeHead[T]
+	cmp   func(a, b T) int
+}
+
+func (h *mergeHeap[T]) Len() int           { return len(h.heads) }
+func (h *mergeHeap[T]) Less(i, j int) bool { return h.cmp(h.heads[i].v, h.heads[j].v) < 0 }
+func (h *mergeHeap[T]) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *mergeHeap[T]) Push(x any)         { h.heads = append(h.heads, x.(mergeHead[T])) }
+func (h *mergeHeap[T]) Pop() any {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/generated/product"
+)
+
+func TestSharded(t *testing.T) {
+	testRepository(t, func(t *testing.T) ProductRepository { return NewSharded(0) })
+}
+
+func TestSharded_ListPagesInIDOrderAcrossShards(t *testing.T) {
+	ctx := context.Background()
+	catalog := testCatalog(1000)
+	s := NewSharded(7, catalog...)
+	var want []string
+	for _, p := range catalog {
+		want = append(want, p.GetId())
+	}
+	for _, limit := range []int{1, 10, 333, 1000, 2000} {
+		var ids []string
+		cursor := ""
+		for {
+			page, next, err := s.List(ctx, cursor, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) > limit {
+				t.Fatalf("limit %d: page of %d", limit, len(page))
+			}
+			for _, p := range page {
+				ids = append(ids, p.GetId())
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if !slices.Equal(ids, want) {
+			t.Fatalf("limit %d: listed %d products, sorted %v; want all %d in ID order", limit, len(ids), slices.IsSorted(ids), len(want))
+		}
+	}
+}
+
+func TestSharded_QueryMatchesMemory(t *testing.T) {
+	catalog := testCatalog(2000)
+	m, s := NewMemory(catalog...), NewSharded(0, catalog...)
+	for _, tc := range testQueries {
+		f, err := filter.Parse(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order, _ := ParseOrder(tc.order)
+		for _, limit := range []int{0, 10} {
+			q := Query{Filter: f, Order: order, Limit: limit}
+			got, plan := selectAll(t, s, q)
+			want, _ := selectAll(t, m, q)
+			if !slices.Equal(got, want) {
+				t.Errorf("filter %q order %q limit %d with plan %+v: got %d products, want %d", tc.filter, tc.order, limit, plan, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestSharded_BatchIsAtomicForListings(t *testing.T) {
+	ctx := context.Background()
+	s := NewSharded(8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			s.Batch(ctx, func(tx Tx) error {
+				for j := range 10 {
+					tx.Put(ctx, &product.Product{Id: fmt.Sprintf("b%03d-%d", i, j), Name: "N"})
+				}
+				return nil
+			})
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		page, _, err := s.List(ctx, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page)%10 != 0 {
+			t.Fatalf("listed %d products, part of a batch", len(page))
+		}
+	}
+}
+
+func TestSharded_QueryCallsFnWithShardsUnlocked(t *testing.T) {
+	ctx := context.Background()
+	s := NewSharded(4, testCatalog(100)...)
+	n := 0
+	_, err := s.Query(ctx, Query{Limit: 10}, func(p *product.Product) bool {
+		// Writing from fn would deadlock if the shards were still locked.
+		if err := s.Delete(ctx, p.GetId()); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Fatalf("fn called %d times, want the limit of 10", n)
+	}
+	if page, _, _ := s.List(ctx, "", 0); len(page) != 90 {
+		t.Fatalf("%d products left, want 90", len(page))
+	}
+}
+
+// BenchmarkParallel compares Memory, behind one lock, with Sharded and the
+// lock-free reads of Versioned under concurrent Gets mixed with Puts, and
+// with first-page listings.
+func BenchmarkParallel(b *testing.B) {
+	const n = 100_000
+	catalog := testCatalog(n)
+	for _, mix := range []struct {
+		name string
+		// writes is the share of operations that are Puts, per thousand.
+		writes int
+		list   bool
+	}{
+		{"Get", 0, false},
+		{"Get999Put1", 1, false},
+		{"Get90Put10", 100, false},
+		{"Get50Put50", 500, false},
+		{"List90Put10", 100, true},
+	} {
+		for _, repo := range []struct {
+			name string
+			new  func() ProductRepository
+		}{
+			{"Memory", func() ProductRepository { return NewMemory(catalog...) }},
+			{"Sharded", func() ProductRepository { return NewSharded(0, catalog...) }},
+			{"Versioned", func() ProductRepository { return NewVersioned(VersionedOptions{}, catalog...) }},
+		} {
+			b.Run(mix.name+"/"+repo.name, func(b *testing.B) {
+				ctx := context.Background()
+				r := repo.new()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rng := rand.New(rand.NewPCG(rand.Uint64(), 0))
+					for pb.Next() {
+						p := catalog[rng.IntN(n)]
+						switch {
+						case rng.IntN(1000) < mix.writes:
+							r.Put(ctx, p)
+						case mix.list:
+							r.List(ctx, p.GetId(), 20)
+						default:
+							r.Get(ctx, p.GetId())
+						}
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Dialect is the flavour of SQL a database speaks. The SQL store only needs
+// to know it for placeholders, upserts and migration locks.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+)
+
+// DialectOf returns the dialect of the database/sql driver registered under
+// name.
+func DialectOf(driver string) (Dialect, error) {
+	switch driver {
+	case "sqlite", "sqlite3":
+		return DialectSQLite, nil
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return DialectPostgres, nil
+	case "mysql":
+		return DialectMySQL, nil
+	}
+	return "", fmt.Errorf("unknown SQL driver %q: want one of sqlite, sqlite3, postgres, pgx, cloudsqlpostgres or mysql", driver)
+}
+
+// SQLOptions configures an SQL store.
+type SQLOptions struct {
+	// Dialect is the dialect of the database; empty means DialectSQLite.
+	Dialect Dialect
+}
+
+// SQL is a ProductRepository stored in a relational database through
+// database/sql. Writes use the dialect's upsert, and creates its conditional
+// insert, so concurrent writers, including other instances sharing the
+// database, cannot both create a product or lose a replaced row. Products are
+// ordered by id, which the migrations give a binary collation so that it
+// matches the byte order used by the other repositories.
+//
+// The schema is created by Migrate; SQL does not check it.
+type SQL struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewSQL creates an SQL store over db. The caller owns db and closes it.
+func NewSQL(db *sql.DB, opts SQLOptions) *SQL {
+	if opts.Dialect == "" {
+		opts.Dialect = DialectSQLite
+	}
+	return &SQL{db: db, dialect: opts.Dialect}
+}
+
+// Get implements ProductRepository.
+func (s *SQL) Get(ctx context.Context, id string) (*product.Product, error) {
+	return getRow(ctx, s.db, s.dialect, id)
+}
+
+// List implements ProductRepository. The cursor is the ID of the last product
+// on the previous page.
+func (s *SQL) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	query := "SELECT body FROM products WHERE id > ? ORDER BY id"
+	if limit > 0 {
+		// Read one more row to find out whether there is a next page.
+		query += " LIMIT " + strconv.Itoa(limit+1)
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	var page []*product.Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		page = append(page, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+		next = page[limit-1].GetId()
+	}
+	return page, next, nil
+}
+
+// Query implements Querier. The comparisons of id, name and price in the
+// filter's top-level conjunction become the WHERE clause, so the database can
+// answer them from the primary key and the name and price indexes; the whole
+// filter is then applied to the rows read. Orders by id and price become the
+// ORDER BY clause; names are sorted here, as the database may collate them
+// differently. LIMIT is pushed down only when the WHERE clause is the whole
+// filter and the rows come in order. The plan's Index is the column of the
+// first pushed-down comparison, or "" if there is none.
+func (s *SQL) Query(ctx context.Context, q Query, fn func(p *product.Product) bool) (Plan, error) {
+	order, err := q.Order.normalize()
+	if err != nil {
+		return Plan{}, err
+	}
+	w := s.where(q.Filter)
+	query := "SELECT body FROM products"
+	if len(w.conds) > 0 {
+		query += " WHERE " + strings.Join(w.conds, " AND ")
+	}
+	plan := Plan{Index: w.index, Sorted: order.Field == "name"}
+	if !plan.Sorted {
+		dir := ""
+		if order.Desc {
+			dir = " DESC"
+		}
+		query += " ORDER BY "
+		if order.Field == "price" {
+			query += "price" + dir + ", "
+		}
+		query += "id" + dir
+		if w.exact && q.Limit > 0 {
+			query += " LIMIT " + strconv.Itoa(q.Limit)
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), w.args...)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer rows.Close()
+	var matches []*product.Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.Estimate++
+		if q.Filter.Match(p) {
+			matches = append(matches, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Plan{}, err
+	}
+	// Rows are read in full before fn runs, so that fn may use s even with
+	// a pool of one connection.
+	rows.Close()
+	if plan.Sorted {
+		slices.SortFunc(matches, order.compare)
+	}
+	for _, p := range matches {
+		if !fn(p) {
+			break
+		}
+	}
+	return plan, nil
+}
+
+// sqlWhere is the WHERE clause of a query: conditions ANDed together and
+// their parameters.
+type sqlWhere struct {
+	conds []string
+	args  []any
+	// exact reports that the conditions match exactly the products the
+	// filter matches, rather than a superset of them.
+	exact bool
+	// index is the column of the first condition.
+	index string
+}
+
+// where translates the comparisons of id, name and price in the top-level
+// conjunction of f to conditions on the columns of the same names. Each
+// condition holds for every product its comparison matches.
+func (s *SQL) where(f *filter.Filter) sqlWhere {
+	w := sqlWhere{exact: true}
+	if f == nil || f.Expr == nil {
+		return w
+	}
+	terms := []filter.Expr{f.Expr}
+	if and, ok := f.Expr.(*filter.And); ok {
+		terms = and.Terms
+	}
+	for _, t := range terms {
+		c, ok := t.(*filter.Comparison)
+		if !ok {
+			w.exact = false
+			continue
+		}
+		cond, arg, exact := s.condition(c)
+		if cond == "" {
+			w.exact = w.exact && exact
+			continue
+		}
+		if w.index == "" {
+			w.index = c.Field
+		}
+		w.conds, w.args = append(w.conds, cond), append(w.args, arg)
+		w.exact = w.exact && exact
+	}
+	return w
+}
+
+// sqlOps are the SQL operators of the filter's ordered comparisons.
+var sqlOps = map[filter.Op]string{
+	filter.OpEq: "=", filter.OpHas: "=", filter.OpNe: "<>",
+	filter.OpLt: "<", filter.OpLe: "<=", filter.OpGt: ">", filter.OpGe: ">=",
+}
+
+// condition translates c to a condition with one parameter, and reports
+// whether it is exact. It returns no condition for comparisons it cannot
+// translate, which are inexact, and for "field:*", which is exact: id, name
+// and price are always present.
+func (s *SQL) condition(c *filter.Comparison) (cond string, arg any, exact bool) {
+	if c.Op == filter.OpHas && c.Value.Text == "*" && !c.Value.Quoted {
+		return "", nil, c.Field == "id" || c.Field == "name" || c.Field == "price"
+	}
+	switch c.Field {
+	case "price":
+		if !c.Value.IsNumber {
+			return "", nil, false
+		}
+		return "price " + sqlOps[c.Op] + " ?", c.Value.Number, true
+	case "id", "name":
+	default:
+		return "", nil, false
+	}
+	if c.Op == filter.OpEq || c.Op == filter.OpHas {
+		if prefix, ok := strings.CutSuffix(c.Value.Text, "*"); ok {
+			// LIKE ignores case on SQLite, and on MySQL for names.
+			return c.Field + " LIKE ? ESCAPE '!'", likeEscaper.Replace(prefix) + "%", false
+		}
+	}
+	if c.Field == "id" {
+		// IDs are compared byte by byte in every dialect (see migrations).
+		return "id " + sqlOps[c.Op] + " ?", c.Value.Text, true
+	}
+	if c.Op == filter.OpEq || c.Op == filter.OpHas {
+		// MySQL compares names regardless of case.
+		return "name = ?", c.Value.Text, s.dialect != DialectMySQL
+	}
+	// Names may be collated in another order than bytes.
+	return "", nil, false
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, and its escape
+// character '!'.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Put implements ProductRepository.
+func (s *SQL) Put(ctx context.Context, p *product.Product) error {
+	return s.Batch(ctx, func(tx Tx) error { return tx.Put(ctx, p) })
+}
+
+// Delete implements ProductRepository.
+func (s *SQL) Delete(ctx context.Context, id string) error {
+	return s.Batch(ctx, func(tx Tx) error { return tx.Delete(ctx, id) })
+}
+
+// Batch implements ProductRepository with a database transaction. fn must not
+// call s's other methods: with a small connection pool they would wait for
+// the connection fn's transaction holds.
+func (s *SQL) Batch(ctx context.Context, fn func(tx Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&sqlTx{tx: tx, dialect: s.dialect}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// AttributeDefinitions implements ProductRepository.
+func (s *SQL) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT body FROM attribute_definitions ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var defs []*product.AttributeDefinition
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return nil, err
+		}
+		def := &product.AttributeDefinition{}
+		if err := protojson.Unmarshal([]byte(body), def); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, rows.Err()
+}
+
+// PutAttributeDefinition implements ProductRepository.
+func (s *SQL) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	body, err := protojson.Marshal(def)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.upsert("attribute_definitions", "name", "body"), def.GetName(), string(body))
+	return err
+}
+
+// rebind rewrites ? parameters to $1, $2, ... for PostgreSQL. Queries must
+// not contain ? in string literals.
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// upsert returns a statement that inserts a row of cols into table, or
+// replaces the other columns of the row whose key, the first column, is
+// taken.
+func (d Dialect) upsert(table string, cols ...string) string {
+	sets := make([]string, 0, len(cols)-1)
+	for _, c := range cols[1:] {
+		if d == DialectMySQL {
+			sets = append(sets, c+" = VALUES("+c+")")
+		} else {
+			sets = append(sets, c+" = excluded."+c)
+		}
+	}
+	return d.insert(table, cols) + d.onConflict(cols[0]) + strings.Join(sets, ", ")
+}
+
+// insertIfAbsent returns a statement that inserts a row of cols into table
+// unless its key, the first column, is taken, in which case it affects no
+// rows.
+func (d Dialect) insertIfAbsent(table string, cols ...string) string {
+	if d == DialectMySQL {
+		// A no-op update reports no affected rows.
+		return d.insert(table, cols) + d.onConflict(cols[0]) + cols[0] + " = " + cols[0]
+	}
+	return d.insert(table, cols) + " ON CONFLICT (" + cols[0] + ") DO NOTHING"
+}
+
+func (d Dialect) insert(table string, cols []string) string {
+	params := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+	return d.rebind("INSERT INTO " + table + " (" + strings.Join(cols, ", ") + ") VALUES (" + params + ")")
+}
+
+func (d Dialect) onConflict(key string) string {
+	if d == DialectMySQL {
+		return " ON DUPLICATE KEY UPDATE "
+	}
+	return " ON CONFLICT (" + key + ") DO UPDATE SET "
+}
+
+// sqlTx is the Tx of SQL.Batch.
+type sqlTx struct {
+	tx      *sql.Tx
+	dialect Dialect
+}
+
+func (t *sqlTx) Get(ctx context.Context, id string) (*product.Product, error) {
+	return getRow(ctx, t.tx, t.dialect, id)
+}
+
+func (t *sqlTx) Put(ctx context.Context, p *product.Product) error {
+	_, err := t.insert(ctx, t.dialect.upsert("products", productColumns...), p)
+	return err
+}
+
+func (t *sqlTx) Create(ctx context.Context, p *product.Product) error {
+	n, err := t.insert(ctx, t.dialect.insertIfAbsent("products", productColumns...), p)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+// productColumns are the columns of products, key first, in the order insert
+// passes them.
+var productColumns = []string{"id", "name", "price", "body"}
+
+// insert runs an insert of p's columns and returns the number of rows it
+// affected.
+func (t *sqlTx) insert(ctx context.Context, query string, p *product.Product) (int64, error) {
+	body, err := protojson.Marshal(p)
+	if err != nil {
+		return 0, err
+	}
+	res, err := t.tx.ExecContext(ctx, query, p.GetId(), p.GetName(), p.GetPrice(), string(body))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (t *sqlTx) Delete(ctx context.Context, id string) error {
+	res, err := t.tx.ExecContext(ctx, t.dialect.rebind("DELETE FROM products WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getRow(ctx context.Context, q queryer, d Dialect, id string) (*product.Product, error) {
+	p, err := scanProduct(q.QueryRowContext(ctx, d.rebind("SELECT body FROM products WHERE id = ?"), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return p, err
+}
+
+func scanProduct(row interface{ Scan(dest ...any) error }) (*product.Product, error) {
+	var body string
+	if err := row.Scan(&body); err != nil {
+		return nil, err
+	}
+	p := &product.Product{}
+	if err := protojson.Unmarshal([]byte(body), p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/generated/product"
+
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens an empty SQLite database in a temporary directory.
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "products.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A single connection keeps SQLite from returning SQLITE_BUSY while a
+	// Batch holds its write transaction.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func migratedSQL(t *testing.T) *SQL {
+	t.Helper()
+	s := NewSQL(openSQLite(t), SQLOptions{})
+	if _, err := s.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return s
+}
+
+func TestSQL(t *testing.T) {
+	testRepository(t, func(t *testing.T) ProductRepository { return migratedSQL(t) })
+}
+
+func TestMigrate_AppliesEachMigrationOnce(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQL(openSQLite(t), SQLOptions{})
+	if err := s.CheckSchema(ctx); err == nil {
+		t.Fatal("CheckSchema passed on an empty database")
+	}
+	ms, err := Migrations(DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := s.Migrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(ms) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(ms))
+	}
+	if err := s.CheckSchema(ctx); err != nil {
+		t.Fatalf("CheckSchema after Migrate: %v", err)
+	}
+	if applied, err := s.Migrate(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("second Migrate applied %d migrations, err %v", len(applied), err)
+	}
+	if v, _ := s.SchemaVersion(ctx); v != ms[len(ms)-1].Version {
+		t.Fatalf("schema version %d, want %d", v, ms[len(ms)-1].Version)
+	}
+
+	// A run that read the version before another run applied a migration
+	// finds out once its transaction holds the write lock.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ok, err := s.apply(ctx, conn, ms[0]); ok || err != nil {
+		t.Fatalf("reapplying %s = %v, %v; want it skipped", ms[0].Name, ok, err)
+	}
+}
+
+func TestMigrations_AreOrderedAndSplit(t *testing.T) {
+	for _, d := range []Dialect{DialectSQLite, DialectPostgres, DialectMySQL} {
+		ms, err := Migrations(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range ms {
+			if m.Version != i+1 {
+				t.Errorf("%s migration %s has version %d, want %d", d, m.Name, m.Version, i+1)
+			}
+			// SQLite already compares IDs in binary.
+			dialectOnly := m.Name == "0004_binary_ids"
+			if len(m.Statements) == 0 && !(dialectOnly && d == DialectSQLite) {
+				t.Errorf("%s migration %s has no statements", d, m.Name)
+			}
+			if len(m.Statements) > 0 && dialectOnly && d == DialectSQLite {
+				t.Errorf("sqlite migration %s has statements %q", m.Name, m.Statements)
+			}
+		}
+	}
+	got := splitStatements("-- comment\nCREATE TABLE a (x INT);\n\nCREATE INDEX a_x\n  ON a (x);\n")
+	if len(got) != 2 || got[0] != "CREATE TABLE a (x INT)" || got[1] != "CREATE INDEX a_x\n  ON a (x)" {
+		t.Fatalf("splitStatements = %q", got)
+	}
+}
+
+func TestDialect_RewritesStatements(t *testing.T) {
+	d, err := DialectOf("pgx")
+	if err != nil || d != DialectPostgres {
+		t.Fatalf("DialectOf(pgx) = %q, %v", d, err)
+	}
+	if _, err := DialectOf("oracle"); err == nil {
+		t.Fatal("DialectOf accepted an unknown driver")
+	}
+	if got := d.rebind("SELECT 1 WHERE a = ? AND b > ?"); got != "SELECT 1 WHERE a = $1 AND b > $2" {
+		t.Fatalf("rebind = %q", got)
+	}
+	if DialectSQLite.rebind("a = ?") != "a = ?" {
+		t.Fatal("question-mark placeholders rewritten")
+	}
+	for _, tc := range []struct {
+		got, want string
+	}{
+		{DialectPostgres.upsert("t", "k", "v"), "INSERT INTO t (k, v) VALUES ($1, $2) ON CONFLICT (k) DO UPDATE SET v = excluded.v"},
+		{DialectMySQL.upsert("t", "k", "v"), "INSERT INTO t (k, v) VALUES (?, ?) ON DUPLICATE KEY UPDATE v = VALUES(v)"},
+		{DialectSQLite.insertIfAbsent("t", "k", "v"), "INSERT INTO t (k, v) VALUES (?, ?) ON CONFLICT (k) DO NOTHING"},
+		{DialectMySQL.insertIfAbsent("t", "k", "v"), "INSERT INTO t (k, v) VALUES (?, ?) ON DUPLICATE KEY UPDATE k = k"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("got %q, want %q", tc.got, tc.want)
+		}
+	}
+}
+
+func TestSQL_QueryMatchesMemory(t *testing.T) {
+	ctx := context.Background()
+	catalog := append(testCatalog(2000),
+		&product.Product{Id: "x%1", Name: "100% Wool_Rug", Price: 5},
+		&product.Product{Id: "x_2", Name: "100 Wool Rug", Price: 5},
+		&product.Product{Id: "x!3", Name: "lamp", Price: 5},
+	)
+	m, s := NewMemory(catalog...), migratedSQL(t)
+	err := s.Batch(ctx, func(tx Tx) error {
+		for _, p := range catalog {
+			if err := tx.Put(ctx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := append(testQueries[:len(testQueries):len(testQueries)], []struct{ filter, order string }{
+		{`name = "100%*"`, ""},
+		{`name = "100% Wool_*"`, "price"},
+		{`id : "x!*"`, ""},
+		{`id = "x_*"`, "id desc"},
+		{`name = "lamp"`, ""},
+		{`price = "cheap"`, ""},
+	}...)
+	for _, tc := range queries {
+		f, err := filter.Parse(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order, _ := ParseOrder(tc.order)
+		for _, limit := range []int{0, 10} {
+			q := Query{Filter: f, Order: order, Limit: limit}
+			got, plan := selectAll(t, s, q)
+			want, _ := selectAll(t, m, q)
+			if !slices.Equal(got, want) {
+				t.Errorf("filter %q order %q limit %d with plan %+v: got %v, want %v", tc.filter, tc.order, limit, plan, got, want)
+			}
+		}
+	}
+}
+
+func TestSQL_QueryPushesDownIndexedComparisons(t *testing.T) {
+	ctx := context.Background()
+	s := migratedSQL(t)
+	for _, p := range testCatalog(100) {
+		if err := s.Put(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		filter, order string
+		index         string
+		maxRead       int
+		sorted        bool
+	}{
+		{`price < 100`, "price", "price", 30, false},
+		{`price < 100 AND tags = "t1"`, "", "price", 30, false},
+		{`id >= "p0000010" AND id < "p0000020"`, "", "id", 10, false},
+		{`name = "Lamp 0"`, "name", "name", 1, true},
+		{`tags = "t1"`, "", "", 100, false},
+	} {
+		f, err := filter.Parse(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order, _ := ParseOrder(tc.order)
+		_, plan := selectAll(t, s, Query{Filter: f, Order: order})
+		if plan.Index != tc.index || plan.Estimate > tc.maxRead || plan.Sorted != tc.sorted {
+			t.Errorf("filter %q order %q: plan %+v, want index %q, at most %d rows read, sorted %v", tc.filter, tc.order, plan, tc.index, tc.maxRead, tc.sorted)
+		}
+	}
+}
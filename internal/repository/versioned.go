@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+// ErrVersionExpired is returned by Pinner.Pinned for a version that is
+// neither current nor pinned.
+var ErrVersionExpired = errors.New("catalog version expired")
+
+// View is a read-only version of a catalog that never changes.
+type View interface {
+	// Version numbers the view; a later version has a higher number.
+	Version() uint64
+	Get(ctx context.Context, id string) (*product.Product, error)
+	List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error)
+	Querier
+}
+
+// Pinner is implemented by repositories that can serve reads from earlier
+// versions of their catalog, so that consecutive pages of a listing come
+// from one version.
+type Pinner interface {
+	// Current returns the current version.
+	Current() View
+	// Pin keeps v available from Pinned for a while, as set by the
+	// repository; pinning it again extends that.
+	Pin(v View)
+	// Pinned returns the given version if it is current or pinned, or
+	// ErrVersionExpired.
+	Pinned(version uint64) (View, error)
+}
+
+// Unwrapper is implemented by decorators of a repository, such as Cache.
+type Unwrapper interface {
+	// Unwrap returns the decorated repository.
+	Unwrap() ProductRepository
+}
+
+// Pinning returns the Pinner of repo, looking through decorators that
+// implement Unwrapper, or false if there is none. Reading from a version
+// bypasses the decorators, which only affects caching.
+func Pinning(repo ProductRepository) (Pinner, bool) {
+	for {
+		if p, ok := repo.(Pinner); ok {
+			return p, true
+		}
+		u, ok := repo.(Unwrapper)
+		if !ok {
+			return nil, false
+		}
+		repo = u.Unwrap()
+	}
+}
+
+const (
+	// DefaultPinTTL is how long a Versioned repository keeps a pinned
+	// version by default.
+	DefaultPinTTL = 5 * time.Minute
+	// DefaultMaxPins is the default number of versions a Versioned
+	// repository keeps pinned.
+	DefaultMaxPins = 1000
+)
+
+// VersionedOptions configures a Versioned repository.
+type VersionedOptions struct {
+	// PinTTL is how long a pinned version is kept after it was last pinned;
+	// zero means DefaultPinTTL.
+	PinTTL time.Duration
+	// MaxPins is the number of versions kept pinned, beyond which the one
+	// closest to expiring is dropped; zero means DefaultMaxPins.
+	MaxPins int
+}
+
+// Versioned is an in-memory ProductRepository for read-heavy workloads. Its
+// catalog, indexes included, is immutable once published: readers load the
+// current version from an atomic pointer and never lock or wait. Writers,
+// serialized by a mutex, clone the current version, apply their writes to the
+// clone and publish it. A clone shares every B-tree and trie node with the
+// version it was made from until a write changes that node (see
+// catalog.clone), so a write copies a few nodes per index rather than the
+// catalog.
+//
+// Every published version is numbered, and Versioned implements Pinner: a
+// pinned version stays readable for VersionedOptions.PinTTL, until garbage
+// collected along with the nodes only it uses.
+type Versioned struct {
+	current atomic.Pointer[version]
+	// writeMu serializes writers, so each builds on the version before it.
+	writeMu sync.Mutex
+	pinTTL  time.Duration
+	maxPins int
+	now     func() time.Time
+	pinMu   sync.Mutex
+	pins    map[uint64]pin
+	// Definitions are not versioned; every View validates against the
+	// current ones.
+	defsMu sync.RWMutex
+	defs   definitions
+}
+
+// version is a published catalog; it implements View.
+type version struct {
+	n        uint64
+	products *catalog
+}
+
+// pin is a pinned version and when it expires.
+type pin struct {
+	v       *version
+	expires time.Time
+}
+
+// NewVersioned creates a Versioned repository holding copies of seed.
+func NewVersioned(opts VersionedOptions, seed ...*product.Product) *Versioned {
+	r := &Versioned{
+		pinTTL:  opts.PinTTL,
+		maxPins: opts.MaxPins,
+		now:     time.Now,
+		pins:    make(map[uint64]pin),
+		defs:    definitions{},
+	}
+	if r.pinTTL <= 0 {
+		r.pinTTL = DefaultPinTTL
+	}
+	if r.maxPins <= 0 {
+		r.maxPins = DefaultMaxPins
+	}
+	c := newCatalog()
+	for _, p := range seed {
+		c.put(clone(p))
+	}
+	r.current.Store(&version{n: 1, products: c})
+	return r
+}
+
+// Get implements ProductRepository.
+func (r *Versioned) Get(ctx context.Context, id string) (*product.Product, error) {
+	return r.current.Load().Get(ctx, id)
+}
+
+// List implements ProductRepository. The cursor is the ID of the last product
+// on the previous page; use a pinned View to page through one version.
+func (r *Versioned) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	return r.current.Load().List(ctx, cursor, limit)
+}
+
+// Query implements Querier.
+func (r *Versioned) Query(ctx context.Context, q Query, fn func(p *product.Product) bool) (Plan, error) {
+	return r.current.Load().Query(ctx, q, fn)
+}
+
+// Put implements ProductRepository.
+func (r *Versioned) Put(ctx context.Context, p *product.Product) error {
+	return r.write(func(c *catalog) error {
+		c.put(clone(p))
+		return nil
+	})
+}
+
+// Delete implements ProductRepository.
+func (r *Versioned) Delete(ctx context.Context, id string) error {
+	return r.write(func(c *catalog) error {
+		if !c.delete(id) {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// Batch implements ProductRepository. fn reads the current version and its
+// own writes; other writers wait, readers do not. Like with Memory, fn must
+// not call r's write methods.
+func (r *Versioned) Batch(ctx context.Context, fn func(tx Tx) error) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	cur := r.current.Load()
+	tx := newMemoryTx(cur.products)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+	next := cur.products.clone()
+	next.apply(tx.writes)
+	r.current.Store(&version{n: cur.n + 1, products: next})
+	return nil
+}
+
+// AttributeDefinitions implements ProductRepository.
+func (r *Versioned) AttributeDefinitions(ctx context.Context) ([]*product.AttributeDefinition, error) {
+	r.defsMu.RLock()
+	defer r.defsMu.RUnlock()
+	return r.defs.list(), nil
+}
+
+// PutAttributeDefinition implements ProductRepository.
+func (r *Versioned) PutAttributeDefinition(ctx context.Context, def *product.AttributeDefinition) error {
+	r.defsMu.Lock()
+	defer r.defsMu.Unlock()
+	r.defs.put(def)
+	return nil
+}
+
+// write publishes a new version with the changes fn makes to a clone of the
+// current one, unless fn fails.
+func (r *Versioned) write(fn func(c *catalog) error) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	cur := r.current.Load()
+	next := cur.products.clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+	r.current.Store(&version{n: cur.n + 1, products: next})
+	return nil
+}
+
+// Current implements Pinner.
+func (r *Versioned) Current() View {
+	return r.current.Load()
+}
+
+// Pin implements Pinner.
+func (r *Versioned) Pin(v View) {
+	ver, ok := v.(*version)
+	if !ok {
+		return
+	}
+	r.pinMu.Lock()
+	defer r.pinMu.Unlock()
+	now := r.now()
+	r.unpinExpired(now)
+	if _, pinned := r.pins[ver.n]; !pinned && len(r.pins) >= r.maxPins {
+		var oldest uint64
+		for n, p := range r.pins {
+			if oldest == 0 || p.expires.Before(r.pins[oldest].expires) {
+				oldest = n
+			}
+		}
+		delete(r.pins, oldest)
+	}
+	r.pins[ver.n] = pin{v: ver, expires: now.Add(r.pinTTL)}
+}
+
+// Pinned implements Pinner.
+func (r *Versioned) Pinned(n uint64) (View, error) {
+	if cur := r.current.Load(); cur.n == n {
+		return cur, nil
+	}
+	r.pinMu.Lock()
+	defer r.pinMu.Unlock()
+	r.unpinExpired(r.now())
+	p, ok := r.pins[n]
+	if !ok {
+		return nil, ErrVersionExpired
+	}
+	return p.v, nil
+}
+
+// unpinExpired drops the pins that expired by now. Callers must hold pinMu.
+func (r *Versioned) unpinExpired(now time.Time) {
+	for n, p := range r.pins {
+		if !now.Before(p.expires) {
+			delete(r.pins, n)
+		}
+	}
+}
+
+func (v *version) Version() uint64 { return v.n }
+
+func (v *version) Get(ctx context.Context, id string) (*product.Product, error) {
+	return v.products.get(id)
+}
+
+func (v *version) List(ctx context.Context, cursor string, limit int) ([]*product.Product, string, error) {
+	page, next := v.products.list(cursor, limit)
+	return page, next, nil
+}
+
+func (v *version) Query(ctx context.Context, q Query, fn func(p *product.Product) bool) (Plan, error) {
+	return v.products.query(q, fn)
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"grpc-go-fx/internal/filter"
+	"grpc-go-fx/internal/generated/product"
+)
+
+func TestVersioned(t *testing.T) {
+	testRepository(t, func(t *testing.T) ProductRepository { return NewVersioned(VersionedOptions{}) })
+}
+
+func TestVersioned_QueryMatchesMemory(t *testing.T) {
+	ctx := context.Background()
+	catalog := testCatalog(2000)
+	m, v := NewMemory(catalog...), NewVersioned(VersionedOptions{}, catalog...)
+	// Build part of the catalog through published versions, so queries run
+	// over indexes that share nodes with earlier ones.
+	for i := 0; i < 2000; i += 3 {
+		p := catalog[i]
+		p.Name, p.Tags = "Lamp "+p.GetName(), []string{"t7"}
+		m.Put(ctx, p)
+		v.Put(ctx, p)
+	}
+	for i := 1; i < 2000; i += 5 {
+		m.Delete(ctx, catalog[i].GetId())
+		v.Delete(ctx, catalog[i].GetId())
+	}
+	for _, tc := range testQueries {
+		f, err := filter.Parse(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order, _ := ParseOrder(tc.order)
+		for _, limit := range []int{0, 10} {
+			q := Query{Filter: f, Order: order, Limit: limit}
+			got, plan := selectAll(t, v, q)
+			want, _ := selectAll(t, m, q)
+			if !slices.Equal(got, want) {
+				t.Errorf("filter %q order %q limit %d with plan %+v: got %d products, want %d", tc.filter, tc.order, limit, plan, len(got), len(want))
+			}
+		}
+	}
+}
+
+// queryView returns the IDs of the products of v matching q.
+func queryView(t *testing.T, v View, q Query) []string {
+	t.Helper()
+	var ids []string
+	if _, err := v.Query(context.Background(), q, func(p *product.Product) bool {
+		ids = append(ids, p.GetId())
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestVersioned_ViewsNeverChange(t *testing.T) {
+	ctx := context.Background()
+	r := NewVersioned(VersionedOptions{}, testCatalog(500)...)
+	before := r.Current()
+	want, _, _ := before.List(ctx, "", 0)
+	flt, _ := filter.Parse(`tags = "t7"`)
+	wantTagged := queryView(t, before, Query{Filter: flt})
+
+	if err := r.Batch(ctx, func(tx Tx) error {
+		for _, p := range want[:250] {
+			p := clone(p)
+			p.Tags = []string{"t7"}
+			tx.Put(ctx, p)
+		}
+		return tx.Delete(ctx, want[499].GetId())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r.Put(ctx, &product.Product{Id: "new", Name: "New", Tags: []string{"t7"}})
+
+	if got, _, _ := before.List(ctx, "", 0); len(got) != len(want) || !slices.EqualFunc(got, want, func(a, b *product.Product) bool { return slices.Equal(a.GetTags(), b.GetTags()) }) {
+		t.Fatal("an earlier version changed after writes")
+	}
+	if got := queryView(t, before, Query{Filter: flt}); !slices.Equal(got, wantTagged) {
+		t.Fatalf("earlier version's tag index: %v, want %v", got, wantTagged)
+	}
+	after := r.Current()
+	if after.Version() != before.Version()+2 {
+		t.Fatalf("version %d after two writes to version %d", after.Version(), before.Version())
+	}
+	if got := queryView(t, after, Query{Filter: flt}); len(got) != 251+len(wantTagged)-5 {
+		t.Fatalf("current version has %d products tagged t7", len(got))
+	}
+	// A failed write publishes nothing.
+	r.Delete(ctx, "missing")
+	r.Batch(ctx, func(tx Tx) error { return nil })
+	if r.Current().Version() != after.Version() {
+		t.Fatal("a write that changed nothing published a version")
+	}
+}
+
+func TestVersioned_PinsExpire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	r := NewVersioned(VersionedOptions{PinTTL: time.Minute, MaxPins: 2})
+	r.now = func() time.Time { return now }
+	var views []View
+	for i := range 4 {
+		views = append(views, r.Current())
+		r.Pin(r.Current())
+		now = now.Add(time.Second)
+		r.Put(ctx, &product.Product{Id: fmt.Sprint(i)})
+	}
+	// Only the last two pins are kept.
+	for i, v := range views {
+		got, err := r.Pinned(v.Version())
+		if pinned := i >= 2; pinned != (err == nil) {
+			t.Fatalf("Pinned(%d) = %v, %v", v.Version(), got, err)
+		}
+		if err == nil && got != v {
+			t.Fatalf("Pinned(%d) returned another view", v.Version())
+		}
+	}
+	if v, err := r.Pinned(r.Current().Version()); err != nil || v != r.Current() {
+		t.Fatalf("Pinned(current) = %v, %v", v, err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := r.Pinned(views[3].Version()); !errors.Is(err, ErrVersionExpired) {
+		t.Fatalf("Pinned after the TTL: %v, want ErrVersionExpired", err)
+	}
+}
+
+func TestPinning_LooksThroughDecorators(t *testing.T) {
+	r := NewVersioned(VersionedOptions{})
+	if p, ok := Pinning(NewCache(r, CacheOptions{})); !ok || p != r {
+		t.Fatalf("Pinning(cache of Versioned) = %v, %v", p, ok)
+	}
+	if _, ok := Pinning(NewCache(NewMemory(), CacheOptions{})); ok {
+		t.Fatal("Pinning(cache of Memory) succeeded")
+	}
+}
+
+func TestVersioned_ReadersSeeWholeBatches(t *testing.T) {
+	ctx := context.Background()
+	r := NewVersioned(VersionedOptions{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			r.Batch(ctx, func(tx Tx) error {
+				for j := range 10 {
+					tx.Put(ctx, &product.Product{Id: fmt.Sprintf("b%03d-%d", i, j), Name: "N", Tags: []string{"t"}})
+				}
+				return nil
+			})
+		}
+	}()
+	flt, _ := filter.Parse(`tags = "t"`)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		v := r.Current()
+		page, _, _ := v.List(ctx, "", 0)
+		tagged := queryView(t, v, Query{Filter: flt})
+		if len(page)%10 != 0 || len(tagged) != len(page) {
+			t.Fatalf("version %d lists %d products and indexes %d, part of a batch", v.Version(), len(page), len(tagged))
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package schema holds the registry of custom product attribute definitions and
+// validates product attribute values against it.
+package schema
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// Violation describes a single invalid field.
+type Violation struct {
+	// Field is the path of the offending field, e.g. "attributes.voltage".
+	Field       string
+	Description string
+}
+
+// ValidationError is returned when a definition or a set of attribute values is invalid.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.Field + ": " + v.Description
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Violations = append(e.Violations, Violation{Field: field, Description: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) orNil() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+// entry is a registered definition together with its compiled pattern.
+type entry struct {
+	def     *product.AttributeDefinition
+	pattern *regexp.Regexp
+}
+
+// Registry is a concurrency-safe set of attribute definitions keyed by name.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]entry
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]entry)}
+}
+
+// Check validates def without registering it.
+func Check(def *product.AttributeDefinition) error {
+	_, err := compile(def)
+	return err
+}
+
+// Put validates def and stores a copy of it, replacing any definition with the same name.
+// Products already stored are not re-validated against the new definition.
+func (r *Registry) Put(def *product.AttributeDefinition) error {
+	e, err := compile(def)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[def.GetName()] = e
+	return nil
+}
+
+// Get returns a copy of the definition registered under name.
+func (r *Registry) Get(name string) (*product.AttributeDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, false
+	}
+	return proto.Clone(e.def).(*product.AttributeDefinition), true
+}
+
+// List returns copies of all definitions ordered by name.
+func (r *Registry) List() []*product.AttributeDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	defs := make([]*product.AttributeDefinition, 0, len(r.entries))
+	for _, e := range r.entries {
+		defs = append(defs, proto.Clone(e.def).(*product.AttributeDefinition))
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].GetName() < defs[j].GetName() })
+	return defs
+}
+
+// Validate checks attribute values against the registered definitions. Unknown
+// keys, type mismatches, constraint violations and missing required attributes
+// are all reported in a single *ValidationError.
+func (r *Registry) Validate(attrs map[string]*product.AttributeValue) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	verr := &ValidationError{}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field := "attributes." + k
+		e, ok := r.entries[k]
+		if !ok {
+			verr.add(field, "attribute is not defined in the schema")
+			continue
+		}
+		validateValue(verr, field, e, attrs[k])
+	}
+
+	required := make([]string, 0)
+	for name, e := range r.entries {
+		if _, ok := attrs[name]; e.def.GetRequired() && !ok {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	for _, name := range required {
+		verr.add("attributes."+name, "required attribute is missing")
+	}
+	return verr.orNil()
+}
+
+func validateValue(verr *ValidationError, field string, e entry, v *product.AttributeValue) {
+	def := e.def
+	switch def.GetType() {
+	case product.AttributeType_ATTRIBUTE_TYPE_STRING:
+		s, ok := v.GetKind().(*product.AttributeValue_StringValue)
+		if !ok {
+			verr.add(field, "expected a string_value")
+			return
+		}
+		c := def.GetStringConstraints()
+		n := int32(utf8.RuneCountInString(s.StringValue))
+		if c.GetMinLength() > 0 && n < c.GetMinLength() {
+			verr.add(field, "must be at least %d characters", c.GetMinLength())
+		}
+		if c.GetMaxLength() > 0 && n > c.GetMaxLength() {
+			verr.add(field, "must be at most %d characters", c.GetMaxLength())
+		}
+		if e.pattern != nil && !e.pattern.MatchString(s.StringValue) {
+			verr.add(field, "must match pattern %q", c.GetPattern())
+		}
+	case product.AttributeType_ATTRIBUTE_TYPE_NUMBER:
+		n, ok := v.GetKind().(*product.AttributeValue_NumberValue)
+		if !ok {
+			verr.add(field, "expected a number_value")
+			return
+		}
+		c := def.GetNumberConstraints()
+		if math.IsNaN(n.NumberValue) || math.IsInf(n.NumberValue, 0) {
+			verr.add(field, "must be a finite number")
+			return
+		}
+		if c != nil && c.Min != nil && n.NumberValue < c.GetMin() {
+			verr.add(field, "must be >= %g", c.GetMin())
+		}
+		if c != nil && c.Max != nil && n.NumberValue > c.GetMax() {
+			verr.add(field, "must be <= %g", c.GetMax())
+		}
+		if c.GetIntegerOnly() && n.NumberValue != math.Trunc(n.NumberValue) {
+			verr.add(field, "must be an integer")
+		}
+	case product.AttributeType_ATTRIBUTE_TYPE_ENUM:
+		s, ok := v.GetKind().(*product.AttributeValue_EnumValue)
+		if !ok {
+			verr.add(field, "expected an enum_value")
+			return
+		}
+		for _, allowed := range def.GetEnumConstraints().GetAllowedValues() {
+			if allowed == s.EnumValue {
+				return
+			}
+		}
+		verr.add(field, "must be one of %s", strings.Join(def.GetEnumConstraints().GetAllowedValues(), ", "))
+	case product.AttributeType_ATTRIBUTE_TYPE_BOOL:
+		if _, ok := v.GetKind().(*product.AttributeValue_BoolValue); !ok {
+			verr.add(field, "expected a bool_value")
+		}
+	}
+}
+
+// compile validates a definition and prepares it for use by Validate.
+func compile(def *product.AttributeDefinition) (entry, error) {
+	verr := &ValidationError{}
+	if !namePattern.MatchString(def.GetName()) {
+		verr.add("definition.name", "must match %s", namePattern)
+	}
+
+	var e entry
+	switch def.GetType() {
+	case product.AttributeType_ATTRIBUTE_TYPE_STRING:
+		if def.GetConstraints() != nil && def.GetStringConstraints() == nil {
+			verr.add("definition.constraints", "STRING attributes only accept string_constraints")
+		}
+		c := def.GetStringConstraints()
+		if c.GetMinLength() < 0 || c.GetMaxLength() < 0 {
+			verr.add("definition.string_constraints", "lengths must not be negative")
+		}
+		if c.GetMaxLength() > 0 && c.GetMinLength() > c.GetMaxLength() {
+			verr.add("definition.string_constraints", "min_length must not exceed max_length")
+		}
+		if c.GetPattern() != "" {
+			re, err := regexp.Compile(`^(?:` + c.GetPattern() + `)$`)
+			if err != nil {
+				verr.add("definition.string_constraints.pattern", "invalid regular expression: %v", err)
+			}
+			e.pattern = re
+		}
+	case product.AttributeType_ATTRIBUTE_TYPE_NUMBER:
+		if def.GetConstraints() != nil && def.GetNumberConstraints() == nil {
+			verr.add("definition.constraints", "NUMBER attributes only accept number_constraints")
+		}
+		c := def.GetNumberConstraints()
+		if c != nil && c.Min != nil && c.Max != nil && c.GetMin() > c.GetMax() {
+			verr.add("definition.number_constraints", "min must not exceed max")
+		}
+	case product.AttributeType_ATTRIBUTE_TYPE_ENUM:
+		if def.GetEnumConstraints() == nil || len(def.GetEnumConstraints().GetAllowedValues()) == 0 {
+			verr.add("definition.enum_constraints.allowed_values", "ENUM attributes need at least one allowed value")
+		}
+		seen := make(map[string]bool)
+		for _, v := range def.GetEnumConstraints().GetAllowedValues() {
+			if v == "" || seen[v] {
+				verr.add("definition.enum_constraints.allowed_values", "values must be non-empty and unique")
+				break
+			}
+			seen[v] = true
+		}
+	case product.AttributeType_ATTRIBUTE_TYPE_BOOL:
+		if def.GetConstraints() != nil {
+			verr.add("definition.constraints", "BOOL attributes do not accept constraints")
+		}
+	default:
+		verr.add("definition.type", "must be STRING, NUMBER, ENUM or BOOL")
+	}
+	if err := verr.orNil(); err != nil {
+		return entry{}, err
+	}
+	e.def = proto.Clone(def).(*product.AttributeDefinition)
+	return e, nil
+}
@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	defs := []*product.AttributeDefinition{
+		{
+			Name: "voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER, Required: true,
+			Constraints: &product.AttributeDefinition_NumberConstraints{NumberConstraints: &product.NumberConstraints{Min: proto.Float64(0), Max: proto.Float64(400), IntegerOnly: true}},
+		},
+		{
+			Name: "isbn", Type: product.AttributeType_ATTRIBUTE_TYPE_STRING,
+			Constraints: &product.AttributeDefinition_StringConstraints{StringConstraints: &product.StringConstraints{Pattern: `[0-9]{13}`}},
+		},
+		{
+			Name: "fabric", Type: product.AttributeType_ATTRIBUTE_TYPE_ENUM,
+			Constraints: &product.AttributeDefinition_EnumConstraints{EnumConstraints: &product.EnumConstraints{AllowedValues: []string{"cotton", "wool"}}},
+		},
+		{Name: "fragile", Type: product.AttributeType_ATTRIBUTE_TYPE_BOOL},
+	}
+	for _, d := range defs {
+		if err := r.Put(d); err != nil {
+			t.Fatalf("Put(%q) returned error: %v", d.GetName(), err)
+		}
+	}
+	return r
+}
+
+func TestRegistryPut_RejectsInvalidDefinitions(t *testing.T) {
+	r := NewRegistry()
+	cases := map[string]*product.AttributeDefinition{
+		"bad name":          {Name: "Voltage", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER},
+		"unspecified type":  {Name: "voltage"},
+		"empty enum":        {Name: "fabric", Type: product.AttributeType_ATTRIBUTE_TYPE_ENUM},
+		"bad pattern":       {Name: "isbn", Type: product.AttributeType_ATTRIBUTE_TYPE_STRING, Constraints: &product.AttributeDefinition_StringConstraints{StringConstraints: &product.StringConstraints{Pattern: "("}}},
+		"mismatched bounds": {Name: "volts", Type: product.AttributeType_ATTRIBUTE_TYPE_NUMBER, Constraints: &product.AttributeDefinition_NumberConstraints{NumberConstraints: &product.NumberConstraints{Min: proto.Float64(5), Max: proto.Float64(1)}}},
+		"wrong constraints": {Name: "flag", Type: product.AttributeType_ATTRIBUTE_TYPE_BOOL, Constraints: &product.AttributeDefinition_StringConstraints{StringConstraints: &product.StringConstraints{}}},
+	}
+	for name, def := range cases {
+		var verr *ValidationError
+		if err := r.Put(def); !errors.As(err, &verr) {
+			t.Fatalf("%s: expected *ValidationError, got %v", name, err)
+		}
+	}
+	if got := len(r.List()); got != 0 {
+		t.Fatalf("invalid definitions were stored: got %d definitions", got)
+	}
+}
+
+func TestRegistryList_SortedByName(t *testing.T) {
+	r := newTestRegistry(t)
+	defs := r.List()
+	want := []string{"fabric", "fragile", "isbn", "voltage"}
+	if len(defs) != len(want) {
+		t.Fatalf("unexpected number of definitions: got %d, want %d", len(defs), len(want))
+	}
+	for i, d := range defs {
+		if d.GetName() != want[i] {
+			t.Fatalf("definition %d: got %q, want %q", i, d.GetName(), want[i])
+		}
+	}
+}
+
+func TestRegistryValidate_Accepts(t *testing.T) {
+	r := newTestRegistry(t)
+	attrs := map[string]*product.AttributeValue{
+		"voltage": {Kind: &product.AttributeValue_NumberValue{NumberValue: 220}},
+		"isbn":    {Kind: &product.AttributeValue_StringValue{StringValue: "9780134190440"}},
+		"fabric":  {Kind: &product.AttributeValue_EnumValue{EnumValue: "wool"}},
+		"fragile": {Kind: &product.AttributeValue_BoolValue{BoolValue: true}},
+	}
+	if err := r.Validate(attrs); err != nil {
+		t.Fatalf("Validate returned error for valid attributes: %v", err)
+	}
+}
+
+func TestRegistryValidate_ReportsEveryViolation(t *testing.T) {
+	r := newTestRegistry(t)
+	attrs := map[string]*product.AttributeValue{
+		"isbn":    {Kind: &product.AttributeValue_StringValue{StringValue: "123"}},
+		"fabric":  {Kind: &product.AttributeValue_EnumValue{EnumValue: "silk"}},
+		"fragile": {Kind: &product.AttributeValue_StringValue{StringValue: "yes"}},
+		"color":   {Kind: &product.AttributeValue_StringValue{StringValue: "red"}},
+	}
+	err := r.Validate(attrs)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, v := range verr.Violations {
+		fields[v.Field] = true
+	}
+	for _, f := range []string{"attributes.isbn", "attributes.fabric", "attributes.fragile", "attributes.color", "attributes.voltage"} {
+		if !fields[f] {
+			t.Fatalf("expected a violation for %s, got %v", f, verr.Violations)
+		}
+	}
+}
+
+func TestRegistryValidate_NumberConstraints(t *testing.T) {
+	r := newTestRegistry(t)
+	for _, v := range []float64{-1, 401, 1.5} {
+		attrs := map[string]*product.AttributeValue{"voltage": {Kind: &product.AttributeValue_NumberValue{NumberValue: v}}}
+		if err := r.Validate(attrs); err == nil {
+			t.Fatalf("expected error for voltage %v", v)
+		}
+	}
+}
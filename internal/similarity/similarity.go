@@ -0,0 +1,291 @@
+// Package similarity scores how alike two products are from their text,
+// categories and tags, and price, over an index that is updated product by
+// product as the catalog changes.
+package similarity
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+// Weights sets how much each signal contributes to a score. Scores are
+// normalised by the sum of the weights, so only their ratios matter.
+type Weights struct {
+	// Text weighs the cosine similarity of the TF-IDF vectors of name and description.
+	Text float64
+	// Labels weighs the Jaccard similarity of the category and tag sets.
+	Labels float64
+	// Price weighs how close the prices are relative to the larger one. It
+	// only re-ranks products whose text is similar; nearly every pair of
+	// prices is somewhat close, so on its own it would make unrelated
+	// products similar.
+	Price float64
+}
+
+// DefaultWeights are used when no weights are configured.
+var DefaultWeights = Weights{Text: 0.5, Labels: 0.3, Price: 0.2}
+
+// Validate reports whether w can be used: no weight may be negative and at
+// least one must be positive.
+func (w Weights) Validate() error {
+	for _, v := range []float64{w.Text, w.Labels, w.Price} {
+		if !(v >= 0) || math.IsInf(v, 0) {
+			return fmt.Errorf("weights must be finite and non-negative, got %+v", w)
+		}
+	}
+	if w.Text+w.Labels+w.Price == 0 {
+		return fmt.Errorf("at least one weight must be positive")
+	}
+	return nil
+}
+
+// ParseWeights parses a comma-separated list of name=value pairs, e.g.
+// "text=0.6,labels=0.3,price=0.1". Omitted names keep their DefaultWeights value.
+func ParseWeights(s string) (Weights, error) {
+	w := DefaultWeights
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return Weights{}, fmt.Errorf("invalid weight %q: want name=value", pair)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return Weights{}, fmt.Errorf("invalid weight %q: %v", pair, err)
+		}
+		switch strings.TrimSpace(name) {
+		case "text":
+			w.Text = v
+		case "labels":
+			w.Labels = v
+		case "price":
+			w.Price = v
+		default:
+			return Weights{}, fmt.Errorf("unknown weight %q: want text, labels or price", name)
+		}
+	}
+	return w, w.Validate()
+}
+
+// Result is the similarity of one product to the queried one.
+type Result struct {
+	ID string
+	// Score is the weighted average of Text, Labels and Price, in [0, 1].
+	Score  float64
+	Text   float64
+	Labels float64
+	// Price is 0 if Text is.
+	Price float64
+}
+
+// Index keeps the term counts of every product, and the number of products
+// each term appears in, so that TF-IDF vectors reflect the current catalog
+// without being rebuilt. It is not safe for concurrent use.
+type Index struct {
+	weights Weights
+	docs    map[string]*document
+	// df counts the documents containing each term.
+	df map[string]int
+}
+
+type document struct {
+	terms  map[string]int
+	labels map[string]bool
+	price  float64
+}
+
+// NewIndex creates an empty index. Weights that fail Validate are replaced by DefaultWeights.
+func NewIndex(w Weights) *Index {
+	if w.Validate() != nil {
+		w = DefaultWeights
+	}
+	return &Index{weights: w, docs: make(map[string]*document), df: make(map[string]int)}
+}
+
+// Weights returns the weights the index scores with.
+func (x *Index) Weights() Weights { return x.weights }
+
+// Add indexes p, replacing any product with the same ID.
+func (x *Index) Add(p *product.Product) {
+	x.Remove(p.GetId())
+	d := &document{terms: termCounts(p), labels: labels(p), price: p.GetPrice()}
+	for t := range d.terms {
+		x.df[t]++
+	}
+	x.docs[p.GetId()] = d
+}
+
+// Remove drops the product with the given ID from the index.
+func (x *Index) Remove(id string) {
+	d, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for t := range d.terms {
+		if x.df[t]--; x.df[t] == 0 {
+			delete(x.df, t)
+		}
+	}
+	delete(x.docs, id)
+}
+
+// Similar returns up to n products most similar to the product with the given
+// ID, best first, with ties broken by ID. Candidates for which keep returns
+// false are skipped, as are products with a zero score. It returns false if
+// the product is not indexed.
+func (x *Index) Similar(id string, n int, keep func(id string) bool) ([]Result, bool) {
+	src, ok := x.docs[id]
+	if !ok {
+		return nil, false
+	}
+	srcVec := x.vector(src)
+	srcNorm := norm(srcVec)
+
+	var results []Result
+	for cid, d := range x.docs {
+		if cid == id || (keep != nil && !keep(cid)) {
+			continue
+		}
+		r := Result{
+			ID:     cid,
+			Text:   x.cosine(srcVec, srcNorm, d),
+			Labels: jaccard(src.labels, d.labels),
+		}
+		if r.Text > 0 {
+			r.Price = priceProximity(src.price, d.price)
+		}
+		w := x.weights
+		r.Score = (w.Text*r.Text + w.Labels*r.Labels + w.Price*r.Price) / (w.Text + w.Labels + w.Price)
+		if r.Score > 0 {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > n {
+		results = results[:n]
+	}
+	return results, true
+}
+
+// idf is the smoothed inverse document frequency of a term.
+func (x *Index) idf(term string) float64 {
+	return math.Log(float64(1+len(x.docs))/float64(1+x.df[term])) + 1
+}
+
+// vector returns the TF-IDF weights of d's terms under the current catalog.
+func (x *Index) vector(d *document) map[string]float64 {
+	v := make(map[string]float64, len(d.terms))
+	for t, c := range d.terms {
+		v[t] = (1 + math.Log(float64(c))) * x.idf(t)
+	}
+	return v
+}
+
+func (x *Index) cosine(a map[string]float64, aNorm float64, d *document) float64 {
+	if aNorm == 0 || len(d.terms) == 0 {
+		return 0
+	}
+	var dot, bSq float64
+	for t, c := range d.terms {
+		w := (1 + math.Log(float64(c))) * x.idf(t)
+		bSq += w * w
+		dot += a[t] * w
+	}
+	if dot == 0 {
+		return 0
+	}
+	return min(dot/(aNorm*math.Sqrt(bSq)), 1)
+}
+
+func norm(v map[string]float64) float64 {
+	var sq float64
+	for _, w := range v {
+		sq += w * w
+	}
+	return math.Sqrt(sq)
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for l := range a {
+		if b[l] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// priceProximity is 1 for equal prices and falls linearly to 0 as the
+// difference approaches the larger price.
+func priceProximity(a, b float64) float64 {
+	hi := max(a, b)
+	if hi <= 0 {
+		return 1
+	}
+	return 1 - math.Abs(a-b)/hi
+}
+
+// nameWeight is how many times a name term counts relative to a description term.
+const nameWeight = 2
+
+// termCounts tokenizes the name and description of p.
+func termCounts(p *product.Product) map[string]int {
+	counts := make(map[string]int)
+	for _, t := range tokenize(p.GetName()) {
+		counts[t] += nameWeight
+	}
+	for _, t := range tokenize(p.GetDescription()) {
+		counts[t]++
+	}
+	return counts
+}
+
+// tokenize lowercases s and splits it into runs of letters and digits,
+// dropping single characters and stop words.
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) > 1 && !stopWords[f] {
+			tokens = append(tokens, f)
+		}
+	}
+	return tokens
+}
+
+var stopWords = map[string]bool{
+	"an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "the": true, "this": true, "to": true, "with": true,
+}
+
+// labels returns the categories and tags of p, prefixed so that a category
+// and a tag with the same name are distinct.
+func labels(p *product.Product) map[string]bool {
+	set := make(map[string]bool, len(p.GetCategories())+len(p.GetTags()))
+	for _, c := range p.GetCategories() {
+		set["category:"+c] = true
+	}
+	for _, t := range p.GetTags() {
+		set["tag:"+t] = true
+	}
+	return set
+}
@@ -0,0 +1,143 @@
+package similarity
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+)
+
+func catalog() *Index {
+	x := NewIndex(DefaultWeights)
+	for _, p := range []*product.Product{
+		{Id: "drill-1", Name: "Cordless drill", Description: "18V cordless drill with two batteries", Price: 89, Categories: []string{"power-tools"}, Tags: []string{"acme"}},
+		{Id: "drill-2", Name: "Hammer drill", Description: "Corded hammer drill for masonry", Price: 99, Categories: []string{"power-tools"}},
+		{Id: "saw-1", Name: "Circular saw", Description: "Cordless circular saw", Price: 120, Categories: []string{"power-tools"}, Tags: []string{"acme"}},
+		{Id: "mug-1", Name: "Coffee mug", Description: "Ceramic mug", Price: 9, Categories: []string{"kitchen"}},
+	} {
+		x.Add(p)
+	}
+	return x
+}
+
+func ids(results []Result) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func TestSimilar_RanksByCombinedScore(t *testing.T) {
+	x := catalog()
+	results, ok := x.Similar("drill-1", 10, nil)
+	if !ok {
+		t.Fatal("drill-1 not indexed")
+	}
+	// The mug shares nothing with a drill but a price range.
+	got := ids(results)
+	if len(got) != 2 || (got[0] != "drill-2" && got[0] != "saw-1") {
+		t.Fatalf("unexpected ranking %v", got)
+	}
+	for _, r := range results {
+		if r.Score < 0 || r.Score > 1 || r.ID == "drill-1" {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	}
+
+	if results, _ := x.Similar("drill-1", 1, nil); len(results) != 1 {
+		t.Fatalf("expected one result, got %v", ids(results))
+	}
+	results, _ = x.Similar("drill-1", 10, func(id string) bool { return id != "drill-2" })
+	for _, r := range results {
+		if r.ID == "drill-2" {
+			t.Fatal("filtered candidate returned")
+		}
+	}
+	if _, ok := x.Similar("missing", 10, nil); ok {
+		t.Fatal("expected false for an unknown product")
+	}
+}
+
+func TestSimilar_WeightsChangeTheRanking(t *testing.T) {
+	byText := NewIndex(Weights{Text: 1})
+	byPrice := NewIndex(Weights{Price: 1})
+	for _, x := range []*Index{byText, byPrice} {
+		x.Add(&product.Product{Id: "a", Name: "red lamp", Price: 10})
+		x.Add(&product.Product{Id: "b", Name: "red lamp", Price: 100})
+		x.Add(&product.Product{Id: "c", Name: "red desk lamp", Price: 11})
+		x.Add(&product.Product{Id: "d", Name: "blue chair", Price: 10})
+	}
+	if got, _ := byText.Similar("a", 1, nil); got[0].ID != "b" {
+		t.Fatalf("text weights: got %v, want b", ids(got))
+	}
+	// Price re-ranks the lamps, but does not make the chair similar.
+	if got, _ := byPrice.Similar("a", 10, nil); !slices.Equal(ids(got), []string{"c", "b"}) {
+		t.Fatalf("price weights: got %v, want c and b", ids(got))
+	}
+}
+
+func TestIndex_UpdatesIncrementally(t *testing.T) {
+	x := catalog()
+	// Renaming the mug into a drill makes it textually similar.
+	x.Add(&product.Product{Id: "mug-1", Name: "Mini drill", Description: "Cordless drill", Price: 9})
+	results, _ := x.Similar("drill-1", 10, nil)
+	var text float64
+	for _, r := range results {
+		if r.ID == "mug-1" {
+			text = r.Text
+		}
+	}
+	if text == 0 {
+		t.Fatal("expected the updated product to share terms with drill-1")
+	}
+
+	// Removing every product leaves no document frequencies behind.
+	for _, id := range []string{"drill-1", "drill-2", "saw-1", "mug-1"} {
+		x.Remove(id)
+	}
+	if len(x.docs) != 0 || len(x.df) != 0 {
+		t.Fatalf("index not empty after removals: docs=%d df=%v", len(x.docs), x.df)
+	}
+}
+
+func TestIndex_MatchesRebuild(t *testing.T) {
+	x := catalog()
+	x.Remove("saw-1")
+	x.Add(&product.Product{Id: "drill-2", Name: "Hammer drill", Description: "SDS hammer drill", Price: 95, Categories: []string{"power-tools"}})
+
+	rebuilt := NewIndex(DefaultWeights)
+	for _, p := range []*product.Product{
+		{Id: "drill-1", Name: "Cordless drill", Description: "18V cordless drill with two batteries", Price: 89, Categories: []string{"power-tools"}, Tags: []string{"acme"}},
+		{Id: "drill-2", Name: "Hammer drill", Description: "SDS hammer drill", Price: 95, Categories: []string{"power-tools"}},
+		{Id: "mug-1", Name: "Coffee mug", Description: "Ceramic mug", Price: 9, Categories: []string{"kitchen"}},
+	} {
+		rebuilt.Add(p)
+	}
+	got, _ := x.Similar("drill-1", 10, nil)
+	want, _ := rebuilt.Similar("drill-1", 10, nil)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i].ID != want[i].ID || math.Abs(got[i].Score-want[i].Score) > 1e-12 {
+			t.Fatalf("incremental %+v differs from rebuilt %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights("text=0.7, price=0.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != (Weights{Text: 0.7, Labels: DefaultWeights.Labels, Price: 0.3}) {
+		t.Fatalf("unexpected weights %+v", w)
+	}
+	for _, s := range []string{"text", "text=x", "colour=1", "text=-1", "text=0,labels=0,price=0"} {
+		if _, err := ParseWeights(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
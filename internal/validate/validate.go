@@ -0,0 +1,181 @@
+// Package validate enforces the field rules declared with the (product.v1.rules)
+// option (api/product/validate.proto) on request messages.
+package validate
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// UnaryServerInterceptor validates every request message against its field
+// rules before calling the handler.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if m, ok := req.(proto.Message); ok {
+			if err := Message(m); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Message checks m and the messages nested in it against their field rules. It
+// returns nil or an InvalidArgument status carrying a google.rpc.BadRequest
+// with one field violation per broken rule, in field order.
+func Message(m proto.Message) error {
+	var v validator
+	v.message(m.ProtoReflect(), "")
+	if v.err != nil {
+		return status.Error(codes.Internal, v.err.Error())
+	}
+	if len(v.violations) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(v.violations))
+	for i, fv := range v.violations {
+		msgs[i] = fv.GetField() + " " + fv.GetDescription()
+	}
+	st := status.New(codes.InvalidArgument, "invalid "+string(m.ProtoReflect().Descriptor().Name())+": "+strings.Join(msgs, "; "))
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: v.violations})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+type validator struct {
+	violations []*errdetails.BadRequest_FieldViolation
+	err        error
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.violations = append(v.violations, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) message(m protoreflect.Message, prefix string) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		r, err := rulesFor(fd)
+		if err != nil {
+			v.err = err
+			return
+		}
+		path := string(fd.Name())
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if !m.Has(fd) {
+			if r.GetRequired() {
+				v.add(path, "must be set")
+			}
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			// Map values (product attributes) are validated against the attribute schema.
+		case fd.IsList():
+			list := m.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				v.value(fd, r, list.Get(j), fmt.Sprintf("%s[%d]", path, j))
+			}
+		default:
+			v.value(fd, r, m.Get(fd), path)
+		}
+	}
+}
+
+// value checks a singular value or a single element of a repeated field.
+func (v *validator) value(fd protoreflect.FieldDescriptor, r *rules, val protoreflect.Value, path string) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if !r.GetSkip() {
+			v.message(val.Message(), path)
+		}
+	case protoreflect.StringKind:
+		v.string(r, val.String(), path)
+	case protoreflect.EnumKind:
+		if r.GetDefinedOnly() && fd.Enum().Values().ByNumber(val.Enum()) == nil {
+			v.add(path, "must be a defined %s value", fd.Enum().Name())
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v.number(r, float64(val.Int()), path)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v.number(r, float64(val.Uint()), path)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		v.number(r, val.Float(), path)
+	}
+}
+
+func (v *validator) string(r *rules, s, path string) {
+	n := uint32(utf8.RuneCountInString(s))
+	if r.MinLen != nil && n < r.GetMinLen() {
+		v.add(path, "must be at least %d characters", r.GetMinLen())
+	}
+	if r.MaxLen != nil && n > r.GetMaxLen() {
+		v.add(path, "must be at most %d characters", r.GetMaxLen())
+	}
+	if r.pattern != nil && !r.pattern.MatchString(s) {
+		v.add(path, "must match %q", r.GetPattern())
+	}
+	if len(r.GetIn()) > 0 && !slices.Contains(r.GetIn(), s) {
+		v.add(path, "must be one of %s", strings.Join(r.GetIn(), ", "))
+	}
+}
+
+// number checks the bounds with negated comparisons so that NaN fails them.
+func (v *validator) number(r *rules, x float64, path string) {
+	if r.Gte != nil && !(x >= r.GetGte()) {
+		v.add(path, "must be greater than or equal to %v", r.GetGte())
+	}
+	if r.Lte != nil && !(x <= r.GetLte()) {
+		v.add(path, "must be less than or equal to %v", r.GetLte())
+	}
+}
+
+// rules is a field's FieldRules, empty if it has none, with its pattern compiled.
+type rules struct {
+	*product.FieldRules
+	pattern *regexp.Regexp
+}
+
+var cache sync.Map // protoreflect.FieldDescriptor -> *rules
+
+func rulesFor(fd protoreflect.FieldDescriptor) (*rules, error) {
+	if r, ok := cache.Load(fd); ok {
+		return r.(*rules), nil
+	}
+	r := &rules{FieldRules: &product.FieldRules{}}
+	if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && proto.HasExtension(opts, product.E_Rules) {
+		r.FieldRules = proto.GetExtension(opts, product.E_Rules).(*product.FieldRules)
+		if p := r.GetPattern(); p != "" {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern on %s: %w", fd.FullName(), err)
+			}
+			r.pattern = re
+		}
+	}
+	cache.Store(fd, r)
+	return r, nil
+}
@@ -0,0 +1,130 @@
+package validate
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// violations returns the BadRequest field violations of err as "field: description".
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	var out []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				out = append(out, v.GetField()+": "+v.GetDescription())
+			}
+		}
+	}
+	return out
+}
+
+func TestMessage_Valid(t *testing.T) {
+	reqs := []proto.Message{
+		&product.CreateProductRequest{Product: &product.Product{Name: "Widget", Price: 0, Tags: []string{"acme"}}},
+		&product.CreateProductRequest{Product: &product.Product{Id: "sku_1.a-b", Name: "Widget", Description: "ok", Price: 9.5}},
+		&product.GetProductRequest{Id: "prod-1"},
+		&product.ListProductsRequest{},
+		&product.GetCatalogStatsRequest{GroupBy: product.GetCatalogStatsRequest_GROUP_BY_TAG},
+		&longrunningpb.GetOperationRequest{},
+	}
+	for _, req := range reqs {
+		if err := Message(req); err != nil {
+			t.Errorf("Message(%v) = %v, want nil", req, err)
+		}
+	}
+}
+
+func TestMessage_ReportsEveryViolationInFieldOrder(t *testing.T) {
+	err := Message(&product.CreateProductRequest{Product: &product.Product{
+		Id:          "bad id",
+		Description: strings.Repeat("é", 2001),
+		Price:       math.NaN(),
+		Tags:        []string{"ok", strings.Repeat("x", 65)},
+	}})
+	got := violations(t, err)
+	want := []string{
+		`product.id: must match "^[A-Za-z0-9][A-Za-z0-9._-]*$"`,
+		"product.name: must be set",
+		"product.description: must be at most 2000 characters",
+		"product.price: must be greater than or equal to 0",
+		"product.tags[1]: must be at most 64 characters",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("violations:\n got %q\nwant %q", got, want)
+	}
+	if msg := status.Convert(err).Message(); !strings.HasPrefix(msg, "invalid CreateProductRequest: product.id must match") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestMessage_RequiredAndEnumRules(t *testing.T) {
+	tests := []struct {
+		req  proto.Message
+		want []string
+	}{
+		{&product.CreateProductRequest{}, []string{"product: must be set"}},
+		{&product.DeleteProductRequest{}, []string{"id: must be set"}},
+		{&product.ListProductsRequest{Limit: -1, Facets: &product.FacetOptions{MaxTags: -5}}, []string{
+			"limit: must be greater than or equal to 0",
+			"facets.max_tags: must be greater than or equal to 0",
+		}},
+		{&product.GetCatalogStatsRequest{GroupBy: 42}, []string{"group_by: must be a defined GroupBy value"}},
+		{&product.PutAttributeDefinitionRequest{Definition: &product.AttributeDefinition{Name: "Bad-Name"}}, []string{
+			`definition.name: must match "^[a-z][a-z0-9_]{0,62}$"`,
+			"definition.type: must be set",
+		}},
+		{&product.BulkUpdatePricesRequest{Change: &product.BulkUpdatePricesRequest_Multiplier{Multiplier: -2}}, []string{
+			"multiplier: must be greater than or equal to 0",
+		}},
+		{&product.PurgeProductsRequest{Filter: strings.Repeat("(", 2001)}, []string{"filter: must be at most 2000 characters"}},
+	}
+	for _, tt := range tests {
+		if got := violations(t, Message(tt.req)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Message(%v):\n got %q\nwant %q", tt.req, got, tt.want)
+		}
+	}
+}
+
+func TestMessage_SkipLeavesItemsToTheHandler(t *testing.T) {
+	req := &product.BulkImportProductsRequest{Products: []*product.Product{{Id: "bad id", Price: -1}}}
+	if err := Message(req); err != nil {
+		t.Fatalf("expected skipped items not to be validated, got %v", err)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	intercept := UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/product.v1.ProductService/GetProduct"}
+	called := false
+	handler := func(ctx context.Context, req any) (any, error) {
+		called = true
+		return &product.Product{}, nil
+	}
+
+	if _, err := intercept(context.Background(), &product.GetProductRequest{}, info, handler); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if called {
+		t.Fatal("handler must not run for an invalid request")
+	}
+	if _, err := intercept(context.Background(), &product.GetProductRequest{Id: "prod-1"}, info, handler); err != nil || !called {
+		t.Fatalf("expected handler to run, err=%v called=%v", err, called)
+	}
+}
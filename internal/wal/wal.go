@@ -0,0 +1,59 @@
+// Package wal frames the protobuf records of the append-only logs and
+// snapshot files kept by the file-backed stores. A frame is the length and
+// CRC-32C of its payload, little-endian, followed by the payload, so a reader
+// can tell a complete record from one a crash cut short. WriteFile and
+// SyncDir write the snapshots and backups kept beside such logs durably.
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// HeaderSize is the length of a frame's header.
+const HeaderSize = 8
+
+// ErrTorn is returned by Decode when data ends inside the frame, as the last
+// frame of a log does after a crash during an append.
+var ErrTorn = errors.New("torn frame")
+
+// ErrChecksum is returned by Decode when a frame's payload does not match its
+// checksum.
+var ErrChecksum = errors.New("checksum mismatch")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Encode returns m, marshaled deterministically, as one frame.
+func Encode(m proto.Message) ([]byte, error) {
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, HeaderSize, HeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, castagnoli))
+	return append(frame, payload...), nil
+}
+
+// Decode unmarshals the frame at the start of data into m and returns its
+// length.
+func Decode(data []byte, m proto.Message) (int, error) {
+	if len(data) < HeaderSize {
+		return 0, ErrTorn
+	}
+	n := int(binary.LittleEndian.Uint32(data[0:4]))
+	if n > len(data)-HeaderSize {
+		return 0, ErrTorn
+	}
+	payload := data[HeaderSize : HeaderSize+n]
+	if crc32.Checksum(payload, castagnoli) != binary.LittleEndian.Uint32(data[4:8]) {
+		return 0, ErrChecksum
+	}
+	if err := proto.Unmarshal(payload, m); err != nil {
+		return 0, err
+	}
+	return HeaderSize + n, nil
+}
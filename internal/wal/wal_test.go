@@ -0,0 +1,35 @@
+package wal
+
+import (
+	"errors"
+	"testing"
+
+	"grpc-go-fx/internal/generated/product"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecode_ReadsEncodedFramesAndRejectsDamagedOnes(t *testing.T) {
+	a, err := Encode(&product.Product{Id: "a", Name: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Encode(&product.Product{Id: "b"})
+	data := append(append([]byte(nil), a...), b...)
+
+	got := &product.Product{}
+	n, err := Decode(data, got)
+	if err != nil || n != len(a) || !proto.Equal(got, &product.Product{Id: "a", Name: "A"}) {
+		t.Fatalf("Decode = %d, %v, %v", n, got, err)
+	}
+	if _, err := Decode(data[n:len(data)-1], &product.Product{}); !errors.Is(err, ErrTorn) {
+		t.Fatalf("Decode of a cut frame: %v, want ErrTorn", err)
+	}
+	if _, err := Decode(data[:HeaderSize-1], &product.Product{}); !errors.Is(err, ErrTorn) {
+		t.Fatalf("Decode of a cut header: %v, want ErrTorn", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if _, err := Decode(data[n:], &product.Product{}); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("Decode of a corrupt frame: %v, want ErrChecksum", err)
+	}
+}
@@ -1,5 +1,5 @@
 #!/usr/bin/env bash
-# Generate Go code from product.proto. Requires protoc, protoc-gen-go, protoc-gen-go-grpc.
+# Generate Go code from the product.v1 (with AdminService), product.v2, storage and replication protos. Requires protoc, protoc-gen-go, protoc-gen-go-grpc.
 # Install: go install google.golang.org/protobuf/cmd/protoc-gen-go@latest
 #          go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@latest
 #          protoc: https://protobuf.dev/downloads/ or brew install protobuf
@@ -9,6 +9,16 @@ mkdir -p internal/generated/product
 protoc --go_out=internal/generated/product --go_opt=paths=source_relative \
   --go-grpc_out=internal/generated/product --go-grpc_opt=paths=source_relative \
   --grpc-gateway_out=internal/generated/product --grpc-gateway_opt=paths=source_relative,generate_unbound_methods=true \
-  -I api/product \
-  api/product/product.proto
-echo "Generated internal/generated/product/*.pb.go and product.pb.gw.go"
+  -I api/product -I api/third_party/googleapis \
+  api/product/product.proto api/product/admin.proto api/product/validate.proto api/product/v2/product.proto \
+  api/product/storage/storage.proto api/product/replication/replication.proto
+# HTTP/JSON bindings for google.longrunning.Operations; the message and gRPC types
+# come from cloud.google.com/go/longrunning, so only a standalone gateway is generated.
+mkdir -p internal/generated/longrunningpb
+protoc --grpc-gateway_out=internal/generated/longrunningpb \
+  --grpc-gateway_opt=paths=source_relative,standalone=true,generate_unbound_methods=true \
+  -I api/third_party/googleapis \
+  google/longrunning/operations.proto
+mv internal/generated/longrunningpb/google/longrunning/operations.pb.gw.go internal/generated/longrunningpb/
+rm -r internal/generated/longrunningpb/google
+echo "Generated internal/generated/product/{,v2/,storage/,replication/}*.pb.go, product.pb.gw.go and internal/generated/longrunningpb/operations.pb.gw.go"